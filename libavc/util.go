@@ -3,6 +3,7 @@ package libavc
 import (
 	"avformat/utils"
 	"encoding/binary"
+	"fmt"
 )
 
 type MPEG4AVCConfig struct {
@@ -128,6 +129,10 @@ bit(8*sequenceParameterSetExtLength) sequenceParameterSetExtNALUnit;
 }*/
 
 func ParseDecoderConfigurationRecord(data []byte) (*MPEG4AVCConfig, error) {
+	if len(data) < 7 {
+		return nil, fmt.Errorf("invalid avcc length %d", len(data))
+	}
+
 	config := &MPEG4AVCConfig{}
 	config.Version = data[0]
 	config.Profile = data[1]
@@ -135,27 +140,42 @@ func ParseDecoderConfigurationRecord(data []byte) (*MPEG4AVCConfig, error) {
 	config.Level = data[3]
 	config.LengthSize = data[4] & 0x3
 
-	spsNum := data[5] & 0x1F
-	config.Sps = make([][]byte, spsNum)
-	index := 6
-	for i := 0; i < int(spsNum); i++ {
-		length := int(data[index])<<8 | int(data[index+1])
-		index += 2 + length
-		bytes := make([]byte, length)
-		copy(bytes, data[index-length:index])
-		config.Sps[i] = bytes
+	//读取count个16位长度前缀的NALU
+	index := 5
+	readNalUnits := func(count int) ([][]byte, error) {
+		units := make([][]byte, count)
+		for i := 0; i < count; i++ {
+			if index+2 > len(data) {
+				return nil, fmt.Errorf("invalid avcc parameter set")
+			}
+			length := int(data[index])<<8 | int(data[index+1])
+			index += 2 + length
+			if index > len(data) {
+				return nil, fmt.Errorf("invalid avcc parameter set length %d", length)
+			}
+			bytes := make([]byte, length)
+			copy(bytes, data[index-length:index])
+			units[i] = bytes
+		}
+		return units, nil
+	}
+
+	var err error
+	spsNum := data[index] & 0x1F
+	index++
+	if config.Sps, err = readNalUnits(int(spsNum)); err != nil {
+		return nil, err
+	} else if index >= len(data) {
+		return nil, fmt.Errorf("missing avcc pps")
 	}
 
 	ppsNum := data[index]
-	config.Pps = make([][]byte, ppsNum)
-	index += 1
-	for i := 0; i < int(ppsNum); i++ {
-		length := int(data[index])<<8 | int(data[index+1])
-		index += 2 + length
-		bytes := make([]byte, length)
-		copy(bytes, data[index-length:index])
-		config.Pps[i] = bytes
+	index++
+	if config.Pps, err = readNalUnits(int(ppsNum)); err != nil {
+		return nil, err
 	}
 
+	config.SpsNum = spsNum
+	config.PpsNum = ppsNum
 	return config, nil
 }
@@ -74,3 +74,64 @@ func Mp4ToAnnexB(dst utils.ByteBuffer, data, extra []byte, lengthSize int) error
 
 	return nil
 }
+
+// IsKeyFrame AnnexB格式的access unit是否包含IRAP
+func IsKeyFrame(p []byte) bool {
+	index := 0
+	for {
+		index = libavc.FindStartCode(p, index)
+		if index < 0 {
+			return false
+		}
+
+		nalType := HEVCNALUnitType(p[index] >> 1 & 0x3F)
+		if nalType >= HevcNalBlaWLP && nalType <= HevcNalRsvIRAPVCL23 {
+			return true
+		} else if nalType < HevcNalBlaWLP {
+			return false
+		}
+	}
+}
+
+// ParseParameterSets 读取HEVCDecoderConfigurationRecord中的VPS/SPS/PPS, 不带起始码
+func ParseParameterSets(src []byte) (vps, sps, pps [][]byte, err error) {
+	if len(src) < 23 {
+		return nil, nil, nil, fmt.Errorf("invalid hvcc length %d", len(src))
+	}
+
+	index := 23
+	for arrays := int(src[22]); arrays > 0; arrays-- {
+		if index+3 > len(src) {
+			return nil, nil, nil, fmt.Errorf("invalid hvcc array")
+		}
+		t := HEVCNALUnitType(src[index] & 0x3F)
+		count := int(src[index+1])<<8 | int(src[index+2])
+		index += 3
+
+		for j := 0; j < count; j++ {
+			if index+2 > len(src) {
+				return nil, nil, nil, fmt.Errorf("invalid hvcc nal unit")
+			}
+			length := int(src[index])<<8 | int(src[index+1])
+			index += 2 + length
+			if index > len(src) {
+				return nil, nil, nil, fmt.Errorf("invalid hvcc nal unit length %d", length)
+			}
+
+			nalu := make([]byte, length)
+			copy(nalu, src[index-length:index])
+			switch t {
+			case HevcNalVPS:
+				vps = append(vps, nalu)
+				break
+			case HevcNalSPS:
+				sps = append(sps, nalu)
+				break
+			case HevcNalPPS:
+				pps = append(pps, nalu)
+				break
+			}
+		}
+	}
+	return
+}
@@ -1,6 +1,9 @@
 package libmpeg
 
 const (
+	TSPacketSize = 188
+	TSSyncByte   = 0x47
+
 	PSIPAT = 0x0000
 	PSICAT = 0x0001
 	//PSINIT = 0x0000
@@ -2,6 +2,7 @@ package libmpeg
 
 import (
 	"avformat/utils"
+	"bytes"
 	"fmt"
 )
 
@@ -15,6 +16,7 @@ type TSDeMuxer struct {
 	lastPesPacket    *PESHeader
 	currentPesPacket *PESHeader
 	handler          deHandler
+	discard          bool //丢包后等待下一个PES的开始
 }
 
 func NewTSDeMuxer(handler deHandler) *TSDeMuxer {
@@ -58,6 +60,13 @@ func (t *TSDeMuxer) doRead(data []byte) error {
 			}
 		}
 	} else if t.existPES(h.pid) {
+		if t.discard {
+			if h.payloadUnitStartIndicator == 0 {
+				return nil
+			}
+			t.discard = false
+		}
+
 		if h.payloadUnitStartIndicator == 0x01 {
 			n := readPESHeader(t.currentPesPacket, data[i:])
 			if n == 0 {
@@ -97,3 +106,32 @@ func (t *TSDeMuxer) doRead(data []byte) error {
 	}
 	return nil
 }
+
+// Input 输入若干个完整的TS包, 例如RTP MP2T负载. 同步字节错误时搜索下一个0x47重新同步,
+// 下一个TS包的同步字节也必须正确, 避免把数据中的0x47当作包的开始
+func (t *TSDeMuxer) Input(data []byte) error {
+	for len(data) >= TSPacketSize {
+		if data[0] != TSSyncByte || (len(data) > TSPacketSize && data[TSPacketSize] != TSSyncByte) {
+			index := bytes.IndexByte(data[1:], TSSyncByte)
+			if index < 0 {
+				break
+			}
+			data = data[index+1:]
+			continue
+		}
+
+		if err := t.doRead(data[:TSPacketSize]); err != nil {
+			return err
+		}
+		data = data[TSPacketSize:]
+	}
+	return nil
+}
+
+// Discontinuity 通知TS包丢失, 丢弃不完整的PES
+func (t *TSDeMuxer) Discontinuity() {
+	t.esLength = 0
+	t.lastPesPacket = nil
+	t.currentPesPacket.Reset()
+	t.discard = true
+}
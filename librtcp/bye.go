@@ -0,0 +1,109 @@
+package librtcp
+
+import (
+	"avformat/utils"
+	"fmt"
+)
+
+// Goodbye RFC 3550 6.6
+type Goodbye struct {
+	Sources []uint32
+	Reason  string
+}
+
+func (g *Goodbye) Marshal() ([]byte, error) {
+	if len(g.Sources) > 31 {
+		return nil, fmt.Errorf("too many bye sources %d", len(g.Sources))
+	} else if len(g.Reason) > 0xFF {
+		return nil, fmt.Errorf("the bye reason is too long %d", len(g.Reason))
+	}
+
+	size := len(g.Sources) * 4
+	if g.Reason != "" {
+		//reason用0填充到4字节对齐, 不使用P位
+		size += 1 + len(g.Reason)
+		size += (4 - size%4) % 4
+	}
+
+	data := newPacket(TypeBYE, len(g.Sources), size)
+	n := HeaderLength
+	for _, ssrc := range g.Sources {
+		utils.WriteDWORD(data[n:], ssrc)
+		n += 4
+	}
+	if g.Reason != "" {
+		data[n] = byte(len(g.Reason))
+		copy(data[n+1:], g.Reason)
+	}
+	return data, nil
+}
+
+func (g *Goodbye) Unmarshal(data []byte) error {
+	header, body, err := parsePacket(data, TypeBYE)
+	if err != nil {
+		return err
+	} else if len(body) < header.Count*4 {
+		return fmt.Errorf("invalid bye source count %d", header.Count)
+	}
+
+	g.Sources = make([]uint32, header.Count)
+	for i := range g.Sources {
+		g.Sources[i] = readUInt32(body[i*4:])
+	}
+
+	g.Reason = ""
+	if reason := body[header.Count*4:]; len(reason) > 0 {
+		if 1+int(reason[0]) > len(reason) {
+			return fmt.Errorf("invalid bye reason length %d", reason[0])
+		}
+		g.Reason = string(reason[1 : 1+int(reason[0])])
+	}
+	return nil
+}
+
+func (g *Goodbye) DestinationSSRC() []uint32 {
+	return g.Sources
+}
+
+// ApplicationDefined RFC 3550 6.7
+type ApplicationDefined struct {
+	SubType int
+	SSRC    uint32
+	Name    string //4个ASCII字符
+	Data    []byte //长度为4的倍数
+}
+
+func (a *ApplicationDefined) Marshal() ([]byte, error) {
+	if len(a.Name) != 4 {
+		return nil, fmt.Errorf("the app name must be 4 characters:%s", a.Name)
+	} else if a.SubType < 0 || a.SubType > 0x1F {
+		return nil, fmt.Errorf("invalid app subtype %d", a.SubType)
+	} else if len(a.Data)%4 != 0 {
+		return nil, fmt.Errorf("the app data must be a multiple of 4 bytes")
+	}
+
+	data := newPacket(TypeAPP, a.SubType, 8+len(a.Data))
+	utils.WriteDWORD(data[4:], a.SSRC)
+	copy(data[8:], a.Name)
+	copy(data[12:], a.Data)
+	return data, nil
+}
+
+func (a *ApplicationDefined) Unmarshal(data []byte) error {
+	header, body, err := parsePacket(data, TypeAPP)
+	if err != nil {
+		return err
+	} else if len(body) < 8 {
+		return fmt.Errorf("the app packet is too short %d", len(body))
+	}
+
+	a.SubType = header.Count
+	a.SSRC = readUInt32(body)
+	a.Name = string(body[4:8])
+	a.Data = append([]byte(nil), body[8:]...)
+	return nil
+}
+
+func (a *ApplicationDefined) DestinationSSRC() []uint32 {
+	return []uint32{a.SSRC}
+}
@@ -0,0 +1,241 @@
+package librtcp
+
+import (
+	"avformat/utils"
+	"fmt"
+	"math/bits"
+)
+
+const (
+	// feedbackHeaderLength sender SSRC + media SSRC
+	feedbackHeaderLength = 8
+	rembIdentifier       = "REMB"
+)
+
+// parseFeedback 解析反馈包的公共部分
+// @return FCI
+func parseFeedback(data []byte, packetType, format int) (senderSSRC, mediaSSRC uint32, fci []byte, err error) {
+	header, body, err := parsePacket(data, packetType)
+	if err != nil {
+		return 0, 0, nil, err
+	} else if header.Count != format {
+		return 0, 0, nil, fmt.Errorf("unexpected feedback format %d, expected %d", header.Count, format)
+	} else if len(body) < feedbackHeaderLength {
+		return 0, 0, nil, fmt.Errorf("the feedback packet is too short %d", len(body))
+	}
+	return readUInt32(body), readUInt32(body[4:]), body[feedbackHeaderLength:], nil
+}
+
+// newFeedback 分配反馈包并写入sender SSRC和media SSRC
+func newFeedback(packetType, format int, senderSSRC, mediaSSRC uint32, fciLength int) []byte {
+	data := newPacket(packetType, format, feedbackHeaderLength+fciLength)
+	utils.WriteDWORD(data[4:], senderSSRC)
+	utils.WriteDWORD(data[8:], mediaSSRC)
+	return data
+}
+
+// NackPair Generic NACK的FCI, PacketID及其后16个包的丢失位图
+type NackPair struct {
+	PacketID    uint16
+	LostPackets uint16 //BLP
+}
+
+// PacketList 返回所有丢失的序号
+func (n NackPair) PacketList() []uint16 {
+	list := []uint16{n.PacketID}
+	for i := 0; i < 16; i++ {
+		if n.LostPackets&(1<<i) != 0 {
+			list = append(list, n.PacketID+uint16(i)+1)
+		}
+	}
+	return list
+}
+
+// NackPairsFromSequenceNumbers 将丢失的序号合并为NackPair, 序号需要按顺序排列
+func NackPairsFromSequenceNumbers(sequenceNumbers []uint16) []NackPair {
+	var pairs []NackPair
+	for _, seq := range sequenceNumbers {
+		if len(pairs) > 0 {
+			pair := &pairs[len(pairs)-1]
+			if diff := seq - pair.PacketID; diff == 0 {
+				continue
+			} else if diff <= 16 {
+				pair.LostPackets |= 1 << (diff - 1)
+				continue
+			}
+		}
+		pairs = append(pairs, NackPair{PacketID: seq})
+	}
+	return pairs
+}
+
+// TransportLayerNack RFC 4585 6.2.1 Generic NACK
+type TransportLayerNack struct {
+	SenderSSRC uint32
+	MediaSSRC  uint32
+	Nacks      []NackPair
+}
+
+func (t *TransportLayerNack) Marshal() ([]byte, error) {
+	data := newFeedback(TypeRTPFB, FormatNACK, t.SenderSSRC, t.MediaSSRC, len(t.Nacks)*4)
+	fci := data[HeaderLength+feedbackHeaderLength:]
+	for i, nack := range t.Nacks {
+		utils.WriteWORD(fci[i*4:], nack.PacketID)
+		utils.WriteWORD(fci[i*4+2:], nack.LostPackets)
+	}
+	return data, nil
+}
+
+func (t *TransportLayerNack) Unmarshal(data []byte) error {
+	senderSSRC, mediaSSRC, fci, err := parseFeedback(data, TypeRTPFB, FormatNACK)
+	if err != nil {
+		return err
+	} else if len(fci) == 0 || len(fci)%4 != 0 {
+		return fmt.Errorf("invalid nack length %d", len(fci))
+	}
+
+	t.SenderSSRC, t.MediaSSRC = senderSSRC, mediaSSRC
+	t.Nacks = make([]NackPair, len(fci)/4)
+	for i := range t.Nacks {
+		t.Nacks[i].PacketID = utils.BytesToUInt16(fci[i*4], fci[i*4+1])
+		t.Nacks[i].LostPackets = utils.BytesToUInt16(fci[i*4+2], fci[i*4+3])
+	}
+	return nil
+}
+
+func (t *TransportLayerNack) DestinationSSRC() []uint32 {
+	return []uint32{t.MediaSSRC}
+}
+
+// PictureLossIndication RFC 4585 6.3.1, 没有FCI
+type PictureLossIndication struct {
+	SenderSSRC uint32
+	MediaSSRC  uint32
+}
+
+func (p *PictureLossIndication) Marshal() ([]byte, error) {
+	return newFeedback(TypePSFB, FormatPLI, p.SenderSSRC, p.MediaSSRC, 0), nil
+}
+
+func (p *PictureLossIndication) Unmarshal(data []byte) error {
+	var err error
+	p.SenderSSRC, p.MediaSSRC, _, err = parseFeedback(data, TypePSFB, FormatPLI)
+	return err
+}
+
+func (p *PictureLossIndication) DestinationSSRC() []uint32 {
+	return []uint32{p.MediaSSRC}
+}
+
+// FIREntry FIR的FCI, 每个请求的SSRC使用独立的序号
+type FIREntry struct {
+	SSRC           uint32
+	SequenceNumber uint8
+}
+
+// FullIntraRequest RFC 5104 4.3.1. media SSRC固定为0, 请求的SSRC在FCI中
+type FullIntraRequest struct {
+	SenderSSRC uint32
+	MediaSSRC  uint32
+	FIR        []FIREntry
+}
+
+func (f *FullIntraRequest) Marshal() ([]byte, error) {
+	data := newFeedback(TypePSFB, FormatFIR, f.SenderSSRC, f.MediaSSRC, len(f.FIR)*8)
+	fci := data[HeaderLength+feedbackHeaderLength:]
+	for i, entry := range f.FIR {
+		utils.WriteDWORD(fci[i*8:], entry.SSRC)
+		fci[i*8+4] = entry.SequenceNumber
+	}
+	return data, nil
+}
+
+func (f *FullIntraRequest) Unmarshal(data []byte) error {
+	senderSSRC, mediaSSRC, fci, err := parseFeedback(data, TypePSFB, FormatFIR)
+	if err != nil {
+		return err
+	} else if len(fci) == 0 || len(fci)%8 != 0 {
+		return fmt.Errorf("invalid fir length %d", len(fci))
+	}
+
+	f.SenderSSRC, f.MediaSSRC = senderSSRC, mediaSSRC
+	f.FIR = make([]FIREntry, len(fci)/8)
+	for i := range f.FIR {
+		f.FIR[i].SSRC = readUInt32(fci[i*8:])
+		f.FIR[i].SequenceNumber = fci[i*8+4]
+	}
+	return nil
+}
+
+func (f *FullIntraRequest) DestinationSSRC() []uint32 {
+	ssrc := make([]uint32, 0, len(f.FIR))
+	for _, entry := range f.FIR {
+		ssrc = append(ssrc, entry.SSRC)
+	}
+	return ssrc
+}
+
+// ReceiverEstimatedMaximumBitrate draft-alvestrand-rmcat-remb, PSFB的ALFB
+// 'R' 'E' 'M' 'B' | Num SSRC(8bits) | BR Exp(6bits) | BR Mantissa(18bits) | SSRC...
+type ReceiverEstimatedMaximumBitrate struct {
+	SenderSSRC uint32
+	Bitrate    uint64 //bps
+	SSRCs      []uint32
+}
+
+func (r *ReceiverEstimatedMaximumBitrate) Marshal() ([]byte, error) {
+	if len(r.SSRCs) > 0xFF {
+		return nil, fmt.Errorf("too many remb ssrcs %d", len(r.SSRCs))
+	}
+
+	//mantissa最多18位, 超出部分舍去
+	var exp uint
+	if length := bits.Len64(r.Bitrate); length > 18 {
+		exp = uint(length - 18)
+	}
+	mantissa := uint32(r.Bitrate >> exp)
+
+	//ALFB的media SSRC固定为0
+	data := newFeedback(TypePSFB, FormatREMB, r.SenderSSRC, 0, 8+len(r.SSRCs)*4)
+	fci := data[HeaderLength+feedbackHeaderLength:]
+	copy(fci, rembIdentifier)
+	fci[4] = byte(len(r.SSRCs))
+	utils.WriteUInt24(fci[5:], uint32(exp)<<18|mantissa)
+	for i, ssrc := range r.SSRCs {
+		utils.WriteDWORD(fci[8+i*4:], ssrc)
+	}
+	return data, nil
+}
+
+func (r *ReceiverEstimatedMaximumBitrate) Unmarshal(data []byte) error {
+	senderSSRC, _, fci, err := parseFeedback(data, TypePSFB, FormatREMB)
+	if err != nil {
+		return err
+	} else if len(fci) < 8 || string(fci[:4]) != rembIdentifier {
+		return fmt.Errorf("invalid remb packet")
+	}
+
+	count := int(fci[4])
+	if len(fci) < 8+count*4 {
+		return fmt.Errorf("invalid remb ssrc count %d", count)
+	}
+
+	value := utils.BytesToUInt24(fci[5], fci[6], fci[7])
+	exp := value >> 18
+	mantissa := uint64(value & 0x3FFFF)
+	if exp > 0 && mantissa<<exp>>exp != mantissa {
+		return fmt.Errorf("the remb bitrate overflows")
+	}
+
+	r.SenderSSRC = senderSSRC
+	r.Bitrate = mantissa << exp
+	r.SSRCs = make([]uint32, count)
+	for i := range r.SSRCs {
+		r.SSRCs[i] = readUInt32(fci[8+i*4:])
+	}
+	return nil
+}
+
+func (r *ReceiverEstimatedMaximumBitrate) DestinationSSRC() []uint32 {
+	return r.SSRCs
+}
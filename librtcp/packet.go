@@ -0,0 +1,220 @@
+package librtcp
+
+import (
+	"avformat/utils"
+	"fmt"
+	"time"
+)
+
+const (
+	VERSION      = 2
+	HeaderLength = 4
+
+	TypeSR    = 200
+	TypeRR    = 201
+	TypeSDES  = 202
+	TypeBYE   = 203
+	TypeAPP   = 204
+	TypeRTPFB = 205 //RFC 4585 传输层反馈
+	TypePSFB  = 206 //RFC 4585 负载相关反馈
+
+	//RTPFB的FMT
+	FormatNACK = 1
+	FormatTCC  = 15 //draft-holmer-rmcat-transport-wide-cc-extensions
+	//PSFB的FMT
+	FormatPLI  = 1
+	FormatFIR  = 4 //RFC 5104
+	FormatREMB = 15
+
+	ntpEpochOffset = 2208988800
+)
+
+// Header RTCP公共头. Count在SR/RR中为RC, SDES/BYE中为SC, 反馈包中为FMT, APP中为subtype
+type Header struct {
+	Padding bool
+	Count   int
+	Type    int
+	Length  int //以4字节为单位的长度减1, 包含公共头
+}
+
+func (h *Header) marshalTo(dst []byte) {
+	dst[0] = VERSION<<6 | byte(h.Count&0x1F)
+	if h.Padding {
+		dst[0] |= 0x20
+	}
+	dst[1] = byte(h.Type)
+	utils.WriteWORD(dst[2:], uint16(h.Length))
+}
+
+func (h *Header) Unmarshal(data []byte) error {
+	if len(data) < HeaderLength {
+		return fmt.Errorf("the rtcp packet is too short %d", len(data))
+	} else if version := data[0] >> 6; version != VERSION {
+		return fmt.Errorf("invalid rtcp version %d", version)
+	}
+
+	h.Padding = data[0]&0x20 != 0
+	h.Count = int(data[0] & 0x1F)
+	h.Type = int(data[1])
+	h.Length = int(utils.BytesToUInt16(data[2], data[3]))
+	return nil
+}
+
+// Packet 复合包中的一个RTCP包
+type Packet interface {
+	// Unmarshal 解析一个完整的RTCP包(包含公共头)
+	Unmarshal(data []byte) error
+	Marshal() ([]byte, error)
+	// DestinationSSRC 报告或反馈针对的SSRC
+	DestinationSSRC() []uint32
+}
+
+// RawPacket 未支持的RTCP包, 保留原始数据
+type RawPacket []byte
+
+func (r *RawPacket) Unmarshal(data []byte) error {
+	var header Header
+	if err := header.Unmarshal(data); err != nil {
+		return err
+	}
+	*r = append((*r)[:0], data...)
+	return nil
+}
+
+func (r RawPacket) Marshal() ([]byte, error) {
+	return r, nil
+}
+
+func (r RawPacket) DestinationSSRC() []uint32 {
+	return nil
+}
+
+// parsePacket 校验公共头, 去掉填充
+// @return 公共头之后的数据
+func parsePacket(data []byte, packetType int) (Header, []byte, error) {
+	var header Header
+	if err := header.Unmarshal(data); err != nil {
+		return header, nil, err
+	} else if header.Type != packetType {
+		return header, nil, fmt.Errorf("unexpected rtcp packet type %d, expected %d", header.Type, packetType)
+	}
+
+	length := (header.Length + 1) * 4
+	if length > len(data) {
+		return header, nil, fmt.Errorf("invalid rtcp packet length %d", length)
+	}
+
+	body := data[HeaderLength:length]
+	if header.Padding {
+		padding := int(data[length-1])
+		if padding == 0 || padding > len(body) {
+			return header, nil, fmt.Errorf("invalid rtcp padding %d", padding)
+		}
+		body = body[:len(body)-padding]
+	}
+	return header, body, nil
+}
+
+// newPacket 分配包含公共头的缓冲区, 长度不是4的倍数时在末尾填充并设置P位
+// @size 公共头之后的长度
+func newPacket(packetType, count, size int) []byte {
+	padding := (4 - size%4) % 4
+	data := make([]byte, HeaderLength+size+padding)
+	header := Header{Padding: padding > 0, Count: count, Type: packetType, Length: len(data)/4 - 1}
+	header.marshalTo(data)
+	if padding > 0 {
+		data[len(data)-1] = byte(padding)
+	}
+	return data
+}
+
+// Unmarshal 解析复合包, 未支持的包类型返回RawPacket
+func Unmarshal(data []byte) ([]Packet, error) {
+	var packets []Packet
+	for len(data) > 0 {
+		var header Header
+		if err := header.Unmarshal(data); err != nil {
+			return packets, err
+		}
+
+		length := (header.Length + 1) * 4
+		if length > len(data) {
+			return packets, fmt.Errorf("invalid rtcp packet length %d", length)
+		}
+
+		var packet Packet
+		switch header.Type {
+		case TypeSR:
+			packet = &SenderReport{}
+		case TypeRR:
+			packet = &ReceiverReport{}
+		case TypeSDES:
+			packet = &SourceDescription{}
+		case TypeBYE:
+			packet = &Goodbye{}
+		case TypeAPP:
+			packet = &ApplicationDefined{}
+		case TypeRTPFB:
+			switch header.Count {
+			case FormatNACK:
+				packet = &TransportLayerNack{}
+			case FormatTCC:
+				packet = &TransportLayerCC{}
+			}
+		case TypePSFB:
+			switch header.Count {
+			case FormatPLI:
+				packet = &PictureLossIndication{}
+			case FormatFIR:
+				packet = &FullIntraRequest{}
+			case FormatREMB:
+				//ALFB中只支持REMB
+				if length >= 16 && string(data[12:16]) == rembIdentifier {
+					packet = &ReceiverEstimatedMaximumBitrate{}
+				}
+			}
+		}
+		if packet == nil {
+			packet = &RawPacket{}
+		}
+
+		if err := packet.Unmarshal(data[:length]); err != nil {
+			return packets, err
+		}
+		packets = append(packets, packet)
+		data = data[length:]
+	}
+
+	return packets, nil
+}
+
+// Marshal 生成复合包
+func Marshal(packets []Packet) ([]byte, error) {
+	var data []byte
+	for _, packet := range packets {
+		bytes, err := packet.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, bytes...)
+	}
+	return data, nil
+}
+
+// ToNtpTime 64位NTP时间, 高32位为1900年以来的秒数, 低32位为秒的小数部分
+func ToNtpTime(t time.Time) uint64 {
+	nanos := uint64(t.UnixNano())
+	seconds := nanos/uint64(time.Second) + ntpEpochOffset
+	fraction := (nanos % uint64(time.Second)) << 32 / uint64(time.Second)
+	return seconds<<32 | fraction
+}
+
+func FromNtpTime(ntp uint64) time.Time {
+	seconds := int64(ntp>>32) - ntpEpochOffset
+	nanos := int64((ntp & 0xFFFFFFFF) * uint64(time.Second) >> 32)
+	return time.Unix(seconds, nanos)
+}
+
+func readUInt32(data []byte) uint32 {
+	return utils.BytesToUInt32(data[0], data[1], data[2], data[3])
+}
@@ -0,0 +1,112 @@
+package librtcp
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCompoundPacket(t *testing.T) {
+	packets := []Packet{
+		&SenderReport{SSRC: 0x11223344, NTPTime: 0xE0000000_80000000, RTPTime: 90000, PacketCount: 10, OctetCount: 12000,
+			Reports: []ReceptionReport{{SSRC: 0x55667788, FractionLost: 25, TotalLost: -2, LastSequenceNumber: 65538, Jitter: 30, LastSenderReport: 0x12345678, Delay: 65536}}},
+		&ReceiverReport{SSRC: 0x01020304},
+		NewCNAMESourceDescription(0x11223344, "avformat"),
+		&Goodbye{Sources: []uint32{0x11223344}, Reason: "teardown"},
+		&ApplicationDefined{SubType: 3, SSRC: 0x11223344, Name: "TEST", Data: []byte{1, 2, 3, 4}},
+	}
+
+	data, err := Marshal(packets)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(data)%4 != 0 {
+		t.Fatalf("the compound packet is not aligned %d", len(data))
+	}
+
+	result, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(result) != len(packets) {
+		t.Fatalf("unmarshal %d packets, expected %d", len(result), len(packets))
+	}
+
+	//空的report block列表解析为长度0的切片
+	packets[1].(*ReceiverReport).Reports = []ReceptionReport{}
+	for i := range packets {
+		if !reflect.DeepEqual(packets[i], result[i]) {
+			t.Fatalf("packet %d mismatch %+v %+v", i, packets[i], result[i])
+		}
+	}
+
+	if cname, ok := result[2].(*SourceDescription).CNAME(0x11223344); !ok || cname != "avformat" {
+		t.Fatalf("unexpected cname %s", cname)
+	}
+
+	//未支持的包类型
+	raw := []byte{0x80, 207, 0, 1, 1, 2, 3, 4}
+	result, err = Unmarshal(append(raw, data...))
+	if err != nil {
+		t.Fatal(err)
+	} else if _, ok := result[0].(*RawPacket); !ok || len(result) != len(packets)+1 {
+		t.Fatalf("unexpected packets %+v", result)
+	}
+
+	if _, err = Unmarshal(data[:len(data)-2]); err == nil {
+		t.Fatalf("truncated packet must fail")
+	}
+}
+
+func TestFeedback(t *testing.T) {
+	packets := []Packet{
+		&TransportLayerNack{SenderSSRC: 1, MediaSSRC: 2, Nacks: NackPairsFromSequenceNumbers([]uint16{65535, 0, 2, 16, 100})},
+		&PictureLossIndication{SenderSSRC: 1, MediaSSRC: 2},
+		&FullIntraRequest{SenderSSRC: 1, FIR: []FIREntry{{SSRC: 2, SequenceNumber: 7}}},
+		&ReceiverEstimatedMaximumBitrate{SenderSSRC: 1, Bitrate: 1 << 20, SSRCs: []uint32{2, 3}},
+		&TransportLayerCC{SenderSSRC: 1, MediaSSRC: 2, BaseSequenceNumber: 100, ReferenceTime: -5, FbPktCount: 9,
+			PacketStatuses: []int{1, 1, 0, 2, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0},
+			RecvDeltas:     []int{4, 255, -10, 1, 2, 3, 4, 5, 6, 7, 8}},
+		&TransportLayerCC{SenderSSRC: 1, MediaSSRC: 2, BaseSequenceNumber: 65530,
+			PacketStatuses: append(make([]int, 20), 1, 2),
+			RecvDeltas:     []int{10, 1000}},
+	}
+
+	data, err := Marshal(packets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range packets {
+		if !reflect.DeepEqual(packets[i], result[i]) {
+			t.Fatalf("packet %d mismatch %+v %+v", i, packets[i], result[i])
+		}
+	}
+
+	nacks := result[0].(*TransportLayerNack).Nacks
+	var lost []uint16
+	for _, nack := range nacks {
+		lost = append(lost, nack.PacketList()...)
+	}
+	if !reflect.DeepEqual(lost, []uint16{65535, 0, 2, 16, 100}) || len(nacks) != 3 {
+		t.Fatalf("unexpected nack list %v", lost)
+	}
+
+	//REMB的mantissa只有18位
+	remb := ReceiverEstimatedMaximumBitrate{Bitrate: 1<<20 + 1}
+	bytes, _ := remb.Marshal()
+	if err = remb.Unmarshal(bytes); err != nil || remb.Bitrate != 1<<20 {
+		t.Fatalf("unexpected remb bitrate %d %v", remb.Bitrate, err)
+	}
+}
+
+func TestNtpTime(t *testing.T) {
+	now := time.Unix(1700000000, 500000000)
+	ntp := ToNtpTime(now)
+	if ntp>>32 != 1700000000+ntpEpochOffset || uint32(ntp) != 1<<31 {
+		t.Fatalf("unexpected ntp time %x", ntp)
+	} else if !FromNtpTime(ntp).Equal(now) {
+		t.Fatalf("unexpected time %v", FromNtpTime(ntp))
+	}
+}
@@ -0,0 +1,177 @@
+package librtcp
+
+import (
+	"avformat/utils"
+	"fmt"
+)
+
+const (
+	receptionReportLength = 24
+	senderInfoLength      = 24 //SSRC + sender info
+)
+
+// ReceptionReport SR/RR中的report block
+type ReceptionReport struct {
+	SSRC               uint32
+	FractionLost       uint8
+	TotalLost          int32 //24位有符号数, 重复包可能导致负数
+	LastSequenceNumber uint32
+	Jitter             uint32
+	LastSenderReport   uint32 //最后收到的SR中NTP时间的中间32位
+	Delay              uint32 //收到最后一个SR到发送本报告的间隔, 单位1/65536秒
+}
+
+func (r *ReceptionReport) marshalTo(dst []byte) {
+	lost := r.TotalLost
+	if lost > 0x7FFFFF {
+		lost = 0x7FFFFF
+	} else if lost < -0x800000 {
+		lost = -0x800000
+	}
+
+	utils.WriteDWORD(dst, r.SSRC)
+	dst[4] = r.FractionLost
+	utils.WriteUInt24(dst[5:], uint32(lost))
+	utils.WriteDWORD(dst[8:], r.LastSequenceNumber)
+	utils.WriteDWORD(dst[12:], r.Jitter)
+	utils.WriteDWORD(dst[16:], r.LastSenderReport)
+	utils.WriteDWORD(dst[20:], r.Delay)
+}
+
+func (r *ReceptionReport) unmarshal(data []byte) {
+	r.SSRC = readUInt32(data)
+	r.FractionLost = data[4]
+	//24位符号扩展
+	r.TotalLost = int32(utils.BytesToUInt24(data[5], data[6], data[7])<<8) >> 8
+	r.LastSequenceNumber = readUInt32(data[8:])
+	r.Jitter = readUInt32(data[12:])
+	r.LastSenderReport = readUInt32(data[16:])
+	r.Delay = readUInt32(data[20:])
+}
+
+func marshalReports(dst []byte, reports []ReceptionReport) int {
+	var n int
+	for i := range reports {
+		reports[i].marshalTo(dst[n:])
+		n += receptionReportLength
+	}
+	return n
+}
+
+// unmarshalReports 解析count个report block
+// @return report block之后的profile-specific extensions
+func unmarshalReports(data []byte, count int) ([]ReceptionReport, []byte, error) {
+	if len(data) < count*receptionReportLength {
+		return nil, nil, fmt.Errorf("invalid reception report count %d", count)
+	}
+
+	reports := make([]ReceptionReport, count)
+	for i := range reports {
+		reports[i].unmarshal(data[i*receptionReportLength:])
+	}
+	var extensions []byte
+	if len(data) > count*receptionReportLength {
+		extensions = data[count*receptionReportLength:]
+	}
+	return reports, extensions, nil
+}
+
+func reportsDestination(reports []ReceptionReport) []uint32 {
+	ssrc := make([]uint32, 0, len(reports))
+	for _, report := range reports {
+		ssrc = append(ssrc, report.SSRC)
+	}
+	return ssrc
+}
+
+// SenderReport RFC 3550 6.4.1
+type SenderReport struct {
+	SSRC        uint32
+	NTPTime     uint64
+	RTPTime     uint32
+	PacketCount uint32
+	OctetCount  uint32
+	Reports     []ReceptionReport
+	//profile-specific extensions, 长度为4的倍数
+	ProfileExtensions []byte
+}
+
+func (s *SenderReport) Marshal() ([]byte, error) {
+	if len(s.Reports) > 31 {
+		return nil, fmt.Errorf("too many reception reports %d", len(s.Reports))
+	} else if len(s.ProfileExtensions)%4 != 0 {
+		return nil, fmt.Errorf("the profile extensions must be a multiple of 4 bytes")
+	}
+
+	data := newPacket(TypeSR, len(s.Reports), senderInfoLength+len(s.Reports)*receptionReportLength+len(s.ProfileExtensions))
+	body := data[HeaderLength:]
+	utils.WriteDWORD(body, s.SSRC)
+	utils.WriteDWORD(body[4:], uint32(s.NTPTime>>32))
+	utils.WriteDWORD(body[8:], uint32(s.NTPTime))
+	utils.WriteDWORD(body[12:], s.RTPTime)
+	utils.WriteDWORD(body[16:], s.PacketCount)
+	utils.WriteDWORD(body[20:], s.OctetCount)
+	n := senderInfoLength + marshalReports(body[senderInfoLength:], s.Reports)
+	copy(body[n:], s.ProfileExtensions)
+	return data, nil
+}
+
+func (s *SenderReport) Unmarshal(data []byte) error {
+	header, body, err := parsePacket(data, TypeSR)
+	if err != nil {
+		return err
+	} else if len(body) < senderInfoLength {
+		return fmt.Errorf("the sender report is too short %d", len(body))
+	}
+
+	s.SSRC = readUInt32(body)
+	s.NTPTime = uint64(readUInt32(body[4:]))<<32 | uint64(readUInt32(body[8:]))
+	s.RTPTime = readUInt32(body[12:])
+	s.PacketCount = readUInt32(body[16:])
+	s.OctetCount = readUInt32(body[20:])
+	s.Reports, s.ProfileExtensions, err = unmarshalReports(body[senderInfoLength:], header.Count)
+	return err
+}
+
+func (s *SenderReport) DestinationSSRC() []uint32 {
+	return reportsDestination(s.Reports)
+}
+
+// ReceiverReport RFC 3550 6.4.2
+type ReceiverReport struct {
+	SSRC              uint32
+	Reports           []ReceptionReport
+	ProfileExtensions []byte
+}
+
+func (r *ReceiverReport) Marshal() ([]byte, error) {
+	if len(r.Reports) > 31 {
+		return nil, fmt.Errorf("too many reception reports %d", len(r.Reports))
+	} else if len(r.ProfileExtensions)%4 != 0 {
+		return nil, fmt.Errorf("the profile extensions must be a multiple of 4 bytes")
+	}
+
+	data := newPacket(TypeRR, len(r.Reports), 4+len(r.Reports)*receptionReportLength+len(r.ProfileExtensions))
+	body := data[HeaderLength:]
+	utils.WriteDWORD(body, r.SSRC)
+	n := 4 + marshalReports(body[4:], r.Reports)
+	copy(body[n:], r.ProfileExtensions)
+	return data, nil
+}
+
+func (r *ReceiverReport) Unmarshal(data []byte) error {
+	header, body, err := parsePacket(data, TypeRR)
+	if err != nil {
+		return err
+	} else if len(body) < 4 {
+		return fmt.Errorf("the receiver report is too short %d", len(body))
+	}
+
+	r.SSRC = readUInt32(body)
+	r.Reports, r.ProfileExtensions, err = unmarshalReports(body[4:], header.Count)
+	return err
+}
+
+func (r *ReceiverReport) DestinationSSRC() []uint32 {
+	return reportsDestination(r.Reports)
+}
@@ -0,0 +1,142 @@
+package librtcp
+
+import (
+	"avformat/utils"
+	"fmt"
+)
+
+// SDES item type
+const (
+	SDESEnd   = 0
+	SDESCNAME = 1
+	SDESName  = 2
+	SDESEmail = 3
+	SDESPhone = 4
+	SDESLoc   = 5
+	SDESTool  = 6
+	SDESNote  = 7
+	SDESPriv  = 8
+)
+
+type SourceDescriptionItem struct {
+	Type int
+	Text string
+}
+
+// SourceDescriptionChunk 一个SSRC/CSRC及其item
+type SourceDescriptionChunk struct {
+	Source uint32
+	Items  []SourceDescriptionItem
+}
+
+// size chunk的长度, 包含结束符和对齐到4字节的填充
+func (c *SourceDescriptionChunk) size() int {
+	n := 4
+	for _, item := range c.Items {
+		n += 2 + len(item.Text)
+	}
+	//至少一个null作为结束
+	return n + 4 - n%4
+}
+
+// SourceDescription RFC 3550 6.5
+type SourceDescription struct {
+	Chunks []SourceDescriptionChunk
+}
+
+// NewCNAMESourceDescription 只包含CNAME的SDES
+func NewCNAMESourceDescription(ssrc uint32, cname string) *SourceDescription {
+	return &SourceDescription{Chunks: []SourceDescriptionChunk{{Source: ssrc, Items: []SourceDescriptionItem{{Type: SDESCNAME, Text: cname}}}}}
+}
+
+func (s *SourceDescription) Marshal() ([]byte, error) {
+	if len(s.Chunks) > 31 {
+		return nil, fmt.Errorf("too many sdes chunks %d", len(s.Chunks))
+	}
+
+	var size int
+	for i := range s.Chunks {
+		for _, item := range s.Chunks[i].Items {
+			if item.Type == SDESEnd || item.Type > 0xFF {
+				return nil, fmt.Errorf("invalid sdes item type %d", item.Type)
+			} else if len(item.Text) > 0xFF {
+				return nil, fmt.Errorf("the sdes item is too long %d", len(item.Text))
+			}
+		}
+		size += s.Chunks[i].size()
+	}
+
+	data := newPacket(TypeSDES, len(s.Chunks), size)
+	n := HeaderLength
+	for i := range s.Chunks {
+		chunk := &s.Chunks[i]
+		utils.WriteDWORD(data[n:], chunk.Source)
+		offset := n + 4
+		for _, item := range chunk.Items {
+			data[offset] = byte(item.Type)
+			data[offset+1] = byte(len(item.Text))
+			offset += 2 + copy(data[offset+2:], item.Text)
+		}
+		//结束符和填充已经是0
+		n += chunk.size()
+	}
+	return data, nil
+}
+
+func (s *SourceDescription) Unmarshal(data []byte) error {
+	header, body, err := parsePacket(data, TypeSDES)
+	if err != nil {
+		return err
+	}
+
+	s.Chunks = make([]SourceDescriptionChunk, 0, header.Count)
+	offset := 0
+	for i := 0; i < header.Count; i++ {
+		if offset+4 > len(body) {
+			return fmt.Errorf("invalid sdes chunk count %d", header.Count)
+		}
+
+		chunk := SourceDescriptionChunk{Source: readUInt32(body[offset:])}
+		offset += 4
+		for {
+			if offset >= len(body) {
+				return fmt.Errorf("the sdes chunk is not terminated")
+			} else if body[offset] == SDESEnd {
+				//跳过结束符, 对齐到4字节
+				offset += 4 - offset%4
+				break
+			} else if offset+2 > len(body) || offset+2+int(body[offset+1]) > len(body) {
+				return fmt.Errorf("invalid sdes item length")
+			}
+
+			length := int(body[offset+1])
+			chunk.Items = append(chunk.Items, SourceDescriptionItem{Type: int(body[offset]), Text: string(body[offset+2 : offset+2+length])})
+			offset += 2 + length
+		}
+		s.Chunks = append(s.Chunks, chunk)
+	}
+	return nil
+}
+
+func (s *SourceDescription) DestinationSSRC() []uint32 {
+	ssrc := make([]uint32, 0, len(s.Chunks))
+	for _, chunk := range s.Chunks {
+		ssrc = append(ssrc, chunk.Source)
+	}
+	return ssrc
+}
+
+// CNAME 返回指定源的CNAME
+func (s *SourceDescription) CNAME(ssrc uint32) (string, bool) {
+	for _, chunk := range s.Chunks {
+		if chunk.Source != ssrc {
+			continue
+		}
+		for _, item := range chunk.Items {
+			if item.Type == SDESCNAME {
+				return item.Text, true
+			}
+		}
+	}
+	return "", false
+}
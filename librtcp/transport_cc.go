@@ -0,0 +1,196 @@
+package librtcp
+
+import (
+	"avformat/utils"
+	"fmt"
+)
+
+// transport-cc的包状态
+const (
+	PacketNotReceived        = 0
+	PacketReceivedSmallDelta = 1 //1字节无符号delta
+	PacketReceivedLargeDelta = 2 //2字节有符号delta
+
+	transportCCHeaderLength = 8 //base seq + status count + reference time + fb pkt count
+	runLengthMaxCount       = 0x1FFF
+)
+
+// TransportLayerCC draft-holmer-rmcat-transport-wide-cc-extensions-01 3.1
+// 从BaseSequenceNumber开始, 每个序号一个状态. 每个收到的包对应一个RecvDelta
+type TransportLayerCC struct {
+	SenderSSRC         uint32
+	MediaSSRC          uint32
+	BaseSequenceNumber uint16
+	ReferenceTime      int32 //24位有符号数, 单位64ms
+	FbPktCount         uint8
+	PacketStatuses     []int
+	RecvDeltas         []int //单位250us, 相对上一个收到的包, 第一个相对ReferenceTime
+}
+
+// writeChunks 生成packet status chunk. 连续7个以上相同状态使用run length, 只有0/1时使用1位的status vector
+func (t *TransportLayerCC) writeChunks() []uint16 {
+	var chunks []uint16
+	statuses := t.PacketStatuses
+	for i := 0; i < len(statuses); {
+		run := 1
+		for i+run < len(statuses) && statuses[i+run] == statuses[i] && run < runLengthMaxCount {
+			run++
+		}
+		if run >= 7 {
+			chunks = append(chunks, uint16(statuses[i])<<13|uint16(run))
+			i += run
+			continue
+		}
+
+		oneBit := true
+		for j := i; j < i+14 && j < len(statuses); j++ {
+			if statuses[j] > PacketReceivedSmallDelta {
+				oneBit = false
+			}
+		}
+
+		//T=1 | S | symbol list
+		chunk := uint16(0x8000)
+		if oneBit {
+			for j := 0; j < 14 && i+j < len(statuses); j++ {
+				chunk |= uint16(statuses[i+j]) << (13 - j)
+			}
+			i += 14
+		} else {
+			chunk |= 0x4000
+			for j := 0; j < 7 && i+j < len(statuses); j++ {
+				chunk |= uint16(statuses[i+j]) << (12 - j*2)
+			}
+			i += 7
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+func (t *TransportLayerCC) Marshal() ([]byte, error) {
+	if len(t.PacketStatuses) > 0xFFFF {
+		return nil, fmt.Errorf("too many packet statuses %d", len(t.PacketStatuses))
+	}
+
+	var received, deltaLength int
+	for _, status := range t.PacketStatuses {
+		switch status {
+		case PacketNotReceived:
+			continue
+		case PacketReceivedSmallDelta:
+			deltaLength++
+		case PacketReceivedLargeDelta:
+			deltaLength += 2
+		default:
+			return nil, fmt.Errorf("invalid packet status %d", status)
+		}
+
+		if received >= len(t.RecvDeltas) {
+			return nil, fmt.Errorf("missing recv delta of packet %d", received)
+		}
+		delta := t.RecvDeltas[received]
+		if status == PacketReceivedSmallDelta && (delta < 0 || delta > 0xFF) {
+			return nil, fmt.Errorf("the small delta is out of range %d", delta)
+		} else if delta < -0x8000 || delta > 0x7FFF {
+			return nil, fmt.Errorf("the large delta is out of range %d", delta)
+		}
+		received++
+	}
+	if received != len(t.RecvDeltas) {
+		return nil, fmt.Errorf("the recv delta count %d does not match %d received packets", len(t.RecvDeltas), received)
+	}
+
+	chunks := t.writeChunks()
+	data := newFeedback(TypeRTPFB, FormatTCC, t.SenderSSRC, t.MediaSSRC, transportCCHeaderLength+len(chunks)*2+deltaLength)
+	fci := data[HeaderLength+feedbackHeaderLength:]
+	utils.WriteWORD(fci, t.BaseSequenceNumber)
+	utils.WriteWORD(fci[2:], uint16(len(t.PacketStatuses)))
+	utils.WriteUInt24(fci[4:], uint32(t.ReferenceTime))
+	fci[7] = t.FbPktCount
+
+	n := transportCCHeaderLength
+	for _, chunk := range chunks {
+		utils.WriteWORD(fci[n:], chunk)
+		n += 2
+	}
+
+	received = 0
+	for _, status := range t.PacketStatuses {
+		if status == PacketReceivedSmallDelta {
+			fci[n] = byte(t.RecvDeltas[received])
+			n++
+		} else if status == PacketReceivedLargeDelta {
+			utils.WriteWORD(fci[n:], uint16(int16(t.RecvDeltas[received])))
+			n += 2
+		} else {
+			continue
+		}
+		received++
+	}
+	return data, nil
+}
+
+func (t *TransportLayerCC) Unmarshal(data []byte) error {
+	senderSSRC, mediaSSRC, fci, err := parseFeedback(data, TypeRTPFB, FormatTCC)
+	if err != nil {
+		return err
+	} else if len(fci) < transportCCHeaderLength {
+		return fmt.Errorf("the transport-cc packet is too short %d", len(fci))
+	}
+
+	t.SenderSSRC, t.MediaSSRC = senderSSRC, mediaSSRC
+	t.BaseSequenceNumber = utils.BytesToUInt16(fci[0], fci[1])
+	count := int(utils.BytesToUInt16(fci[2], fci[3]))
+	t.ReferenceTime = int32(utils.BytesToUInt24(fci[4], fci[5], fci[6])<<8) >> 8
+	t.FbPktCount = fci[7]
+
+	t.PacketStatuses = make([]int, 0, count)
+	offset := transportCCHeaderLength
+	for len(t.PacketStatuses) < count {
+		if offset+2 > len(fci) {
+			return fmt.Errorf("missing packet status chunk")
+		}
+		chunk := utils.BytesToUInt16(fci[offset], fci[offset+1])
+		offset += 2
+
+		if chunk&0x8000 == 0 {
+			//run length chunk
+			status := int(chunk >> 13 & 0x3)
+			for run := int(chunk & runLengthMaxCount); run > 0 && len(t.PacketStatuses) < count; run-- {
+				t.PacketStatuses = append(t.PacketStatuses, status)
+			}
+		} else if chunk&0x4000 == 0 {
+			//1位的status vector
+			for j := 0; j < 14 && len(t.PacketStatuses) < count; j++ {
+				t.PacketStatuses = append(t.PacketStatuses, int(chunk>>(13-j)&0x1))
+			}
+		} else {
+			for j := 0; j < 7 && len(t.PacketStatuses) < count; j++ {
+				t.PacketStatuses = append(t.PacketStatuses, int(chunk>>(12-j*2)&0x3))
+			}
+		}
+	}
+
+	t.RecvDeltas = nil
+	for _, status := range t.PacketStatuses {
+		if status == PacketReceivedSmallDelta {
+			if offset+1 > len(fci) {
+				return fmt.Errorf("missing recv delta")
+			}
+			t.RecvDeltas = append(t.RecvDeltas, int(fci[offset]))
+			offset++
+		} else if status == PacketReceivedLargeDelta {
+			if offset+2 > len(fci) {
+				return fmt.Errorf("missing recv delta")
+			}
+			t.RecvDeltas = append(t.RecvDeltas, int(int16(utils.BytesToUInt16(fci[offset], fci[offset+1]))))
+			offset += 2
+		}
+	}
+	return nil
+}
+
+func (t *TransportLayerCC) DestinationSSRC() []uint32 {
+	return []uint32{t.MediaSSRC}
+}
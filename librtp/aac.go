@@ -0,0 +1,328 @@
+package librtp
+
+import (
+	"avformat/utils"
+	"fmt"
+)
+
+// AACFrameSize AAC-LC每帧的采样数
+const AACFrameSize = 1024
+
+// maxInterleavedFrames 交织时最多缓存的AU, 超过后认为有丢包
+const maxInterleavedFrames = 64
+
+// aacDepacketizer RFC 3640 mpeg4-generic, 输出不带ADTS头的AAC帧
+// 交织时按AU-Index计算时间戳, 并按时间戳顺序输出
+type aacDepacketizer struct {
+	frameAssembler
+	sizeLength       int
+	indexLength      int
+	indexDeltaLength int
+	fragmentSize     int //分片AU的总长度
+
+	interleaved   bool
+	pending       []aacFrame //交织时等待输出的AU, 按时间戳排序
+	started       bool       //nextTimestamp是否有效
+	nextTimestamp uint32
+}
+
+type aacFrame struct {
+	data      []byte
+	timestamp uint32
+}
+
+// NewAACDepacketizer AAC-hbr: sizeLength=13 indexLength=3 indexDeltaLength=3
+// AAC-lbr: sizeLength=6 indexLength=2 indexDeltaLength=2
+func NewAACDepacketizer(sizeLength, indexLength, indexDeltaLength int, handler decodeHandler) Depacketizer {
+	return &aacDepacketizer{
+		frameAssembler:   frameAssembler{handler: handler},
+		sizeLength:       sizeLength,
+		indexLength:      indexLength,
+		indexDeltaLength: indexDeltaLength,
+	}
+}
+
+func (d *aacDepacketizer) Input(packet []byte) error {
+	h, payload, err := parseHeader(packet)
+	if err != nil {
+		return err
+	}
+
+	if d.stale(h) {
+		return nil
+	}
+	//未结束的分片AU
+	if len(d.buffer) > 0 && h.timestamp != d.timestamp {
+		d.lost = true
+	}
+	d.checkPacket(h, nil)
+
+	sizes, indices, data, err := d.readAUHeaders(payload)
+	if err != nil {
+		d.lost = true
+		return err
+	}
+
+	//AU被分片
+	if len(sizes) == 1 && sizes[0] > len(data) {
+		if len(d.buffer) == 0 {
+			d.fragmentSize = sizes[0]
+			d.lost = false
+		}
+		d.write(data)
+		if h.m == 1 {
+			if len(d.buffer) != d.fragmentSize {
+				d.lost = true
+			}
+			if len(d.buffer) > 0 && !d.lost {
+				d.output(append([]byte(nil), d.buffer...), h.timestamp)
+				d.flush()
+			}
+			d.buffer = d.buffer[:0]
+			d.lost = false
+		}
+		return nil
+	}
+
+	//丢弃未结束的分片AU
+	d.buffer = d.buffer[:0]
+	d.lost = false
+
+	offset := 0
+	for i, size := range sizes {
+		if offset+size > len(data) {
+			return fmt.Errorf("invalid AU size %d", size)
+		}
+
+		au := make([]byte, size)
+		copy(au, data[offset:offset+size])
+		d.output(au, h.timestamp+uint32((indices[i]-indices[0])*AACFrameSize))
+		offset += size
+	}
+
+	d.flush()
+	return nil
+}
+
+// output 非交织时直接输出, 交织时按时间戳插入等待队列
+func (d *aacDepacketizer) output(data []byte, timestamp uint32) {
+	if !d.interleaved {
+		d.handler(data, timestamp, true)
+		d.started = true
+		d.nextTimestamp = timestamp + AACFrameSize
+		return
+	}
+
+	//迟到的AU
+	if d.started && int32(timestamp-d.nextTimestamp) < 0 {
+		return
+	}
+
+	index := len(d.pending)
+	for i, f := range d.pending {
+		if f.timestamp == timestamp {
+			return
+		} else if int32(timestamp-f.timestamp) < 0 {
+			index = i
+			break
+		}
+	}
+	d.pending = append(d.pending, aacFrame{})
+	copy(d.pending[index+1:], d.pending[index:])
+	d.pending[index] = aacFrame{data, timestamp}
+}
+
+// flush 交织时输出连续的AU. 第一个包的AU缓存后, 以其中最小的时间戳作为起点,
+// 流的初始时间戳是随机的, 不能从0开始
+func (d *aacDepacketizer) flush() {
+	if !d.interleaved || len(d.pending) == 0 {
+		return
+	}
+
+	if !d.started {
+		d.started = true
+		d.nextTimestamp = d.pending[0].timestamp
+	}
+
+	for len(d.pending) > 0 && (d.pending[0].timestamp == d.nextTimestamp || len(d.pending) > maxInterleavedFrames) {
+		f := d.pending[0]
+		d.pending = d.pending[1:]
+		d.handler(f.data, f.timestamp, true)
+		d.nextTimestamp = f.timestamp + AACFrameSize
+	}
+}
+
+// readAUHeaders 解析AU-headers-length和AU-header
+// @return 每个AU的大小, AU-Index和AU数据
+func (d *aacDepacketizer) readAUHeaders(payload []byte) ([]int, []int, []byte, error) {
+	if len(payload) < 2 {
+		return nil, nil, nil, fmt.Errorf("invalid aac rtp payload length %d", len(payload))
+	}
+
+	headersLength := int(utils.BytesToUInt16(payload[0], payload[1]))
+	headersSize := (headersLength + 7) / 8
+	if 2+headersSize > len(payload) {
+		return nil, nil, nil, fmt.Errorf("invalid AU-headers-length %d", headersLength)
+	}
+
+	reader := utils.NewBitReader(payload[2 : 2+headersSize])
+	var sizes, indices []int
+	for i := 0; reader.Offset() < headersLength; i++ {
+		size, err := reader.ReadBits(d.sizeLength)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		//AU-Index/AU-Index-delta
+		var index uint32
+		if i == 0 {
+			index, err = reader.ReadBits(d.indexLength)
+			indices = append(indices, int(index))
+		} else {
+			index, err = reader.ReadBits(d.indexDeltaLength)
+			indices = append(indices, indices[i-1]+int(index)+1)
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		//AU-Index-delta不为0时为交织
+		if i > 0 && index > 0 && !d.interleaved {
+			d.interleaved = true
+		}
+		sizes = append(sizes, int(size))
+	}
+
+	return sizes, indices, payload[2+headersSize:], nil
+}
+
+// aacPacketizer RFC 3640. 不交织时每个包一个AU, 交织时每个包多个AU.
+// 超过MTU的AU分片发送, 每个分片的AU-size都为AU的总长度
+type aacPacketizer struct {
+	packetWriter
+	sizeLength       int
+	indexLength      int
+	indexDeltaLength int
+
+	depth           int        //交织的包数, 小于2时不交织
+	framesPerPacket int        //交织时每个包的AU数
+	frames          []aacFrame //等待交织的AU
+	serial          int        //AU序号, 用作AU-Index
+}
+
+// NewAACPacketizer 输入不带ADTS头的AAC帧. AAC-hbr: sizeLength=13 indexLength=3, AAC-lbr: sizeLength=6 indexLength=2
+func NewAACPacketizer(pt int, ssrc uint32, sizeLength, indexLength int, handler encodeHandler) Packetizer {
+	return &aacPacketizer{
+		packetWriter: newPacketWriter(pt, ssrc, handler),
+		sizeLength:   sizeLength,
+		indexLength:  indexLength,
+	}
+}
+
+// NewAACInterleavedPacketizer 交织发送, 每depth*framesPerPacket个AU为一组, 组内第j个包发送第j, j+depth, j+2*depth...个AU,
+// 丢失一个包时只丢失不相邻的AU. 输入的AAC帧时间戳必须连续, 不足一组的AU等到下一组时才发送
+// @depth 交织的包数, AU-Index-delta为depth-1, 不能超过indexDeltaLength的表示范围
+func NewAACInterleavedPacketizer(pt int, ssrc uint32, sizeLength, indexLength, indexDeltaLength, depth, framesPerPacket int, handler encodeHandler) Packetizer {
+	return &aacPacketizer{
+		packetWriter:     newPacketWriter(pt, ssrc, handler),
+		sizeLength:       sizeLength,
+		indexLength:      indexLength,
+		indexDeltaLength: indexDeltaLength,
+		depth:            utils.MinInt(depth, 1<<uint(indexDeltaLength)),
+		framesPerPacket:  framesPerPacket,
+	}
+}
+
+func (p *aacPacketizer) Input(data []byte, timestamp uint32) error {
+	if len(data) >= 1<<uint(p.sizeLength) {
+		return fmt.Errorf("the aac frame is too large %d", len(data))
+	}
+
+	if p.depth < 2 || p.framesPerPacket < 1 {
+		p.writeFragments(data, timestamp, 0)
+		return nil
+	}
+
+	p.frames = append(p.frames, aacFrame{append([]byte(nil), data...), timestamp})
+	if len(p.frames) < p.depth*p.framesPerPacket {
+		return nil
+	}
+
+	for i := 0; i < p.depth; i++ {
+		var aus []aacFrame
+		for j := i; j < len(p.frames); j += p.depth {
+			aus = append(aus, p.frames[j])
+		}
+		p.writeInterleaved(aus, p.serial+i)
+	}
+	p.serial += len(p.frames)
+	p.frames = p.frames[:0]
+	return nil
+}
+
+// writeFragments 发送一个AU, 超过MTU时分片
+func (p *aacPacketizer) writeFragments(data []byte, timestamp uint32, index int) {
+	//AU-headers-length | AU-size | AU-Index
+	headersLength := p.sizeLength + p.indexLength
+	writer := utils.NewBitWriter()
+	writer.WriteBits(uint32(headersLength), 16)
+	writer.WriteBits(uint32(len(data)), p.sizeLength)
+	writer.WriteBits(uint32(index), p.indexLength)
+	header := writer.Bytes()
+
+	maxSize := p.maxPayloadSize() - len(header)
+	for len(data) > 0 {
+		size := utils.MinInt(len(data), maxSize)
+		p.write(timestamp, size == len(data), header, data[:size])
+		data = data[size:]
+	}
+}
+
+// writeInterleaved 发送间隔为depth的AU, 超过MTU时拆分为多个包
+// @index 第一个AU的序号
+func (p *aacPacketizer) writeInterleaved(aus []aacFrame, index int) {
+	for len(aus) > 0 {
+		//能放入一个包的AU数
+		n, size := 0, 0
+		for ; n < len(aus); n++ {
+			headersLength := p.sizeLength + p.indexLength + n*(p.sizeLength+p.indexDeltaLength)
+			if 2+(headersLength+7)/8+size+len(aus[n].data) > p.maxPayloadSize() {
+				break
+			}
+			size += len(aus[n].data)
+		}
+
+		if n == 0 {
+			p.writeFragments(aus[0].data, aus[0].timestamp, index)
+			index += p.depth
+			aus = aus[1:]
+			continue
+		}
+
+		headersLength := p.sizeLength + p.indexLength + (n-1)*(p.sizeLength+p.indexDeltaLength)
+		writer := utils.NewBitWriter()
+		writer.WriteBits(uint32(headersLength), 16)
+		for i, au := range aus[:n] {
+			writer.WriteBits(uint32(len(au.data)), p.sizeLength)
+			if i == 0 {
+				writer.WriteBits(uint32(index), p.indexLength)
+			} else {
+				writer.WriteBits(uint32(p.depth-1), p.indexDeltaLength)
+			}
+		}
+
+		payloads := [][]byte{writer.Bytes()}
+		for _, au := range aus[:n] {
+			payloads = append(payloads, au.data)
+		}
+		p.write(aus[0].timestamp, true, payloads...)
+		index += n * p.depth
+		aus = aus[n:]
+	}
+}
+
+// AACFmtp 生成mpeg4-generic的fmtp参数, 不包含payload type
+func AACFmtp(config *utils.MPEG4AudioConfig, sizeLength, indexLength int) string {
+	params := AACParameters{Config: config, SizeLength: sizeLength, IndexLength: indexLength, IndexDeltaLength: indexLength}
+	return params.Fmtp()
+}
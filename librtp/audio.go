@@ -0,0 +1,191 @@
+package librtp
+
+import (
+	"fmt"
+)
+
+const (
+	// DefaultPtime RFC 3551 4.2 基于采样的音频默认每包20ms
+	DefaultPtime = 20
+	// OpusClockRate RFC 7587 Opus的RTP时钟固定为48000, 与实际采样率无关
+	OpusClockRate = 48000
+)
+
+// sampleAudioPacketizer RFC 3551 4.3 基于采样的音频(G.711/G.722/G.726/L16), 按ptime拆分或合并输入的数据.
+// 不足一个包的数据等待下次输入, 时间戳不连续时先发送
+type sampleAudioPacketizer struct {
+	packetWriter
+	bitsPerSample int
+	packetSize    int //每个包的字节数
+	pending       []byte
+	timestamp     uint32 //pending中第一个采样的时间戳
+}
+
+// NewSampleAudioPacketizer 输入任意长度的编码数据, 时间戳为RTP时钟
+// @bitsPerSample 每个RTP时钟周期的位数. G.711为8; G.722的采样率为16000, 但RTP时钟为8000, 因此也为8; G.726-32为4; L16双声道为32
+// @ptime 每个包的时长, 单位毫秒. <=0时为20ms
+func NewSampleAudioPacketizer(pt int, ssrc uint32, clockRate, bitsPerSample, ptime int, handler encodeHandler) Packetizer {
+	if ptime <= 0 {
+		ptime = DefaultPtime
+	}
+
+	p := &sampleAudioPacketizer{packetWriter: newPacketWriter(pt, ssrc, handler), bitsPerSample: bitsPerSample}
+	//8个采样对齐字节边界
+	samples := clockRate * ptime / 1000
+	if limit := p.maxPayloadSize() * 8 / bitsPerSample; samples > limit {
+		samples = limit
+	}
+	if samples = samples / 8 * 8; samples < 8 {
+		samples = 8
+	}
+	p.packetSize = samples * bitsPerSample / 8
+	return p
+}
+
+// samples 数据的时长, 单位为RTP时钟
+func (p *sampleAudioPacketizer) samples(data []byte) uint32 {
+	return uint32(len(data) * 8 / p.bitsPerSample)
+}
+
+func (p *sampleAudioPacketizer) Input(data []byte, timestamp uint32) error {
+	if len(data)*8%p.bitsPerSample != 0 {
+		return fmt.Errorf("the audio data length %d is not a multiple of the sample size", len(data))
+	}
+
+	if len(p.pending) > 0 && p.timestamp+p.samples(p.pending) != timestamp {
+		p.write(p.timestamp, false, p.pending)
+		p.pending = p.pending[:0]
+	}
+	if len(p.pending) == 0 {
+		p.timestamp = timestamp
+	}
+
+	//先补齐pending中的包
+	if len(p.pending) > 0 {
+		n := p.packetSize - len(p.pending)
+		if n > len(data) {
+			n = len(data)
+		}
+		p.pending = append(p.pending, data[:n]...)
+		data = data[n:]
+		if len(p.pending) < p.packetSize {
+			return nil
+		}
+
+		p.write(p.timestamp, false, p.pending)
+		p.timestamp += p.samples(p.pending)
+		p.pending = p.pending[:0]
+	}
+
+	for len(data) >= p.packetSize {
+		p.write(p.timestamp, false, data[:p.packetSize])
+		p.timestamp += p.samples(data[:p.packetSize])
+		data = data[p.packetSize:]
+	}
+	p.pending = append(p.pending, data...)
+	return nil
+}
+
+// OpusPacketSamples RFC 6716 3.1 根据TOC计算Opus包的采样数, 采样率为48000
+func OpusPacketSamples(packet []byte) (int, error) {
+	if len(packet) < 1 {
+		return 0, fmt.Errorf("empty opus packet")
+	}
+
+	//每帧的时长, 单位为48KHz的采样
+	var frameSize int
+	config := int(packet[0] >> 3)
+	if config < 12 {
+		//SILK 10/20/40/60ms
+		frameSize = []int{480, 960, 1920, 2880}[config&0x3]
+	} else if config < 16 {
+		//Hybrid 10/20ms
+		frameSize = []int{480, 960}[config&0x1]
+	} else {
+		//CELT 2.5/5/10/20ms
+		frameSize = []int{120, 240, 480, 960}[config&0x3]
+	}
+
+	var frames int
+	switch packet[0] & 0x3 {
+	case 0:
+		frames = 1
+		break
+	case 1, 2:
+		frames = 2
+		break
+	case 3:
+		if len(packet) < 2 {
+			return 0, fmt.Errorf("invalid opus code 3 packet")
+		}
+		frames = int(packet[1] & 0x3F)
+		break
+	}
+
+	//一个包最多120ms
+	if frames == 0 || frames*frameSize > 5760 {
+		return 0, fmt.Errorf("invalid opus frame count %d", frames)
+	}
+	return frames * frameSize, nil
+}
+
+// opusPacketizer RFC 7587, 每个RTP包一个Opus包, RTP时钟固定为48000
+type opusPacketizer struct {
+	packetWriter
+}
+
+// NewOpusPacketizer 输入编码器输出的Opus包, 时间戳为48KHz
+func NewOpusPacketizer(pt int, ssrc uint32, handler encodeHandler) Packetizer {
+	return &opusPacketizer{newPacketWriter(pt, ssrc, handler)}
+}
+
+func (p *opusPacketizer) Input(data []byte, timestamp uint32) error {
+	if _, err := OpusPacketSamples(data); err != nil {
+		return err
+	} else if len(data) > p.maxPayloadSize() {
+		return fmt.Errorf("the opus packet is too large %d", len(data))
+	}
+
+	p.write(timestamp, false, data)
+	return nil
+}
+
+// repackG726 按码字重新打包. RFC 3551的G726-xx从字节的低位开始打包,
+// ITU-T I.366.2的AAL2-G726-xx从字节的高位开始打包
+func repackG726(data []byte, bitsPerSample int, fromBigEndian bool) []byte {
+	dst := make([]byte, len(data))
+	count := len(data) * 8 / bitsPerSample
+
+	for i := 0; i < count; i++ {
+		//第i个码字的第j位在比特流中的位置为i*bitsPerSample+j
+		codeword := 0
+		for j := 0; j < bitsPerSample; j++ {
+			position := i*bitsPerSample + j
+			if fromBigEndian {
+				codeword |= int(data[position/8]>>uint(7-position%8)&1) << uint(bitsPerSample-1-j)
+			} else {
+				codeword |= int(data[position/8]>>uint(position%8)&1) << uint(j)
+			}
+		}
+
+		for j := 0; j < bitsPerSample; j++ {
+			position := i*bitsPerSample + j
+			if fromBigEndian {
+				dst[position/8] |= byte(codeword>>uint(j)&1) << uint(position%8)
+			} else {
+				dst[position/8] |= byte(codeword>>uint(bitsPerSample-1-j)&1) << uint(7-position%8)
+			}
+		}
+	}
+	return dst
+}
+
+// G726ToLittleEndian AAL2-G726-xx(ITU-T打包)转换为G726-xx(RFC 3551打包)
+func G726ToLittleEndian(data []byte, bitsPerSample int) []byte {
+	return repackG726(data, bitsPerSample, true)
+}
+
+// G726ToBigEndian G726-xx(RFC 3551打包)转换为AAL2-G726-xx(ITU-T打包)
+func G726ToBigEndian(data []byte, bitsPerSample int) []byte {
+	return repackG726(data, bitsPerSample, false)
+}
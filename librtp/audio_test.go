@@ -0,0 +1,146 @@
+package librtp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSampleAudioPacketizer(t *testing.T) {
+	type packet struct {
+		timestamp uint32
+		length    int
+	}
+
+	var packets []packet
+	//G.722 RTP时钟8000, 20ms为160字节
+	packetizer := NewSampleAudioPacketizer(9, 0x12345678, 8000, 8, 20, func(data []byte, timestamp uint32) {
+		packets = append(packets, packet{timestamp, len(data) - FixedHeaderLength})
+	})
+
+	//100+300字节连续输入, 输出2个160字节的包, 剩余80字节等待
+	_ = packetizer.Input(make([]byte, 100), 1000)
+	_ = packetizer.Input(make([]byte, 300), 1100)
+	if len(packets) != 2 || packets[0] != (packet{1000, 160}) || packets[1] != (packet{1160, 160}) {
+		t.Fatalf("unexpected packets %v", packets)
+	}
+
+	//时间戳不连续时先发送剩余的数据
+	_ = packetizer.Input(make([]byte, 160), 5000)
+	if len(packets) != 4 || packets[2] != (packet{1320, 80}) || packets[3] != (packet{5000, 160}) {
+		t.Fatalf("unexpected packets %v", packets)
+	}
+
+	//L16双声道44100Hz, 20ms超过MTU
+	packets = nil
+	packetizer = NewSampleAudioPacketizer(10, 0x12345678, 44100, 32, 20, func(data []byte, timestamp uint32) {
+		packets = append(packets, packet{timestamp, len(data) - FixedHeaderLength})
+	})
+	if err := packetizer.Input(make([]byte, 3), 0); err == nil {
+		t.Fatalf("the l16 stereo sample is 4 bytes")
+	}
+	_ = packetizer.Input(make([]byte, 882*4), 0)
+	if len(packets) != 2 || packets[0].length > PacketMaxSize-FixedHeaderLength || packets[0].length%32 != 0 || packets[1].timestamp != uint32(packets[0].length/4) {
+		t.Fatalf("unexpected packets %v", packets)
+	}
+}
+
+func TestG726BitOrder(t *testing.T) {
+	//G726-32: 码字1,2 -> 0x21(RFC 3551) 0x12(AAL2)
+	if data := G726ToBigEndian([]byte{0x21, 0x43}, 4); !bytes.Equal(data, []byte{0x12, 0x34}) {
+		t.Fatalf("unexpected g726-32 data % x", data)
+	}
+
+	//G726-24: 8个码字0-7
+	big := []byte{0x05, 0x39, 0x77}
+	little := G726ToLittleEndian(big, 3)
+	if !bytes.Equal(little, []byte{0x88, 0xC6, 0xFA}) {
+		t.Fatalf("unexpected g726-24 data % x", little)
+	} else if data := G726ToBigEndian(little, 3); !bytes.Equal(data, big) {
+		t.Fatalf("unexpected g726-24 data % x", data)
+	}
+}
+
+func TestOpusPacketSamples(t *testing.T) {
+	tests := []struct {
+		packet  []byte
+		samples int
+	}{
+		{[]byte{0x78}, 960},              //config 15, hybrid FB 20ms
+		{[]byte{0xF8 | 0x1}, 1920},       //config 31, CELT 20ms, 2帧
+		{[]byte{0x18 | 0x3, 0x02}, 5760}, //config 3, SILK 60ms, 2帧
+	}
+	for _, test := range tests {
+		if samples, err := OpusPacketSamples(test.packet); err != nil || samples != test.samples {
+			t.Fatalf("unexpected samples %d of % x", samples, test.packet)
+		}
+	}
+
+	//超过120ms
+	if _, err := OpusPacketSamples([]byte{0x18 | 0x3, 0x03}); err == nil {
+		t.Fatalf("the opus packet is longer than 120ms")
+	}
+	if err := NewOpusPacketizer(111, 1, func(data []byte, timestamp uint32) {}).Input(nil, 0); err == nil {
+		t.Fatalf("empty opus packet")
+	}
+}
+
+func TestMPAPacketizer(t *testing.T) {
+	//MPEG-1 Layer II 128kbps 48000Hz, 384字节
+	small := make([]byte, 384)
+	copy(small, []byte{0xFF, 0xFD, 0x84, 0xC4})
+	//MPEG-1 Layer II 384kbps 32000Hz, 1728字节
+	large := make([]byte, 1728)
+	copy(large, []byte{0xFF, 0xFD, 0xE8, 0xC4})
+	for i := 4; i < len(large); i++ {
+		large[i] = byte(i)
+	}
+
+	var frames []frame
+	depacketizer := NewMPADepacketizer(func(data []byte, timestamp uint32, keyFrame bool) {
+		frames = append(frames, frame{data, timestamp, keyFrame})
+	})
+	var packets [][]byte
+	packetizer := NewMPAPacketizer(14, 0x12345678, func(data []byte, timestamp uint32) {
+		packets = append(packets, append([]byte(nil), data...))
+	})
+
+	var input []byte
+	for i := 0; i < 5; i++ {
+		input = append(input, small...)
+	}
+	if err := packetizer.Input(append(input, large...), 90000); err != nil {
+		t.Fatal(err)
+	}
+	if err := packetizer.Input(small[:100], 0); err == nil {
+		t.Fatalf("the mpeg audio frame is incomplete")
+	}
+
+	//3帧+2帧+2个分片
+	if len(packets) != 4 {
+		t.Fatalf("unexpected packet count %d", len(packets))
+	}
+	for _, packet := range packets {
+		if err := depacketizer.Input(packet); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(frames) != 6 || !bytes.Equal(frames[5].data, large) {
+		t.Fatalf("unexpected frames %d", len(frames))
+	}
+	for i, f := range frames {
+		if f.timestamp != 90000+uint32(i)*2160 {
+			t.Fatalf("unexpected timestamp %d of frame %d", f.timestamp, i)
+		}
+	}
+
+	//丢失第一个分片
+	frames = nil
+	depacketizer = NewMPADepacketizer(func(data []byte, timestamp uint32, keyFrame bool) {
+		frames = append(frames, frame{data, timestamp, keyFrame})
+	})
+	_ = depacketizer.Input(packets[0])
+	if err := depacketizer.Input(packets[3]); err == nil || len(frames) != 3 {
+		t.Fatalf("the fragment without the first part is accepted")
+	}
+}
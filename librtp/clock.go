@@ -0,0 +1,40 @@
+package librtp
+
+import (
+	"avformat/librtcp"
+	"time"
+)
+
+// SenderClock 根据RTCP SR将RTP时间戳映射为发送端的NTP时间
+type SenderClock struct {
+	clockRate int
+	ntpTime   time.Time
+	rtpTime   uint32
+	valid     bool
+}
+
+func NewSenderClock(clockRate int) *SenderClock {
+	return &SenderClock{clockRate: clockRate}
+}
+
+// Update 收到SR时更新映射关系
+func (c *SenderClock) Update(ntp uint64, rtpTime uint32) {
+	c.ntpTime = librtcp.FromNtpTime(ntp)
+	c.rtpTime = rtpTime
+	c.valid = true
+}
+
+// Valid 是否收到过SR
+func (c *SenderClock) Valid() bool {
+	return c.valid && c.clockRate > 0
+}
+
+// Time 时间戳对应的发送端绝对时间. 时间戳和SR的间隔不能超过2^31
+func (c *SenderClock) Time(timestamp uint32) (time.Time, bool) {
+	if !c.Valid() {
+		return time.Time{}, false
+	}
+
+	delta := int64(int32(timestamp - c.rtpTime))
+	return c.ntpTime.Add(time.Duration(delta * int64(time.Second) / int64(c.clockRate))), true
+}
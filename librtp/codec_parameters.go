@@ -0,0 +1,268 @@
+package librtp
+
+import (
+	"avformat/libavc"
+	"avformat/libhevc"
+	"avformat/librtsp/sdp"
+	"avformat/utils"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// SDP媒体描述和解码器参数的相互转换
+
+// newMediaDescription m=<media> 0 RTP/AVP, 端口由SETUP协商
+func newMediaDescription(media string) *sdp.MediaDescription {
+	return &sdp.MediaDescription{
+		MediaName: sdp.MediaName{Media: media, Port: sdp.RangedPort{Value: 0}, Protos: []string{"RTP", "AVP"}},
+	}
+}
+
+// H264Parameters RFC 6184 8.1
+type H264Parameters struct {
+	ProfileLevelId    [3]byte //profile_idc, profile-iop, level_idc
+	PacketizationMode int
+	SPS               [][]byte //不带起始码
+	PPS               [][]byte
+}
+
+// NewH264Parameters 读取avcC, packetization-mode为1
+func NewH264Parameters(avcC []byte) (*H264Parameters, error) {
+	config, err := libavc.ParseDecoderConfigurationRecord(avcC)
+	if err != nil {
+		return nil, err
+	} else if len(config.Sps) == 0 || len(config.Pps) == 0 {
+		return nil, fmt.Errorf("missing sps or pps in the avcc")
+	}
+
+	return &H264Parameters{
+		ProfileLevelId:    [3]byte{config.Profile, config.Compatibility, config.Level},
+		PacketizationMode: 1,
+		SPS:               config.Sps,
+		PPS:               config.Pps,
+	}, nil
+}
+
+// H264Parameters 读取fmtp中的sprop-parameter-sets/profile-level-id/packetization-mode.
+// 没有profile-level-id时使用SPS中的值
+func (f *PayloadFormat) H264Parameters() (*H264Parameters, error) {
+	if f.CodecId != utils.AVCodecIdH264 {
+		return nil, fmt.Errorf("the payload type %d is not h264", f.PayloadType)
+	}
+
+	params := &H264Parameters{PacketizationMode: f.fmtpInt("packetization-mode", 0)}
+	for _, set := range strings.Split(f.Fmtp["sprop-parameter-sets"], ",") {
+		if set = strings.TrimSpace(set); set == "" {
+			continue
+		}
+
+		nalu, err := base64.StdEncoding.DecodeString(set)
+		if err != nil || len(nalu) == 0 {
+			return nil, fmt.Errorf("invalid parameter set %s", set)
+		}
+		switch nalu[0] & 0x1F {
+		case libavc.H264NalSPS:
+			params.SPS = append(params.SPS, nalu)
+			break
+		case libavc.H264NalPPS:
+			params.PPS = append(params.PPS, nalu)
+			break
+		}
+	}
+
+	if value, ok := f.Fmtp["profile-level-id"]; ok {
+		bytes, err := hex.DecodeString(value)
+		if err != nil || len(bytes) != 3 {
+			return nil, fmt.Errorf("invalid profile-level-id %s", value)
+		}
+		copy(params.ProfileLevelId[:], bytes)
+	} else if len(params.SPS) > 0 && len(params.SPS[0]) >= 4 {
+		copy(params.ProfileLevelId[:], params.SPS[0][1:4])
+	}
+	return params, nil
+}
+
+// Fmtp 生成fmtp参数, 不包含payload type
+func (p *H264Parameters) Fmtp() string {
+	sets := make([]string, 0, len(p.SPS)+len(p.PPS))
+	for _, set := range append(append([][]byte(nil), p.SPS...), p.PPS...) {
+		sets = append(sets, base64.StdEncoding.EncodeToString(set))
+	}
+	return fmt.Sprintf("packetization-mode=%d;profile-level-id=%s;sprop-parameter-sets=%s",
+		p.PacketizationMode, strings.ToUpper(hex.EncodeToString(p.ProfileLevelId[:])), strings.Join(sets, ","))
+}
+
+// MediaDescription 生成m=video和rtpmap/fmtp
+func (p *H264Parameters) MediaDescription(pt int) *sdp.MediaDescription {
+	return newMediaDescription("video").WithCodec(uint8(pt), "H264", 90000, 0, p.Fmtp())
+}
+
+// HEVCParameters RFC 7798 7.1
+type HEVCParameters struct {
+	VPS        [][]byte //不带起始码
+	SPS        [][]byte
+	PPS        [][]byte
+	MaxDonDiff int //sprop-max-don-diff, 大于0时负载携带DONL
+}
+
+// NewHEVCParameters 读取hvcC
+func NewHEVCParameters(hvcC []byte) (*HEVCParameters, error) {
+	vps, sps, pps, err := libhevc.ParseParameterSets(hvcC)
+	if err != nil {
+		return nil, err
+	} else if len(vps) == 0 || len(sps) == 0 || len(pps) == 0 {
+		return nil, fmt.Errorf("missing vps, sps or pps in the hvcc")
+	}
+	return &HEVCParameters{VPS: vps, SPS: sps, PPS: pps}, nil
+}
+
+// HEVCParameters 读取fmtp中的sprop-vps/sprop-sps/sprop-pps/sprop-max-don-diff
+func (f *PayloadFormat) HEVCParameters() (*HEVCParameters, error) {
+	if f.CodecId != utils.AVCodecIdHEVC {
+		return nil, fmt.Errorf("the payload type %d is not h265", f.PayloadType)
+	}
+
+	vps, sps, pps, err := HEVCParameterSets(f.Fmtp)
+	if err != nil {
+		return nil, err
+	}
+	return &HEVCParameters{VPS: vps, SPS: sps, PPS: pps, MaxDonDiff: HEVCMaxDonDiff(f.Fmtp)}, nil
+}
+
+// Fmtp 生成fmtp参数, 不包含payload type
+func (p *HEVCParameters) Fmtp() string {
+	fmtp := HEVCFmtpParameterSets(p.VPS, p.SPS, p.PPS)
+	if p.MaxDonDiff > 0 {
+		fmtp += fmt.Sprintf(";sprop-max-don-diff=%d", p.MaxDonDiff)
+	}
+	return fmtp
+}
+
+// MediaDescription 生成m=video和rtpmap/fmtp
+func (p *HEVCParameters) MediaDescription(pt int) *sdp.MediaDescription {
+	return newMediaDescription("video").WithCodec(uint8(pt), "H265", 90000, 0, p.Fmtp())
+}
+
+// AACParameters RFC 3640 mpeg4-generic或RFC 6416 MP4A-LATM
+type AACParameters struct {
+	Config *utils.MPEG4AudioConfig
+	LATM   bool
+	//mpeg4-generic的AU头
+	SizeLength       int
+	IndexLength      int
+	IndexDeltaLength int
+}
+
+// NewAACParameters 读取AudioSpecificConfig, mpeg4-generic使用AAC-hbr
+func NewAACParameters(asc []byte, latm bool) (*AACParameters, error) {
+	if len(asc) < 2 {
+		return nil, fmt.Errorf("invalid audio specific config length %d", len(asc))
+	}
+
+	config, err := utils.ParseMpeg4AudioConfig(asc)
+	if err != nil {
+		return nil, err
+	}
+
+	params := &AACParameters{Config: config, LATM: latm}
+	if !latm {
+		params.SizeLength, params.IndexLength, params.IndexDeltaLength = 13, 3, 3
+	}
+	return params, nil
+}
+
+// AACParameters 读取fmtp中的config/sizelength/indexlength/indexdeltalength
+func (f *PayloadFormat) AACParameters() (*AACParameters, error) {
+	if f.CodecId != utils.AVCodecIdAAC {
+		return nil, fmt.Errorf("the payload type %d is not aac", f.PayloadType)
+	} else if f.AudioConfig == nil {
+		return nil, fmt.Errorf("missing the config of the payload type %d", f.PayloadType)
+	}
+
+	params := &AACParameters{Config: f.AudioConfig, LATM: f.Encoding == "MP4A-LATM"}
+	if !params.LATM {
+		params.SizeLength = f.fmtpInt("sizelength", 0)
+		params.IndexLength = f.fmtpInt("indexlength", 0)
+		params.IndexDeltaLength = f.fmtpInt("indexdeltalength", 0)
+		if params.SizeLength <= 0 {
+			return nil, fmt.Errorf("the sizelength of mpeg4-generic is required")
+		}
+	}
+	return params, nil
+}
+
+// Fmtp 生成fmtp参数, 不包含payload type
+func (p *AACParameters) Fmtp() string {
+	if p.LATM {
+		return LATMFmtp(p.Config)
+	}
+
+	mode := "AAC-hbr"
+	if p.SizeLength == 6 {
+		mode = "AAC-lbr"
+	}
+	return fmt.Sprintf("streamtype=5;profile-level-id=1;mode=%s;sizelength=%d;indexlength=%d;indexdeltalength=%d;config=%s",
+		mode, p.SizeLength, p.IndexLength, p.IndexDeltaLength, hex.EncodeToString(p.Config.ToBytes()))
+}
+
+// MediaDescription 生成m=audio和rtpmap/fmtp, RTP时钟为采样率
+func (p *AACParameters) MediaDescription(pt int) *sdp.MediaDescription {
+	name := "MPEG4-GENERIC"
+	if p.LATM {
+		name = "MP4A-LATM"
+	}
+	return newMediaDescription("audio").WithCodec(uint8(pt), name, uint32(p.Config.SampleRate), uint16(p.Config.Channels), p.Fmtp())
+}
+
+// AudioParameters G.711/G.722/Opus的采样率和声道数
+type AudioParameters struct {
+	CodecId    utils.AVCodecID
+	SampleRate int
+	Channels   int
+}
+
+// AudioParameters Opus的rtpmap固定为opus/48000/2, 声道数由sprop-stereo决定
+func (f *PayloadFormat) AudioParameters() (*AudioParameters, error) {
+	params := &AudioParameters{CodecId: f.CodecId, SampleRate: f.SampleRate, Channels: f.Channels}
+	switch f.CodecId {
+	case utils.AVCodecIdPCMMULAW, utils.AVCodecIdPCMALAW, utils.AVCodecIdADPCMG722:
+		break
+	case utils.AVCodecIdOPUS:
+		params.Channels = 1
+		if f.Fmtp["sprop-stereo"] == "1" {
+			params.Channels = 2
+		}
+		break
+	default:
+		return nil, fmt.Errorf("unsupported audio codec %d", f.CodecId)
+	}
+	return params, nil
+}
+
+// MediaDescription 生成m=audio和rtpmap/fmtp. 8000Hz单声道的G.711使用静态负载类型时仍然生成rtpmap
+func (p *AudioParameters) MediaDescription(pt int) (*sdp.MediaDescription, error) {
+	md := newMediaDescription("audio")
+	channels := uint16(0)
+	if p.Channels > 1 {
+		channels = uint16(p.Channels)
+	}
+
+	switch p.CodecId {
+	case utils.AVCodecIdPCMMULAW:
+		return md.WithCodec(uint8(pt), "PCMU", uint32(p.SampleRate), channels, ""), nil
+	case utils.AVCodecIdPCMALAW:
+		return md.WithCodec(uint8(pt), "PCMA", uint32(p.SampleRate), channels, ""), nil
+	case utils.AVCodecIdADPCMG722:
+		//RFC 3551 4.5.2 采样率为16000, RTP时钟为8000
+		return md.WithCodec(uint8(pt), "G722", 8000, channels, ""), nil
+	case utils.AVCodecIdOPUS:
+		fmtp := ""
+		if p.Channels == 2 {
+			fmtp = "sprop-stereo=1;stereo=1"
+		}
+		return md.WithCodec(uint8(pt), "opus", OpusClockRate, 2, fmtp), nil
+	}
+	return nil, fmt.Errorf("unsupported audio codec %d", p.CodecId)
+}
@@ -0,0 +1,136 @@
+package librtp
+
+import (
+	"avformat/librtsp/sdp"
+	"avformat/utils"
+	"bytes"
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// profileOf 序列化后重新解析媒体描述
+func profileOf(t *testing.T, md *sdp.MediaDescription) *PayloadFormat {
+	var sd sdp.SessionDescription
+	description := "v=0\r\no=- 0 0 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\n"
+	data, err := (&sdp.SessionDescription{}).WithMedia(md).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	} else if err = sd.Unmarshal(append([]byte(description), data[strings.Index(string(data), "m="):]...)); err != nil {
+		t.Fatal(err)
+	}
+
+	profile, err := NewProfile(sd.MediaDescriptions[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return profile.Formats[0]
+}
+
+func TestH264Parameters(t *testing.T) {
+	sps, _ := base64.StdEncoding.DecodeString("Z0IAKeKQFAe2AtwEBAaQeJEV")
+	pps, _ := base64.StdEncoding.DecodeString("aM48gA==")
+	avcC := append([]byte{1, sps[1], sps[2], sps[3], 0xFF, 0xE1, 0, byte(len(sps))}, sps...)
+	avcC = append(append(avcC, 1, 0, byte(len(pps))), pps...)
+
+	params, err := NewH264Parameters(avcC)
+	if err != nil {
+		t.Fatal(err)
+	} else if params.Fmtp() != "packetization-mode=1;profile-level-id=420029;sprop-parameter-sets=Z0IAKeKQFAe2AtwEBAaQeJEV,aM48gA==" {
+		t.Fatalf("unexpected fmtp %s", params.Fmtp())
+	}
+
+	format := profileOf(t, params.MediaDescription(96))
+	if parsed, err := format.H264Parameters(); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(parsed, params) {
+		t.Fatalf("unexpected h264 parameters %+v", parsed)
+	}
+
+	if _, err = NewH264Parameters(avcC[:10]); err == nil {
+		t.Fatalf("the avcc is truncated")
+	} else if _, err = format.AACParameters(); err == nil {
+		t.Fatalf("the h264 format has no aac parameters")
+	}
+}
+
+func TestHEVCParameters(t *testing.T) {
+	vps, sps, pps := []byte{0x40, 0x01, 0x0C}, []byte{0x42, 0x01, 0x01, 0x60}, []byte{0x44, 0x01, 0xC1}
+	hvcC := append(make([]byte, 22), 3)
+	for _, nalu := range [][]byte{vps, sps, pps} {
+		hvcC = append(append(hvcC, nalu[0]>>1, 0, 1, 0, byte(len(nalu))), nalu...)
+	}
+
+	params, err := NewHEVCParameters(hvcC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params.MaxDonDiff = 2
+
+	if parsed, err := profileOf(t, params.MediaDescription(97)).HEVCParameters(); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(parsed, params) {
+		t.Fatalf("unexpected h265 parameters %+v", parsed)
+	}
+
+	if _, err = NewHEVCParameters(hvcC[:len(hvcC)-1]); err == nil {
+		t.Fatalf("the hvcc is truncated")
+	}
+}
+
+func TestAACParameters(t *testing.T) {
+	if _, err := NewAACParameters([]byte{0x12}, false); err == nil {
+		t.Fatalf("the audio specific config is truncated")
+	}
+
+	for _, latm := range []bool{false, true} {
+		params, err := NewAACParameters([]byte{0x12, 0x10}, latm)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		format := profileOf(t, params.MediaDescription(98))
+		if format.ClockRate != 44100 || format.Channels != 2 {
+			t.Fatalf("unexpected aac format %+v", format)
+		} else if parsed, err := format.AACParameters(); err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(parsed.Config.ToBytes(), []byte{0x12, 0x10}) || parsed.LATM != latm || parsed.SizeLength != params.SizeLength || parsed.IndexDeltaLength != params.IndexDeltaLength {
+			t.Fatalf("unexpected aac parameters %+v", parsed)
+		}
+	}
+}
+
+func TestAudioParameters(t *testing.T) {
+	tests := []struct {
+		params    AudioParameters
+		pt        int
+		clockRate int
+	}{
+		{AudioParameters{utils.AVCodecIdPCMMULAW, 8000, 1}, 0, 8000},
+		{AudioParameters{utils.AVCodecIdPCMALAW, 16000, 2}, 100, 16000},
+		{AudioParameters{utils.AVCodecIdADPCMG722, 16000, 1}, 9, 8000},
+		{AudioParameters{utils.AVCodecIdOPUS, 48000, 1}, 111, 48000},
+		{AudioParameters{utils.AVCodecIdOPUS, 48000, 2}, 111, 48000},
+	}
+
+	for _, test := range tests {
+		md, err := test.params.MediaDescription(test.pt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		format := profileOf(t, md)
+		if format.PayloadType != test.pt || format.ClockRate != test.clockRate {
+			t.Fatalf("unexpected format %+v", format)
+		} else if parsed, err := format.AudioParameters(); err != nil {
+			t.Fatal(err)
+		} else if *parsed != test.params {
+			t.Fatalf("unexpected audio parameters %+v", parsed)
+		}
+	}
+
+	if _, err := (&AudioParameters{CodecId: utils.AVCodecIdAAC}).MediaDescription(96); err == nil {
+		t.Fatalf("aac is not a simple audio codec")
+	}
+}
@@ -0,0 +1,93 @@
+package librtp
+
+// decodeHandler 回调一个完整的帧(access unit)
+type decodeHandler func(data []byte, timestamp uint32, keyFrame bool)
+
+// Depacketizer 将RTP负载还原为完整的帧
+type Depacketizer interface {
+	// Input 输入一个完整的RTP包(包含RTP头)
+	Input(packet []byte) error
+}
+
+// frameAssembler 按marker位和时间戳组帧. 检测到丢包时丢弃当前帧
+type frameAssembler struct {
+	handler   decodeHandler
+	buffer    []byte
+	timestamp uint32
+	seq       uint16
+	started   bool //已收到过包
+	lost      bool //当前帧不完整
+}
+
+// stale 重复或迟到的包
+func (f *frameAssembler) stale(h *Header) bool {
+	return f.started && int16(uint16(h.seq)-f.seq) <= 0
+}
+
+// checkPacket 检查序号连续性和时间戳. 时间戳变化时输出上一帧
+// @return false表示重复或迟到的包, 应丢弃
+func (f *frameAssembler) checkPacket(h *Header, keyFrame func([]byte) bool) bool {
+	if f.stale(h) {
+		return false
+	}
+
+	seq := uint16(h.seq)
+	gap := f.started && seq != f.seq+1
+	//无法确定丢失的包属于上一帧还是当前帧, 两帧都丢弃
+	if f.started && h.timestamp != f.timestamp {
+		if gap {
+			f.lost = true
+		}
+		f.flush(keyFrame)
+	}
+	if gap {
+		f.lost = true
+	}
+
+	f.started = true
+	f.seq = seq
+	f.timestamp = h.timestamp
+	return true
+}
+
+func (f *frameAssembler) write(data ...[]byte) {
+	for _, bytes := range data {
+		f.buffer = append(f.buffer, bytes...)
+	}
+}
+
+// flush 输出当前帧, 不完整的帧直接丢弃
+func (f *frameAssembler) flush(keyFrame func([]byte) bool) {
+	if len(f.buffer) > 0 && !f.lost {
+		frame := make([]byte, len(f.buffer))
+		copy(frame, f.buffer)
+		f.handler(frame, f.timestamp, keyFrame == nil || keyFrame(frame))
+	}
+
+	f.buffer = f.buffer[:0]
+	f.lost = false
+}
+
+// audioDepacketizer 每个RTP包都是一个或多个完整的音频帧, 例如G.711/Opus
+type audioDepacketizer struct {
+	handler decodeHandler
+}
+
+func (a *audioDepacketizer) Input(packet []byte) error {
+	h, payload, err := parseHeader(packet)
+	if err != nil {
+		return err
+	} else if len(payload) == 0 {
+		return nil
+	}
+
+	frame := make([]byte, len(payload))
+	copy(frame, payload)
+	a.handler(frame, h.timestamp, true)
+	return nil
+}
+
+// NewAudioDepacketizer 负载即帧的音频格式
+func NewAudioDepacketizer(handler decodeHandler) Depacketizer {
+	return &audioDepacketizer{handler: handler}
+}
@@ -0,0 +1,278 @@
+package librtp
+
+import (
+	"avformat/libavc"
+	"avformat/utils"
+	"bytes"
+	"testing"
+)
+
+func makePacket(seq uint16, timestamp uint32, marker bool, payload ...byte) []byte {
+	packet := make([]byte, FixedHeaderLength, FixedHeaderLength+len(payload))
+	packet[0] = 0x80
+	packet[1] = 96
+	if marker {
+		packet[1] |= 0x80
+	}
+	utils.WriteWORD(packet[2:], seq)
+	utils.WriteDWORD(packet[4:], timestamp)
+	utils.WriteDWORD(packet[8:], 0x12345678)
+	return append(packet, payload...)
+}
+
+type frame struct {
+	data      []byte
+	timestamp uint32
+	keyFrame  bool
+}
+
+func TestH264Depacketizer(t *testing.T) {
+	var frames []frame
+	depacketizer := NewH264Depacketizer(func(data []byte, timestamp uint32, keyFrame bool) {
+		frames = append(frames, frame{data, timestamp, keyFrame})
+	})
+
+	//STAP-A(SPS+PPS), FU-A(IDR)
+	packets := [][]byte{
+		makePacket(1, 3000, false, 0x78, 0x00, 0x02, 0x67, 0x42, 0x00, 0x02, 0x68, 0xCE),
+		makePacket(2, 3000, false, 0x7C, 0x85, 0x01, 0x02),
+		makePacket(3, 3000, true, 0x7C, 0x45, 0x03),
+		makePacket(4, 6000, true, 0x41, 0x9A, 0x01),
+	}
+	for _, packet := range packets {
+		if err := depacketizer.Input(packet); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(frames) != 2 {
+		t.Fatalf("expected 2 frames, got %d", len(frames))
+	}
+
+	expected := bytes.Join([][]byte{{}, {0x67, 0x42}, {0x68, 0xCE}, {0x65, 0x01, 0x02, 0x03}}, libavc.StartCode4)
+	if !bytes.Equal(frames[0].data, expected) || !frames[0].keyFrame || frames[0].timestamp != 3000 {
+		t.Fatalf("unexpected key frame % x", frames[0].data)
+	}
+	if frames[1].keyFrame || frames[1].timestamp != 6000 {
+		t.Fatalf("unexpected frame % x", frames[1].data)
+	}
+
+	//丢失FU-A的中间分片, 整帧丢弃
+	frames = nil
+	_ = depacketizer.Input(makePacket(5, 9000, false, 0x7C, 0x85, 0x01))
+	_ = depacketizer.Input(makePacket(7, 9000, true, 0x7C, 0x45, 0x03))
+	_ = depacketizer.Input(makePacket(8, 12000, true, 0x41, 0x9A, 0x01))
+	if len(frames) != 1 || frames[0].timestamp != 12000 {
+		t.Fatalf("the incomplete frame must be dropped. frames:%d", len(frames))
+	}
+}
+
+func TestH264DepacketizerInterleaved(t *testing.T) {
+	var frames []frame
+	depacketizer := NewH264Depacketizer(func(data []byte, timestamp uint32, keyFrame bool) {
+		frames = append(frames, frame{data, timestamp, keyFrame})
+	})
+
+	packets := [][]byte{
+		//STAP-B(DON=0, SPS+PPS), FU-B(DON=1)+FU-A(IDR)
+		makePacket(1, 3000, false, 0x79, 0x00, 0x00, 0x00, 0x02, 0x67, 0x42, 0x00, 0x02, 0x68, 0xCE),
+		makePacket(2, 3000, false, 0x7D, 0x85, 0x00, 0x01, 0x01, 0x02),
+		makePacket(2, 3000, false, 0x7D, 0x85, 0x00, 0x01, 0x01, 0x02),
+		makePacket(3, 3000, false, 0x7C, 0x45, 0x03),
+		//MTAP16(DONB=2), TS offset 0和3000的两个P帧
+		makePacket(4, 3000, true, 0x7A, 0x00, 0x02,
+			0x00, 0x05, 0x00, 0x00, 0x00, 0x41, 0x9A,
+			0x00, 0x05, 0x01, 0x0B, 0xB8, 0x41, 0x9B),
+	}
+	for _, packet := range packets {
+		if err := depacketizer.Input(packet); err != nil {
+			t.Fatal(err)
+		}
+	}
+	//迟到的包被丢弃
+	_ = depacketizer.Input(makePacket(3, 3000, false, 0x7C, 0x45, 0x03))
+
+	if len(frames) != 2 {
+		t.Fatalf("expected 2 frames, got %d", len(frames))
+	}
+	expected := bytes.Join([][]byte{{}, {0x67, 0x42}, {0x68, 0xCE}, {0x65, 0x01, 0x02, 0x03}, {0x41, 0x9A}}, libavc.StartCode4)
+	if !bytes.Equal(frames[0].data, expected) || !frames[0].keyFrame || frames[0].timestamp != 3000 {
+		t.Fatalf("unexpected key frame % x", frames[0].data)
+	}
+	if !bytes.Equal(frames[1].data, append(libavc.StartCode4, 0x41, 0x9B)) || frames[1].timestamp != 6000 {
+		t.Fatalf("unexpected frame % x", frames[1].data)
+	}
+
+	//FU-A未结束时收到其他NALU, 整帧丢弃
+	frames = nil
+	_ = depacketizer.Input(makePacket(5, 9000, false, 0x7C, 0x85, 0x01))
+	_ = depacketizer.Input(makePacket(6, 9000, true, 0x41, 0x9A, 0x01))
+	_ = depacketizer.Input(makePacket(7, 12000, true, 0x41, 0x9A, 0x01))
+	if len(frames) != 1 || frames[0].timestamp != 12000 {
+		t.Fatalf("the incomplete frame must be dropped. frames:%d", len(frames))
+	}
+}
+
+func TestHEVCDepacketizerDONL(t *testing.T) {
+	var frames []frame
+	depacketizer := NewHEVCDepacketizer(2, func(data []byte, timestamp uint32, keyFrame bool) {
+		frames = append(frames, frame{data, timestamp, keyFrame})
+	})
+
+	//传输顺序和解码顺序不同, DON 3的P帧在DON 2的IDR之前发送
+	packets := [][]byte{
+		//AP(DONL 0, VPS, DOND 0, SPS)
+		makePacket(1, 3000, false, 0x60, 0x01, 0x00, 0x00, 0x00, 0x03, 0x40, 0x01, 0x0C, 0x00, 0x00, 0x03, 0x42, 0x01, 0x01),
+		//Single NAL(DONL 3)
+		makePacket(2, 6000, true, 0x02, 0x01, 0x00, 0x03, 0xCC),
+		//FU(DONL 2, IDR_N_LP)
+		makePacket(3, 3000, false, 0x62, 0x01, 0x94, 0x00, 0x02, 0xAA),
+		makePacket(4, 3000, true, 0x62, 0x01, 0x54, 0xBB),
+		makePacket(5, 9000, true, 0x02, 0x01, 0x00, 0x04, 0xDD),
+		makePacket(6, 12000, true, 0x02, 0x01, 0x00, 0x05, 0xEE),
+		//迟到的NALU
+		makePacket(7, 3000, true, 0x02, 0x01, 0x00, 0x01, 0xFF),
+		makePacket(8, 15000, true, 0x02, 0x01, 0x00, 0x07, 0x11),
+	}
+	for _, packet := range packets {
+		if err := depacketizer.Input(packet); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//DON小于等于7-2的NALU已输出, 最后一个access unit等待下一个时间戳
+	if len(frames) != 3 {
+		t.Fatalf("expected 3 frames, got %d", len(frames))
+	}
+	expected := bytes.Join([][]byte{{}, {0x40, 0x01, 0x0C}, {0x42, 0x01, 0x01}, {0x28, 0x01, 0xAA, 0xBB}}, libavc.StartCode4)
+	if !bytes.Equal(frames[0].data, expected) || !frames[0].keyFrame || frames[0].timestamp != 3000 {
+		t.Fatalf("unexpected key frame % x", frames[0].data)
+	}
+	if !bytes.Equal(frames[1].data, append(libavc.StartCode4, 0x02, 0x01, 0xCC)) || frames[1].keyFrame || frames[1].timestamp != 6000 {
+		t.Fatalf("unexpected frame % x", frames[1].data)
+	}
+	if !bytes.Equal(frames[2].data, append(libavc.StartCode4, 0x02, 0x01, 0xDD)) || frames[2].timestamp != 9000 {
+		t.Fatalf("unexpected frame % x", frames[2].data)
+	}
+}
+
+func TestAACDepacketizer(t *testing.T) {
+	var frames []frame
+	depacketizer := NewAACDepacketizer(13, 3, 3, func(data []byte, timestamp uint32, keyFrame bool) {
+		frames = append(frames, frame{data, timestamp, keyFrame})
+	})
+
+	//2个AU, 长度分别为2和3
+	packet := makePacket(1, 1000, true, 0x00, 0x20, 0x00, 0x10, 0x00, 0x18, 0xA1, 0xA2, 0xB1, 0xB2, 0xB3)
+	if err := depacketizer.Input(packet); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(frames) != 2 || !bytes.Equal(frames[0].data, []byte{0xA1, 0xA2}) || !bytes.Equal(frames[1].data, []byte{0xB1, 0xB2, 0xB3}) {
+		t.Fatalf("unexpected frames %v", frames)
+	} else if frames[1].timestamp != 1000+AACFrameSize {
+		t.Fatalf("unexpected timestamp %d", frames[1].timestamp)
+	}
+
+	//一个AU分为2个包
+	frames = nil
+	_ = depacketizer.Input(makePacket(2, 3048, false, 0x00, 0x10, 0x00, 0x20, 0x01, 0x02))
+	_ = depacketizer.Input(makePacket(3, 3048, true, 0x00, 0x10, 0x00, 0x20, 0x03, 0x04))
+	if len(frames) != 1 || !bytes.Equal(frames[0].data, []byte{0x01, 0x02, 0x03, 0x04}) {
+		t.Fatalf("unexpected fragmented frame %v", frames)
+	}
+}
+
+func TestJPEGDepacketizer(t *testing.T) {
+	var frames []frame
+	depacketizer := NewJPEGDepacketizer(func(data []byte, timestamp uint32, keyFrame bool) {
+		frames = append(frames, frame{data, timestamp, keyFrame})
+	})
+
+	if len(jpegLumAcSymbols) != 162 || len(jpegChmAcSymbols) != 162 {
+		t.Fatalf("invalid huffman tables")
+	}
+
+	//type 1, Q 50, 16x16
+	_ = depacketizer.Input(makePacket(1, 0, false, 0, 0, 0, 0, 1, 50, 2, 2, 0x11, 0x22))
+	_ = depacketizer.Input(makePacket(2, 0, true, 0, 0, 0, 2, 1, 50, 2, 2, 0x33))
+	if len(frames) != 1 {
+		t.Fatalf("expected 1 frame, got %d", len(frames))
+	}
+
+	image := frames[0].data
+	if !bytes.HasPrefix(image, []byte{0xFF, 0xD8}) || !bytes.HasSuffix(image, []byte{0x11, 0x22, 0x33, 0xFF, 0xD9}) {
+		t.Fatalf("invalid jpeg image % x", image)
+	}
+	//SOF0 height/width/4:2:0
+	sof := bytes.Index(image, []byte{0xFF, 0xC0})
+	if sof < 0 || image[sof+5] != 0 || image[sof+6] != 16 || image[sof+8] != 16 || image[sof+11] != 0x22 {
+		t.Fatalf("invalid SOF0 % x", image[sof:sof+19])
+	}
+	//Q=50时使用标准量化表
+	dqt := bytes.Index(image, []byte{0xFF, 0xDB})
+	if image[dqt+5] != 16 || image[dqt+6] != 11 {
+		t.Fatalf("invalid DQT % x", image[dqt:dqt+8])
+	}
+}
+
+func TestAACDepacketizerInterleaved(t *testing.T) {
+	//初始时间戳是随机的, 包括大于2^31的情况
+	for _, base := range []uint32{0, 5000, 0x90000000} {
+		var frames []frame
+		depacketizer := NewAACDepacketizer(13, 3, 3, func(data []byte, timestamp uint32, keyFrame bool) {
+			frames = append(frames, frame{data, timestamp, keyFrame})
+		})
+
+		//AU-size=1, 第一个包AU 0,2, 第二个包AU 1,3
+		packets := [][]byte{
+			makePacket(1, base, true, 0x00, 0x20, 0x00, 0x08, 0x00, 0x09, 0xA0, 0xA2),
+			makePacket(2, base+1024, true, 0x00, 0x20, 0x00, 0x09, 0x00, 0x09, 0xA1, 0xA3),
+		}
+		for _, packet := range packets {
+			if err := depacketizer.Input(packet); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if len(frames) != 4 {
+			t.Fatalf("base %d: expected 4 frames, got %d", base, len(frames))
+		}
+		for i, f := range frames {
+			if f.timestamp != base+uint32(i*AACFrameSize) || f.data[0] != byte(0xA0+i) {
+				t.Fatalf("base %d: unexpected frame %d % x %d", base, i, f.data, f.timestamp)
+			}
+		}
+	}
+}
+
+func TestLATM(t *testing.T) {
+	//44100Hz 双声道 AAC-LC
+	config, err := ParseStreamMuxConfig([]byte{0x40, 0x00, 0x24, 0x20, 0x3F, 0xC0})
+	if err != nil {
+		t.Fatal(err)
+	} else if config.NumSubFrames != 0 || config.AudioConfig.SampleRate != 44100 || config.AudioConfig.Channels != 2 || config.AudioConfig.ObjectType != 2 {
+		t.Fatalf("unexpected config %+v", config.AudioConfig)
+	}
+	if !bytes.Equal(WriteStreamMuxConfig(config.AudioConfig), []byte{0x40, 0x00, 0x24, 0x20, 0x3F, 0xC0}) {
+		t.Fatalf("write StreamMuxConfig failed % x", WriteStreamMuxConfig(config.AudioConfig))
+	}
+
+	var frames []frame
+	depacketizer := NewLATMDepacketizer(config, func(data []byte, timestamp uint32, keyFrame bool) {
+		frames = append(frames, frame{data, timestamp, keyFrame})
+	})
+	packetizer := NewLATMPacketizer(96, 0x12345678, func(data []byte, timestamp uint32) {
+		if err := depacketizer.Input(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	small := bytes.Repeat([]byte{0x21}, 300)
+	large := bytes.Repeat([]byte{0x01, 0x02}, 1000)
+	_ = packetizer.Input(small, 1024)
+	_ = packetizer.Input(large, 2048)
+	if len(frames) != 2 || !bytes.Equal(frames[0].data, small) || !bytes.Equal(frames[1].data, large) || frames[1].timestamp != 2048 {
+		t.Fatalf("the latm frames are different")
+	}
+}
@@ -0,0 +1,269 @@
+package librtp
+
+import (
+	"avformat/librtcp"
+	"avformat/librtsp/sdp"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// RFC 8285 header extension profile
+const (
+	ExtensionProfileOneByte = 0xBEDE
+	ExtensionProfileTwoByte = 0x1000
+)
+
+// HeaderExtension RFC 8285 的一个扩展元素
+type HeaderExtension struct {
+	ID      int
+	Payload []byte
+}
+
+// isTwoByteProfile 0x100X, 低4位为appbits
+func isTwoByteProfile(profile uint16) bool {
+	return profile&0xFFF0 == ExtensionProfileTwoByte
+}
+
+// HeaderExtensions 解析RFC 8285的扩展元素, 其他profile返回错误
+func (h *Header) HeaderExtensions() ([]HeaderExtension, error) {
+	if h.x == 0 {
+		return nil, nil
+	}
+
+	oneByte := h.extensionProfile == ExtensionProfileOneByte
+	if !oneByte && !isTwoByteProfile(h.extensionProfile) {
+		return nil, fmt.Errorf("unsupported extension profile 0x%x", h.extensionProfile)
+	}
+
+	var extensions []HeaderExtension
+	data := h.extension
+	for offset := 0; offset < len(data); {
+		//padding
+		if data[offset] == 0 {
+			offset++
+			continue
+		}
+
+		var id, length int
+		if oneByte {
+			//ID(4bits) | L(4bits), 长度为L+1. ID为15时停止解析
+			id, length = int(data[offset]>>4), int(data[offset]&0xF)+1
+			if id == 15 {
+				break
+			}
+			offset++
+		} else {
+			//ID(8bits) | L(8bits)
+			if offset+2 > len(data) {
+				return nil, fmt.Errorf("invalid two-byte header extension")
+			}
+			id, length = int(data[offset]), int(data[offset+1])
+			offset += 2
+		}
+
+		if offset+length > len(data) {
+			return nil, fmt.Errorf("invalid header extension length %d", length)
+		}
+		extensions = append(extensions, HeaderExtension{ID: id, Payload: data[offset : offset+length]})
+		offset += length
+	}
+
+	return extensions, nil
+}
+
+// GetHeaderExtension 返回指定ID的扩展元素
+func (h *Header) GetHeaderExtension(id int) ([]byte, bool) {
+	extensions, err := h.HeaderExtensions()
+	if err != nil {
+		return nil, false
+	}
+
+	for _, extension := range extensions {
+		if extension.ID == id {
+			return extension.Payload, true
+		}
+	}
+	return nil, false
+}
+
+// SetHeaderExtension 添加或替换扩展元素. ID为1-14且长度为1-16时使用one-byte, 否则使用two-byte
+func (h *Header) SetHeaderExtension(id int, payload []byte) error {
+	if id < 1 || id > 255 {
+		return fmt.Errorf("invalid header extension id %d", id)
+	} else if len(payload) > 255 {
+		return fmt.Errorf("the header extension is too large %d", len(payload))
+	}
+
+	var extensions []HeaderExtension
+	if h.x == 1 {
+		var err error
+		if extensions, err = h.HeaderExtensions(); err != nil {
+			return err
+		}
+	}
+
+	replaced := false
+	for i := range extensions {
+		if extensions[i].ID == id {
+			extensions[i].Payload = payload
+			replaced = true
+		}
+	}
+	if !replaced {
+		extensions = append(extensions, HeaderExtension{ID: id, Payload: payload})
+	}
+
+	h.setHeaderExtensions(extensions)
+	return nil
+}
+
+func (h *Header) setHeaderExtensions(extensions []HeaderExtension) {
+	oneByte := true
+	for _, extension := range extensions {
+		if extension.ID > 14 || len(extension.Payload) == 0 || len(extension.Payload) > 16 {
+			oneByte = false
+		}
+	}
+
+	sort.Slice(extensions, func(i, j int) bool {
+		return extensions[i].ID < extensions[j].ID
+	})
+
+	var data []byte
+	profile := uint16(ExtensionProfileOneByte)
+	if !oneByte {
+		profile = ExtensionProfileTwoByte
+	}
+	for _, extension := range extensions {
+		if oneByte {
+			data = append(data, byte(extension.ID<<4|(len(extension.Payload)-1)))
+		} else {
+			data = append(data, byte(extension.ID), byte(len(extension.Payload)))
+		}
+		data = append(data, extension.Payload...)
+	}
+
+	h.SetExtensionPayload(profile, data)
+}
+
+// ExtensionMap SDP中a=extmap的URI和ID
+type ExtensionMap map[string]int
+
+// NewExtensionMap 读取媒体描述中的a=extmap
+func NewExtensionMap(md *sdp.MediaDescription) (ExtensionMap, error) {
+	extensionMap := make(ExtensionMap, 4)
+	for _, attribute := range md.Attributes {
+		if attribute.Key != "extmap" {
+			continue
+		}
+
+		var extMap sdp.ExtMap
+		if err := extMap.Unmarshal("extmap:" + attribute.Value); err != nil {
+			return nil, err
+		}
+		extensionMap[extMap.URI.String()] = extMap.Value
+	}
+	return extensionMap, nil
+}
+
+// ID 返回URI对应的扩展ID
+func (m ExtensionMap) ID(uri string) (int, bool) {
+	id, ok := m[uri]
+	return id, ok
+}
+
+// AbsSendTime abs-send-time, 6.18定点数格式的NTP时间, 单位秒, 24bits
+type AbsSendTime struct {
+	Timestamp uint32
+}
+
+func NewAbsSendTime(t time.Time) AbsSendTime {
+	ntp := librtcp.ToNtpTime(t)
+	return AbsSendTime{Timestamp: uint32(ntp >> 14 & 0xFFFFFF)}
+}
+
+func (a AbsSendTime) Marshal() []byte {
+	return []byte{byte(a.Timestamp >> 16), byte(a.Timestamp >> 8), byte(a.Timestamp)}
+}
+
+func (a *AbsSendTime) Unmarshal(data []byte) error {
+	if len(data) < 3 {
+		return fmt.Errorf("invalid abs-send-time length %d", len(data))
+	}
+	a.Timestamp = uint32(data[0])<<16 | uint32(data[1])<<8 | uint32(data[2])
+	return nil
+}
+
+// Duration 只保留了64秒内的时间
+func (a AbsSendTime) Duration() time.Duration {
+	return time.Duration(uint64(a.Timestamp) * uint64(time.Second) >> 18)
+}
+
+// TransportCC transport-wide-cc, 16bits的全局序号
+type TransportCC struct {
+	SequenceNumber uint16
+}
+
+func (t TransportCC) Marshal() []byte {
+	return []byte{byte(t.SequenceNumber >> 8), byte(t.SequenceNumber)}
+}
+
+func (t *TransportCC) Unmarshal(data []byte) error {
+	if len(data) < 2 {
+		return fmt.Errorf("invalid transport-wide-cc length %d", len(data))
+	}
+	t.SequenceNumber = uint16(data[0])<<8 | uint16(data[1])
+	return nil
+}
+
+// AudioLevel RFC 6464, V(1bit) | level(7bits), level为-dBov
+type AudioLevel struct {
+	Voice bool
+	Level int
+}
+
+func (a AudioLevel) Marshal() []byte {
+	value := byte(a.Level & 0x7F)
+	if a.Voice {
+		value |= 0x80
+	}
+	return []byte{value}
+}
+
+func (a *AudioLevel) Unmarshal(data []byte) error {
+	if len(data) < 1 {
+		return fmt.Errorf("invalid audio level length %d", len(data))
+	}
+	a.Voice = data[0]&0x80 != 0
+	a.Level = int(data[0] & 0x7F)
+	return nil
+}
+
+// VideoOrientation 3GPP TS 26.114, 0 0 0 0 C F R1 R0
+type VideoOrientation struct {
+	BackCamera bool
+	Flip       bool
+	Rotation   int //0, 90, 180, 270
+}
+
+func (v VideoOrientation) Marshal() []byte {
+	value := byte(v.Rotation / 90 & 0x3)
+	if v.BackCamera {
+		value |= 0x8
+	}
+	if v.Flip {
+		value |= 0x4
+	}
+	return []byte{value}
+}
+
+func (v *VideoOrientation) Unmarshal(data []byte) error {
+	if len(data) < 1 {
+		return fmt.Errorf("invalid video orientation length %d", len(data))
+	}
+	v.BackCamera = data[0]&0x8 != 0
+	v.Flip = data[0]&0x4 != 0
+	v.Rotation = int(data[0]&0x3) * 90
+	return nil
+}
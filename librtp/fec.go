@@ -0,0 +1,197 @@
+package librtp
+
+import (
+	"avformat/utils"
+	"fmt"
+)
+
+const (
+	// fecMediaHistory 保存用于恢复的媒体包数
+	fecMediaHistory = 1024
+	// fecPacketHistory 最多等待的FEC包数
+	fecPacketHistory = 64
+)
+
+// fecPacket RFC 5109/RFC 8627共用的XOR结果, 与序列化格式无关
+type fecPacket struct {
+	ssrc      uint32   //被保护流的SSRC
+	protected []uint16 //被保护包的序号
+	header    [2]byte  //RTP头前两个字节的XOR, 只使用P/X/CC/M/PT
+	timestamp uint32
+	length    uint16 //RTP头之后的长度的XOR, 包含CSRC/扩展头/padding
+	payload   []byte //RTP固定头之后数据的XOR
+}
+
+// xorPackets 计算一组RTP包的FEC, 负载长度为最长的包
+func xorPackets(packets [][]byte) *fecPacket {
+	fec := &fecPacket{}
+	for _, packet := range packets {
+		fec.xor(packet)
+	}
+	return fec
+}
+
+func (f *fecPacket) xor(packet []byte) {
+	f.header[0] ^= packet[0]
+	f.header[1] ^= packet[1]
+	f.timestamp ^= utils.BytesToUInt32(packet[4], packet[5], packet[6], packet[7])
+	f.length ^= uint16(len(packet) - FixedHeaderLength)
+
+	body := packet[FixedHeaderLength:]
+	if len(body) > len(f.payload) {
+		f.payload = append(f.payload, make([]byte, len(body)-len(f.payload))...)
+	}
+	for i, b := range body {
+		f.payload[i] ^= b
+	}
+}
+
+// recover 与除seq之外的被保护包XOR, 还原丢失的包
+func (f *fecPacket) recover(seq uint16, media map[uint16][]byte) ([]byte, error) {
+	restored := &fecPacket{header: f.header, timestamp: f.timestamp, length: f.length, payload: append([]byte(nil), f.payload...)}
+	for _, protected := range f.protected {
+		if protected != seq {
+			restored.xor(media[protected])
+		}
+	}
+
+	if int(restored.length) > len(restored.payload) {
+		return nil, fmt.Errorf("invalid fec length recovery %d", restored.length)
+	}
+
+	packet := make([]byte, FixedHeaderLength+int(restored.length))
+	packet[0] = VERSION<<6 | restored.header[0]&0x3F
+	packet[1] = restored.header[1]
+	utils.WriteWORD(packet[2:], seq)
+	utils.WriteDWORD(packet[4:], restored.timestamp)
+	utils.WriteDWORD(packet[8:], f.ssrc)
+	copy(packet[FixedHeaderLength:], restored.payload)
+	return packet, nil
+}
+
+// fecRecovery 保存最近的媒体包和FEC包, 一个FEC包只缺少一个被保护包时恢复
+// 恢复出的包可能使其他FEC包满足条件, 循环直到没有新的包
+type fecRecovery struct {
+	handler   func(packet []byte)
+	ssrc      uint32
+	started   bool
+	highest   uint16
+	media     map[uint16][]byte
+	packets   []*fecPacket
+	recovered uint32
+}
+
+func newFECRecovery(handler func(packet []byte)) *fecRecovery {
+	return &fecRecovery{handler: handler, media: make(map[uint16][]byte, fecMediaHistory)}
+}
+
+// addMedia 保存媒体包并回调, 已收到或已恢复的包丢弃
+func (r *fecRecovery) addMedia(packet []byte) {
+	h, _, err := parseHeader(packet)
+	if err != nil {
+		return
+	}
+
+	seq := uint16(h.seq)
+	if r.started && r.ssrc != h.ssrc {
+		r.reset()
+	}
+	if !r.started {
+		r.started = true
+		r.ssrc = h.ssrc
+		r.highest = seq
+	} else if _, ok := r.media[seq]; ok {
+		return
+	}
+
+	if int16(seq-r.highest) > 0 {
+		r.highest = seq
+	}
+	r.store(seq, packet)
+	r.handler(packet)
+	r.recover()
+}
+
+func (r *fecRecovery) addFEC(fec *fecPacket) {
+	if r.started && r.ssrc != fec.ssrc {
+		return
+	}
+
+	if len(r.packets) >= fecPacketHistory {
+		r.packets[0] = nil
+		r.packets = r.packets[1:]
+	}
+	r.packets = append(r.packets, fec)
+	r.recover()
+}
+
+func (r *fecRecovery) store(seq uint16, packet []byte) {
+	r.media[seq] = packet
+	if len(r.media) <= fecMediaHistory {
+		return
+	}
+
+	for s := range r.media {
+		if uint16(r.highest-s) >= fecMediaHistory/2 {
+			delete(r.media, s)
+		}
+	}
+}
+
+func (r *fecRecovery) recover() {
+	for progress := true; progress; {
+		progress = false
+		packets := r.packets[:0]
+		for _, fec := range r.packets {
+			if r.started && fec.ssrc != r.ssrc {
+				continue
+			}
+
+			var missing []uint16
+			expired := false
+			for _, seq := range fec.protected {
+				if _, ok := r.media[seq]; !ok {
+					missing = append(missing, seq)
+				}
+				//被保护的包可能已从历史中删除
+				expired = expired || (r.started && int16(r.highest-seq) >= fecMediaHistory/2)
+			}
+
+			//已完整或已恢复的FEC包不再保留
+			if len(missing) == 0 || expired {
+				continue
+			} else if len(missing) > 1 || !r.started {
+				packets = append(packets, fec)
+				continue
+			}
+
+			packet, err := fec.recover(missing[0], r.media)
+			if err != nil {
+				continue
+			}
+			r.store(missing[0], packet)
+			r.recovered++
+			r.handler(packet)
+			progress = true
+		}
+		r.packets = packets
+	}
+}
+
+func (r *fecRecovery) reset() {
+	r.started = false
+	r.packets = r.packets[:0]
+	for seq := range r.media {
+		delete(r.media, seq)
+	}
+}
+
+// appendMask 最高位对应base
+func appendMask(protected []uint16, base uint16, mask uint64, bits int) []uint16 {
+	for i := 0; i < bits; i++ {
+		if mask>>(bits-1-i)&1 != 0 {
+			protected = append(protected, base+uint16(i))
+		}
+	}
+	return protected
+}
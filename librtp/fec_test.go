@@ -0,0 +1,188 @@
+package librtp
+
+import (
+	"avformat/utils"
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// makeMediaPackets 负载长度不同的媒体包, 最后一个包设置marker
+func makeMediaPackets(firstSeq uint16, count int) [][]byte {
+	packets := make([][]byte, count)
+	for i := range packets {
+		payload := bytes.Repeat([]byte{byte(i + 1)}, 20+i*7)
+		packets[i] = makePacket(firstSeq+uint16(i), uint32(i/3)*3000, i == count-1, payload...)
+	}
+	return packets
+}
+
+func TestRED(t *testing.T) {
+	blocks := []REDBlock{
+		{PayloadType: 111, TimestampOffset: 960, Payload: []byte{1, 2, 3}},
+		{PayloadType: 111, Payload: []byte{4, 5}},
+	}
+	data, err := MarshalRED(blocks)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(data, []byte{0xEF, 0x0F, 0x00, 0x03, 0x6F, 1, 2, 3, 4, 5}) {
+		t.Fatalf("unexpected red payload %x", data)
+	}
+
+	parsed, err := UnmarshalRED(data)
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(parsed, blocks) {
+		t.Fatalf("unexpected red blocks %+v", parsed)
+	}
+
+	if _, err = UnmarshalRED([]byte{0xEF, 0x0F, 0x00, 0x03, 0x6F, 1}); err == nil {
+		t.Fatalf("the block length exceeds the payload")
+	}
+}
+
+func TestULPFEC(t *testing.T) {
+	var red [][]byte
+	encoder := NewULPFECEncoder(100, 101, 5, func(packet []byte) {
+		red = append(red, packet)
+	})
+
+	media := makeMediaPackets(65533, 10)
+	for _, packet := range media {
+		if err := encoder.Input(packet); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//每组5个媒体包之后是1个FEC包, 共占用12个序号
+	if len(red) != 12 {
+		t.Fatalf("unexpected red packet count %d", len(red))
+	}
+
+	//媒体包重新编号后的期望值
+	expected := make(map[uint16][]byte)
+	for i, index := range []int{0, 1, 2, 3, 4, 6, 7, 8, 9, 10} {
+		packet := append([]byte(nil), media[i]...)
+		utils.WriteWORD(packet[2:], 65533+uint16(index))
+		expected[65533+uint16(index)] = packet
+	}
+
+	received := make(map[uint16][]byte)
+	decoder := NewULPFECDecoder(100, 101, func(packet []byte) {
+		received[utils.BytesToUInt16(packet[2], packet[3])] = packet
+	})
+	//每组各丢失一个媒体包
+	for i, packet := range red {
+		if i == 1 || i == 8 {
+			continue
+		} else if err := decoder.Input(packet); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if decoder.Recovered() != 2 {
+		t.Fatalf("unexpected recovered count %d", decoder.Recovered())
+	} else if !reflect.DeepEqual(received, expected) {
+		t.Fatalf("failed to recover the lost packets")
+	}
+
+	//同一组丢失两个包无法恢复
+	received = make(map[uint16][]byte)
+	decoder = NewULPFECDecoder(100, 101, func(packet []byte) {
+		received[utils.BytesToUInt16(packet[2], packet[3])] = packet
+	})
+	for i, packet := range red[:6] {
+		if i != 1 && i != 2 {
+			decoder.Input(packet)
+		}
+	}
+	if decoder.Recovered() != 0 || len(received) != 3 {
+		t.Fatalf("recovered %d packets from two losses", decoder.Recovered())
+	}
+}
+
+func TestFlexFEC(t *testing.T) {
+	if _, err := NewFlexFECEncoder(110, 0x2222, 4, 1, FlexFECColumn, nil); err == nil {
+		t.Fatalf("column protection requires more than one row")
+	}
+
+	var fec [][]byte
+	encoder, err := NewFlexFECEncoder(110, 0x2222, 4, 3, FlexFECRowColumn, func(packet []byte) {
+		fec = append(fec, packet)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	media := makeMediaPackets(65530, 12)
+	for _, packet := range media {
+		if err := encoder.Input(packet); err != nil {
+			t.Fatal(err)
+		}
+	}
+	//3个行FEC + 4个列FEC
+	if len(fec) != 7 {
+		t.Fatalf("unexpected fec packet count %d", len(fec))
+	}
+
+	received := make(map[uint16][]byte)
+	decoder := NewFlexFECDecoder(110, func(packet []byte) {
+		received[utils.BytesToUInt16(packet[2], packet[3])] = packet
+	})
+
+	//第0行丢失0和1, 第1列丢失1/5/9, 需要先用行恢复5和9, 再用列恢复1, 最后用行恢复0
+	lost := map[int]bool{0: true, 1: true, 5: true, 9: true}
+	for i, packet := range media {
+		if !lost[i] {
+			decoder.Input(packet)
+		}
+	}
+	//第0列的FEC包丢失
+	for i, packet := range fec {
+		if i == 3 {
+			continue
+		} else if err := decoder.Input(packet); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if decoder.Recovered() != 4 || len(received) != len(media) {
+		t.Fatalf("unexpected recovered count %d", decoder.Recovered())
+	}
+	for i, packet := range media {
+		if !bytes.Equal(received[65530+uint16(i)], packet) {
+			t.Fatalf("failed to recover packet %d", i)
+		}
+	}
+}
+
+func TestFlexFECMask(t *testing.T) {
+	var fec [][]byte
+	encoder, _ := NewFlexFECEncoder(110, 0x2222, 4, 3, FlexFECColumn, func(packet []byte) {
+		fec = append(fec, packet)
+	})
+	for _, packet := range makeMediaPackets(100, 12) {
+		encoder.Input(packet)
+	}
+
+	//将第1列的FEC改为F=0的灵活掩码, 保护101/105/109
+	var packet Packet
+	if err := packet.Unmarshal(fec[1]); err != nil {
+		t.Fatal(err)
+	}
+	column, err := parseFlexFEC(&packet)
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(column.protected, []uint16{101, 105, 109}) {
+		t.Fatalf("unexpected protected sequence numbers %v", column.protected)
+	}
+
+	packet.Payload[0] &^= 0x40
+	utils.WriteWORD(packet.Payload[10:], 0x8000|1<<14|1<<10|1<<6)
+	flexible, err := parseFlexFEC(&packet)
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(flexible, column) {
+		t.Fatalf("unexpected flexible mask %v", flexible.protected)
+	}
+}
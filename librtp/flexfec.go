@@ -0,0 +1,220 @@
+package librtp
+
+import (
+	"avformat/utils"
+	"fmt"
+)
+
+// FlexFEC保护方式, 可组合
+const (
+	FlexFECRow       = 1 << iota //每行L个连续的包生成一个FEC包
+	FlexFECColumn                //每列D个间隔为L的包生成一个FEC包
+	FlexFECRowColumn = FlexFECRow | FlexFECColumn
+
+	flexfecHeaderLength = 12 //R F P X CC M PT | length recovery | TS recovery | SN base + L + D或掩码
+	flexfecMaxColumns   = 0xFF
+	flexfecMaxRows      = 0xFF
+)
+
+// FlexFECEncoder RFC 8627 FlexFEC, FEC包使用单独的SSRC和负载类型, CSRC为被保护流的SSRC
+// 使用固定的行列(F=1)保护L x D个连续的媒体包
+// 非线程安全
+type FlexFECEncoder struct {
+	payloadType int
+	ssrc        uint32
+	columns     int //L
+	rows        int //D
+	mode        int
+	handler     func(packet []byte)
+
+	seq     uint16
+	next    uint16 //下一个媒体包的序号
+	started bool
+	block   [][]byte
+}
+
+// NewFlexFECEncoder 列保护需要rows大于1
+// @columns L, 一行的包数
+// @rows D, 一列的包数, 只使用行保护时忽略
+// @handler 回调FEC包, 媒体包由调用方正常发送
+func NewFlexFECEncoder(payloadType int, ssrc uint32, columns, rows, mode int, handler func(packet []byte)) (*FlexFECEncoder, error) {
+	if columns < 1 || columns > flexfecMaxColumns {
+		return nil, fmt.Errorf("invalid flexfec columns %d", columns)
+	} else if mode&FlexFECRowColumn == 0 || mode&^FlexFECRowColumn != 0 {
+		return nil, fmt.Errorf("invalid flexfec mode %d", mode)
+	} else if mode&FlexFECColumn != 0 && (rows < 2 || rows > flexfecMaxRows) {
+		return nil, fmt.Errorf("invalid flexfec rows %d", rows)
+	}
+
+	if mode&FlexFECColumn == 0 {
+		rows = 1
+	}
+	return &FlexFECEncoder{payloadType: payloadType, ssrc: ssrc, columns: columns, rows: rows, mode: mode, handler: handler}, nil
+}
+
+// Input 输入发送的媒体包, 行或块完整时回调FEC包. 序号不连续时丢弃当前块
+func (e *FlexFECEncoder) Input(data []byte) error {
+	h, _, err := parseHeader(data)
+	if err != nil {
+		return err
+	}
+
+	seq := uint16(h.seq)
+	if !e.started || seq != e.next || (len(e.block) > 0 && h.ssrc != e.mediaSSRC()) {
+		e.block = e.block[:0]
+	}
+	e.started = true
+	e.next = seq + 1
+	e.block = append(e.block, append([]byte(nil), data...))
+
+	if e.mode&FlexFECRow != 0 && len(e.block)%e.columns == 0 {
+		e.output(e.block[len(e.block)-e.columns:], 1)
+	}
+
+	if len(e.block) < e.columns*e.rows {
+		return nil
+	}
+	if e.mode&FlexFECColumn != 0 {
+		for column := 0; column < e.columns; column++ {
+			packets := make([][]byte, 0, e.rows)
+			for row := 0; row < e.rows; row++ {
+				packets = append(packets, e.block[row*e.columns+column])
+			}
+			e.output(packets, e.rows)
+		}
+	}
+	e.block = e.block[:0]
+	return nil
+}
+
+func (e *FlexFECEncoder) mediaSSRC() uint32 {
+	return utils.BytesToUInt32(e.block[0][8], e.block[0][9], e.block[0][10], e.block[0][11])
+}
+
+// output 行保护时D为0或1(之后还有列保护), 列保护时D为行数
+func (e *FlexFECEncoder) output(packets [][]byte, rows int) {
+	if rows == 1 && e.mode&FlexFECColumn == 0 {
+		rows = 0
+	}
+
+	var first, last Packet
+	first.Unmarshal(packets[0])
+	last.Unmarshal(packets[len(packets)-1])
+
+	fec := xorPackets(packets)
+	data := make([]byte, flexfecHeaderLength+len(fec.payload))
+	//R=0, F=1
+	data[0] = 0x40 | fec.header[0]&0x3F
+	data[1] = fec.header[1]
+	utils.WriteWORD(data[2:], fec.length)
+	utils.WriteDWORD(data[4:], fec.timestamp)
+	utils.WriteWORD(data[8:], uint16(first.seq))
+	data[10] = byte(e.columns)
+	data[11] = byte(rows)
+	copy(data[flexfecHeaderLength:], fec.payload)
+
+	packet := NewPacket(e.payloadType, e.seq, last.Timestamp(), e.ssrc, data)
+	packet.SetCSRCList([]uint32{last.SSRC()})
+	e.seq++
+	if bytes, err := packet.Marshal(); err == nil {
+		e.handler(bytes)
+	}
+}
+
+// parseFlexFEC 解析R=0的FEC包, 支持固定行列(F=1)和灵活掩码(F=0). 只支持保护一个SSRC
+func parseFlexFEC(packet *Packet) (*fecPacket, error) {
+	data := packet.Payload
+	if len(packet.csrc) != 1 {
+		return nil, fmt.Errorf("unsupported flexfec ssrc count %d", len(packet.csrc))
+	} else if len(data) < flexfecHeaderLength {
+		return nil, fmt.Errorf("invalid flexfec length %d", len(data))
+	} else if data[0]&0x80 != 0 {
+		return nil, fmt.Errorf("unsupported flexfec retransmission")
+	}
+
+	fec := &fecPacket{
+		ssrc:      packet.csrc[0],
+		header:    [2]byte{data[0] & 0x3F, data[1]},
+		length:    utils.BytesToUInt16(data[2], data[3]),
+		timestamp: utils.BytesToUInt32(data[4], data[5], data[6], data[7]),
+	}
+	base := utils.BytesToUInt16(data[8], data[9])
+	offset := flexfecHeaderLength
+
+	if data[0]&0x40 != 0 {
+		columns, rows := int(data[10]), int(data[11])
+		if columns == 0 {
+			return nil, fmt.Errorf("invalid flexfec columns %d", columns)
+		} else if rows <= 1 {
+			for i := 0; i < columns; i++ {
+				fec.protected = append(fec.protected, base+uint16(i))
+			}
+		} else {
+			for i := 0; i < rows; i++ {
+				fec.protected = append(fec.protected, base+uint16(i*columns))
+			}
+		}
+	} else {
+		//k位为1表示掩码结束. 掩码依次为15/31/64位
+		word := utils.BytesToUInt16(data[10], data[11])
+		fec.protected = appendMask(fec.protected, base, uint64(word&0x7FFF), 15)
+		if word&0x8000 == 0 {
+			if len(data) < offset+4 {
+				return nil, fmt.Errorf("invalid flexfec mask")
+			}
+			dword := utils.BytesToUInt32(data[12], data[13], data[14], data[15])
+			fec.protected = appendMask(fec.protected, base+15, uint64(dword&0x7FFFFFFF), 31)
+			offset += 4
+			if dword&0x80000000 == 0 {
+				if len(data) < offset+8 {
+					return nil, fmt.Errorf("invalid flexfec mask")
+				}
+				high := utils.BytesToUInt32(data[16], data[17], data[18], data[19])
+				low := utils.BytesToUInt32(data[20], data[21], data[22], data[23])
+				fec.protected = appendMask(fec.protected, base+46, uint64(high)<<32|uint64(low), 64)
+				offset += 8
+			}
+		}
+	}
+
+	fec.payload = append([]byte(nil), data[offset:]...)
+	return fec, nil
+}
+
+// FlexFECDecoder 使用FlexFEC恢复丢失的媒体包, 媒体包和FEC包可以从同一个入口输入
+// 恢复的包在恢复时回调, 与收到的包不保证顺序, 需要后接JitterBuffer
+// 非线程安全
+type FlexFECDecoder struct {
+	payloadType int
+	recovery    *fecRecovery
+}
+
+// NewFlexFECDecoder
+// @payloadType FEC流的负载类型
+// @handler 回调收到和恢复的媒体包
+func NewFlexFECDecoder(payloadType int, handler func(packet []byte)) *FlexFECDecoder {
+	return &FlexFECDecoder{payloadType: payloadType, recovery: newFECRecovery(handler)}
+}
+
+// Recovered 已恢复的包数
+func (d *FlexFECDecoder) Recovered() uint32 {
+	return d.recovery.recovered
+}
+
+// Input 输入媒体包或FEC包, 按负载类型区分
+func (d *FlexFECDecoder) Input(data []byte) error {
+	var packet Packet
+	if err := packet.Unmarshal(data); err != nil {
+		return err
+	} else if int(packet.pt) != d.payloadType {
+		d.recovery.addMedia(append([]byte(nil), data...))
+		return nil
+	}
+
+	fec, err := parseFlexFEC(&packet)
+	if err != nil {
+		return err
+	}
+	d.recovery.addFEC(fec)
+	return nil
+}
@@ -0,0 +1,278 @@
+package librtp
+
+import (
+	"avformat/libavc"
+	"avformat/utils"
+	"fmt"
+)
+
+// RFC 6184 5.2 NAL unit type
+const (
+	H264PacketSTAPA  = 24
+	H264PacketSTAPB  = 25
+	H264PacketMTAP16 = 26
+	H264PacketMTAP24 = 27
+	H264PacketFUA    = 28
+	H264PacketFUB    = 29
+)
+
+// h264Depacketizer 交织模式(STAP-B/MTAP/FU-B)的NALU按接收顺序输出, 不按DON重排
+type h264Depacketizer struct {
+	frameAssembler
+	fragmented bool //FU分片未结束
+}
+
+// NewH264Depacketizer RFC 6184, 输出AnnexB格式的access unit
+func NewH264Depacketizer(handler decodeHandler) Depacketizer {
+	return &h264Depacketizer{frameAssembler: frameAssembler{handler: handler}}
+}
+
+func (d *h264Depacketizer) Input(packet []byte) error {
+	h, payload, err := parseHeader(packet)
+	if err != nil {
+		return err
+	}
+
+	if d.stale(h) {
+		return nil
+	} else if d.fragmented && h.timestamp != d.timestamp {
+		d.lost = true
+	}
+
+	d.checkPacket(h, libavc.IsKeyFrame)
+	if len(d.buffer) == 0 {
+		d.fragmented = false
+	}
+	if err = d.depacketize(h, payload); err != nil {
+		d.lost = true
+	}
+
+	if h.m == 1 {
+		//分片未结束的NALU不完整
+		if d.fragmented {
+			d.lost = true
+			d.fragmented = false
+		}
+		d.flush(libavc.IsKeyFrame)
+	}
+	return err
+}
+
+func (d *h264Depacketizer) depacketize(h *Header, payload []byte) error {
+	if len(payload) < 1 {
+		return fmt.Errorf("invalid h264 rtp payload length %d", len(payload))
+	}
+
+	nalType := payload[0] & 0x1F
+	//上一个FU分片未结束
+	if d.fragmented && nalType != H264PacketFUA {
+		d.fragmented = false
+		d.lost = true
+	}
+
+	switch {
+	case nalType >= 1 && nalType <= 23:
+		d.write(libavc.StartCode4, payload)
+		break
+	case nalType == H264PacketSTAPA:
+		//STAP-A NAL HDR | NALU 1 Size | NALU 1 HDR | NALU 1 Data | NALU 2 Size ...
+		return d.depacketizeSTAP(payload[1:])
+	case nalType == H264PacketSTAPB:
+		//STAP-B NAL HDR | DON | NALU 1 Size | NALU 1 HDR | NALU 1 Data ...
+		if len(payload) < 3 {
+			return fmt.Errorf("invalid STAP-B payload length %d", len(payload))
+		}
+		return d.depacketizeSTAP(payload[3:])
+	case nalType == H264PacketMTAP16 || nalType == H264PacketMTAP24:
+		return d.depacketizeMTAP(h, payload, nalType == H264PacketMTAP24)
+	case nalType == H264PacketFUA || nalType == H264PacketFUB:
+		//FU indicator | FU header | DON(FU-B) | FU payload
+		offset := 2
+		if nalType == H264PacketFUB {
+			offset = 4
+		}
+		if len(payload) < offset {
+			return fmt.Errorf("invalid FU payload length %d", len(payload))
+		}
+
+		start, end := payload[1]>>7 == 1, payload[1]>>6&0x1 == 1
+		if start {
+			d.write(libavc.StartCode4, []byte{payload[0]&0xE0 | payload[1]&0x1F}, payload[offset:])
+			d.fragmented = true
+		} else if nalType == H264PacketFUB {
+			//FU-B只能用于第一个分片
+			return fmt.Errorf("FU-B must be the start fragment")
+		} else if !d.fragmented {
+			//丢失了起始分片
+			return fmt.Errorf("missing the start fragment of FU-A")
+		} else {
+			d.write(payload[offset:])
+		}
+
+		if end {
+			d.fragmented = false
+		}
+		break
+	default:
+		return fmt.Errorf("unsupported h264 rtp packet type %d", nalType)
+	}
+
+	return nil
+}
+
+func (d *h264Depacketizer) depacketizeSTAP(data []byte) error {
+	for offset := 0; offset < len(data); {
+		if offset+2 > len(data) {
+			return fmt.Errorf("invalid STAP payload")
+		}
+		size := int(utils.BytesToUInt16(data[offset], data[offset+1]))
+		offset += 2
+		if size == 0 || offset+size > len(data) {
+			return fmt.Errorf("invalid STAP nal size %d", size)
+		}
+		d.write(libavc.StartCode4, data[offset:offset+size])
+		offset += size
+	}
+	return nil
+}
+
+// depacketizeMTAP MTAP NAL HDR | DONB | NALU 1 Size | NALU 1 DOND | NALU 1 TS offset | NALU 1 HDR | NALU 1 Data ...
+// TS offset不同的NALU属于不同的access unit
+func (d *h264Depacketizer) depacketizeMTAP(h *Header, payload []byte, ts24 bool) error {
+	tsLength := 2
+	if ts24 {
+		tsLength = 3
+	}
+
+	for offset := 3; offset < len(payload); {
+		if offset+3+tsLength > len(payload) {
+			return fmt.Errorf("invalid MTAP payload")
+		}
+		size := int(utils.BytesToUInt16(payload[offset], payload[offset+1]))
+		tsOffset := uint32(utils.BytesToUInt16(payload[offset+3], payload[offset+4]))
+		if ts24 {
+			tsOffset = utils.BytesToUInt24(payload[offset+3], payload[offset+4], payload[offset+5])
+		}
+
+		//NALU size包含DOND和TS offset
+		offset += 2
+		if size <= 1+tsLength || offset+size > len(payload) {
+			return fmt.Errorf("invalid MTAP nal size %d", size)
+		}
+
+		timestamp := h.timestamp + tsOffset
+		if timestamp != d.timestamp {
+			d.flush(libavc.IsKeyFrame)
+			d.timestamp = timestamp
+		}
+		d.write(libavc.StartCode4, payload[offset+1+tsLength:offset+size])
+		offset += size
+	}
+	return nil
+}
+
+// h264Packetizer RFC 6184, 输入AnnexB格式的access unit
+type h264Packetizer struct {
+	packetWriter
+	packetizationMode int
+	stapA             []byte //待发送的STAP-A
+	stapANalUnits     int
+}
+
+// NewH264Packetizer packetizationMode=0时只使用Single NAL; 1时使用STAP-A聚合SPS/PPS等非VCL的NALU, FU-A分片大的NALU
+func NewH264Packetizer(pt int, ssrc uint32, packetizationMode int, handler encodeHandler) Packetizer {
+	return &h264Packetizer{
+		packetWriter:      newPacketWriter(pt, ssrc, handler),
+		packetizationMode: packetizationMode,
+	}
+}
+
+func (p *h264Packetizer) Input(data []byte, timestamp uint32) error {
+	nalUnits := splitNalUnits(data)
+	if len(nalUnits) == 0 {
+		return fmt.Errorf("no nal unit in the h264 frame")
+	}
+
+	if p.packetizationMode == 0 {
+		for _, nalu := range nalUnits {
+			if len(nalu) > p.maxPayloadSize() {
+				return fmt.Errorf("the nal unit is too large %d for packetization-mode 0", len(nalu))
+			}
+		}
+		for i, nalu := range nalUnits {
+			p.write(timestamp, i+1 == len(nalUnits), nalu)
+		}
+		return nil
+	}
+
+	for i, nalu := range nalUnits {
+		last := i+1 == len(nalUnits)
+		nalType := nalu[0] & 0x1F
+		//SEI/SPS/PPS/AUD
+		if nalType >= 6 && nalType <= 9 && !last {
+			if 1+len(p.stapA)+2+len(nalu) > p.maxPayloadSize() {
+				p.flushSTAPA(timestamp)
+			}
+			if 1+2+len(nalu) <= p.maxPayloadSize() {
+				p.appendSTAPA(nalu)
+				continue
+			}
+		}
+
+		p.flushSTAPA(timestamp)
+		if len(nalu) <= p.maxPayloadSize() {
+			p.write(timestamp, last, nalu)
+		} else {
+			p.writeFUA(nalu, timestamp, last)
+		}
+	}
+	return nil
+}
+
+func (p *h264Packetizer) appendSTAPA(nalu []byte) {
+	var size [2]byte
+	utils.WriteWORD(size[:], uint16(len(nalu)))
+	p.stapA = append(p.stapA, size[:]...)
+	p.stapA = append(p.stapA, nalu...)
+	p.stapANalUnits++
+}
+
+// flushSTAPA 只有一个NALU时使用Single NAL
+func (p *h264Packetizer) flushSTAPA(timestamp uint32) {
+	if p.stapANalUnits == 1 {
+		p.write(timestamp, false, p.stapA[2:])
+	} else if p.stapANalUnits > 1 {
+		//F为所有NALU的F的或, NRI取最大值
+		var f, nri byte
+		for offset := 0; offset < len(p.stapA); {
+			size := int(utils.BytesToUInt16(p.stapA[offset], p.stapA[offset+1]))
+			f |= p.stapA[offset+2] & 0x80
+			if p.stapA[offset+2]&0x60 > nri {
+				nri = p.stapA[offset+2] & 0x60
+			}
+			offset += 2 + size
+		}
+		p.write(timestamp, false, []byte{f | nri | H264PacketSTAPA}, p.stapA)
+	}
+
+	p.stapA = p.stapA[:0]
+	p.stapANalUnits = 0
+}
+
+// writeFUA FU indicator | FU header | FU payload
+func (p *h264Packetizer) writeFUA(nalu []byte, timestamp uint32, last bool) {
+	header := [2]byte{nalu[0]&0xE0 | H264PacketFUA, 0x80 | nalu[0]&0x1F}
+	data := nalu[1:]
+	maxSize := p.maxPayloadSize() - 2
+	for len(data) > 0 {
+		size := utils.MinInt(len(data), maxSize)
+		end := size == len(data)
+		if end {
+			header[1] |= 0x40
+		}
+
+		p.write(timestamp, last && end, header[:], data[:size])
+		header[1] &= 0x7F
+		data = data[size:]
+	}
+}
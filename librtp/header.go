@@ -1,6 +1,9 @@
 package librtp
 
-import "avformat/utils"
+import (
+	"avformat/utils"
+	"fmt"
+)
 
 const (
 	VERSION           = 2
@@ -23,8 +26,7 @@ type Header struct {
 
 	csrc             []uint32
 	extensionProfile uint16
-	extensionLength  uint16
-	extensions       []uint32
+	extension        []byte //扩展头数据, 长度为4的倍数
 }
 
 func NewHeader() *Header {
@@ -33,7 +35,8 @@ func NewHeader() *Header {
 	}
 }
 
-func (h *Header) toBytes(dst []byte) int {
+// marshalTo 写入RTP头, 不修改seq
+func (h *Header) marshalTo(dst []byte) int {
 	dst[0] = h.v << 6
 	dst[0] = dst[0] | (h.p << 5)
 	dst[0] = dst[0] | (h.x << 4)
@@ -47,36 +50,35 @@ func (h *Header) toBytes(dst []byte) int {
 
 	//csrc
 	offset := FixedHeaderLength
-	if h.cc > 0 {
-		for i, v := range h.csrc {
-			offset += i * 4
-			utils.WriteDWORD(dst[offset:], v)
-		}
+	for _, v := range h.csrc {
+		utils.WriteDWORD(dst[offset:], v)
+		offset += 4
 	}
 
 	//extension
-	if h.p > 0 {
+	if h.x > 0 {
 		utils.WriteWORD(dst[offset:], h.extensionProfile)
-		utils.WriteWORD(dst[offset+2:], h.extensionLength)
+		utils.WriteWORD(dst[offset+2:], uint16(len(h.extension)/4))
 		offset += 4
-		for i, v := range h.extensions {
-			offset += i * 4
-			utils.WriteDWORD(dst[offset:], v)
-		}
+		offset += copy(dst[offset:], h.extension)
 	}
 
-	h.seq = (h.seq + 1) & 0xFFFF
 	return offset
 }
 
+// toBytes 写入RTP头, 写入后seq加1
+func (h *Header) toBytes(dst []byte) int {
+	n := h.marshalTo(dst)
+	h.seq = (h.seq + 1) & 0xFFFF
+	return n
+}
+
 func (h *Header) Length() int {
 	length := FixedHeaderLength
-	if h.cc > 0 {
-		length += len(h.csrc) * 4
-	}
-	if h.p > 0 {
+	length += len(h.csrc) * 4
+	if h.x > 0 {
 		length += 4
-		length += len(h.extensions) * 4
+		length += len(h.extension)
 	}
 	return length
 }
@@ -90,10 +92,26 @@ func (h *Header) SetCSRCList(list []uint32) {
 }
 
 func (h *Header) SetExtensions(profile uint16, extensions []uint32) {
+	payload := make([]byte, len(extensions)*4)
+	for i, v := range extensions {
+		utils.WriteDWORD(payload[i*4:], v)
+	}
+	h.SetExtensionPayload(profile, payload)
+}
+
+// SetExtensionPayload 设置扩展头, 长度不足4的倍数时补0
+func (h *Header) SetExtensionPayload(profile uint16, payload []byte) {
 	h.x = 1
 	h.extensionProfile = profile
-	h.extensionLength = uint16(len(extensions))
-	h.extensions = extensions
+	h.extension = make([]byte, (len(payload)+3)/4*4)
+	copy(h.extension, payload)
+}
+
+// ClearExtension 删除扩展头
+func (h *Header) ClearExtension() {
+	h.x = 0
+	h.extensionProfile = 0
+	h.extension = nil
 }
 
 func (h *Header) Padding() bool {
@@ -103,3 +121,198 @@ func (h *Header) Padding() bool {
 func (h *Header) Extension() bool {
 	return h.x == 1
 }
+
+func (h *Header) Version() int {
+	return int(h.v)
+}
+
+func (h *Header) Marker() bool {
+	return h.m == 1
+}
+
+func (h *Header) SetMarker(marker bool) {
+	h.m = 0
+	if marker {
+		h.m = 1
+	}
+}
+
+func (h *Header) PayloadType() int {
+	return int(h.pt)
+}
+
+func (h *Header) SetPayloadType(pt int) {
+	h.pt = byte(pt)
+}
+
+func (h *Header) SequenceNumber() uint16 {
+	return uint16(h.seq)
+}
+
+func (h *Header) SetSequenceNumber(seq uint16) {
+	h.seq = int(seq)
+}
+
+func (h *Header) Timestamp() uint32 {
+	return h.timestamp
+}
+
+func (h *Header) SetTimestamp(timestamp uint32) {
+	h.timestamp = timestamp
+}
+
+func (h *Header) SSRC() uint32 {
+	return h.ssrc
+}
+
+func (h *Header) SetSSRC(ssrc uint32) {
+	h.ssrc = ssrc
+}
+
+func (h *Header) CSRC() []uint32 {
+	return h.csrc
+}
+
+func (h *Header) ExtensionProfile() uint16 {
+	return h.extensionProfile
+}
+
+// ExtensionPayload 扩展头数据, 不包含profile和length
+func (h *Header) ExtensionPayload() []byte {
+	return h.extension
+}
+
+// Unmarshal 解析RTP头, 扩展头数据引用data
+// @return RTP头的长度
+func (h *Header) Unmarshal(data []byte) (int, error) {
+	if len(data) < FixedHeaderLength {
+		return 0, fmt.Errorf("invalid rtp packet length %d", len(data))
+	}
+
+	h.v = data[0] >> 6
+	h.p = data[0] >> 5 & 0x1
+	h.x = data[0] >> 4 & 0x1
+	h.cc = data[0] & 0xF
+	h.m = data[1] >> 7
+	h.pt = data[1] & 0x7F
+	h.seq = int(utils.BytesToUInt16(data[2], data[3]))
+	h.timestamp = utils.BytesToUInt32(data[4], data[5], data[6], data[7])
+	h.ssrc = utils.BytesToUInt32(data[8], data[9], data[10], data[11])
+	if h.v != VERSION {
+		return 0, fmt.Errorf("unknow rtp version %d", h.v)
+	}
+
+	offset := FixedHeaderLength
+	length := len(data)
+	if length < offset+int(h.cc)*4 {
+		return 0, fmt.Errorf("invalid rtp packet length %d", length)
+	}
+	h.csrc = nil
+	for i := 0; i < int(h.cc); i++ {
+		h.csrc = append(h.csrc, utils.BytesToUInt32(data[offset], data[offset+1], data[offset+2], data[offset+3]))
+		offset += 4
+	}
+
+	h.extensionProfile = 0
+	h.extension = nil
+	if h.x == 1 {
+		if length < offset+4 {
+			return 0, fmt.Errorf("invalid rtp packet length %d", length)
+		}
+		h.extensionProfile = utils.BytesToUInt16(data[offset], data[offset+1])
+		extensionLength := int(utils.BytesToUInt16(data[offset+2], data[offset+3])) * 4
+		offset += 4
+		if length < offset+extensionLength {
+			return 0, fmt.Errorf("invalid rtp packet length %d", length)
+		}
+		h.extension = data[offset : offset+extensionLength]
+		offset += extensionLength
+	}
+
+	return offset, nil
+}
+
+// parseHeader 解析RTP头
+// @return RTP头和去除padding后的负载
+func parseHeader(data []byte) (*Header, []byte, error) {
+	packet := &Packet{}
+	if err := packet.Unmarshal(data); err != nil {
+		return nil, nil, err
+	}
+	return &packet.Header, packet.Payload, nil
+}
+
+// Packet RTP包
+type Packet struct {
+	Header
+	Payload     []byte
+	PaddingSize int
+}
+
+// Unmarshal 解析RTP包, Payload引用data
+func (p *Packet) Unmarshal(data []byte) error {
+	offset, err := p.Header.Unmarshal(data)
+	if err != nil {
+		return err
+	}
+
+	length := len(data)
+	p.PaddingSize = 0
+	if p.p == 1 {
+		padding := int(data[length-1])
+		if padding == 0 || offset+padding > length {
+			return fmt.Errorf("invalid rtp padding %d", padding)
+		}
+		p.PaddingSize = padding
+		length -= padding
+	}
+
+	p.Payload = data[offset:length]
+	return nil
+}
+
+// MarshalSize 序列化后的长度
+func (p *Packet) MarshalSize() int {
+	return p.Header.Length() + len(p.Payload) + p.PaddingSize
+}
+
+// MarshalTo 写入RTP包. PaddingSize大于0时设置padding位
+// @return 写入的长度
+func (p *Packet) MarshalTo(dst []byte) (int, error) {
+	if len(dst) < p.MarshalSize() {
+		return 0, fmt.Errorf("the buffer is too small %d, need %d", len(dst), p.MarshalSize())
+	} else if p.PaddingSize > 0xFF {
+		return 0, fmt.Errorf("invalid rtp padding %d", p.PaddingSize)
+	}
+
+	p.p = 0
+	if p.PaddingSize > 0 {
+		p.p = 1
+	}
+	n := p.Header.marshalTo(dst)
+	n += copy(dst[n:], p.Payload)
+	if p.PaddingSize > 0 {
+		for i := 0; i < p.PaddingSize-1; i++ {
+			dst[n+i] = 0
+		}
+		n += p.PaddingSize
+		dst[n-1] = byte(p.PaddingSize)
+	}
+	return n, nil
+}
+
+// Marshal 序列化RTP包
+func (p *Packet) Marshal() ([]byte, error) {
+	dst := make([]byte, p.MarshalSize())
+	n, err := p.MarshalTo(dst)
+	return dst[:n], err
+}
+
+func NewPacket(pt int, seq uint16, timestamp, ssrc uint32, payload []byte) *Packet {
+	packet := &Packet{Header: *NewHeader(), Payload: payload}
+	packet.pt = byte(pt)
+	packet.seq = int(seq)
+	packet.timestamp = timestamp
+	packet.ssrc = ssrc
+	return packet
+}
@@ -0,0 +1,115 @@
+package librtp
+
+import (
+	"avformat/librtsp/sdp"
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestPacket(t *testing.T) {
+	packet := NewPacket(96, 0xFFFF, 90000, 0x12345678, []byte{0x01, 0x02, 0x03})
+	packet.SetMarker(true)
+	packet.SetCSRCList([]uint32{0x11111111, 0x22222222})
+	packet.SetExtensions(0x1234, []uint32{0xAABBCCDD})
+	packet.PaddingSize = 4
+
+	data, err := packet.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(data) != 12+8+8+3+4 || data[len(data)-1] != 4 {
+		t.Fatalf("unexpected packet % x", data)
+	}
+
+	var parsed Packet
+	if err = parsed.Unmarshal(data); err != nil {
+		t.Fatal(err)
+	}
+	if !parsed.Marker() || parsed.PayloadType() != 96 || parsed.SequenceNumber() != 0xFFFF || parsed.Timestamp() != 90000 || parsed.SSRC() != 0x12345678 {
+		t.Fatalf("unexpected header %+v", parsed.Header)
+	}
+	if len(parsed.CSRC()) != 2 || parsed.CSRC()[1] != 0x22222222 {
+		t.Fatalf("unexpected csrc %v", parsed.CSRC())
+	}
+	if parsed.ExtensionProfile() != 0x1234 || !bytes.Equal(parsed.ExtensionPayload(), []byte{0xAA, 0xBB, 0xCC, 0xDD}) {
+		t.Fatalf("unexpected extension % x", parsed.ExtensionPayload())
+	}
+	if !bytes.Equal(parsed.Payload, []byte{0x01, 0x02, 0x03}) || parsed.PaddingSize != 4 {
+		t.Fatalf("unexpected payload % x", parsed.Payload)
+	}
+
+	if err = parsed.Unmarshal(data[:20]); err == nil {
+		t.Fatalf("the truncated packet must fail")
+	}
+}
+
+func TestHeaderExtensions(t *testing.T) {
+	h := NewHeader()
+	orientation := VideoOrientation{BackCamera: true, Rotation: 270}
+	if err := h.SetHeaderExtension(3, orientation.Marshal()); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.SetHeaderExtension(1, NewAbsSendTime(time.Unix(0, 0).Add(1500*time.Millisecond)).Marshal()); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.SetHeaderExtension(2, TransportCC{SequenceNumber: 1000}.Marshal()); err != nil {
+		t.Fatal(err)
+	}
+
+	//one-byte
+	if h.ExtensionProfile() != ExtensionProfileOneByte || len(h.ExtensionPayload())%4 != 0 {
+		t.Fatalf("unexpected extension % x", h.ExtensionPayload())
+	}
+
+	var transportCC TransportCC
+	if payload, ok := h.GetHeaderExtension(2); !ok || transportCC.Unmarshal(payload) != nil || transportCC.SequenceNumber != 1000 {
+		t.Fatalf("get transport-wide-cc failed")
+	}
+	var parsedOrientation VideoOrientation
+	if payload, ok := h.GetHeaderExtension(3); !ok || parsedOrientation.Unmarshal(payload) != nil || parsedOrientation != orientation {
+		t.Fatalf("get video orientation failed %+v", parsedOrientation)
+	}
+
+	//ID大于14时使用two-byte
+	level := AudioLevel{Voice: true, Level: 30}
+	if err := h.SetHeaderExtension(20, level.Marshal()); err != nil {
+		t.Fatal(err)
+	}
+	if h.ExtensionProfile() != ExtensionProfileTwoByte {
+		t.Fatalf("unexpected profile 0x%x", h.ExtensionProfile())
+	}
+
+	extensions, err := h.HeaderExtensions()
+	if err != nil || len(extensions) != 4 {
+		t.Fatalf("parse extensions failed %v %v", extensions, err)
+	}
+	var absSendTime AbsSendTime
+	var parsedLevel AudioLevel
+	if absSendTime.Unmarshal(extensions[0].Payload) != nil || absSendTime.Duration()%(64*time.Second) != 1500*time.Millisecond {
+		t.Fatalf("unexpected abs-send-time %v", absSendTime.Duration())
+	}
+	if parsedLevel.Unmarshal(extensions[3].Payload) != nil || parsedLevel != level {
+		t.Fatalf("unexpected audio level %+v", parsedLevel)
+	}
+}
+
+func TestExtensionMap(t *testing.T) {
+	var sd sdp.SessionDescription
+	if err := sd.Unmarshal([]byte("v=0\r\no=- 0 0 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\nm=video 9 RTP/AVP 96\r\na=extmap:3 " + sdp.TransportCCURI + "\r\na=extmap:13/sendonly " + sdp.VideoOrientationURI + "\r\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	extensionMap, err := NewExtensionMap(sd.MediaDescriptions[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, ok := extensionMap.ID(sdp.TransportCCURI); !ok || id != 3 {
+		t.Fatalf("unexpected transport-wide-cc id %d", id)
+	}
+	if id, ok := extensionMap.ID(sdp.VideoOrientationURI); !ok || id != 13 {
+		t.Fatalf("unexpected video orientation id %d", id)
+	}
+	if _, ok := extensionMap.ID(sdp.AudioLevelURI); ok {
+		t.Fatalf("the audio level is not mapped")
+	}
+}
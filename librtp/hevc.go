@@ -0,0 +1,438 @@
+package librtp
+
+import (
+	"avformat/libavc"
+	"avformat/libhevc"
+	"avformat/utils"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RFC 7798 4.4 payload structures
+const (
+	HEVCPacketAP = 48
+	HEVCPacketFU = 49
+)
+
+// hevcDepacketizer 负载中不携带DONL/DOND(sprop-max-don-diff=0), 传输顺序即解码顺序
+type hevcDepacketizer struct {
+	frameAssembler
+	fragmented bool //FU分片未结束
+}
+
+// NewHEVCDepacketizer RFC 7798, 输出AnnexB格式的access unit
+// @maxDonDiff fmtp中的sprop-max-don-diff, 大于0时按DON重排
+func NewHEVCDepacketizer(maxDonDiff int, handler decodeHandler) Depacketizer {
+	if maxDonDiff > 0 {
+		return &hevcDONDepacketizer{handler: handler, maxDonDiff: maxDonDiff}
+	}
+	return &hevcDepacketizer{frameAssembler: frameAssembler{handler: handler}}
+}
+
+func (d *hevcDepacketizer) Input(packet []byte) error {
+	h, payload, err := parseHeader(packet)
+	if err != nil {
+		return err
+	}
+
+	if d.stale(h) {
+		return nil
+	} else if d.fragmented && h.timestamp != d.timestamp {
+		d.lost = true
+	}
+
+	d.checkPacket(h, libhevc.IsKeyFrame)
+	if len(d.buffer) == 0 {
+		d.fragmented = false
+	}
+	if err = d.depacketize(payload); err != nil {
+		d.lost = true
+	}
+
+	if h.m == 1 {
+		if d.fragmented {
+			d.lost = true
+			d.fragmented = false
+		}
+		d.flush(libhevc.IsKeyFrame)
+	}
+	return err
+}
+
+func (d *hevcDepacketizer) depacketize(payload []byte) error {
+	if len(payload) < 3 {
+		return fmt.Errorf("invalid hevc rtp payload length %d", len(payload))
+	}
+
+	nalType := payload[0] >> 1 & 0x3F
+	//上一个FU分片未结束
+	if d.fragmented && nalType != HEVCPacketFU {
+		d.fragmented = false
+		d.lost = true
+	}
+
+	switch {
+	case nalType < HEVCPacketAP:
+		d.write(libavc.StartCode4, payload)
+		break
+	case nalType == HEVCPacketAP:
+		//PayloadHdr | NALU 1 Size | NALU 1 HDR | NALU 1 Data | NALU 2 Size ...
+		for offset := 2; offset < len(payload); {
+			if offset+2 > len(payload) {
+				return fmt.Errorf("invalid AP payload")
+			}
+			size := int(utils.BytesToUInt16(payload[offset], payload[offset+1]))
+			offset += 2
+			if size == 0 || offset+size > len(payload) {
+				return fmt.Errorf("invalid AP nal size %d", size)
+			}
+			d.write(libavc.StartCode4, payload[offset:offset+size])
+			offset += size
+		}
+		break
+	case nalType == HEVCPacketFU:
+		//PayloadHdr | FU header | FU payload
+		fuType := payload[2] & 0x3F
+		start, end := payload[2]>>7 == 1, payload[2]>>6&0x1 == 1
+		if start {
+			d.write(libavc.StartCode4, []byte{payload[0]&0x81 | fuType<<1, payload[1]}, payload[3:])
+			d.fragmented = true
+		} else if !d.fragmented {
+			return fmt.Errorf("missing the start fragment of FU")
+		} else {
+			d.write(payload[3:])
+		}
+
+		if end {
+			d.fragmented = false
+		}
+		break
+	default:
+		return fmt.Errorf("unsupported hevc rtp packet type %d", nalType)
+	}
+
+	return nil
+}
+
+// hevcNALUnit 等待按DON输出的NALU
+type hevcNALUnit struct {
+	don       int //扩展后不回绕的DON
+	timestamp uint32
+	data      []byte
+}
+
+// hevcDONDepacketizer sprop-max-don-diff大于0时, 负载中携带DONL/DOND, 传输顺序和解码顺序不同.
+// RFC 7798 6: 按DON重排后输出. 之后收到的NALU的DON不会小于已收到的最大DON减去max-don-diff,
+// 小于等于该值的NALU可以按DON顺序输出. 解码顺序中时间戳变化时输出上一个access unit
+type hevcDONDepacketizer struct {
+	handler    decodeHandler
+	maxDonDiff int
+	seq        uint16
+	started    bool //已收到过包
+
+	fu          []byte //未结束的FU分片
+	fuDon       uint16
+	fuTimestamp uint32
+	fragmented  bool
+
+	pending     []hevcNALUnit //按DON排序
+	maxDon      int           //已收到的最大DON
+	donStarted  bool
+	nextDon     int //之前的NALU都已输出, 更小的DON为迟到的NALU
+	au          []byte
+	auTimestamp uint32
+}
+
+func (d *hevcDONDepacketizer) Input(packet []byte) error {
+	h, payload, err := parseHeader(packet)
+	if err != nil {
+		return err
+	}
+
+	seq := uint16(h.seq)
+	if d.started && int16(seq-d.seq) <= 0 {
+		return nil
+	}
+	//丢包时丢弃未结束的FU
+	if d.started && seq != d.seq+1 {
+		d.fragmented = false
+	}
+	d.started = true
+	d.seq = seq
+
+	if err = d.depacketize(payload, h.timestamp); err != nil {
+		d.fragmented = false
+	}
+	d.release()
+	return err
+}
+
+func (d *hevcDONDepacketizer) depacketize(payload []byte, timestamp uint32) error {
+	if len(payload) < 3 {
+		return fmt.Errorf("invalid hevc rtp payload length %d", len(payload))
+	}
+
+	nalType := payload[0] >> 1 & 0x3F
+	//上一个FU分片未结束
+	if d.fragmented && nalType != HEVCPacketFU {
+		d.fragmented = false
+	}
+
+	switch {
+	case nalType < HEVCPacketAP:
+		//PayloadHdr | DONL | NAL unit payload data
+		if len(payload) < 5 {
+			return fmt.Errorf("invalid hevc single nal payload length %d", len(payload))
+		}
+		don := utils.BytesToUInt16(payload[2], payload[3])
+		d.push(don, timestamp, append(payload[:2:2], payload[4:]...))
+		break
+	case nalType == HEVCPacketAP:
+		//PayloadHdr | DONL | NALU 1 Size | NALU 1 HDR | NALU 1 Data | DOND | NALU 2 Size ...
+		var don uint16
+		for offset, i := 2, 0; offset < len(payload); i++ {
+			if i == 0 {
+				if offset+2 > len(payload) {
+					return fmt.Errorf("invalid AP payload")
+				}
+				don = utils.BytesToUInt16(payload[offset], payload[offset+1])
+				offset += 2
+			} else {
+				don += uint16(payload[offset]) + 1
+				offset++
+			}
+
+			if offset+2 > len(payload) {
+				return fmt.Errorf("invalid AP payload")
+			}
+			size := int(utils.BytesToUInt16(payload[offset], payload[offset+1]))
+			offset += 2
+			if size == 0 || offset+size > len(payload) {
+				return fmt.Errorf("invalid AP nal size %d", size)
+			}
+			d.push(don, timestamp, append([]byte(nil), payload[offset:offset+size]...))
+			offset += size
+		}
+		break
+	case nalType == HEVCPacketFU:
+		//PayloadHdr | FU header | DONL(起始分片) | FU payload
+		fuType := payload[2] & 0x3F
+		start, end := payload[2]>>7 == 1, payload[2]>>6&0x1 == 1
+		if start {
+			if len(payload) < 5 {
+				return fmt.Errorf("invalid FU payload length %d", len(payload))
+			}
+			d.fu = append(d.fu[:0], payload[0]&0x81|fuType<<1, payload[1])
+			d.fu = append(d.fu, payload[5:]...)
+			d.fuDon = utils.BytesToUInt16(payload[3], payload[4])
+			d.fuTimestamp = timestamp
+			d.fragmented = true
+		} else if !d.fragmented {
+			return fmt.Errorf("missing the start fragment of FU")
+		} else {
+			d.fu = append(d.fu, payload[3:]...)
+		}
+
+		if end && d.fragmented {
+			d.fragmented = false
+			d.push(d.fuDon, d.fuTimestamp, append([]byte(nil), d.fu...))
+		}
+		break
+	default:
+		return fmt.Errorf("unsupported hevc rtp packet type %d", nalType)
+	}
+
+	return nil
+}
+
+// push 按DON插入等待队列, 丢弃迟到和重复的NALU
+func (d *hevcDONDepacketizer) push(don uint16, timestamp uint32, data []byte) {
+	abs := int(don)
+	if d.donStarted {
+		abs = d.maxDon + int(int16(don-uint16(d.maxDon)))
+	} else {
+		d.donStarted = true
+		d.maxDon = abs
+		d.nextDon = abs - d.maxDonDiff
+	}
+
+	if abs < d.nextDon {
+		return
+	} else if abs > d.maxDon {
+		d.maxDon = abs
+	}
+
+	index := len(d.pending)
+	for i, n := range d.pending {
+		if n.don == abs {
+			return
+		} else if abs < n.don {
+			index = i
+			break
+		}
+	}
+	d.pending = append(d.pending, hevcNALUnit{})
+	copy(d.pending[index+1:], d.pending[index:])
+	d.pending[index] = hevcNALUnit{abs, timestamp, data}
+}
+
+// release 输出不会再有更小DON的NALU
+func (d *hevcDONDepacketizer) release() {
+	for len(d.pending) > 0 && d.pending[0].don <= d.maxDon-d.maxDonDiff {
+		n := d.pending[0]
+		d.pending = d.pending[1:]
+		d.nextDon = n.don + 1
+
+		if len(d.au) > 0 && n.timestamp != d.auTimestamp {
+			frame := append([]byte(nil), d.au...)
+			d.handler(frame, d.auTimestamp, libhevc.IsKeyFrame(frame))
+			d.au = d.au[:0]
+		}
+		d.au = append(d.au, libavc.StartCode4...)
+		d.au = append(d.au, n.data...)
+		d.auTimestamp = n.timestamp
+	}
+}
+
+// hevcPacketizer RFC 7798, 输入AnnexB格式的access unit. 不使用DONL(sprop-max-don-diff=0)
+type hevcPacketizer struct {
+	packetWriter
+	ap           []byte //待发送的AP
+	apNalUnits   int
+	apPayloadHdr [2]byte
+}
+
+// NewHEVCPacketizer VPS/SPS/PPS/SEI使用AP聚合, 大的NALU使用FU分片
+func NewHEVCPacketizer(pt int, ssrc uint32, handler encodeHandler) Packetizer {
+	return &hevcPacketizer{packetWriter: newPacketWriter(pt, ssrc, handler)}
+}
+
+func (p *hevcPacketizer) Input(data []byte, timestamp uint32) error {
+	nalUnits := splitNalUnits(data)
+	if len(nalUnits) == 0 {
+		return fmt.Errorf("no nal unit in the hevc frame")
+	}
+
+	for i, nalu := range nalUnits {
+		if len(nalu) < 2 {
+			return fmt.Errorf("invalid hevc nal unit length %d", len(nalu))
+		}
+
+		last := i+1 == len(nalUnits)
+		nalType := libhevc.HEVCNALUnitType(nalu[0] >> 1 & 0x3F)
+		if !last && (nalType == libhevc.HevcNalVPS || nalType == libhevc.HevcNalSPS || nalType == libhevc.HevcNalPPS || nalType == libhevc.HevcNalSeiPPrefix) {
+			if 2+len(p.ap)+2+len(nalu) > p.maxPayloadSize() {
+				p.flushAP(timestamp)
+			}
+			if 2+2+len(nalu) <= p.maxPayloadSize() {
+				p.appendAP(nalu)
+				continue
+			}
+		}
+
+		p.flushAP(timestamp)
+		if len(nalu) <= p.maxPayloadSize() {
+			p.write(timestamp, last, nalu)
+		} else {
+			p.writeFU(nalu, timestamp, last)
+		}
+	}
+	return nil
+}
+
+func (p *hevcPacketizer) appendAP(nalu []byte) {
+	//F为所有NALU的F的或, LayerId和TID取最小值
+	if p.apNalUnits == 0 {
+		p.apPayloadHdr = [2]byte{nalu[0] & 0x81, nalu[1]}
+	} else {
+		f := (p.apPayloadHdr[0] | nalu[0]) & 0x80
+		layerId := utils.MinInt(int(p.apPayloadHdr[0]&0x1)<<5|int(p.apPayloadHdr[1]>>3), int(nalu[0]&0x1)<<5|int(nalu[1]>>3))
+		tid := utils.MinInt(int(p.apPayloadHdr[1]&0x7), int(nalu[1]&0x7))
+		p.apPayloadHdr = [2]byte{f | byte(layerId>>5), byte(layerId<<3) | byte(tid)}
+	}
+
+	var size [2]byte
+	utils.WriteWORD(size[:], uint16(len(nalu)))
+	p.ap = append(p.ap, size[:]...)
+	p.ap = append(p.ap, nalu...)
+	p.apNalUnits++
+}
+
+// flushAP 只有一个NALU时使用Single NAL
+func (p *hevcPacketizer) flushAP(timestamp uint32) {
+	if p.apNalUnits == 1 {
+		p.write(timestamp, false, p.ap[2:])
+	} else if p.apNalUnits > 1 {
+		p.write(timestamp, false, []byte{p.apPayloadHdr[0] | HEVCPacketAP<<1, p.apPayloadHdr[1]}, p.ap)
+	}
+
+	p.ap = p.ap[:0]
+	p.apNalUnits = 0
+}
+
+// writeFU PayloadHdr | FU header | FU payload
+func (p *hevcPacketizer) writeFU(nalu []byte, timestamp uint32, last bool) {
+	header := [3]byte{nalu[0]&0x81 | HEVCPacketFU<<1, nalu[1], 0x80 | nalu[0]>>1&0x3F}
+	data := nalu[2:]
+	maxSize := p.maxPayloadSize() - 3
+	for len(data) > 0 {
+		size := utils.MinInt(len(data), maxSize)
+		end := size == len(data)
+		if end {
+			header[2] |= 0x40
+		}
+
+		p.write(timestamp, last && end, header[:], data[:size])
+		header[2] &= 0x7F
+		data = data[size:]
+	}
+}
+
+// HEVCParameterSets 读取fmtp中的sprop-vps/sprop-sps/sprop-pps, 返回不带起始码的参数集
+// @fmtp key为小写
+func HEVCParameterSets(fmtp map[string]string) (vps, sps, pps [][]byte, err error) {
+	decode := func(value string) ([][]byte, error) {
+		var sets [][]byte
+		for _, set := range strings.Split(value, ",") {
+			if set = strings.TrimSpace(set); set == "" {
+				continue
+			}
+
+			bytes, err := base64.StdEncoding.DecodeString(set)
+			if err != nil {
+				return nil, fmt.Errorf("invalid parameter set %s", set)
+			}
+			sets = append(sets, bytes)
+		}
+		return sets, nil
+	}
+
+	if vps, err = decode(fmtp["sprop-vps"]); err != nil {
+		return
+	} else if sps, err = decode(fmtp["sprop-sps"]); err != nil {
+		return
+	}
+	pps, err = decode(fmtp["sprop-pps"])
+	return
+}
+
+// HEVCMaxDonDiff fmtp中的sprop-max-don-diff, 大于0时负载携带DONL
+func HEVCMaxDonDiff(fmtp map[string]string) int {
+	value, _ := strconv.Atoi(fmtp["sprop-max-don-diff"])
+	return value
+}
+
+// HEVCFmtpParameterSets 生成fmtp中的sprop-vps=...;sprop-sps=...;sprop-pps=...
+func HEVCFmtpParameterSets(vps, sps, pps [][]byte) string {
+	encode := func(sets [][]byte) string {
+		values := make([]string, len(sets))
+		for i, set := range sets {
+			values[i] = base64.StdEncoding.EncodeToString(set)
+		}
+		return strings.Join(values, ",")
+	}
+
+	return fmt.Sprintf("sprop-vps=%s;sprop-sps=%s;sprop-pps=%s", encode(vps), encode(sps), encode(pps))
+}
@@ -0,0 +1,234 @@
+package librtp
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// DefaultJitterBufferSize 最多缓存的包数, 超出后不再等待直接输出
+	DefaultJitterBufferSize = 512
+	// maxLostReport 单次最多上报的丢失序号, 更大的间隔通常是对端重启或seek
+	maxLostReport = 256
+)
+
+// releaseHandler 按序号顺序输出RTP包
+type releaseHandler func(packet []byte)
+
+// lostHandler 检测到序号间隔时回调丢失的序号, 用于生成NACK
+type lostHandler func(sequenceNumbers []uint16)
+
+type jitterPacket struct {
+	seq       int64 //扩展序号
+	timestamp int64 //扩展时间戳
+	arrival   time.Time
+	data      []byte
+}
+
+// JitterBuffer 按扩展序号重排序, 在RTP时间戳对应的播放时间加上latency后输出
+// 播放时间 = 最小传输延迟 + 时间戳, 最小传输延迟由所有包的到达时间估算
+// 非线程安全
+type JitterBuffer struct {
+	clockRate   int
+	latency     time.Duration
+	size        int
+	handler     releaseHandler
+	lostHandler lostHandler
+	statistics  *ReceiverStatistics
+
+	packets    []*jitterPacket //按扩展序号升序
+	started    bool
+	ssrc       uint32
+	nextSeq    int64 //下一个输出的序号
+	highestSeq int64
+
+	//扩展时间戳
+	refTimestamp uint32
+	refExtended  int64
+	//以第一个包为基准的最小传输延迟
+	baseArrival time.Time
+	baseExtend  int64
+	minTransit  time.Duration
+
+	duplicated uint32
+	late       uint32
+	lost       uint32
+}
+
+// NewJitterBuffer clockRate小于等于0时按到达时间加latency输出
+func NewJitterBuffer(clockRate int, latency time.Duration, handler releaseHandler) *JitterBuffer {
+	return &JitterBuffer{
+		clockRate:  clockRate,
+		latency:    latency,
+		size:       DefaultJitterBufferSize,
+		handler:    handler,
+		statistics: NewReceiverStatistics(clockRate),
+	}
+}
+
+func (j *JitterBuffer) SetOnLostHandler(handler lostHandler) {
+	j.lostHandler = handler
+}
+
+func (j *JitterBuffer) SetLatency(latency time.Duration) {
+	j.latency = latency
+}
+
+func (j *JitterBuffer) Statistics() *ReceiverStatistics {
+	return j.statistics
+}
+
+// Duplicated 丢弃的重复包
+func (j *JitterBuffer) Duplicated() uint32 {
+	return j.duplicated
+}
+
+// Late 输出之后才到达的包
+func (j *JitterBuffer) Late() uint32 {
+	return j.late
+}
+
+// Lost 输出时跳过的序号
+func (j *JitterBuffer) Lost() uint32 {
+	return j.lost
+}
+
+// extendSequenceNumber 相对最大序号扩展, 首个序号从1<<32开始避免乱序时为负数
+func (j *JitterBuffer) extendSequenceNumber(seq uint16) int64 {
+	if !j.started {
+		return 1<<32 + int64(seq)
+	}
+	return j.highestSeq + int64(int16(seq-uint16(j.highestSeq)))
+}
+
+func (j *JitterBuffer) extendTimestamp(timestamp uint32) int64 {
+	extended := j.refExtended + int64(int32(timestamp-j.refTimestamp))
+	j.refTimestamp = timestamp
+	j.refExtended = extended
+	return extended
+}
+
+// mediaTime 扩展时间戳相对第一个包的时长
+func (j *JitterBuffer) mediaTime(timestamp int64) time.Duration {
+	//分开计算秒和余数, 避免乘以time.Second溢出
+	d, clockRate := timestamp-j.baseExtend, int64(j.clockRate)
+	return time.Duration(d/clockRate)*time.Second + time.Duration(d%clockRate)*time.Second/time.Duration(clockRate)
+}
+
+// playoutTime 包的输出时间
+func (j *JitterBuffer) playoutTime(packet *jitterPacket) time.Time {
+	if j.clockRate <= 0 {
+		return packet.arrival.Add(j.latency)
+	}
+	return j.baseArrival.Add(j.mediaTime(packet.timestamp) + j.minTransit + j.latency)
+}
+
+// Reset 丢弃缓存的包, 重新开始计算序号和时间戳. 不影响统计
+func (j *JitterBuffer) Reset() {
+	j.packets = j.packets[:0]
+	j.started = false
+}
+
+// Push 输入一个RTP包并输出已到播放时间的包
+func (j *JitterBuffer) Push(data []byte, arrival time.Time) error {
+	h, _, err := parseHeader(data)
+	if err != nil {
+		return err
+	}
+
+	j.statistics.Update(h, arrival)
+
+	//新的流或序号大幅跳变, 输出之前缓存的包后重新开始
+	seq := j.extendSequenceNumber(uint16(h.seq))
+	if j.started && (j.ssrc != h.ssrc || seq-j.highestSeq > maxDropout || j.highestSeq-seq > maxDropout) {
+		j.Flush()
+		j.started = false
+		seq = j.extendSequenceNumber(uint16(h.seq))
+	}
+
+	if !j.started {
+		j.started = true
+		j.ssrc = h.ssrc
+		j.nextSeq = seq
+		j.highestSeq = seq - 1
+		j.refTimestamp = h.timestamp
+		j.refExtended = 0
+		j.baseArrival = arrival
+		j.baseExtend = 0
+		j.minTransit = 0
+	}
+
+	if seq < j.nextSeq {
+		j.late++
+		return nil
+	}
+
+	index := sort.Search(len(j.packets), func(i int) bool {
+		return j.packets[i].seq >= seq
+	})
+	if index < len(j.packets) && j.packets[index].seq == seq {
+		j.duplicated++
+		return nil
+	}
+
+	if seq > j.highestSeq {
+		if gap := seq - j.highestSeq - 1; gap > 0 && j.lostHandler != nil {
+			lost := make([]uint16, 0, gap)
+			for i := j.highestSeq + 1; i < seq && len(lost) < maxLostReport; i++ {
+				lost = append(lost, uint16(i))
+			}
+			j.lostHandler(lost)
+		}
+		j.highestSeq = seq
+	}
+
+	packet := &jitterPacket{seq: seq, timestamp: j.extendTimestamp(h.timestamp), arrival: arrival, data: append([]byte(nil), data...)}
+	if j.clockRate > 0 {
+		if transit := arrival.Sub(j.baseArrival) - j.mediaTime(packet.timestamp); transit < j.minTransit {
+			j.minTransit = transit
+		}
+	}
+
+	j.packets = append(j.packets, nil)
+	copy(j.packets[index+1:], j.packets[index:])
+	j.packets[index] = packet
+
+	j.Release(arrival)
+	return nil
+}
+
+// Release 输出播放时间早于now的包, 跳过仍未到达的序号. 缓存超过上限时不再等待
+func (j *JitterBuffer) Release(now time.Time) {
+	for len(j.packets) > 0 {
+		packet := j.packets[0]
+		if len(j.packets) <= j.size && now.Before(j.playoutTime(packet)) {
+			break
+		}
+		j.pop()
+	}
+}
+
+// NextRelease 下一个包的播放时间
+func (j *JitterBuffer) NextRelease() (time.Time, bool) {
+	if len(j.packets) == 0 {
+		return time.Time{}, false
+	}
+	return j.playoutTime(j.packets[0]), true
+}
+
+// Flush 输出所有缓存的包
+func (j *JitterBuffer) Flush() {
+	for len(j.packets) > 0 {
+		j.pop()
+	}
+}
+
+func (j *JitterBuffer) pop() {
+	packet := j.packets[0]
+	j.packets[0] = nil
+	j.packets = j.packets[1:]
+
+	j.lost += uint32(packet.seq - j.nextSeq)
+	j.nextSeq = packet.seq + 1
+	j.handler(packet.data)
+}
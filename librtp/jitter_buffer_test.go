@@ -0,0 +1,130 @@
+package librtp
+
+import (
+	"avformat/librtcp"
+	"avformat/utils"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestJitterBuffer(t *testing.T) {
+	var released []uint16
+	var lost []uint16
+	buffer := NewJitterBuffer(90000, 100*time.Millisecond, func(packet []byte) {
+		released = append(released, utils.BytesToUInt16(packet[2], packet[3]))
+	})
+	buffer.SetOnLostHandler(func(sequenceNumbers []uint16) {
+		lost = append(lost, sequenceNumbers...)
+	})
+
+	//每个包间隔10ms, 65534的时间戳为0, 65535之后回绕. 0和1乱序, 2重复, 3和4丢失
+	now := time.Unix(1000, 0)
+	input := []struct {
+		seq     uint16
+		arrival int
+	}{{65534, 0}, {65535, 10}, {1, 30}, {0, 32}, {2, 40}, {2, 41}, {5, 70}}
+	for _, packet := range input {
+		if err := buffer.Push(makePacket(packet.seq, uint32(packet.seq+2)*900, false), now.Add(time.Duration(packet.arrival)*time.Millisecond)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(released) != 0 {
+		t.Fatalf("released before the latency %v", released)
+	} else if !reflect.DeepEqual(lost, []uint16{0, 3, 4}) {
+		t.Fatalf("unexpected lost sequence numbers %v", lost)
+	} else if buffer.Duplicated() != 1 {
+		t.Fatalf("unexpected duplicated count %d", buffer.Duplicated())
+	}
+
+	if next, ok := buffer.NextRelease(); !ok || !next.Equal(now.Add(100*time.Millisecond)) {
+		t.Fatalf("unexpected next release time %v", next)
+	}
+
+	//3和4没有到达, 到5的播放时间时跳过
+	buffer.Release(now.Add(145 * time.Millisecond))
+	if !reflect.DeepEqual(released, []uint16{65534, 65535, 0, 1, 2}) {
+		t.Fatalf("unexpected release order %v", released)
+	}
+	buffer.Release(now.Add(170 * time.Millisecond))
+	if !reflect.DeepEqual(released, []uint16{65534, 65535, 0, 1, 2, 5}) || buffer.Lost() != 2 {
+		t.Fatalf("unexpected release order %v lost %d", released, buffer.Lost())
+	}
+
+	//已经输出过的序号
+	_ = buffer.Push(makePacket(4, 6*900, false), now.Add(180*time.Millisecond))
+	if buffer.Late() != 1 || len(released) != 6 {
+		t.Fatalf("the late packet must be dropped")
+	}
+
+	statistics := buffer.Statistics()
+	if statistics.Received() != 8 || statistics.ExtendedHighestSequenceNumber() != 65536+5 || statistics.Lost() != 0 {
+		t.Fatalf("unexpected statistics received %d lost %d", statistics.Received(), statistics.Lost())
+	}
+}
+
+func TestReceiverStatistics(t *testing.T) {
+	statistics := NewReceiverStatistics(90000)
+	now := time.Now()
+	h := NewHeader()
+	h.ssrc = 1
+	for _, seq := range []uint16{65534, 65535, 1, 2} {
+		h.seq = int(seq)
+		statistics.Update(h, now)
+	}
+
+	report := statistics.ReceptionReport(now)
+	//1 packet lost, extended highest sequence number 65536+2
+	if report.TotalLost != 1 || report.LastSequenceNumber != 65538 || report.FractionLost != 256/5 {
+		t.Fatalf("unexpected report block %+v", report)
+	}
+	if report = statistics.ReceptionReport(now); report.FractionLost != 0 {
+		t.Fatalf("the fraction lost must be reset %d", report.FractionLost)
+	}
+}
+
+func TestJitterBufferLongRunning(t *testing.T) {
+	var released []uint16
+	buffer := NewJitterBuffer(90000, 100*time.Millisecond, func(packet []byte) {
+		released = append(released, utils.BytesToUInt16(packet[2], packet[3]))
+	})
+
+	//每个包间隔20000秒, 扩展时间戳超过1<<33, 到达时间为真实的墙上时间
+	now := time.Unix(1700000000, 0)
+	for i := 0; i < 8; i++ {
+		arrival := now.Add(time.Duration(i) * 20000 * time.Second)
+		if err := buffer.Push(makePacket(uint16(i), uint32(int64(i)*20000*90000), false), arrival); err != nil {
+			t.Fatal(err)
+		}
+
+		if next, ok := buffer.NextRelease(); !ok || !next.Equal(arrival.Add(100*time.Millisecond)) {
+			t.Fatalf("unexpected next release time %v of packet %d", next, i)
+		}
+		buffer.Release(arrival.Add(100 * time.Millisecond))
+		if len(released) != i+1 {
+			t.Fatalf("packet %d is not released", i)
+		}
+	}
+
+	if jitter := buffer.Statistics().Jitter(); jitter != 0 {
+		t.Fatalf("unexpected jitter %f", jitter)
+	}
+}
+
+func TestSenderClock(t *testing.T) {
+	clock := NewSenderClock(90000)
+	if _, ok := clock.Time(0); ok {
+		t.Fatalf("the clock is not valid before the sender report")
+	}
+
+	now := time.Unix(1700000000, 0)
+	//时间戳回绕
+	rtpTime := uint32(0xFFFFFFFF - 8999)
+	clock.Update(librtcp.ToNtpTime(now), rtpTime)
+	if wallClock, _ := clock.Time(rtpTime + 45000); !wallClock.Equal(now.Add(500 * time.Millisecond)) {
+		t.Fatalf("unexpected wallclock %v", wallClock)
+	} else if wallClock, _ = clock.Time(rtpTime - 9000); !wallClock.Equal(now.Add(-100 * time.Millisecond)) {
+		t.Fatalf("unexpected wallclock %v", wallClock)
+	}
+}
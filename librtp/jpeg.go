@@ -0,0 +1,562 @@
+package librtp
+
+import (
+	"avformat/utils"
+	"bytes"
+	"fmt"
+)
+
+// RFC 2435 Appendix A/B
+
+var (
+	jpegZigzag = [64]int{
+		0, 1, 8, 16, 9, 2, 3, 10, 17, 24, 32, 25, 18, 11, 4, 5,
+		12, 19, 26, 33, 40, 48, 41, 34, 27, 20, 13, 6, 7, 14, 21, 28,
+		35, 42, 49, 56, 57, 50, 43, 36, 29, 22, 15, 23, 30, 37, 44, 51,
+		58, 59, 52, 45, 38, 31, 39, 46, 53, 60, 61, 54, 47, 55, 62, 63,
+	}
+
+	jpegLumaQuantizer = [64]int{
+		16, 11, 10, 16, 24, 40, 51, 61,
+		12, 12, 14, 19, 26, 58, 60, 55,
+		14, 13, 16, 24, 40, 57, 69, 56,
+		14, 17, 22, 29, 51, 87, 80, 62,
+		18, 22, 37, 56, 68, 109, 103, 77,
+		24, 35, 55, 64, 81, 104, 113, 92,
+		49, 64, 78, 87, 103, 121, 120, 101,
+		72, 92, 95, 98, 112, 100, 103, 99,
+	}
+
+	jpegChromaQuantizer = [64]int{
+		17, 18, 24, 47, 99, 99, 99, 99,
+		18, 21, 26, 66, 99, 99, 99, 99,
+		24, 26, 56, 99, 99, 99, 99, 99,
+		47, 66, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+	}
+
+	jpegLumDcCodeLens = []byte{0, 1, 5, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0, 0, 0}
+	jpegLumDcSymbols  = []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+	jpegLumAcCodeLens = []byte{0, 2, 1, 3, 3, 2, 4, 3, 5, 5, 4, 4, 0, 0, 1, 0x7d}
+	jpegLumAcSymbols  = []byte{
+		0x01, 0x02, 0x03, 0x00, 0x04, 0x11, 0x05, 0x12,
+		0x21, 0x31, 0x41, 0x06, 0x13, 0x51, 0x61, 0x07,
+		0x22, 0x71, 0x14, 0x32, 0x81, 0x91, 0xa1, 0x08,
+		0x23, 0x42, 0xb1, 0xc1, 0x15, 0x52, 0xd1, 0xf0,
+		0x24, 0x33, 0x62, 0x72, 0x82, 0x09, 0x0a, 0x16,
+		0x17, 0x18, 0x19, 0x1a, 0x25, 0x26, 0x27, 0x28,
+		0x29, 0x2a, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39,
+		0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49,
+		0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59,
+		0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69,
+		0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78, 0x79,
+		0x7a, 0x83, 0x84, 0x85, 0x86, 0x87, 0x88, 0x89,
+		0x8a, 0x92, 0x93, 0x94, 0x95, 0x96, 0x97, 0x98,
+		0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7,
+		0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4, 0xb5, 0xb6,
+		0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3, 0xc4, 0xc5,
+		0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2, 0xd3, 0xd4,
+		0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda, 0xe1, 0xe2,
+		0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9, 0xea,
+		0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+		0xf9, 0xfa,
+	}
+	jpegChmDcCodeLens = []byte{0, 3, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0}
+	jpegChmDcSymbols  = []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+	jpegChmAcCodeLens = []byte{0, 2, 1, 2, 4, 4, 3, 4, 7, 5, 4, 4, 0, 1, 2, 0x77}
+	jpegChmAcSymbols  = []byte{
+		0x00, 0x01, 0x02, 0x03, 0x11, 0x04, 0x05, 0x21,
+		0x31, 0x06, 0x12, 0x41, 0x51, 0x07, 0x61, 0x71,
+		0x13, 0x22, 0x32, 0x81, 0x08, 0x14, 0x42, 0x91,
+		0xa1, 0xb1, 0xc1, 0x09, 0x23, 0x33, 0x52, 0xf0,
+		0x15, 0x62, 0x72, 0xd1, 0x0a, 0x16, 0x24, 0x34,
+		0xe1, 0x25, 0xf1, 0x17, 0x18, 0x19, 0x1a, 0x26,
+		0x27, 0x28, 0x29, 0x2a, 0x35, 0x36, 0x37, 0x38,
+		0x39, 0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48,
+		0x49, 0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58,
+		0x59, 0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68,
+		0x69, 0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78,
+		0x79, 0x7a, 0x82, 0x83, 0x84, 0x85, 0x86, 0x87,
+		0x88, 0x89, 0x8a, 0x92, 0x93, 0x94, 0x95, 0x96,
+		0x97, 0x98, 0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5,
+		0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4,
+		0xb5, 0xb6, 0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3,
+		0xc4, 0xc5, 0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2,
+		0xd3, 0xd4, 0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda,
+		0xe2, 0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9,
+		0xea, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+		0xf9, 0xfa,
+	}
+)
+
+// jpegHeader RFC 2435 3.1 main JPEG header
+type jpegHeader struct {
+	typeSpecific   byte
+	fragmentOffset int //3 bytes
+	t              byte
+	q              byte
+	width          int //8-pixel multiples
+	height         int
+
+	//3.1.7 restart marker header
+	restartInterval int
+	restartCount    int
+
+	//3.1.8 quantization table header
+	precision byte
+	tables    []byte
+}
+
+// makeQuantizationTables RFC 2435 Appendix A. 输出zigzag顺序的亮度和色度表
+func makeQuantizationTables(q int) []byte {
+	factor := q
+	if factor < 1 {
+		factor = 1
+	} else if factor > 99 {
+		factor = 99
+	}
+	if q < 50 {
+		q = 5000 / factor
+	} else {
+		q = 200 - factor*2
+	}
+
+	tables := make([]byte, 128)
+	for i := 0; i < 64; i++ {
+		lq := (jpegLumaQuantizer[jpegZigzag[i]]*q + 50) / 100
+		cq := (jpegChromaQuantizer[jpegZigzag[i]]*q + 50) / 100
+		//Limit the quantizers to 1 <= q <= 255
+		if lq < 1 {
+			lq = 1
+		} else if lq > 255 {
+			lq = 255
+		}
+		if cq < 1 {
+			cq = 1
+		} else if cq > 255 {
+			cq = 255
+		}
+		tables[i] = byte(lq)
+		tables[64+i] = byte(cq)
+	}
+	return tables
+}
+
+// readJPEGHeader 解析main JPEG header及可选的restart/quantization头
+// @return 头和扫描数据
+func readJPEGHeader(payload []byte) (*jpegHeader, []byte, error) {
+	if len(payload) < 8 {
+		return nil, nil, fmt.Errorf("invalid jpeg rtp payload length %d", len(payload))
+	}
+
+	h := &jpegHeader{
+		typeSpecific:   payload[0],
+		fragmentOffset: int(utils.BytesToUInt24(payload[1], payload[2], payload[3])),
+		t:              payload[4],
+		q:              payload[5],
+		width:          int(payload[6]),
+		height:         int(payload[7]),
+	}
+	offset := 8
+
+	if h.t >= 64 && h.t <= 127 {
+		if len(payload) < offset+4 {
+			return nil, nil, fmt.Errorf("invalid restart marker header")
+		}
+		h.restartInterval = int(utils.BytesToUInt16(payload[offset], payload[offset+1]))
+		h.restartCount = int(utils.BytesToUInt16(payload[offset+2], payload[offset+3]) & 0x3FFF)
+		offset += 4
+	}
+
+	if h.q >= 128 && h.fragmentOffset == 0 {
+		if len(payload) < offset+4 {
+			return nil, nil, fmt.Errorf("invalid quantization table header")
+		}
+		h.precision = payload[offset+1]
+		length := int(utils.BytesToUInt16(payload[offset+2], payload[offset+3]))
+		offset += 4
+		if len(payload) < offset+length {
+			return nil, nil, fmt.Errorf("invalid quantization table length %d", length)
+		}
+		h.tables = payload[offset : offset+length]
+		offset += length
+	}
+
+	return h, payload[offset:], nil
+}
+
+// writeHuffmanTable DHT
+func writeHuffmanTable(dst []byte, class, id byte, codeLens, symbols []byte) []byte {
+	dst = append(dst, 0xFF, 0xC4)
+	length := 2 + 1 + len(codeLens) + len(symbols)
+	dst = append(dst, byte(length>>8), byte(length), class<<4|id)
+	dst = append(dst, codeLens...)
+	return append(dst, symbols...)
+}
+
+// makeJPEGHeaders RFC 2435 Appendix B. 生成SOI到SOS之间的所有段
+func makeJPEGHeaders(dst []byte, h *jpegHeader, tables []byte, precision byte) []byte {
+	//SOI
+	dst = append(dst, 0xFF, 0xD8)
+	//APP0 JFIF
+	dst = append(dst, 0xFF, 0xE0, 0x00, 0x10, 'J', 'F', 'I', 'F', 0x00, 0x01, 0x01, 0x00, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00)
+
+	//DQT
+	for i, offset := 0, 0; offset < len(tables); i++ {
+		size := 64
+		if precision>>uint(i)&0x1 == 1 {
+			size = 128
+		}
+		if offset+size > len(tables) {
+			break
+		}
+
+		length := 2 + 1 + size
+		dst = append(dst, 0xFF, 0xDB, byte(length>>8), byte(length), precision>>uint(i)&0x1<<4|byte(i))
+		dst = append(dst, tables[offset:offset+size]...)
+		offset += size
+	}
+
+	//DRI
+	if h.restartInterval > 0 {
+		dst = append(dst, 0xFF, 0xDD, 0x00, 0x04, byte(h.restartInterval>>8), byte(h.restartInterval))
+	}
+
+	//SOF0 baseline
+	width, height := h.width*8, h.height*8
+	lumaSampling := byte(0x21) //type 0: 4:2:2
+	if h.t&0x3F == 1 {
+		lumaSampling = 0x22 //type 1: 4:2:0
+	}
+	chromaTable := byte(1)
+	if len(tables) <= 64 {
+		chromaTable = 0
+	}
+	dst = append(dst, 0xFF, 0xC0, 0x00, 0x11, 0x08,
+		byte(height>>8), byte(height), byte(width>>8), byte(width), 0x03,
+		0x01, lumaSampling, 0x00,
+		0x02, 0x11, chromaTable,
+		0x03, 0x11, chromaTable)
+
+	//DHT
+	dst = writeHuffmanTable(dst, 0, 0, jpegLumDcCodeLens, jpegLumDcSymbols)
+	dst = writeHuffmanTable(dst, 1, 0, jpegLumAcCodeLens, jpegLumAcSymbols)
+	dst = writeHuffmanTable(dst, 0, 1, jpegChmDcCodeLens, jpegChmDcSymbols)
+	dst = writeHuffmanTable(dst, 1, 1, jpegChmAcCodeLens, jpegChmAcSymbols)
+
+	//SOS
+	return append(dst, 0xFF, 0xDA, 0x00, 0x0C, 0x03,
+		0x01, 0x00,
+		0x02, 0x11,
+		0x03, 0x11,
+		0x00, 0x3F, 0x00)
+}
+
+type jpegDepacketizer struct {
+	frameAssembler
+	header     *jpegHeader
+	tables     []byte
+	precision  byte
+	qCache     map[byte][]byte //Q>=128且长度为0时沿用之前的表
+	nextOffset int
+}
+
+// NewJPEGDepacketizer RFC 2435, 输出完整的JFIF图像
+func NewJPEGDepacketizer(handler decodeHandler) Depacketizer {
+	return &jpegDepacketizer{frameAssembler: frameAssembler{handler: handler}, qCache: make(map[byte][]byte, 2)}
+}
+
+func (d *jpegDepacketizer) Input(packet []byte) error {
+	h, payload, err := parseHeader(packet)
+	if err != nil {
+		return err
+	}
+
+	if !d.checkPacket(h, nil) {
+		return nil
+	}
+	if err = d.depacketize(payload); err != nil {
+		d.lost = true
+	}
+
+	if h.m == 1 {
+		if len(d.buffer) > 0 && !d.lost {
+			//EOI
+			if n := len(d.buffer); n < 2 || d.buffer[n-2] != 0xFF || d.buffer[n-1] != 0xD9 {
+				d.write([]byte{0xFF, 0xD9})
+			}
+		}
+		d.flush(nil)
+	}
+	return err
+}
+
+func (d *jpegDepacketizer) depacketize(payload []byte) error {
+	h, data, err := readJPEGHeader(payload)
+	if err != nil {
+		return err
+	}
+
+	//只支持type 0/1及带restart marker的64/65
+	if h.t&^0x40 > 1 {
+		return fmt.Errorf("unsupported jpeg type %d", h.t)
+	}
+
+	if h.fragmentOffset == 0 {
+		if len(d.buffer) > 0 {
+			d.lost = true
+			d.flush(nil)
+		}
+
+		var tables []byte
+		precision := byte(0)
+		if h.q < 128 {
+			tables = makeQuantizationTables(int(h.q))
+		} else if len(h.tables) > 0 {
+			tables = make([]byte, len(h.tables))
+			copy(tables, h.tables)
+			precision = h.precision
+			if h.q != 255 {
+				d.qCache[h.q] = tables
+			}
+		} else if cache, ok := d.qCache[h.q]; ok {
+			tables = cache
+		} else {
+			return fmt.Errorf("missing quantization table of Q %d", h.q)
+		}
+
+		d.header = h
+		d.buffer = makeJPEGHeaders(d.buffer, h, tables, precision)
+		d.nextOffset = 0
+	} else if d.header == nil || len(d.buffer) == 0 {
+		return fmt.Errorf("missing the first fragment of jpeg")
+	} else if h.fragmentOffset != d.nextOffset {
+		return fmt.Errorf("discontinuous fragment offset %d, expected %d", h.fragmentOffset, d.nextOffset)
+	}
+
+	d.write(data)
+	d.nextOffset += len(data)
+	return nil
+}
+
+// marshal 生成RTP负载头, 第一个分片携带量化表
+func (h *jpegHeader) marshal(dst []byte, fragmentOffset int) []byte {
+	dst = append(dst, h.typeSpecific, byte(fragmentOffset>>16), byte(fragmentOffset>>8), byte(fragmentOffset), h.t, h.q, byte(h.width), byte(h.height))
+
+	if h.t >= 64 && h.t <= 127 {
+		//包边界不与restart interval对齐, F=1 L=1 count=0x3FFF
+		dst = append(dst, byte(h.restartInterval>>8), byte(h.restartInterval), 0xFF, 0xFF)
+	}
+
+	if h.q >= 128 && fragmentOffset == 0 {
+		dst = append(dst, 0, h.precision, byte(len(h.tables)>>8), byte(len(h.tables)))
+		dst = append(dst, h.tables...)
+	}
+	return dst
+}
+
+// checkHuffmanTables DHT段中的表必须与RFC 2435 Appendix B的表相同, RTP不传输霍夫曼表
+func checkHuffmanTables(segment []byte) error {
+	for len(segment) > 0 {
+		if len(segment) < 17 {
+			return fmt.Errorf("invalid jpeg dht segment")
+		}
+
+		total := 0
+		for _, count := range segment[1:17] {
+			total += int(count)
+		}
+		if len(segment) < 17+total {
+			return fmt.Errorf("invalid jpeg dht segment")
+		}
+
+		codeLens, symbols := segment[1:17], segment[17:17+total]
+		var standard bool
+		switch segment[0] {
+		case 0x00:
+			standard = bytes.Equal(codeLens, jpegLumDcCodeLens) && bytes.Equal(symbols, jpegLumDcSymbols)
+			break
+		case 0x10:
+			standard = bytes.Equal(codeLens, jpegLumAcCodeLens) && bytes.Equal(symbols, jpegLumAcSymbols)
+			break
+		case 0x01:
+			standard = bytes.Equal(codeLens, jpegChmDcCodeLens) && bytes.Equal(symbols, jpegChmDcSymbols)
+			break
+		case 0x11:
+			standard = bytes.Equal(codeLens, jpegChmAcCodeLens) && bytes.Equal(symbols, jpegChmAcSymbols)
+			break
+		default:
+			//SOS中未使用的表
+			standard = true
+			break
+		}
+
+		if !standard {
+			return fmt.Errorf("non-standard huffman table %x", segment[0])
+		}
+		segment = segment[17+total:]
+	}
+	return nil
+}
+
+// parseJPEG 解析baseline JPEG, 生成RFC 2435头. 只支持YUV 4:2:2和4:2:0
+// @return 头和扫描数据(不包含EOI)
+func parseJPEG(data []byte) (*jpegHeader, []byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, nil, fmt.Errorf("missing jpeg soi marker")
+	}
+
+	h := &jpegHeader{q: 255}
+	quantizationTables := make(map[byte][]byte, 2)
+	precisions := make(map[byte]byte, 2)
+	//SOF0中的亮度和色度量化表ID
+	lumaTable, chromaTable := -1, -1
+
+	for offset := 2; ; {
+		if offset+1 >= len(data) || data[offset] != 0xFF {
+			return nil, nil, fmt.Errorf("invalid jpeg marker at %d", offset)
+		}
+		//跳过填充的0xFF
+		for offset+2 < len(data) && data[offset+1] == 0xFF {
+			offset++
+		}
+
+		marker := data[offset+1]
+		offset += 2
+		if marker == 0x01 || marker >= 0xD0 && marker <= 0xD7 {
+			continue
+		} else if marker == 0xD9 {
+			return nil, nil, fmt.Errorf("missing jpeg sos marker")
+		} else if offset+2 > len(data) {
+			return nil, nil, fmt.Errorf("invalid jpeg segment %x", marker)
+		}
+
+		length := int(utils.BytesToUInt16(data[offset], data[offset+1]))
+		if length < 2 || offset+length > len(data) {
+			return nil, nil, fmt.Errorf("invalid jpeg segment %x length %d", marker, length)
+		}
+		segment := data[offset+2 : offset+length]
+		offset += length
+
+		switch marker {
+		case 0xDB:
+			//DQT中可能有多个表
+			for len(segment) > 0 {
+				pq, tq := segment[0]>>4, segment[0]&0xF
+				size := 64 * (int(pq) + 1)
+				if pq > 1 || len(segment) < 1+size {
+					return nil, nil, fmt.Errorf("invalid jpeg dqt segment")
+				}
+				quantizationTables[tq] = segment[1 : 1+size]
+				precisions[tq] = pq
+				segment = segment[1+size:]
+			}
+			break
+		case 0xC4:
+			if err := checkHuffmanTables(segment); err != nil {
+				return nil, nil, err
+			}
+			break
+		case 0xDD:
+			if len(segment) < 2 {
+				return nil, nil, fmt.Errorf("invalid jpeg dri segment")
+			}
+			h.restartInterval = int(utils.BytesToUInt16(segment[0], segment[1]))
+			break
+		case 0xC0:
+			if len(segment) < 15 || segment[0] != 8 || segment[5] != 3 {
+				return nil, nil, fmt.Errorf("only 8-bit YUV jpeg is supported")
+			}
+
+			height := int(utils.BytesToUInt16(segment[1], segment[2]))
+			width := int(utils.BytesToUInt16(segment[3], segment[4]))
+			if width <= 0 || height <= 0 || width > 2040 || height > 2040 || width%8 != 0 || height%8 != 0 {
+				return nil, nil, fmt.Errorf("unsupported jpeg size %dx%d", width, height)
+			}
+			h.width, h.height = width/8, height/8
+
+			//Y 2x1或2x2, Cb/Cr 1x1且使用同一个量化表
+			switch segment[7] {
+			case 0x21:
+				h.t = 0
+				break
+			case 0x22:
+				h.t = 1
+				break
+			default:
+				return nil, nil, fmt.Errorf("unsupported jpeg sampling factor %x", segment[7])
+			}
+			if segment[10] != 0x11 || segment[13] != 0x11 || segment[11] != segment[14] {
+				return nil, nil, fmt.Errorf("unsupported jpeg chroma components")
+			}
+			lumaTable, chromaTable = int(segment[8]), int(segment[11])
+			break
+		case 0xDA:
+			if lumaTable < 0 {
+				return nil, nil, fmt.Errorf("missing jpeg sof0 marker")
+			} else if len(segment) < 7 || segment[0] != 3 || segment[2] != 0x00 || segment[4] != 0x11 || segment[6] != 0x11 {
+				return nil, nil, fmt.Errorf("unsupported jpeg scan")
+			}
+
+			luma, chroma := quantizationTables[byte(lumaTable)], quantizationTables[byte(chromaTable)]
+			if luma == nil || chroma == nil {
+				return nil, nil, fmt.Errorf("missing jpeg quantization table")
+			}
+			h.tables = append(append(h.tables, luma...), chroma...)
+			h.precision = precisions[byte(lumaTable)] | precisions[byte(chromaTable)]<<1
+			if h.restartInterval > 0 {
+				h.t += 64
+			}
+
+			//熵编码数据中的0xFF后为0x00或RSTn, 第一个FFD9为EOI
+			scan := data[offset:]
+			if index := bytes.Index(scan, []byte{0xFF, 0xD9}); index >= 0 {
+				scan = scan[:index]
+			}
+			return h, scan, nil
+		default:
+			//SOF1-SOF15
+			if marker > 0xC0 && marker <= 0xCF && marker != 0xC8 && marker != 0xCC {
+				return nil, nil, fmt.Errorf("only baseline jpeg is supported")
+			}
+			break
+		}
+	}
+}
+
+// jpegPacketizer RFC 2435, 量化表在每帧的第一个包中传输(Q=255)
+type jpegPacketizer struct {
+	packetWriter
+	header []byte
+}
+
+// NewJPEGPacketizer 输入完整的baseline JPEG图像. 霍夫曼表必须为标准表
+func NewJPEGPacketizer(pt int, ssrc uint32, handler encodeHandler) Packetizer {
+	return &jpegPacketizer{packetWriter: newPacketWriter(pt, ssrc, handler)}
+}
+
+func (p *jpegPacketizer) Input(data []byte, timestamp uint32) error {
+	h, scan, err := parseJPEG(data)
+	if err != nil {
+		return err
+	} else if len(scan) == 0 || len(scan) > 0xFFFFFF {
+		return fmt.Errorf("invalid jpeg scan length %d", len(scan))
+	}
+
+	for offset := 0; offset < len(scan); {
+		p.header = h.marshal(p.header[:0], offset)
+		n := p.maxPayloadSize() - len(p.header)
+		if n <= 0 {
+			return fmt.Errorf("the jpeg quantization tables are too large")
+		} else if n > len(scan)-offset {
+			n = len(scan) - offset
+		}
+
+		p.write(timestamp, offset+n == len(scan), p.header, scan[offset:offset+n])
+		offset += n
+	}
+	return nil
+}
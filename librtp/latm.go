@@ -0,0 +1,191 @@
+package librtp
+
+import (
+	"avformat/utils"
+	"encoding/hex"
+	"fmt"
+)
+
+// LATMConfig RFC 6416 MP4A-LATM的StreamMuxConfig, 只支持audioMuxVersion=0的单program单layer
+type LATMConfig struct {
+	NumSubFrames int //每个AudioMuxElement包含NumSubFrames+1个AU
+	AudioConfig  *utils.MPEG4AudioConfig
+	// OtherDataBits 每个AudioMuxElement结尾的otherData长度
+	OtherDataBits int
+}
+
+// ParseStreamMuxConfig 解析fmtp中config的十六进制数据
+func ParseStreamMuxConfig(data []byte) (*LATMConfig, error) {
+	reader := utils.NewBitReader(data)
+	//读取失败后返回0, 最后统一检查err
+	var err error
+	read := func(n int) int {
+		if err != nil {
+			return 0
+		}
+		value, e := reader.ReadBits(n)
+		err = e
+		return int(value)
+	}
+
+	if audioMuxVersion := read(1); audioMuxVersion != 0 {
+		return nil, fmt.Errorf("unsupported audioMuxVersion %d", audioMuxVersion)
+	} else if allStreamsSameTimeFraming := read(1); err == nil && allStreamsSameTimeFraming != 1 {
+		return nil, fmt.Errorf("allStreamsSameTimeFraming must be 1")
+	}
+
+	config := &LATMConfig{NumSubFrames: read(6)}
+	if numProgram, numLayer := read(4), read(3); numProgram != 0 || numLayer != 0 {
+		return nil, fmt.Errorf("only one program and one layer are supported")
+	} else if err != nil {
+		return nil, err
+	}
+
+	if config.AudioConfig, err = utils.ReadMpeg4AudioConfig(reader); err != nil {
+		return nil, err
+	}
+
+	if frameLengthType := read(3); frameLengthType != 0 {
+		return nil, fmt.Errorf("unsupported frameLengthType %d", frameLengthType)
+	}
+	//latmBufferFullness
+	read(8)
+
+	if otherDataPresent := read(1); otherDataPresent == 1 {
+		//otherDataLenBits, escape编码
+		for escape := 1; escape == 1 && err == nil; {
+			escape = read(1)
+			config.OtherDataBits = config.OtherDataBits<<8 | read(8)
+		}
+	}
+	if crcCheckPresent := read(1); crcCheckPresent == 1 {
+		read(8)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid StreamMuxConfig:%s", err.Error())
+	}
+	return config, nil
+}
+
+// WriteStreamMuxConfig 生成audioMuxVersion=0, 单个subframe的StreamMuxConfig
+func WriteStreamMuxConfig(config *utils.MPEG4AudioConfig) []byte {
+	writer := utils.NewBitWriter()
+	//audioMuxVersion, allStreamsSameTimeFraming, numSubFrames, numProgram, numLayer
+	writer.WriteBits(0, 1)
+	writer.WriteBits(1, 1)
+	writer.WriteBits(0, 6)
+	writer.WriteBits(0, 4)
+	writer.WriteBits(0, 3)
+	utils.WriteMpeg4AudioConfig(writer, config)
+	//frameLengthType, latmBufferFullness, otherDataPresent, crcCheckPresent
+	writer.WriteBits(0, 3)
+	writer.WriteBits(0xFF, 8)
+	writer.WriteBits(0, 1)
+	writer.WriteBits(0, 1)
+	return writer.Bytes()
+}
+
+// LATMFmtp 生成MP4A-LATM的fmtp参数, 不包含payload type
+func LATMFmtp(config *utils.MPEG4AudioConfig) string {
+	return fmt.Sprintf("profile-level-id=30;object=%d;cpresent=0;config=%s", config.ObjectType, hex.EncodeToString(WriteStreamMuxConfig(config)))
+}
+
+// latmDepacketizer cpresent=0, StreamMuxConfig由fmtp带外传输
+// AudioMuxElement可能被分为多个RTP包, marker位表示结束
+type latmDepacketizer struct {
+	frameAssembler
+	config *LATMConfig
+}
+
+func NewLATMDepacketizer(config *LATMConfig, handler decodeHandler) Depacketizer {
+	return &latmDepacketizer{frameAssembler: frameAssembler{handler: handler}, config: config}
+}
+
+func (d *latmDepacketizer) Input(packet []byte) error {
+	h, payload, err := parseHeader(packet)
+	if err != nil {
+		return err
+	}
+
+	if d.stale(h) {
+		return nil
+	}
+	//未结束的AudioMuxElement
+	if len(d.buffer) > 0 && h.timestamp != d.timestamp {
+		d.lost = true
+	}
+	d.checkPacket(h, nil)
+	d.write(payload)
+	if h.m != 1 {
+		return nil
+	}
+
+	if !d.lost {
+		err = d.readAudioMuxElement(d.buffer, h.timestamp)
+	}
+	d.buffer = d.buffer[:0]
+	d.lost = false
+	return err
+}
+
+// readAudioMuxElement PayloadLengthInfo | PayloadMux, 共NumSubFrames+1个
+func (d *latmDepacketizer) readAudioMuxElement(data []byte, timestamp uint32) error {
+	frameSize := AACFrameSize
+	if d.config.AudioConfig != nil {
+		frameSize = d.config.AudioConfig.FrameSize()
+	}
+
+	offset := 0
+	for i := 0; i <= d.config.NumSubFrames; i++ {
+		//PayloadLengthInfo, 0xFF表示后面还有长度
+		size := 0
+		for {
+			if offset >= len(data) {
+				return fmt.Errorf("invalid PayloadLengthInfo")
+			}
+			size += int(data[offset])
+			offset++
+			if data[offset-1] != 0xFF {
+				break
+			}
+		}
+
+		if offset+size > len(data) {
+			return fmt.Errorf("invalid PayloadMux size %d", size)
+		}
+		au := make([]byte, size)
+		copy(au, data[offset:offset+size])
+		d.handler(au, timestamp+uint32(i*frameSize), true)
+		offset += size
+	}
+	return nil
+}
+
+// latmPacketizer cpresent=0, 每个AudioMuxElement一个AU, 超过MTU时分片
+type latmPacketizer struct {
+	packetWriter
+}
+
+func NewLATMPacketizer(pt int, ssrc uint32, handler encodeHandler) Packetizer {
+	return &latmPacketizer{newPacketWriter(pt, ssrc, handler)}
+}
+
+func (p *latmPacketizer) Input(data []byte, timestamp uint32) error {
+	element := make([]byte, 0, len(data)/255+1+len(data))
+	for size := len(data); ; size -= 255 {
+		if size < 255 {
+			element = append(element, byte(size))
+			break
+		}
+		element = append(element, 0xFF)
+	}
+	element = append(element, data...)
+
+	for len(element) > 0 {
+		size := utils.MinInt(len(element), p.maxPayloadSize())
+		p.write(timestamp, size == len(element), element[:size])
+		element = element[size:]
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+package librtp
+
+import (
+	"avformat/librtcp"
+	"avformat/utils"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// RFC 3830 6 payload types
+const (
+	mikeyPayloadLast  = 0
+	mikeyPayloadKEMAC = 1
+	mikeyPayloadT     = 5
+	mikeyPayloadSP    = 10
+	mikeyPayloadRAND  = 11
+
+	mikeyDataTypePSKInit = 0
+	mikeyMapTypeSRTPID   = 0
+	mikeyTSTypeNTPUTC    = 0
+	mikeyProtTypeSRTP    = 0
+	mikeyKeyTypeTEKSalt  = 3
+	mikeyRandLength      = 16
+
+	//RFC 3830 6.10.1 SRTP policy参数
+	mikeyParamEncAlg        = 0
+	mikeyParamEncKeyLength  = 1
+	mikeyParamAuthAlg       = 2
+	mikeyParamAuthKeyLength = 3
+	mikeyParamSaltLength    = 4
+	mikeyParamSRTPEnc       = 7
+	mikeyParamSRTCPEnc      = 8
+	mikeyParamSRTPAuth      = 10
+	mikeyParamAuthTagLength = 11
+	mikeyParamAEADTagLength = 20 //RFC 7714 14.2
+
+	mikeyEncAlgAESCM  = 1
+	mikeyEncAlgAESGCM = 6
+	mikeyAuthAlgHMAC  = 1
+)
+
+// MIKEY RFC 3830 pre-shared key模式的初始化消息, 只有一个SRTP crypto session.
+// KEMAC的加密和MAC都为NULL, 密钥明文传输, 只能在TLS等安全通道中使用, 例如RTSPS SETUP请求的KeyMgmt头
+type MIKEY struct {
+	Profile SRTPProfile
+	Key     []byte //master key, 作为TEK直接使用, 不经过TGK派生
+	Salt    []byte
+	SSRC    uint32
+	ROC     uint32
+}
+
+// NewMIKEY 随机生成master key和master salt
+func NewMIKEY(profile SRTPProfile, ssrc uint32) (*MIKEY, error) {
+	key, salt, err := GenerateSRTPMasterKey(profile)
+	if err != nil {
+		return nil, err
+	}
+	return &MIKEY{Profile: profile, Key: key, Salt: salt, SSRC: ssrc}, nil
+}
+
+// NewSRTPContext 使用消息中的密钥创建SRTP上下文
+func (m *MIKEY) NewSRTPContext() (*SRTPContext, error) {
+	context, err := NewSRTPContext(m.Profile, m.Key, m.Salt)
+	if err != nil {
+		return nil, err
+	}
+	context.SetROC(m.SSRC, m.ROC)
+	return context, nil
+}
+
+// policyParams SP负载的参数, 按type-length-value编码
+func (m *MIKEY) policyParams() ([]byte, error) {
+	params, ok := srtpProfiles[m.Profile]
+	if !ok {
+		return nil, fmt.Errorf("unsupported srtp profile %d", m.Profile)
+	}
+
+	var values [][2]int
+	if params.aead {
+		values = [][2]int{
+			{mikeyParamEncAlg, mikeyEncAlgAESGCM},
+			{mikeyParamEncKeyLength, params.keyLength},
+			{mikeyParamSaltLength, params.saltLength},
+			{mikeyParamAEADTagLength, params.authTagLength},
+		}
+	} else {
+		values = [][2]int{
+			{mikeyParamEncAlg, mikeyEncAlgAESCM},
+			{mikeyParamEncKeyLength, params.keyLength},
+			{mikeyParamAuthAlg, mikeyAuthAlgHMAC},
+			{mikeyParamAuthKeyLength, params.authKeyLength},
+			{mikeyParamSaltLength, params.saltLength},
+			{mikeyParamAuthTagLength, params.authTagLength},
+		}
+	}
+	values = append(values, [2]int{mikeyParamSRTPEnc, 1}, [2]int{mikeyParamSRTCPEnc, 1})
+	if !params.aead {
+		values = append(values, [2]int{mikeyParamSRTPAuth, 1})
+	}
+
+	data := make([]byte, 0, len(values)*3)
+	for _, value := range values {
+		data = append(data, byte(value[0]), 1, byte(value[1]))
+	}
+	return data, nil
+}
+
+// Marshal HDR | T | RAND | SP | KEMAC
+func (m *MIKEY) Marshal() ([]byte, error) {
+	params, err := m.policyParams()
+	if err != nil {
+		return nil, err
+	} else if len(m.Key) != m.Profile.KeyLength() || len(m.Salt) != m.Profile.SaltLength() {
+		return nil, fmt.Errorf("invalid srtp key length %d salt length %d", len(m.Key), len(m.Salt))
+	}
+
+	random := make([]byte, 4+mikeyRandLength)
+	if _, err = rand.Read(random); err != nil {
+		return nil, err
+	}
+
+	//HDR: version | data type | next payload | V, PRF func | CSB ID | #CS | CS ID map type | policy no, SSRC, ROC
+	hdr := make([]byte, 19)
+	hdr[0], hdr[1], hdr[2] = 1, mikeyDataTypePSKInit, mikeyPayloadT
+	copy(hdr[4:], random[:4])
+	hdr[8], hdr[9] = 1, mikeyMapTypeSRTPID
+	binary.BigEndian.PutUint32(hdr[11:], m.SSRC)
+	binary.BigEndian.PutUint32(hdr[15:], m.ROC)
+
+	//T: NTP-UTC
+	t := make([]byte, 10)
+	t[0], t[1] = mikeyPayloadRAND, mikeyTSTypeNTPUTC
+	binary.BigEndian.PutUint64(t[2:], librtcp.ToNtpTime(time.Now()))
+
+	//RAND
+	rnd := append([]byte{mikeyPayloadSP, mikeyRandLength}, random[4:]...)
+
+	//SP: policy no | prot type | policy param length | params
+	sp := make([]byte, 5, 5+len(params))
+	sp[0], sp[1], sp[2] = mikeyPayloadKEMAC, 0, mikeyProtTypeSRTP
+	binary.BigEndian.PutUint16(sp[3:], uint16(len(params)))
+	sp = append(sp, params...)
+
+	//Key data: next payload | type, KV | key data length | TEK | salt length | salt
+	keyData := make([]byte, 6+len(m.Key)+len(m.Salt))
+	keyData[0], keyData[1] = mikeyPayloadLast, mikeyKeyTypeTEKSalt<<4
+	binary.BigEndian.PutUint16(keyData[2:], uint16(len(m.Key)))
+	copy(keyData[4:], m.Key)
+	binary.BigEndian.PutUint16(keyData[4+len(m.Key):], uint16(len(m.Salt)))
+	copy(keyData[6+len(m.Key):], m.Salt)
+
+	//KEMAC: next payload | encr alg(NULL) | encr data length | encr data | mac alg(NULL)
+	kemac := make([]byte, 4, 5+len(keyData))
+	kemac[0], kemac[1] = mikeyPayloadLast, 0
+	binary.BigEndian.PutUint16(kemac[2:], uint16(len(keyData)))
+	kemac = append(append(kemac, keyData...), 0)
+
+	return bytes.Join([][]byte{hdr, t, rnd, sp, kemac}, nil), nil
+}
+
+// ParseMIKEY 解析NULL加密的pre-shared key初始化消息, 例如RTSP服务端收到的KeyMgmt头
+func ParseMIKEY(data []byte) (*MIKEY, error) {
+	//HDR + 一个SRTP-ID map
+	if len(data) < 19 {
+		return nil, fmt.Errorf("invalid mikey length %d", len(data))
+	} else if data[0] != 1 || data[1] != mikeyDataTypePSKInit {
+		return nil, fmt.Errorf("unsupported mikey version %d data type %d", data[0], data[1])
+	} else if data[8] != 1 || data[9] != mikeyMapTypeSRTPID {
+		return nil, fmt.Errorf("unsupported mikey crypto sessions %d map type %d", data[8], data[9])
+	}
+
+	m := &MIKEY{SSRC: binary.BigEndian.Uint32(data[11:]), ROC: binary.BigEndian.Uint32(data[15:])}
+	var params map[int]int
+	next, offset := int(data[2]), 19
+	for next != mikeyPayloadLast {
+		if offset+2 > len(data) {
+			return nil, fmt.Errorf("invalid mikey payload %d", next)
+		}
+
+		payload := next
+		next = int(data[offset])
+		switch payload {
+		case mikeyPayloadT:
+			offset += 10
+			break
+		case mikeyPayloadRAND:
+			offset += 2 + int(data[offset+1])
+			break
+		case mikeyPayloadSP:
+			if offset+5 > len(data) || data[offset+2] != mikeyProtTypeSRTP {
+				return nil, fmt.Errorf("invalid mikey security policy")
+			}
+			length := int(utils.BytesToUInt16(data[offset+3], data[offset+4]))
+			offset += 5
+			if offset+length > len(data) {
+				return nil, fmt.Errorf("invalid mikey security policy length %d", length)
+			}
+			params = make(map[int]int)
+			for i := offset; i+2 <= offset+length; {
+				size := int(data[i+1])
+				if i+2+size > offset+length {
+					return nil, fmt.Errorf("invalid mikey policy param %d", data[i])
+				}
+				value := 0
+				for _, b := range data[i+2 : i+2+size] {
+					value = value<<8 | int(b)
+				}
+				params[int(data[i])] = value
+				i += 2 + size
+			}
+			offset += length
+			break
+		case mikeyPayloadKEMAC:
+			if offset+4 > len(data) || data[offset+1] != 0 {
+				return nil, fmt.Errorf("unsupported mikey KEMAC encryption")
+			}
+			length := int(utils.BytesToUInt16(data[offset+2], data[offset+3]))
+			offset += 4
+			if offset+length+1 > len(data) || data[offset+length] != 0 {
+				return nil, fmt.Errorf("unsupported mikey KEMAC mac")
+			}
+			if err := m.parseKeyData(data[offset : offset+length]); err != nil {
+				return nil, err
+			}
+			offset += length + 1
+			break
+		default:
+			return nil, fmt.Errorf("unsupported mikey payload %d", payload)
+		}
+	}
+
+	if m.Key == nil {
+		return nil, fmt.Errorf("the mikey message has no key")
+	}
+	profile, err := mikeyProfile(params, len(m.Key))
+	if err != nil {
+		return nil, err
+	} else if len(m.Salt) != profile.SaltLength() {
+		return nil, fmt.Errorf("invalid srtp salt length %d", len(m.Salt))
+	}
+	m.Profile = profile
+	return m, nil
+}
+
+// parseKeyData 只支持TEK+SALT
+func (m *MIKEY) parseKeyData(data []byte) error {
+	if len(data) < 4 || data[1]>>4 != mikeyKeyTypeTEKSalt {
+		return fmt.Errorf("unsupported mikey key data")
+	}
+	keyLength := int(utils.BytesToUInt16(data[2], data[3]))
+	if 4+keyLength+2 > len(data) {
+		return fmt.Errorf("invalid mikey key length %d", keyLength)
+	}
+	saltLength := int(utils.BytesToUInt16(data[4+keyLength], data[5+keyLength]))
+	if 6+keyLength+saltLength > len(data) {
+		return fmt.Errorf("invalid mikey salt length %d", saltLength)
+	}
+
+	m.Key = append([]byte(nil), data[4:4+keyLength]...)
+	m.Salt = append([]byte(nil), data[6+keyLength:6+keyLength+saltLength]...)
+	return nil
+}
+
+// mikeyProfile 根据SP参数查找SRTP保护方案, 没有SP时使用RFC 3830 6.10.1的默认值AES_CM_128_HMAC_SHA1_80
+func mikeyProfile(params map[int]int, keyLength int) (SRTPProfile, error) {
+	encAlg, ok := params[mikeyParamEncAlg]
+	if !ok {
+		encAlg = mikeyEncAlgAESCM
+	}
+	tagLength, ok := params[mikeyParamAuthTagLength]
+	if !ok {
+		tagLength = 10
+	}
+
+	for profile, p := range srtpProfiles {
+		if p.keyLength != keyLength {
+			continue
+		} else if p.aead && encAlg == mikeyEncAlgAESGCM {
+			return profile, nil
+		} else if !p.aead && encAlg == mikeyEncAlgAESCM && p.authTagLength == tagLength {
+			return profile, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported mikey srtp policy encryption %d key length %d", encAlg, keyLength)
+}
@@ -0,0 +1,142 @@
+package librtp
+
+import (
+	"avformat/libmpeg"
+	"bytes"
+	"fmt"
+)
+
+// RFC 2250 2 MPEG-2 TS over RTP, PT 33, 时钟频率90000. 负载为整数个188字节的TS包, IPTV/SMPTE 2022通常为7个
+
+const (
+	MP2TPayloadType   = 33
+	MP2TClockRate     = 90000
+	MP2TPacketsPerRTP = 7
+)
+
+// syncTSPackets 从第一个有效的同步字节开始, 截取整数个TS包
+// @return 对齐的TS包和跳过的字节数
+func syncTSPackets(data []byte) ([]byte, int) {
+	offset := 0
+	for offset < len(data) {
+		index := bytes.IndexByte(data[offset:], libmpeg.TSSyncByte)
+		if index < 0 {
+			return nil, len(data)
+		}
+
+		//下一个TS包的同步字节也正确, 或是最后一个包
+		offset += index
+		if next := offset + libmpeg.TSPacketSize; next >= len(data) || data[next] == libmpeg.TSSyncByte {
+			break
+		}
+		offset++
+	}
+
+	n := (len(data) - offset) / libmpeg.TSPacketSize * libmpeg.TSPacketSize
+	return data[offset : offset+n], len(data) - n
+}
+
+// isTSRandomAccess TS包的adaptation field中设置了random_access_indicator
+func isTSRandomAccess(packet []byte) bool {
+	return packet[3]&0x20 != 0 && packet[4] > 0 && packet[5]&0x40 != 0
+}
+
+// mp2tPacketizer 凑满MP2TPacketsPerRTP个TS包后发送一个RTP包
+type mp2tPacketizer struct {
+	packetWriter
+	pending   []byte
+	timestamp uint32 //pending中第一个TS包的时间戳
+}
+
+// NewMP2TPacketizer 输入TS复用器输出的数据, 长度不要求是188的整数倍. 时间戳为90KHz的发送时间
+func NewMP2TPacketizer(pt int, ssrc uint32, handler encodeHandler) Packetizer {
+	return &mp2tPacketizer{packetWriter: newPacketWriter(pt, ssrc, handler)}
+}
+
+func (p *mp2tPacketizer) Input(data []byte, timestamp uint32) error {
+	size := MP2TPacketsPerRTP * libmpeg.TSPacketSize
+	for len(data) > 0 {
+		//每个TS包的开始位置必须是同步字节
+		if len(p.pending)%libmpeg.TSPacketSize == 0 && data[0] != libmpeg.TSSyncByte {
+			index := bytes.IndexByte(data, libmpeg.TSSyncByte)
+			if index < 0 {
+				return fmt.Errorf("missing ts sync byte")
+			}
+			data = data[index:]
+		}
+
+		if len(p.pending) == 0 {
+			p.timestamp = timestamp
+		}
+		n := size - len(p.pending)
+		if n > len(data) {
+			n = len(data)
+		}
+		p.pending = append(p.pending, data[:n]...)
+		data = data[n:]
+
+		if len(p.pending) == size {
+			p.write(p.timestamp, false, p.pending)
+			p.pending = p.pending[:0]
+		}
+	}
+	return nil
+}
+
+// MP2TDepacketizer 输出每个RTP包中的TS包, 可直接输入libmpeg.TSDeMuxer
+type MP2TDepacketizer struct {
+	handler       decodeHandler
+	discontinuity func(lost int)
+	seq           uint16
+	started       bool
+	lost          int
+}
+
+// NewMP2TDepacketizer 回调的keyFrame表示TS包中有random_access_indicator
+// @discontinuity 检测到丢包时回调丢失的RTP包数, 例如调用TSDeMuxer.Discontinuity. 可为nil
+func NewMP2TDepacketizer(handler decodeHandler, discontinuity func(lost int)) *MP2TDepacketizer {
+	return &MP2TDepacketizer{handler: handler, discontinuity: discontinuity}
+}
+
+// Lost 累计丢失的RTP包数
+func (d *MP2TDepacketizer) Lost() int {
+	return d.lost
+}
+
+func (d *MP2TDepacketizer) Input(packet []byte) error {
+	h, payload, err := parseHeader(packet)
+	if err != nil {
+		return err
+	}
+
+	seq := uint16(h.seq)
+	if d.started {
+		if gap := int16(seq - d.seq); gap <= 0 {
+			return nil
+		} else if gap > 1 {
+			d.lost += int(gap) - 1
+			if d.discontinuity != nil {
+				d.discontinuity(int(gap) - 1)
+			}
+		}
+	}
+	d.started = true
+	d.seq = seq
+
+	packets, skipped := syncTSPackets(payload)
+	if len(packets) > 0 {
+		keyFrame := false
+		for i := 0; i < len(packets) && !keyFrame; i += libmpeg.TSPacketSize {
+			keyFrame = isTSRandomAccess(packets[i:])
+		}
+
+		data := make([]byte, len(packets))
+		copy(data, packets)
+		d.handler(data, h.timestamp, keyFrame)
+	}
+
+	if skipped > 0 {
+		return fmt.Errorf("skipped %d bytes to resync the ts packets", skipped)
+	}
+	return nil
+}
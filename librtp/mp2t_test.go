@@ -0,0 +1,192 @@
+package librtp
+
+import (
+	"avformat/libmpeg"
+	"avformat/utils"
+	"bytes"
+	"testing"
+)
+
+// makeTSPackets PID 0x100的TS包, 第一个包的adaptation field设置random_access_indicator
+func makeTSPackets(count int) []byte {
+	var data []byte
+	for i := 0; i < count; i++ {
+		packet := bytes.Repeat([]byte{byte(i)}, libmpeg.TSPacketSize)
+		packet[0], packet[1], packet[2], packet[3] = libmpeg.TSSyncByte, 0x01, 0x00, 0x10|byte(i&0xF)
+		if i == 0 {
+			packet[1] |= 0x40
+			packet[3] |= 0x20
+			packet[4], packet[5] = 1, 0x40
+		}
+		data = append(data, packet...)
+	}
+	return data
+}
+
+func TestMP2TPacketizer(t *testing.T) {
+	var packets [][]byte
+	packetizer := NewMP2TPacketizer(MP2TPayloadType, 0x12345678, func(data []byte, timestamp uint32) {
+		packets = append(packets, append([]byte(nil), data...))
+	})
+
+	//前面的无效数据被跳过, 任意长度输入
+	ts := makeTSPackets(22)
+	stream := append([]byte{0x00, 0x11, 0x22}, ts...)
+	for i := 0; i < len(stream); i += 100 {
+		end := i + 100
+		if end > len(stream) {
+			end = len(stream)
+		}
+		if err := packetizer.Input(stream[i:end], uint32(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(packets) != 3 {
+		t.Fatalf("unexpected packet count %d", len(packets))
+	}
+	for i, packet := range packets {
+		if len(packet) != FixedHeaderLength+MP2TPacketsPerRTP*libmpeg.TSPacketSize || !bytes.Equal(packet[FixedHeaderLength:], ts[i*1316:(i+1)*1316]) {
+			t.Fatalf("unexpected packet %d", i)
+		}
+	}
+
+	type frame struct {
+		data     []byte
+		keyFrame bool
+	}
+	var frames []frame
+	var lost []int
+	depacketizer := NewMP2TDepacketizer(func(data []byte, timestamp uint32, keyFrame bool) {
+		frames = append(frames, frame{data, keyFrame})
+	}, func(n int) {
+		lost = append(lost, n)
+	})
+
+	//丢失第二个包
+	for _, i := range []int{0, 2} {
+		if err := depacketizer.Input(packets[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(frames) != 2 || !frames[0].keyFrame || frames[1].keyFrame || !bytes.Equal(frames[1].data, ts[2*1316:3*1316]) {
+		t.Fatalf("unexpected ts packets")
+	} else if depacketizer.Lost() != 1 || len(lost) != 1 || lost[0] != 1 {
+		t.Fatalf("unexpected lost %d", depacketizer.Lost())
+	}
+
+	//负载前后的无效数据
+	frames = nil
+	depacketizer = NewMP2TDepacketizer(func(data []byte, timestamp uint32, keyFrame bool) {
+		frames = append(frames, frame{data, keyFrame})
+	}, nil)
+	payload := append(append([]byte{0x47, 0x01, 0x02}, ts[:2*libmpeg.TSPacketSize]...), 0x47, 0x00)
+	if err := depacketizer.Input(makePacket(1, 0, false, payload...)); err == nil {
+		t.Fatalf("the invalid bytes are not reported")
+	} else if len(frames) != 1 || !bytes.Equal(frames[0].data, ts[:2*libmpeg.TSPacketSize]) {
+		t.Fatalf("failed to resync the ts packets")
+	}
+}
+
+// makeTSPacket 填充到188字节, 不足的部分为0xFF
+func makeTSPacket(pid int, start bool, counter int, payload []byte) []byte {
+	packet := bytes.Repeat([]byte{0xFF}, libmpeg.TSPacketSize)
+	packet[0], packet[1], packet[2], packet[3] = libmpeg.TSSyncByte, byte(pid>>8&0x1F), byte(pid), 0x10|byte(counter&0xF)
+	if start {
+		packet[1] |= 0x40
+	}
+	copy(packet[4:], payload)
+	return packet
+}
+
+// makeAudioPES 音频PES分为count个TS包, 第一个包带PTS的PES头
+// @return TS包和ES数据
+func makeAudioPES(pid int, pts int64, count int, fill byte) ([][]byte, []byte) {
+	header := []byte{0x00, 0x00, 0x01, libmpeg.StreamIdAudio, 0x00, 0x00, 0x80, 0x80, 0x05,
+		0x21 | byte(pts>>29&0xE), byte(pts >> 22), byte(pts>>14&0xFE) | 1, byte(pts >> 7), byte(pts<<1) | 1}
+
+	var packets [][]byte
+	var es []byte
+	for i := 0; i < count; i++ {
+		payload := bytes.Repeat([]byte{fill + byte(i)}, libmpeg.TSPacketSize-4)
+		if i == 0 {
+			payload = append(append([]byte(nil), header...), payload[len(header):]...)
+			es = append(es, payload[len(header):]...)
+		} else {
+			es = append(es, payload...)
+		}
+		packets = append(packets, makeTSPacket(pid, i == 0, i, payload))
+	}
+	return packets, es
+}
+
+func TestMP2TDepacketizerToTSDeMuxer(t *testing.T) {
+	//PAT: program 1 -> PMT PID 0x100, PMT: stream type 0x0F -> PID 0x101
+	pat := makeTSPacket(libmpeg.PSIPAT, true, 0, []byte{0x00, 0x00, 0xB0, 0x0D, 0x00, 0x01, 0xC1, 0x00, 0x00,
+		0x00, 0x01, 0xE1, 0x00, 0x00, 0x00, 0x00, 0x00})
+	pmt := makeTSPacket(0x100, true, 0, []byte{0x00, 0x02, 0xB0, 0x12, 0x00, 0x01, 0xC1, 0x00, 0x00,
+		0xE1, 0x01, 0xF0, 0x00, 0x0F, 0xE1, 0x01, 0xF0, 0x00, 0x00, 0x00, 0x00, 0x00})
+
+	pes1, es1 := makeAudioPES(0x101, 90000, 2, 0x10)
+	pes2, _ := makeAudioPES(0x101, 93600, 3, 0x20)
+	pes3, es3 := makeAudioPES(0x101, 97200, 2, 0x30)
+	pes4, es4 := makeAudioPES(0x101, 100800, 2, 0x40)
+	pes5, _ := makeAudioPES(0x101, 104400, 1, 0x50)
+
+	type frame struct {
+		data []byte
+		pts  int64
+	}
+	var frames []frame
+	demuxer := libmpeg.NewTSDeMuxer(func(buffer utils.ByteBuffer, keyFrame bool, streamType int, pts, dts int64) {
+		frames = append(frames, frame{append([]byte(nil), buffer.ToBytes()...), pts})
+	})
+
+	depacketizer := NewMP2TDepacketizer(func(data []byte, timestamp uint32, keyFrame bool) {
+		if err := demuxer.Input(data); err != nil {
+			t.Fatal(err)
+		}
+	}, func(lost int) {
+		demuxer.Discontinuity()
+	})
+
+	join := func(packets ...[]byte) []byte {
+		return bytes.Join(packets, nil)
+	}
+	//第3个RTP包丢失, PES2被截断. 第5个RTP包前有无效数据
+	rtp := [][]byte{
+		join(pat, pmt, pes1[0], pes1[1]),
+		join(pes2[0]),
+		join(pes2[1]),
+		join(pes2[2], pes3[0], pes3[1]),
+		join([]byte{0x47, 0x01, 0x02}, pes4[0], pes4[1]),
+	}
+	for i, payload := range rtp {
+		if i == 2 {
+			continue
+		}
+
+		err := depacketizer.Input(makePacket(uint16(i), 0, false, payload...))
+		if i == 4 && err == nil {
+			t.Fatalf("the invalid bytes are not reported")
+		} else if i != 4 && err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//PES1在PES2开始时输出, PES2被丢弃, PES3在PES4开始时输出
+	if depacketizer.Lost() != 1 || len(frames) != 2 {
+		t.Fatalf("unexpected frame count %d lost %d", len(frames), depacketizer.Lost())
+	} else if frames[0].pts != 90000 || !bytes.Equal(frames[0].data, es1) {
+		t.Fatalf("unexpected first pes pts %d", frames[0].pts)
+	} else if frames[1].pts != 97200 || !bytes.Equal(frames[1].data, es3) {
+		t.Fatalf("the pes after the loss is not delivered, pts %d", frames[1].pts)
+	}
+
+	//TSDeMuxer自身在0x47之前的无效数据后重新同步
+	if err := demuxer.Input(join([]byte{0x00, 0x47, 0x12}, pes5[0])); err != nil {
+		t.Fatal(err)
+	} else if len(frames) != 3 || frames[2].pts != 100800 || !bytes.Equal(frames[2].data, es4) {
+		t.Fatalf("failed to resync the ts demuxer")
+	}
+}
@@ -0,0 +1,223 @@
+package librtp
+
+import (
+	"fmt"
+)
+
+// RFC 2250 3.5 MPEG Audio, 时钟频率90000. 负载前4字节为MBZ(16bits)和Frag_offset(16bits)
+
+const (
+	MPAClockRate    = 90000
+	mpaHeaderLength = 4
+)
+
+var (
+	//kbps, 下标为bitrate_index
+	mpaBitRates = [2][3][15]int{
+		//MPEG-1 Layer I/II/III
+		{
+			{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+			{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+		},
+		//MPEG-2/MPEG-2.5 Layer I/II/III
+		{
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		},
+	}
+
+	mpaSampleRates = [3]int{44100, 48000, 32000}
+)
+
+// mpaFrameHeader MPEG-1/2/2.5 Layer I/II/III帧头
+type mpaFrameHeader struct {
+	layer      int
+	sampleRate int
+	samples    int //每帧的采样数
+	length     int //帧长度, 包含帧头
+}
+
+// parseMPAFrameHeader ISO/IEC 11172-3 2.4.1.3, 不支持free format
+func parseMPAFrameHeader(data []byte) (*mpaFrameHeader, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1]&0xE0 != 0xE0 {
+		return nil, fmt.Errorf("missing mpeg audio sync word")
+	}
+
+	version := int(data[1] >> 3 & 0x3) //0-MPEG-2.5 2-MPEG-2 3-MPEG-1
+	layer := 4 - int(data[1]>>1&0x3)
+	bitRateIndex := int(data[2] >> 4)
+	sampleRateIndex := int(data[2] >> 2 & 0x3)
+	padding := int(data[2] >> 1 & 0x1)
+	if version == 1 || layer == 4 || bitRateIndex == 0 || bitRateIndex == 15 || sampleRateIndex == 3 {
+		return nil, fmt.Errorf("invalid mpeg audio header %x", data[:4])
+	}
+
+	h := &mpaFrameHeader{layer: layer, sampleRate: mpaSampleRates[sampleRateIndex]}
+	lsf := 0
+	if version != 3 {
+		lsf = 1
+		h.sampleRate /= 2
+		if version == 0 {
+			h.sampleRate /= 2
+		}
+	}
+
+	bitRate := mpaBitRates[lsf][layer-1][bitRateIndex] * 1000
+	switch layer {
+	case 1:
+		h.samples = 384
+		h.length = (12*bitRate/h.sampleRate + padding) * 4
+		break
+	case 2:
+		h.samples = 1152
+		h.length = 144*bitRate/h.sampleRate + padding
+		break
+	case 3:
+		//MPEG-2/2.5 Layer III每帧576个采样
+		h.samples = 1152 >> uint(lsf)
+		h.length = (144>>uint(lsf))*bitRate/h.sampleRate + padding
+		break
+	}
+	return h, nil
+}
+
+// duration 帧时长, 单位为90KHz
+func (h *mpaFrameHeader) duration() uint32 {
+	return uint32(h.samples * MPAClockRate / h.sampleRate)
+}
+
+// mpaPacketizer 多个小的帧合并为一个包, 超过MTU的帧按Frag_offset分片
+type mpaPacketizer struct {
+	packetWriter
+	frames    []byte //待发送的完整帧
+	timestamp uint32
+}
+
+// NewMPAPacketizer 输入一个或多个完整的MPEG音频帧, 时间戳为第一帧的90KHz pts
+func NewMPAPacketizer(pt int, ssrc uint32, handler encodeHandler) Packetizer {
+	return &mpaPacketizer{packetWriter: newPacketWriter(pt, ssrc, handler)}
+}
+
+func (p *mpaPacketizer) flush() {
+	if len(p.frames) > 0 {
+		p.write(p.timestamp, false, []byte{0, 0, 0, 0}, p.frames)
+		p.frames = p.frames[:0]
+	}
+}
+
+func (p *mpaPacketizer) Input(data []byte, timestamp uint32) error {
+	size := p.maxPayloadSize() - mpaHeaderLength
+	for len(data) > 0 {
+		h, err := parseMPAFrameHeader(data)
+		if err != nil {
+			return err
+		} else if h.length > len(data) {
+			return fmt.Errorf("incomplete mpeg audio frame %d/%d", len(data), h.length)
+		}
+
+		frame := data[:h.length]
+		data = data[h.length:]
+		if len(p.frames)+len(frame) > size {
+			p.flush()
+		}
+
+		if len(frame) <= size {
+			if len(p.frames) == 0 {
+				p.timestamp = timestamp
+			}
+			p.frames = append(p.frames, frame...)
+		} else {
+			//分片的时间戳相同
+			for offset := 0; offset < len(frame); offset += size {
+				n := len(frame) - offset
+				if n > size {
+					n = size
+				}
+				p.write(timestamp, false, []byte{0, 0, byte(offset >> 8), byte(offset)}, frame[offset:offset+n])
+			}
+		}
+		timestamp += h.duration()
+	}
+
+	p.flush()
+	return nil
+}
+
+// mpaDepacketizer 输出单个完整的帧, 合并的帧按帧头拆分, 分片的帧按Frag_offset重组
+type mpaDepacketizer struct {
+	handler   decodeHandler
+	buffer    []byte //分片的帧
+	length    int    //分片帧的完整长度
+	timestamp uint32
+	seq       uint16
+	started   bool
+}
+
+// NewMPADepacketizer RFC 2250 MPEG音频, 时间戳为90KHz
+func NewMPADepacketizer(handler decodeHandler) Depacketizer {
+	return &mpaDepacketizer{handler: handler}
+}
+
+func (d *mpaDepacketizer) output(frame []byte, timestamp uint32) {
+	data := make([]byte, len(frame))
+	copy(data, frame)
+	d.handler(data, timestamp, true)
+}
+
+func (d *mpaDepacketizer) Input(packet []byte) error {
+	h, payload, err := parseHeader(packet)
+	if err != nil {
+		return err
+	} else if len(payload) < mpaHeaderLength {
+		return fmt.Errorf("invalid mpeg audio payload length %d", len(payload))
+	}
+
+	seq := uint16(h.seq)
+	if d.started && int16(seq-d.seq) <= 0 {
+		return nil
+	}
+	continuous := d.started && seq == d.seq+1
+	d.started = true
+	d.seq = seq
+
+	offset := int(payload[2])<<8 | int(payload[3])
+	payload = payload[mpaHeaderLength:]
+	if offset > 0 {
+		//丢包或不属于同一帧时丢弃
+		if len(d.buffer) == 0 || !continuous || h.timestamp != d.timestamp || offset != len(d.buffer) {
+			d.buffer = d.buffer[:0]
+			return fmt.Errorf("discontinuous mpeg audio fragment offset %d", offset)
+		}
+
+		d.buffer = append(d.buffer, payload...)
+		if len(d.buffer) >= d.length {
+			d.output(d.buffer[:d.length], d.timestamp)
+			d.buffer = d.buffer[:0]
+		}
+		return nil
+	}
+
+	d.buffer = d.buffer[:0]
+	timestamp := h.timestamp
+	for len(payload) > 0 {
+		frame, err := parseMPAFrameHeader(payload)
+		if err != nil {
+			return err
+		}
+
+		//帧的剩余部分在后续分片中
+		if frame.length > len(payload) {
+			d.buffer = append(d.buffer, payload...)
+			d.length = frame.length
+			d.timestamp = timestamp
+			break
+		}
+
+		d.output(payload[:frame.length], timestamp)
+		payload = payload[frame.length:]
+		timestamp += frame.duration()
+	}
+	return nil
+}
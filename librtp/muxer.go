@@ -6,6 +6,7 @@ import (
 
 type encodeHandler func(data []byte, timestamp uint32)
 
+// Muxer 按PacketMaxSize切分负载, 不识别NALU. H264使用NewH264Packetizer
 type Muxer struct {
 	buffer       []byte
 	header       *Header
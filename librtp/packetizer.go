@@ -0,0 +1,96 @@
+package librtp
+
+import (
+	"avformat/libavc"
+	"fmt"
+	"math/rand"
+)
+
+// Packetizer 将帧封装为RTP包, 通过encodeHandler输出. 输出的数据在下次调用前有效
+type Packetizer interface {
+	Input(data []byte, timestamp uint32) error
+}
+
+// packetWriter 生成RTP头, 每输出一个包seq加1
+type packetWriter struct {
+	header  *Header
+	buffer  []byte
+	handler encodeHandler
+}
+
+// newPacketWriter 随机生成初始seq
+func newPacketWriter(pt int, ssrc uint32, handler encodeHandler) packetWriter {
+	header := NewHeader()
+	header.pt = byte(pt)
+	header.seq = rand.Intn(0xFFFF)
+	header.ssrc = ssrc
+	return packetWriter{header: header, buffer: make([]byte, PacketMaxSize), handler: handler}
+}
+
+// maxPayloadSize 单个包的最大负载
+func (w *packetWriter) maxPayloadSize() int {
+	return PacketMaxSize - FixedHeaderLength
+}
+
+// write 输出一个RTP包, 负载由多段数据拼接
+func (w *packetWriter) write(timestamp uint32, marker bool, payloads ...[]byte) {
+	w.header.timestamp = timestamp
+	w.header.m = 0
+	if marker {
+		w.header.m = 1
+	}
+
+	n := w.header.toBytes(w.buffer)
+	for _, payload := range payloads {
+		n += copy(w.buffer[n:], payload)
+	}
+	w.handler(w.buffer[:n], timestamp)
+}
+
+// audioPacketizer 每帧一个包, 用于G.711/Opus等帧较小的编码
+type audioPacketizer struct {
+	packetWriter
+}
+
+func NewAudioPacketizer(pt int, ssrc uint32, handler encodeHandler) Packetizer {
+	return &audioPacketizer{newPacketWriter(pt, ssrc, handler)}
+}
+
+func (p *audioPacketizer) Input(data []byte, timestamp uint32) error {
+	if len(data) > p.maxPayloadSize() {
+		return fmt.Errorf("the audio frame is too large %d", len(data))
+	}
+
+	p.write(timestamp, false, data)
+	return nil
+}
+
+// splitNalUnits 按起始码拆分AnnexB格式的数据, 返回不带起始码的NALU. 没有起始码时整体作为一个NALU
+func splitNalUnits(data []byte) [][]byte {
+	var nalUnits [][]byte
+	start := libavc.FindStartCode(data, 0)
+	if start < 0 {
+		if len(data) > 0 {
+			nalUnits = append(nalUnits, data)
+		}
+		return nalUnits
+	}
+
+	for start >= 0 {
+		next := libavc.FindStartCode(data, start)
+		end := len(data)
+		if next >= 0 {
+			end = next - 3
+		}
+
+		//去掉trailing_zero_8bits和4字节起始码的第一个0
+		for end > start && data[end-1] == 0 {
+			end--
+		}
+		if end > start {
+			nalUnits = append(nalUnits, data[start:end])
+		}
+		start = next
+	}
+	return nalUnits
+}
@@ -0,0 +1,314 @@
+package librtp
+
+import (
+	"avformat/libavc"
+	"bytes"
+	"image"
+	"image/jpeg"
+	"strings"
+	"testing"
+)
+
+func TestAACPacketizer(t *testing.T) {
+	var frames []frame
+	depacketizer := NewAACDepacketizer(13, 3, 3, func(data []byte, timestamp uint32, keyFrame bool) {
+		frames = append(frames, frame{data, timestamp, keyFrame})
+	})
+
+	var packets int
+	packetizer := NewAACPacketizer(97, 0x12345678, 13, 3, func(data []byte, timestamp uint32) {
+		packets++
+		if err := depacketizer.Input(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	small := bytes.Repeat([]byte{0x21}, 300)
+	large := bytes.Repeat([]byte{0x01, 0x02, 0x03}, 1000)
+	if err := packetizer.Input(small, 1024); err != nil {
+		t.Fatal(err)
+	}
+	if err := packetizer.Input(large, 2048); err != nil {
+		t.Fatal(err)
+	}
+
+	//大于MTU的AU被分片
+	if packets != 4 || len(frames) != 2 {
+		t.Fatalf("unexpected packets %d frames %d", packets, len(frames))
+	}
+	if !bytes.Equal(frames[0].data, small) || frames[0].timestamp != 1024 || !bytes.Equal(frames[1].data, large) || frames[1].timestamp != 2048 {
+		t.Fatalf("the aac frames are different")
+	}
+}
+
+func TestAACInterleavedPacketizer(t *testing.T) {
+	var frames []frame
+	depacketizer := NewAACDepacketizer(13, 3, 3, func(data []byte, timestamp uint32, keyFrame bool) {
+		frames = append(frames, frame{data, timestamp, keyFrame})
+	})
+
+	//3个包为一组, 每个包2个AU, 第一个包发送AU 0,3
+	var packets [][]byte
+	packetizer := NewAACInterleavedPacketizer(97, 0x12345678, 13, 3, 3, 3, 2, func(data []byte, timestamp uint32) {
+		packets = append(packets, append([]byte(nil), data...))
+	})
+
+	base := uint32(0x90000000)
+	large := bytes.Repeat([]byte{0x01}, 2000)
+	for i := 0; i < 12; i++ {
+		data := []byte{byte(i), byte(i)}
+		if i == 7 {
+			data = large
+		}
+		if err := packetizer.Input(data, base+uint32(i*AACFrameSize)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//第二组的AU 7分为2个包, 同一个包的AU 10单独发送
+	if len(packets) != 8 {
+		t.Fatalf("unexpected packets %d", len(packets))
+	}
+	if h, payload, _ := parseHeader(packets[0]); h.timestamp != base || !bytes.Equal(payload, []byte{0x00, 0x20, 0x00, 0x10, 0x00, 0x12, 0x00, 0x00, 0x03, 0x03}) {
+		t.Fatalf("unexpected packet %d % x", h.timestamp, payload)
+	}
+
+	for _, packet := range packets {
+		if err := depacketizer.Input(packet); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(frames) != 12 {
+		t.Fatalf("expected 12 frames, got %d", len(frames))
+	}
+	for i, f := range frames {
+		if f.timestamp != base+uint32(i*AACFrameSize) || (i != 7 && f.data[0] != byte(i)) || (i == 7 && !bytes.Equal(f.data, large)) {
+			t.Fatalf("unexpected frame %d %d", i, f.timestamp)
+		}
+	}
+}
+
+func TestAudioPacketizer(t *testing.T) {
+	var seqs []uint16
+	packetizer := NewAudioPacketizer(0, 0x12345678, func(data []byte, timestamp uint32) {
+		h, payload, err := parseHeader(data)
+		if err != nil {
+			t.Fatal(err)
+		} else if h.pt != 0 || h.timestamp != timestamp || len(payload) != 160 {
+			t.Fatalf("unexpected packet %+v", h)
+		}
+		seqs = append(seqs, uint16(h.seq))
+	})
+
+	for i := 0; i < 3; i++ {
+		_ = packetizer.Input(make([]byte, 160), uint32(i*160))
+	}
+	if len(seqs) != 3 || seqs[1] != seqs[0]+1 || seqs[2] != seqs[1]+1 {
+		t.Fatalf("unexpected seqs %v", seqs)
+	}
+}
+
+func TestH264Packetizer(t *testing.T) {
+	sps := []byte{0x67, 0x42, 0x00, 0x1F, 0xE9}
+	pps := []byte{0x68, 0xCE, 0x3C, 0x80}
+	idr := append([]byte{0x65}, bytes.Repeat([]byte{0x88, 0x84}, 2000)...)
+	var au []byte
+	for _, nalu := range [][]byte{sps, pps, idr} {
+		au = append(au, libavc.StartCode4...)
+		au = append(au, nalu...)
+	}
+
+	for _, mode := range []int{0, 1} {
+		var frames []frame
+		depacketizer := NewH264Depacketizer(func(data []byte, timestamp uint32, keyFrame bool) {
+			frames = append(frames, frame{data, timestamp, keyFrame})
+		})
+
+		var headers []*Header
+		var types []byte
+		packetizer := NewH264Packetizer(96, 0x12345678, mode, func(data []byte, timestamp uint32) {
+			h, payload, err := parseHeader(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			headers = append(headers, h)
+			types = append(types, payload[0]&0x1F)
+			if err = depacketizer.Input(data); err != nil {
+				t.Fatal(err)
+			}
+		})
+		packetizer.(*h264Packetizer).header.seq = 0xFFFF
+
+		err := packetizer.Input(au, 3000)
+		if mode == 0 {
+			//大的NALU不能使用Single NAL
+			if err == nil {
+				t.Fatalf("packetization-mode 0 must reject the large nal unit")
+			}
+			continue
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		//STAP-A(SPS+PPS), FU-A * 3
+		if len(types) != 4 || types[0] != H264PacketSTAPA || types[1] != H264PacketFUA {
+			t.Fatalf("unexpected packet types %v", types)
+		}
+		for i, h := range headers {
+			if (h.m == 1) != (i == len(headers)-1) {
+				t.Fatalf("the marker must be set on the last packet only")
+			}
+			if h.seq != (0xFFFF+i)&0xFFFF {
+				t.Fatalf("unexpected seq %d", h.seq)
+			}
+		}
+		if len(frames) != 1 || !bytes.Equal(frames[0].data, au) || !frames[0].keyFrame {
+			t.Fatalf("the h264 frames are different")
+		}
+	}
+
+	var seqs []int
+	packetizer := NewH264Packetizer(96, 0x12345678, 0, func(data []byte, timestamp uint32) {
+		h, _, _ := parseHeader(data)
+		seqs = append(seqs, h.seq)
+	})
+	if err := packetizer.Input(append(append([]byte{0, 0, 1}, sps...), append([]byte{0, 0, 0, 1}, pps...)...), 0); err != nil || len(seqs) != 2 {
+		t.Fatalf("packetization-mode 0 failed %v %v", seqs, err)
+	}
+}
+
+func TestSplitNalUnits(t *testing.T) {
+	nalUnits := splitNalUnits([]byte{0, 0, 0, 1, 0x67, 0x42, 0, 0, 1, 0x68, 0xCE, 0, 0, 0, 1, 0x65, 0x88})
+	if len(nalUnits) != 3 || !bytes.Equal(nalUnits[0], []byte{0x67, 0x42}) || !bytes.Equal(nalUnits[1], []byte{0x68, 0xCE}) || !bytes.Equal(nalUnits[2], []byte{0x65, 0x88}) {
+		t.Fatalf("split nal units failed %v", nalUnits)
+	}
+
+	if nalUnits = splitNalUnits([]byte{0x41, 0x9A}); len(nalUnits) != 1 {
+		t.Fatalf("the data without start code must be one nal unit")
+	}
+}
+
+func TestHEVCPacketizer(t *testing.T) {
+	vps := []byte{0x40, 0x01, 0x0C, 0x01}
+	sps := []byte{0x42, 0x01, 0x01, 0x01}
+	pps := []byte{0x44, 0x01, 0xC1, 0x72}
+	idr := append([]byte{0x26, 0x01}, bytes.Repeat([]byte{0xAF, 0x06}, 2000)...)
+	var au []byte
+	for _, nalu := range [][]byte{vps, sps, pps, idr} {
+		au = append(au, libavc.StartCode4...)
+		au = append(au, nalu...)
+	}
+
+	var frames []frame
+	depacketizer := NewHEVCDepacketizer(0, func(data []byte, timestamp uint32, keyFrame bool) {
+		frames = append(frames, frame{data, timestamp, keyFrame})
+	})
+
+	var types []byte
+	packetizer := NewHEVCPacketizer(96, 0x12345678, func(data []byte, timestamp uint32) {
+		_, payload, _ := parseHeader(data)
+		types = append(types, payload[0]>>1&0x3F)
+		if err := depacketizer.Input(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if err := packetizer.Input(au, 3000); err != nil {
+		t.Fatal(err)
+	}
+
+	//AP(VPS+SPS+PPS), FU * 3
+	if len(types) != 4 || types[0] != HEVCPacketAP || types[1] != HEVCPacketFU {
+		t.Fatalf("unexpected packet types %v", types)
+	}
+	if len(frames) != 1 || !bytes.Equal(frames[0].data, au) || !frames[0].keyFrame {
+		t.Fatalf("the hevc frames are different")
+	}
+}
+
+func TestHEVCParameterSets(t *testing.T) {
+	fmtp := map[string]string{}
+	for _, param := range strings.Split(HEVCFmtpParameterSets([][]byte{{0x40, 0x01}}, [][]byte{{0x42, 0x01}}, [][]byte{{0x44, 0x01}, {0x44, 0x02}}), ";") {
+		split := strings.SplitN(param, "=", 2)
+		fmtp[split[0]] = split[1]
+	}
+
+	vps, sps, pps, err := HEVCParameterSets(fmtp)
+	if err != nil || len(vps) != 1 || len(sps) != 1 || len(pps) != 2 || !bytes.Equal(pps[1], []byte{0x44, 0x02}) {
+		t.Fatalf("parse parameter sets failed %v %v %v %v", vps, sps, pps, err)
+	}
+	if HEVCMaxDonDiff(map[string]string{"sprop-max-don-diff": "2"}) != 2 {
+		t.Fatalf("parse sprop-max-don-diff failed")
+	}
+}
+
+func TestJPEGPacketizer(t *testing.T) {
+	//image/jpeg编码为4:2:0, 使用标准霍夫曼表
+	src := image.NewYCbCr(image.Rect(0, 0, 320, 240), image.YCbCrSubsampleRatio420)
+	for i := range src.Y {
+		src.Y[i] = byte(i * 7 % 251)
+	}
+	for i := range src.Cb {
+		src.Cb[i] = byte(i % 200)
+		src.Cr[i] = byte(255 - i%200)
+	}
+	var buffer bytes.Buffer
+	if err := jpeg.Encode(&buffer, src, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatal(err)
+	}
+
+	var frames []frame
+	depacketizer := NewJPEGDepacketizer(func(data []byte, timestamp uint32, keyFrame bool) {
+		frames = append(frames, frame{append([]byte(nil), data...), timestamp, keyFrame})
+	})
+	var packets int
+	packetizer := NewJPEGPacketizer(26, 0x12345678, func(data []byte, timestamp uint32) {
+		packets++
+		if err := depacketizer.Input(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if err := packetizer.Input(buffer.Bytes(), 3000); err != nil {
+		t.Fatal(err)
+	}
+	if packets < 2 || len(frames) != 1 || frames[0].timestamp != 3000 {
+		t.Fatalf("unexpected packets %d frames %d", packets, len(frames))
+	}
+
+	//重建的JFIF与原图解码结果相同
+	expected, err := jpeg.Decode(bytes.NewReader(buffer.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := jpeg.Decode(bytes.NewReader(frames[0].data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, a := expected.(*image.YCbCr), actual.(*image.YCbCr)
+	if a.Rect != e.Rect || a.SubsampleRatio != e.SubsampleRatio || !bytes.Equal(a.Y, e.Y) || !bytes.Equal(a.Cb, e.Cb) || !bytes.Equal(a.Cr, e.Cr) {
+		t.Fatalf("the jpeg images are different")
+	}
+
+	//灰度图不支持
+	buffer.Reset()
+	_ = jpeg.Encode(&buffer, image.NewGray(image.Rect(0, 0, 16, 16)), nil)
+	if err = packetizer.Input(buffer.Bytes(), 6000); err == nil {
+		t.Fatalf("the gray jpeg is not supported")
+	}
+}
+
+func TestJPEGRestartHeader(t *testing.T) {
+	h := &jpegHeader{t: 65, q: 255, width: 40, height: 30, restartInterval: 20, precision: 0, tables: makeQuantizationTables(50)}
+	payload := append(h.marshal(nil, 0), 0x12, 0x34)
+
+	parsed, scan, err := readJPEGHeader(payload)
+	if err != nil {
+		t.Fatal(err)
+	} else if parsed.t != 65 || parsed.restartInterval != 20 || parsed.restartCount != 0x3FFF || !bytes.Equal(parsed.tables, h.tables) || !bytes.Equal(scan, []byte{0x12, 0x34}) {
+		t.Fatalf("unexpected jpeg header %+v", parsed)
+	}
+
+	//后续分片不携带量化表
+	if parsed, _, err = readJPEGHeader(h.marshal(nil, 1000)); err != nil || parsed.fragmentOffset != 1000 || parsed.tables != nil {
+		t.Fatalf("unexpected jpeg fragment %+v", parsed)
+	}
+}
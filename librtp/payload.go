@@ -127,7 +127,7 @@ func init() {
 		11: {11, "L16", utils.AVMediaTypeAudio, utils.AVCodecIdPCMS16BE, 44100, 1},
 		12: {12, "QCELP", utils.AVMediaTypeAudio, utils.AVCodecIdQCELP, 8000, 1},
 		13: {13, "CN", utils.AVMediaTypeAudio, utils.AVCodecIdNONE, 8000, 1},
-		14: {14, "MPA", utils.AVMediaTypeAudio, utils.AVCodecIdMP2, -1, -1},
+		14: {14, "MPA", utils.AVMediaTypeAudio, utils.AVCodecIdMP2, 90000, -1},
 		//14: {14, "MPA", utils.AVMediaTypeAudio, utils.AVCodecIdMP3, -1, -1},
 		15: {15, "G728", utils.AVMediaTypeAudio, utils.AVCodecIdNONE, 8000, 1},
 		16: {16, "DVI4", utils.AVMediaTypeAudio, utils.AVCodecIdNONE, 11025, 1},
@@ -141,10 +141,15 @@ func init() {
 		//32: {32, "MPV", utils.AVMediaTypeVideo, utils.AVCodecIdMPEG2VIDEO, 90000, -1},
 		33: {33, "MP2T", utils.AVMediaTypeData, utils.AVCodecIdMPEG2TS, 90000, -1},
 		34: {34, "H263", utils.AVMediaTypeVideo, utils.AVCodecIdH263, 90000, -1},
-
-		96: {96, "", utils.AVMediaTypeVideo, utils.AVCodecIdNONE, 90000, -1},
 	}
 }
 
-type Profile struct {
+// StaticPayloadType 查询RFC 3551静态负载类型的编码/时钟频率/声道数
+func StaticPayloadType(pt int) (utils.AVCodecID, int, int, bool) {
+	if pt >= 96 {
+		return utils.AVCodecIdNONE, 0, 0, false
+	}
+
+	t, ok := payloadTypes[pt]
+	return t.codeId, t.clockRate, t.channels, ok
 }
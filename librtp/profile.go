@@ -0,0 +1,395 @@
+package librtp
+
+import (
+	"avformat/libavc"
+	"avformat/librtsp/sdp"
+	"avformat/utils"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// dynamicEncodings rtpmap编码名(大写)对应的编码器ID, 不在表中的编码名为AVCodecIdNONE.
+// GB28181的PS/90000没有对应的编码器ID, 通过Encoding区分
+var dynamicEncodings = map[string]utils.AVCodecID{
+	"H264":          utils.AVCodecIdH264,
+	"H265":          utils.AVCodecIdHEVC,
+	"HEVC":          utils.AVCodecIdHEVC,
+	"VP8":           utils.AVCodecIdVP8,
+	"VP9":           utils.AVCodecIdVP9,
+	"AV1":           utils.AVCodecIdAV1,
+	"JPEG":          utils.AVCodecIdMJPEG,
+	"MP4V-ES":       utils.AVCodecIdMPEG4,
+	"MP2T":          utils.AVCodecIdMPEG2TS,
+	"MPEG4-GENERIC": utils.AVCodecIdAAC,
+	"MP4A-LATM":     utils.AVCodecIdAAC,
+	"PCMU":          utils.AVCodecIdPCMMULAW,
+	"PCMA":          utils.AVCodecIdPCMALAW,
+	"G722":          utils.AVCodecIdADPCMG722,
+	//RFC 3551从字节的低位开始打包, AAL2从高位开始打包
+	"G726-16":      utils.AVCodecIdADPCMG726LE,
+	"G726-24":      utils.AVCodecIdADPCMG726LE,
+	"G726-32":      utils.AVCodecIdADPCMG726LE,
+	"G726-40":      utils.AVCodecIdADPCMG726LE,
+	"AAL2-G726-16": utils.AVCodecIdADPCMG726,
+	"AAL2-G726-24": utils.AVCodecIdADPCMG726,
+	"AAL2-G726-32": utils.AVCodecIdADPCMG726,
+	"AAL2-G726-40": utils.AVCodecIdADPCMG726,
+	"L16":          utils.AVCodecIdPCMS16BE,
+	"MPA":          utils.AVCodecIdMP2,
+	"OPUS":         utils.AVCodecIdOPUS,
+	"AMR":          utils.AVCodecIdAMRNB,
+	"AMR-WB":       utils.AVCodecIdAMRWB,
+	"SPEEX":        utils.AVCodecIdSPEEX,
+	"G729":         utils.AVCodecIdG729,
+}
+
+// PayloadFormat m=行中一个负载类型的编码参数
+type PayloadFormat struct {
+	PayloadType int
+	Encoding    string //rtpmap中的编码名, 大写. 静态负载类型没有rtpmap时为RFC 3551中的名称
+	MediaType   utils.AVMediaType
+	CodecId     utils.AVCodecID
+	ClockRate   int
+	Channels    int
+	// SampleRate 音频的采样率. G.722为16000, 而RTP时钟为8000; MPEG音频由码流决定, 为0
+	SampleRate int
+	Ptime      int               //a=ptime, 单位毫秒
+	Fmtp       map[string]string //a=fmtp, key为小写
+	Feedbacks  []string          //a=rtcp-fb, 包含*通配的反馈
+	// ExtraData H264/H265为AnnexB格式的参数集, AAC为AudioSpecificConfig
+	ExtraData   []byte
+	AudioConfig *utils.MPEG4AudioConfig
+
+	latmConfig *LATMConfig
+}
+
+// Profile 一个媒体描述中所有负载类型的编码参数, 按m=行的顺序排列
+type Profile struct {
+	MediaType utils.AVMediaType
+	Formats   []*PayloadFormat
+}
+
+// ParseFmtp a=fmtp:96 packetization-mode=1;sprop-parameter-sets=Z0IAKeKQFAe2AtwEBAaQeJEV,aM48gA==
+// @return 参数名转换为小写
+func ParseFmtp(value string) map[string]string {
+	params := make(map[string]string, 8)
+	index := strings.Index(value, " ")
+	if index < 0 {
+		return params
+	}
+
+	for _, param := range strings.Split(value[index+1:], ";") {
+		param = strings.TrimSpace(param)
+		if param == "" {
+			continue
+		}
+
+		if i := strings.Index(param, "="); i < 0 {
+			params[strings.ToLower(param)] = ""
+		} else {
+			params[strings.ToLower(strings.TrimSpace(param[:i]))] = strings.TrimSpace(param[i+1:])
+		}
+	}
+	return params
+}
+
+// decodeParameterSets 将base64编码的参数集转换为AnnexB格式
+func decodeParameterSets(values ...string) ([]byte, error) {
+	var extra []byte
+	for _, value := range values {
+		for _, set := range strings.Split(value, ",") {
+			if set == "" {
+				continue
+			}
+
+			bytes, err := base64.StdEncoding.DecodeString(set)
+			if err != nil {
+				return nil, err
+			}
+			extra = append(extra, libavc.StartCode4...)
+			extra = append(extra, bytes...)
+		}
+	}
+	return extra, nil
+}
+
+func mediaTypeOf(media string) utils.AVMediaType {
+	switch strings.ToLower(media) {
+	case "video":
+		return utils.AVMediaTypeVideo
+	case "application":
+		return utils.AVMediaTypeData
+	}
+	return utils.AVMediaTypeAudio
+}
+
+// formatAttributes 按负载类型归类a=rtpmap/a=fmtp/a=rtcp-fb, a=rtcp-fb:*保存在*下
+func formatAttributes(md *sdp.MediaDescription, key string) map[string][]string {
+	attributes := make(map[string][]string, len(md.MediaName.Formats))
+	for _, attribute := range md.Attributes {
+		if attribute.Key != key {
+			continue
+		}
+
+		index := strings.Index(attribute.Value, " ")
+		if index < 0 {
+			continue
+		}
+		pt := attribute.Value[:index]
+		attributes[pt] = append(attributes[pt], attribute.Value)
+	}
+	return attributes
+}
+
+// NewProfile 解析媒体描述中的rtpmap/fmtp/rtcp-fb, 没有rtpmap的静态负载类型使用RFC 3551中的参数
+func NewProfile(md *sdp.MediaDescription) (*Profile, error) {
+	if len(md.MediaName.Formats) == 0 {
+		return nil, fmt.Errorf("no format in the media %s", md.MediaName.Media)
+	}
+
+	profile := &Profile{MediaType: mediaTypeOf(md.MediaName.Media)}
+	var ptime int
+	if value, ok := md.Attribute("ptime"); ok {
+		ptime, _ = strconv.Atoi(strings.TrimSpace(value))
+	}
+	rtpmaps := formatAttributes(md, "rtpmap")
+	fmtps := formatAttributes(md, "fmtp")
+	feedbacks := formatAttributes(md, "rtcp-fb")
+
+	for _, value := range md.MediaName.Formats {
+		pt, err := strconv.Atoi(value)
+		if err != nil || pt < 0 || pt > 127 {
+			return nil, fmt.Errorf("invalid payload type %s", value)
+		}
+
+		format := &PayloadFormat{PayloadType: pt, MediaType: profile.MediaType, Fmtp: make(map[string]string)}
+		if rtpmap := rtpmaps[value]; len(rtpmap) > 0 {
+			if err = format.parseRtpmap(rtpmap[0]); err != nil {
+				return nil, err
+			}
+		} else if t, ok := payloadTypes[pt]; ok {
+			format.Encoding = strings.ToUpper(t.encoding)
+			format.CodecId = t.codeId
+			format.ClockRate = t.clockRate
+			format.Channels = t.channels
+		}
+
+		if format.ClockRate <= 0 {
+			format.ClockRate = 8000
+			if format.MediaType == utils.AVMediaTypeVideo {
+				format.ClockRate = 90000
+			}
+		}
+		if format.Channels <= 0 && format.MediaType == utils.AVMediaTypeAudio {
+			format.Channels = 1
+		}
+		if format.MediaType == utils.AVMediaTypeAudio {
+			format.SampleRate = format.ClockRate
+			if format.CodecId == utils.AVCodecIdADPCMG722 {
+				format.SampleRate = 16000
+			} else if format.CodecId == utils.AVCodecIdMP2 {
+				format.SampleRate = 0
+			}
+		}
+		format.Ptime = ptime
+
+		if fmtp := fmtps[value]; len(fmtp) > 0 {
+			format.Fmtp = ParseFmtp(fmtp[0])
+		}
+		for _, key := range []string{"*", value} {
+			for _, feedback := range feedbacks[key] {
+				format.Feedbacks = append(format.Feedbacks, feedback[strings.Index(feedback, " ")+1:])
+			}
+		}
+
+		if err = format.parseCodecParameters(); err != nil {
+			return nil, fmt.Errorf("invalid fmtp of the payload type %d: %s", pt, err.Error())
+		}
+		profile.Formats = append(profile.Formats, format)
+	}
+
+	return profile, nil
+}
+
+// Format 查找负载类型的编码参数
+func (p *Profile) Format(pt int) (*PayloadFormat, bool) {
+	for _, format := range p.Formats {
+		if format.PayloadType == pt {
+			return format, true
+		}
+	}
+	return nil, false
+}
+
+// FindCodec 按m=行的顺序查找第一个指定编码的负载类型
+func (p *Profile) FindCodec(codecId utils.AVCodecID) (*PayloadFormat, bool) {
+	for _, format := range p.Formats {
+		if format.CodecId == codecId {
+			return format, true
+		}
+	}
+	return nil, false
+}
+
+// parseRtpmap 96 H264/90000 or 97 MPEG4-GENERIC/44100/2
+func (f *PayloadFormat) parseRtpmap(rtpmap string) error {
+	var err error
+	split := strings.Split(rtpmap[strings.Index(rtpmap, " ")+1:], "/")
+	if len(split) > 1 {
+		if f.ClockRate, err = strconv.Atoi(split[1]); err != nil {
+			return fmt.Errorf("invalid rtpmap:%s", rtpmap)
+		}
+	}
+	if len(split) > 2 {
+		if f.Channels, err = strconv.Atoi(split[2]); err != nil {
+			return fmt.Errorf("invalid rtpmap:%s", rtpmap)
+		}
+	}
+
+	f.Encoding = strings.ToUpper(split[0])
+	f.CodecId = dynamicEncodings[f.Encoding]
+	return nil
+}
+
+// parseCodecParameters 从fmtp中读取H264/H265的参数集和AAC的AudioSpecificConfig
+func (f *PayloadFormat) parseCodecParameters() error {
+	var err error
+	switch f.CodecId {
+	case utils.AVCodecIdH264:
+		f.ExtraData, err = decodeParameterSets(f.Fmtp["sprop-parameter-sets"])
+		break
+	case utils.AVCodecIdHEVC:
+		var vps, sps, pps [][]byte
+		if vps, sps, pps, err = HEVCParameterSets(f.Fmtp); err == nil {
+			for _, set := range append(append(vps, sps...), pps...) {
+				f.ExtraData = append(f.ExtraData, libavc.StartCode4...)
+				f.ExtraData = append(f.ExtraData, set...)
+			}
+		}
+		break
+	case utils.AVCodecIdAAC:
+		config := f.Fmtp["config"]
+		if config == "" {
+			break
+		}
+
+		var bytes []byte
+		if bytes, err = hex.DecodeString(config); err != nil {
+			break
+		}
+
+		//MP4A-LATM的config为StreamMuxConfig
+		if f.Encoding == "MP4A-LATM" {
+			if f.latmConfig, err = ParseStreamMuxConfig(bytes); err == nil {
+				f.AudioConfig = f.latmConfig.AudioConfig
+				f.ExtraData = f.latmConfig.AudioConfig.ToBytes()
+			}
+		} else if f.ExtraData = bytes; len(f.ExtraData) >= 2 {
+			f.AudioConfig, err = utils.ParseMpeg4AudioConfig(f.ExtraData)
+		}
+		break
+	}
+
+	return err
+}
+
+// fmtpInt 读取整型的fmtp参数, 不存在或无效时返回默认值
+func (f *PayloadFormat) fmtpInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(f.Fmtp[key]); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// BitsPerSample 基于采样的音频每个RTP时钟周期的位数, 其他编码为0
+func (f *PayloadFormat) BitsPerSample() int {
+	switch f.CodecId {
+	case utils.AVCodecIdPCMMULAW, utils.AVCodecIdPCMALAW, utils.AVCodecIdADPCMG722:
+		return 8
+	case utils.AVCodecIdADPCMG726, utils.AVCodecIdADPCMG726LE:
+		//G726-32的码率为32kbps, 每个采样4位
+		if bitRate, err := strconv.Atoi(f.Encoding[strings.LastIndex(f.Encoding, "-")+1:]); err == nil {
+			return bitRate / 8
+		}
+		return 4
+	case utils.AVCodecIdPCMS16BE:
+		return 16 * f.Channels
+	}
+	return 0
+}
+
+// NewDepacketizer 根据编码创建负载解析器
+func (f *PayloadFormat) NewDepacketizer(handler decodeHandler) (Depacketizer, error) {
+	switch f.CodecId {
+	case utils.AVCodecIdH264:
+		return NewH264Depacketizer(handler), nil
+	case utils.AVCodecIdHEVC:
+		return NewHEVCDepacketizer(HEVCMaxDonDiff(f.Fmtp), handler), nil
+	case utils.AVCodecIdAAC:
+		if f.Encoding == "MP4A-LATM" {
+			if cpresent, ok := f.Fmtp["cpresent"]; ok && cpresent != "0" {
+				return nil, fmt.Errorf("only cpresent=0 is supported")
+			} else if f.latmConfig == nil {
+				return nil, fmt.Errorf("the config of mp4a-latm is required")
+			}
+			return NewLATMDepacketizer(f.latmConfig, handler), nil
+		} else if _, ok := f.Fmtp["sizelength"]; !ok {
+			return nil, fmt.Errorf("the sizelength of mpeg4-generic is required")
+		}
+
+		return NewAACDepacketizer(f.fmtpInt("sizelength", 0), f.fmtpInt("indexlength", 0), f.fmtpInt("indexdeltalength", 0), handler), nil
+	case utils.AVCodecIdPCMMULAW, utils.AVCodecIdPCMALAW, utils.AVCodecIdADPCMG722, utils.AVCodecIdADPCMG726, utils.AVCodecIdADPCMG726LE, utils.AVCodecIdPCMS16BE, utils.AVCodecIdOPUS:
+		return NewAudioDepacketizer(handler), nil
+	case utils.AVCodecIdMP2:
+		return NewMPADepacketizer(handler), nil
+	case utils.AVCodecIdMPEG2TS:
+		return NewMP2TDepacketizer(handler, nil), nil
+	case utils.AVCodecIdMJPEG:
+		return NewJPEGDepacketizer(handler), nil
+	}
+
+	if f.Encoding == "PS" {
+		return NewPSDepacketizer(0, handler), nil
+	}
+
+	return nil, fmt.Errorf("unsupported codec %d", f.CodecId)
+}
+
+// NewPacketizer 根据编码创建封装器
+func (f *PayloadFormat) NewPacketizer(ssrc uint32, handler encodeHandler) (Packetizer, error) {
+	switch f.CodecId {
+	case utils.AVCodecIdH264:
+		return NewH264Packetizer(f.PayloadType, ssrc, f.fmtpInt("packetization-mode", 0), handler), nil
+	case utils.AVCodecIdHEVC:
+		return NewHEVCPacketizer(f.PayloadType, ssrc, handler), nil
+	case utils.AVCodecIdAAC:
+		if f.Encoding == "MP4A-LATM" {
+			return NewLATMPacketizer(f.PayloadType, ssrc, handler), nil
+		}
+
+		sizeLength := f.fmtpInt("sizelength", 0)
+		if sizeLength <= 0 {
+			return nil, fmt.Errorf("the sizelength of mpeg4-generic is required")
+		}
+		return NewAACPacketizer(f.PayloadType, ssrc, sizeLength, f.fmtpInt("indexlength", 0), handler), nil
+	case utils.AVCodecIdPCMMULAW, utils.AVCodecIdPCMALAW, utils.AVCodecIdADPCMG722, utils.AVCodecIdADPCMG726, utils.AVCodecIdADPCMG726LE, utils.AVCodecIdPCMS16BE:
+		return NewSampleAudioPacketizer(f.PayloadType, ssrc, f.ClockRate, f.BitsPerSample(), f.Ptime, handler), nil
+	case utils.AVCodecIdOPUS:
+		return NewOpusPacketizer(f.PayloadType, ssrc, handler), nil
+	case utils.AVCodecIdMP2:
+		return NewMPAPacketizer(f.PayloadType, ssrc, handler), nil
+	case utils.AVCodecIdMPEG2TS:
+		return NewMP2TPacketizer(f.PayloadType, ssrc, handler), nil
+	case utils.AVCodecIdMJPEG:
+		return NewJPEGPacketizer(f.PayloadType, ssrc, handler), nil
+	}
+
+	if f.Encoding == "PS" {
+		return NewPSPacketizer(f.PayloadType, ssrc, handler), nil
+	}
+
+	return nil, fmt.Errorf("unsupported codec %d", f.CodecId)
+}
@@ -0,0 +1,120 @@
+package librtp
+
+import (
+	"avformat/librtsp/sdp"
+	"avformat/utils"
+	"reflect"
+	"testing"
+)
+
+func TestProfile(t *testing.T) {
+	description := "v=0\r\no=- 0 0 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\n" +
+		"m=video 9 RTP/AVPF 96 97 98 26\r\n" +
+		"a=rtpmap:96 H264/90000\r\na=fmtp:96 packetization-mode=1;sprop-parameter-sets=Z0IAKeKQFAe2AtwEBAaQeJEV,aM48gA==\r\n" +
+		"a=rtcp-fb:* nack\r\na=rtcp-fb:96 nack pli\r\n" +
+		"a=rtpmap:97 rtx/90000\r\na=fmtp:97 apt=96\r\n" +
+		"a=rtpmap:98 PS/90000\r\n" +
+		"m=audio 9 RTP/AVP 8 101 0\r\n" +
+		"a=rtpmap:101 MP4A-LATM/44100/2\r\na=fmtp:101 cpresent=0;config=400024203fc0\r\n"
+
+	var sd sdp.SessionDescription
+	if err := sd.Unmarshal([]byte(description)); err != nil {
+		t.Fatal(err)
+	}
+
+	video, err := NewProfile(sd.MediaDescriptions[0])
+	if err != nil {
+		t.Fatal(err)
+	} else if len(video.Formats) != 4 {
+		t.Fatalf("unexpected format count %d", len(video.Formats))
+	}
+
+	h264, ok := video.FindCodec(utils.AVCodecIdH264)
+	if !ok || h264.PayloadType != 96 || h264.ClockRate != 90000 || h264.Fmtp["packetization-mode"] != "1" || len(h264.ExtraData) == 0 {
+		t.Fatalf("unexpected h264 format %+v", h264)
+	} else if !reflect.DeepEqual(h264.Feedbacks, []string{"nack", "nack pli"}) {
+		t.Fatalf("unexpected rtcp feedbacks %v", h264.Feedbacks)
+	}
+
+	rtx, _ := video.Format(97)
+	if rtx.Encoding != "RTX" || rtx.CodecId != utils.AVCodecIdNONE || rtx.Fmtp["apt"] != "96" {
+		t.Fatalf("unexpected rtx format %+v", rtx)
+	} else if _, err = rtx.NewDepacketizer(func(data []byte, timestamp uint32, keyFrame bool) {}); err == nil {
+		t.Fatalf("rtx has no depacketizer")
+	}
+
+	//动态PS和静态JPEG
+	for _, pt := range []int{96, 98, 26} {
+		format, _ := video.Format(pt)
+		if _, err = format.NewDepacketizer(func(data []byte, timestamp uint32, keyFrame bool) {}); err != nil {
+			t.Fatalf("payload type %d: %s", pt, err.Error())
+		}
+	}
+	if _, ok = video.Format(100); ok {
+		t.Fatalf("payload type 100 is not in the media")
+	}
+
+	audio, err := NewProfile(sd.MediaDescriptions[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		pt        int
+		codecId   utils.AVCodecID
+		clockRate int
+		channels  int
+	}{{8, utils.AVCodecIdPCMALAW, 8000, 1}, {101, utils.AVCodecIdAAC, 44100, 2}, {0, utils.AVCodecIdPCMMULAW, 8000, 1}}
+	for i, format := range audio.Formats {
+		if format.PayloadType != expected[i].pt || format.CodecId != expected[i].codecId || format.ClockRate != expected[i].clockRate || format.Channels != expected[i].channels {
+			t.Fatalf("unexpected audio format %+v", format)
+		} else if _, err = format.NewPacketizer(1, func(data []byte, timestamp uint32) {}); err != nil {
+			t.Fatal(err)
+		} else if _, err = format.NewDepacketizer(func(data []byte, timestamp uint32, keyFrame bool) {}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if latm, _ := audio.Format(101); latm.AudioConfig == nil || latm.AudioConfig.SampleRate != 44100 {
+		t.Fatalf("unexpected latm config %+v", latm.AudioConfig)
+	}
+}
+
+func TestProfileAudioSampleRate(t *testing.T) {
+	var sd sdp.SessionDescription
+	description := "v=0\r\no=- 0 0 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\n" +
+		"m=audio 9 RTP/AVP 9 102 103\r\na=rtpmap:102 G726-24/8000\r\na=rtpmap:103 AAL2-G726-40/8000\r\na=ptime:40\r\n"
+	if err := sd.Unmarshal([]byte(description)); err != nil {
+		t.Fatal(err)
+	}
+
+	profile, err := NewProfile(sd.MediaDescriptions[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//G.722的RTP时钟为8000, 采样率为16000
+	g722, _ := profile.Format(9)
+	if g722.CodecId != utils.AVCodecIdADPCMG722 || g722.ClockRate != 8000 || g722.SampleRate != 16000 || g722.Ptime != 40 {
+		t.Fatalf("unexpected g722 format %+v", g722)
+	}
+
+	g726, _ := profile.Format(102)
+	aal2, _ := profile.Format(103)
+	if g726.CodecId != utils.AVCodecIdADPCMG726LE || g726.BitsPerSample() != 3 || aal2.CodecId != utils.AVCodecIdADPCMG726 || aal2.BitsPerSample() != 5 {
+		t.Fatalf("unexpected g726 formats %+v %+v", g726, aal2)
+	}
+
+	//40ms的G.726-40为200字节
+	var lengths []int
+	packetizer, err := aal2.NewPacketizer(1, func(data []byte, timestamp uint32) {
+		lengths = append(lengths, len(data)-FixedHeaderLength)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = packetizer.Input(make([]byte, 400), 0)
+	if !reflect.DeepEqual(lengths, []int{200, 200}) {
+		t.Fatalf("unexpected packet lengths %v", lengths)
+	}
+}
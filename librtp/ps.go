@@ -0,0 +1,82 @@
+package librtp
+
+import (
+	"fmt"
+)
+
+// GB28181 PS over RTP, 默认PT为96, 时钟频率90000
+
+const (
+	PSPayloadType = 96
+	PSClockRate   = 90000
+)
+
+// psPacketizer 一个PS包(pack)按最大负载拆分, 最后一个包设置marker
+type psPacketizer struct {
+	packetWriter
+}
+
+// NewPSPacketizer 输入libmpeg.Muxer输出的PS包, 时间戳为90KHz的pts
+func NewPSPacketizer(pt int, ssrc uint32, handler encodeHandler) Packetizer {
+	return &psPacketizer{newPacketWriter(pt, ssrc, handler)}
+}
+
+func (p *psPacketizer) Input(data []byte, timestamp uint32) error {
+	size := p.maxPayloadSize()
+	for len(data) > 0 {
+		n := len(data)
+		if n > size {
+			n = size
+		}
+		p.write(timestamp, n == len(data), data[:n])
+		data = data[n:]
+	}
+	return nil
+}
+
+// psDepacketizer 按时间戳和marker拼接完整的PS包, 丢包时丢弃整个PS包
+type psDepacketizer struct {
+	frameAssembler
+	ssrc uint32
+}
+
+// NewPSDepacketizer 输出完整的PS包, 可直接输入libmpeg.DeMuxer
+// @ssrc SDP中y=指定的SSRC, 其他SSRC的包返回错误. 为0时使用收到的第一个包的SSRC
+func NewPSDepacketizer(ssrc uint32, handler decodeHandler) Depacketizer {
+	return &psDepacketizer{frameAssembler: frameAssembler{handler: handler}, ssrc: ssrc}
+}
+
+// isPSKeyFrame 关键帧的pack header之后是system header或PSM
+func isPSKeyFrame(data []byte) bool {
+	//MPEG-2 pack header 14字节 + stuffing
+	if len(data) < 14 || data[0] != 0 || data[1] != 0 || data[2] != 1 || data[3] != 0xBA {
+		return false
+	}
+
+	n := 14 + int(data[13]&0x7)
+	return len(data) >= n+4 && data[n] == 0 && data[n+1] == 0 && data[n+2] == 1 && (data[n+3] == 0xBB || data[n+3] == 0xBC)
+}
+
+func (d *psDepacketizer) Input(packet []byte) error {
+	h, payload, err := parseHeader(packet)
+	if err != nil {
+		return err
+	}
+
+	if d.ssrc == 0 {
+		d.ssrc = h.ssrc
+	} else if d.ssrc != h.ssrc {
+		return fmt.Errorf("unexpected ssrc %d, expected %d", h.ssrc, d.ssrc)
+	}
+
+	if !d.checkPacket(h, isPSKeyFrame) {
+		return nil
+	}
+
+	d.write(payload)
+	//部分设备不设置marker, 在时间戳变化时输出
+	if h.m == 1 {
+		d.flush(isPSKeyFrame)
+	}
+	return nil
+}
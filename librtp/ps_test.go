@@ -0,0 +1,126 @@
+package librtp
+
+import (
+	"avformat/libmpeg"
+	"avformat/utils"
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+// muxPS 生成一个关键帧和一个非关键帧的PS包
+func muxPS(t *testing.T) ([][]byte, []int64) {
+	var packs [][]byte
+	var timestamps []int64
+	muxer := libmpeg.NewMuxer(func(index int, data []byte, pts, dts int64) {
+		packs = append(packs, append([]byte(nil), data...))
+		timestamps = append(timestamps, pts)
+	})
+	index, err := muxer.AddStream(libmpeg.StreamTypeVideoH264)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idr := append([]byte{0, 0, 0, 1, 0x65}, bytes.Repeat([]byte{0x88}, 4000)...)
+	muxer.Input(index, true, idr, 3600, 3600)
+	muxer.Input(index, false, []byte{0, 0, 0, 1, 0x41, 0x9A}, 7200, 7200)
+	return packs, timestamps
+}
+
+func TestPSOverRTP(t *testing.T) {
+	packs, timestamps := muxPS(t)
+
+	var stream []byte
+	packetizer := NewPSPacketizer(PSPayloadType, 0x12345678, func(data []byte, timestamp uint32) {
+		frame := make([]byte, RFC4571HeaderLength+len(data))
+		if _, err := WriteRFC4571Frame(frame, data); err != nil {
+			t.Fatal(err)
+		}
+		stream = append(stream, frame...)
+	})
+	for i, pack := range packs {
+		if err := packetizer.Input(pack, uint32(timestamps[i])); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var frames []frame
+	depacketizer := NewPSDepacketizer(0x12345678, func(data []byte, timestamp uint32, keyFrame bool) {
+		frames = append(frames, frame{data, timestamp, keyFrame})
+	})
+	decoder := NewRFC4571Decoder(func(packet []byte) {
+		if err := depacketizer.Input(packet); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	//TCP读取的数据长度和包边界无关
+	for i := 0; i < len(stream); i += 7 {
+		decoder.Input(stream[i:utils.MinInt(i+7, len(stream))])
+	}
+
+	if len(frames) != len(packs) {
+		t.Fatalf("depacketized %d packs, expected %d", len(frames), len(packs))
+	}
+	for i := range packs {
+		if !bytes.Equal(frames[i].data, packs[i]) || frames[i].timestamp != uint32(timestamps[i]) || frames[i].keyFrame != (i == 0) {
+			t.Fatalf("pack %d mismatch. keyFrame:%t", i, frames[i].keyFrame)
+		}
+	}
+
+	other, _ := NewPacket(PSPayloadType, 100, 0, 1, []byte{0}).Marshal()
+	if err := depacketizer.Input(other); err == nil {
+		t.Fatalf("the packet with unexpected ssrc must be rejected")
+	}
+}
+
+func TestPSOverTCPPassive(t *testing.T) {
+	packs, timestamps := muxPS(t)
+	server, err := utils.NewTCPServer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	received := make(chan []byte, len(packs))
+	depacketizer := NewPSDepacketizer(0, func(data []byte, timestamp uint32, keyFrame bool) {
+		received <- data
+	})
+	decoder := NewRFC4571Decoder(func(packet []byte) {
+		_ = depacketizer.Input(packet)
+	})
+	server.SetOnPacketHandler(func(conn net.Conn, data []byte) {
+		decoder.Input(data)
+	})
+	server.Read()
+
+	//设备主动连接
+	client, err := utils.NewTCPClient(nil, "127.0.0.1", server.ListenPort())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	buffer := make([]byte, RFC4571HeaderLength+PacketMaxSize)
+	packetizer := NewPSPacketizer(PSPayloadType, 1, func(data []byte, timestamp uint32) {
+		n, _ := WriteRFC4571Frame(buffer, data)
+		if _, err := client.Write(buffer[:n]); err != nil {
+			t.Error(err)
+		}
+	})
+	for i, pack := range packs {
+		_ = packetizer.Input(pack, uint32(timestamps[i]))
+	}
+
+	for i := range packs {
+		select {
+		case data := <-received:
+			if !bytes.Equal(data, packs[i]) {
+				t.Fatalf("pack %d mismatch", i)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for pack %d", i)
+		}
+	}
+}
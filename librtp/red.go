@@ -0,0 +1,87 @@
+package librtp
+
+import (
+	"avformat/utils"
+	"fmt"
+)
+
+const (
+	redHeaderLength      = 4 //F(1) | PT(7) | timestamp offset(14) | block length(10)
+	redFinalHeaderLength = 1 //F(1) | PT(7)
+	redMaxBlockLength    = 0x3FF
+	redMaxTimestamp      = 0x3FFF
+)
+
+// REDBlock RFC 2198的一个数据块
+type REDBlock struct {
+	PayloadType     int
+	TimestampOffset uint32 //RED包时间戳减去该块的时间戳, 主块为0
+	Payload         []byte
+}
+
+// MarshalRED 生成RED负载, 最后一个为主块
+func MarshalRED(blocks []REDBlock) ([]byte, error) {
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("the red payload requires a primary block")
+	}
+
+	var size int
+	for i, block := range blocks {
+		size += len(block.Payload) + redHeaderLength
+		if i == len(blocks)-1 {
+			size -= redHeaderLength - redFinalHeaderLength
+		} else if len(block.Payload) > redMaxBlockLength || block.TimestampOffset > redMaxTimestamp {
+			return nil, fmt.Errorf("the redundant block is too large %d", len(block.Payload))
+		}
+	}
+
+	data := make([]byte, 0, size)
+	for _, block := range blocks[:len(blocks)-1] {
+		header := uint32(0x80|block.PayloadType&0x7F)<<24 | block.TimestampOffset<<10 | uint32(len(block.Payload))
+		data = append(data, byte(header>>24), byte(header>>16), byte(header>>8), byte(header))
+	}
+	primary := blocks[len(blocks)-1]
+	data = append(data, byte(primary.PayloadType&0x7F))
+
+	for _, block := range blocks {
+		data = append(data, block.Payload...)
+	}
+	return data, nil
+}
+
+// UnmarshalRED 解析RED负载, Payload引用data
+func UnmarshalRED(data []byte) ([]REDBlock, error) {
+	var blocks []REDBlock
+	var lengths []int
+	offset := 0
+	for {
+		if offset >= len(data) {
+			return nil, fmt.Errorf("invalid red header")
+		}
+
+		//F位为0表示最后一个块头
+		if data[offset]&0x80 == 0 {
+			blocks = append(blocks, REDBlock{PayloadType: int(data[offset] & 0x7F)})
+			offset += redFinalHeaderLength
+			break
+		}
+
+		if offset+redHeaderLength > len(data) {
+			return nil, fmt.Errorf("invalid red header")
+		}
+		header := utils.BytesToUInt32(data[offset], data[offset+1], data[offset+2], data[offset+3])
+		blocks = append(blocks, REDBlock{PayloadType: int(header >> 24 & 0x7F), TimestampOffset: header >> 10 & redMaxTimestamp})
+		lengths = append(lengths, int(header&redMaxBlockLength))
+		offset += redHeaderLength
+	}
+
+	for i, length := range lengths {
+		if offset+length > len(data) {
+			return nil, fmt.Errorf("invalid red block length %d", length)
+		}
+		blocks[i].Payload = data[offset : offset+length]
+		offset += length
+	}
+	blocks[len(blocks)-1].Payload = data[offset:]
+	return blocks, nil
+}
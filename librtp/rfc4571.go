@@ -0,0 +1,67 @@
+package librtp
+
+import (
+	"avformat/utils"
+	"fmt"
+)
+
+// RFC 4571 TCP传输RTP/RTCP, 每个包前加2字节长度
+
+const RFC4571HeaderLength = 2
+
+// WriteRFC4571Frame 写入长度和数据
+// @return 写入的总长度
+func WriteRFC4571Frame(dst []byte, packet []byte) (int, error) {
+	if len(packet) > 0xFFFF {
+		return 0, fmt.Errorf("the packet is too large %d", len(packet))
+	} else if len(dst) < RFC4571HeaderLength+len(packet) {
+		return 0, fmt.Errorf("the buffer is too small %d", len(dst))
+	}
+
+	utils.WriteWORD(dst, uint16(len(packet)))
+	return RFC4571HeaderLength + copy(dst[RFC4571HeaderLength:], packet), nil
+}
+
+// RFC4571Decoder 从TCP流中拆分出完整的包
+type RFC4571Decoder struct {
+	buffer  []byte
+	handler func(packet []byte)
+}
+
+// NewRFC4571Decoder handler中的数据在返回后失效
+func NewRFC4571Decoder(handler func(packet []byte)) *RFC4571Decoder {
+	return &RFC4571Decoder{handler: handler}
+}
+
+// Input 输入TCP读取的任意长度数据
+func (d *RFC4571Decoder) Input(data []byte) {
+	//没有缓存时直接解析, 避免拷贝
+	if len(d.buffer) == 0 {
+		n := d.split(data)
+		d.buffer = append(d.buffer, data[n:]...)
+		return
+	}
+
+	d.buffer = append(d.buffer, data...)
+	n := d.split(d.buffer)
+	d.buffer = d.buffer[:copy(d.buffer, d.buffer[n:])]
+}
+
+// split 回调完整的包
+// @return 消耗的长度
+func (d *RFC4571Decoder) split(data []byte) int {
+	var n int
+	for len(data)-n >= RFC4571HeaderLength {
+		length := int(utils.BytesToUInt16(data[n], data[n+1]))
+		if len(data)-n-RFC4571HeaderLength < length {
+			break
+		}
+
+		n += RFC4571HeaderLength
+		if length > 0 {
+			d.handler(data[n : n+length])
+		}
+		n += length
+	}
+	return n
+}
@@ -0,0 +1,174 @@
+package librtp
+
+import (
+	"avformat/librtcp"
+	"avformat/utils"
+	"fmt"
+)
+
+const (
+	// DefaultHistorySize 默认保存最近发送的包数
+	DefaultHistorySize = 512
+	// rtxHeaderLength RFC 4588 RTX负载前的原始序号(OSN)
+	rtxHeaderLength = 2
+)
+
+// retransmitHandler 发送重传包
+type retransmitHandler func(packet []byte)
+
+type historyPacket struct {
+	seq   uint16
+	valid bool
+	data  []byte
+}
+
+// Retransmitter 保存最近发送的RTP包, 收到Generic NACK时重传
+// 未开启RTX时使用原始SSRC和序号重传, 开启后按RFC 4588封装到单独的SSRC和负载类型
+// 非线程安全
+type Retransmitter struct {
+	handler retransmitHandler
+	history []historyPacket //按序号取模索引
+	ssrc    uint32          //原始流的SSRC
+	started bool
+
+	rtx            bool
+	rtxPayloadType int
+	rtxSSRC        uint32
+	rtxSeq         uint16
+
+	retransmitted uint32
+	missed        uint32
+}
+
+// NewRetransmitter size小于等于0时使用DefaultHistorySize
+func NewRetransmitter(size int, handler retransmitHandler) *Retransmitter {
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+	return &Retransmitter{handler: handler, history: make([]historyPacket, size)}
+}
+
+// EnableRTX 使用RFC 4588 RTX重传, 负载类型需要在SDP中通过apt关联原始负载类型
+func (r *Retransmitter) EnableRTX(payloadType int, ssrc uint32) {
+	r.rtx = true
+	r.rtxPayloadType = payloadType
+	r.rtxSSRC = ssrc
+}
+
+// Retransmitted 已重传的包数
+func (r *Retransmitter) Retransmitted() uint32 {
+	return r.retransmitted
+}
+
+// Missed 请求重传时已不在历史中的包数
+func (r *Retransmitter) Missed() uint32 {
+	return r.missed
+}
+
+// Push 保存发送的RTP包, 拷贝data
+func (r *Retransmitter) Push(data []byte) error {
+	h, _, err := parseHeader(data)
+	if err != nil {
+		return err
+	}
+
+	//SSRC变化时丢弃之前的历史
+	if r.started && r.ssrc != h.ssrc {
+		r.Reset()
+	}
+	r.started = true
+	r.ssrc = h.ssrc
+
+	seq := uint16(h.seq)
+	packet := &r.history[int(seq)%len(r.history)]
+	packet.seq = seq
+	packet.valid = true
+	packet.data = append(packet.data[:0], data...)
+	return nil
+}
+
+// Get 返回历史中的包
+func (r *Retransmitter) Get(seq uint16) ([]byte, bool) {
+	packet := &r.history[int(seq)%len(r.history)]
+	if !packet.valid || packet.seq != seq {
+		return nil, false
+	}
+	return packet.data, true
+}
+
+// Reset 清空历史
+func (r *Retransmitter) Reset() {
+	for i := range r.history {
+		r.history[i].valid = false
+	}
+	r.started = false
+}
+
+// OnNack 重传NACK请求的包, 忽略其他SSRC的NACK
+// @return 重传的包数
+func (r *Retransmitter) OnNack(nack *librtcp.TransportLayerNack) int {
+	if !r.started || nack.MediaSSRC != r.ssrc {
+		return 0
+	}
+
+	var count int
+	for _, pair := range nack.Nacks {
+		for _, seq := range pair.PacketList() {
+			data, ok := r.Get(seq)
+			if !ok {
+				r.missed++
+				continue
+			}
+
+			if r.rtx {
+				var err error
+				if data, err = r.wrap(data); err != nil {
+					continue
+				}
+			}
+			r.handler(data)
+			r.retransmitted++
+			count++
+		}
+	}
+	return count
+}
+
+// wrap 封装为RTX包, 时间戳/marker/扩展头与原始包相同, 负载前加上原始序号
+func (r *Retransmitter) wrap(data []byte) ([]byte, error) {
+	var packet Packet
+	if err := packet.Unmarshal(data); err != nil {
+		return nil, err
+	}
+
+	payload := make([]byte, rtxHeaderLength+len(packet.Payload))
+	utils.WriteWORD(payload, uint16(packet.seq))
+	copy(payload[rtxHeaderLength:], packet.Payload)
+
+	packet.Payload = payload
+	packet.PaddingSize = 0
+	packet.pt = byte(r.rtxPayloadType)
+	packet.ssrc = r.rtxSSRC
+	packet.seq = int(r.rtxSeq)
+	r.rtxSeq++
+	return packet.Marshal()
+}
+
+// UnwrapRTX 将RFC 4588 RTX包还原为原始的RTP包
+// @payloadType 原始负载类型, 即fmtp中的apt
+// @ssrc 原始流的SSRC
+func UnwrapRTX(data []byte, payloadType int, ssrc uint32) ([]byte, error) {
+	var packet Packet
+	if err := packet.Unmarshal(data); err != nil {
+		return nil, err
+	} else if len(packet.Payload) < rtxHeaderLength {
+		return nil, fmt.Errorf("invalid rtx packet length %d", len(packet.Payload))
+	}
+
+	packet.seq = int(utils.BytesToUInt16(packet.Payload[0], packet.Payload[1]))
+	packet.Payload = packet.Payload[rtxHeaderLength:]
+	packet.PaddingSize = 0
+	packet.pt = byte(payloadType)
+	packet.ssrc = ssrc
+	return packet.Marshal()
+}
@@ -0,0 +1,64 @@
+package librtp
+
+import (
+	"avformat/librtcp"
+	"bytes"
+	"testing"
+)
+
+func TestRetransmitter(t *testing.T) {
+	var sent [][]byte
+	retransmitter := NewRetransmitter(4, func(packet []byte) {
+		sent = append(sent, packet)
+	})
+
+	//历史只保存4个包, 0和1已被覆盖
+	packets := make([][]byte, 6)
+	for i := range packets {
+		packets[i] = makePacket(uint16(i), uint32(i)*3000, i == 5, byte(i), 0xAA)
+		if err := retransmitter.Push(packets[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	nack := &librtcp.TransportLayerNack{MediaSSRC: 0x12345678, Nacks: librtcp.NackPairsFromSequenceNumbers([]uint16{1, 3, 5})}
+	if n := retransmitter.OnNack(nack); n != 2 || len(sent) != 2 {
+		t.Fatalf("unexpected retransmitted count %d", n)
+	} else if !bytes.Equal(sent[0], packets[3]) || !bytes.Equal(sent[1], packets[5]) {
+		t.Fatalf("the retransmitted packets are different from the original")
+	} else if retransmitter.Missed() != 1 {
+		t.Fatalf("unexpected missed count %d", retransmitter.Missed())
+	}
+
+	//其他SSRC的NACK
+	if n := retransmitter.OnNack(&librtcp.TransportLayerNack{MediaSSRC: 1, Nacks: nack.Nacks}); n != 0 {
+		t.Fatalf("retransmitted for another ssrc")
+	}
+
+	sent = nil
+	retransmitter.EnableRTX(97, 0x87654321)
+	retransmitter.OnNack(nack)
+	if len(sent) != 2 {
+		t.Fatalf("unexpected rtx count %d", len(sent))
+	}
+
+	for i, index := range []int{3, 5} {
+		var rtx Packet
+		if err := rtx.Unmarshal(sent[i]); err != nil {
+			t.Fatal(err)
+		} else if rtx.PayloadType() != 97 || rtx.SSRC() != 0x87654321 || rtx.SequenceNumber() != uint16(i) {
+			t.Fatalf("unexpected rtx header pt:%d ssrc:%x seq:%d", rtx.PayloadType(), rtx.SSRC(), rtx.SequenceNumber())
+		} else if rtx.Timestamp() != uint32(index)*3000 || rtx.Marker() != (index == 5) {
+			t.Fatalf("the rtx packet must keep the timestamp and marker")
+		} else if !bytes.Equal(rtx.Payload, []byte{0, byte(index), byte(index), 0xAA}) {
+			t.Fatalf("unexpected rtx payload %v", rtx.Payload)
+		}
+
+		original, err := UnwrapRTX(sent[i], 96, 0x12345678)
+		if err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(original, packets[index]) {
+			t.Fatalf("failed to restore the original packet %v", original)
+		}
+	}
+}
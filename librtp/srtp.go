@@ -0,0 +1,487 @@
+package librtp
+
+import (
+	"avformat/librtsp/sdp"
+	"avformat/utils"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"fmt"
+	"hash"
+	"sync"
+)
+
+// SRTPProfile SRTP保护方案, 名称与SDES的crypto-suite相同
+type SRTPProfile int
+
+const (
+	SRTPProfileAes128CmHmacSha1_80 SRTPProfile = iota + 1
+	SRTPProfileAes128CmHmacSha1_32
+	SRTPProfileAeadAes128Gcm //RFC 7714
+	SRTPProfileAeadAes256Gcm
+
+	//RFC 3711 4.3.1 密钥派生的label
+	labelSRTPEncryption  = 0x00
+	labelSRTPAuth        = 0x01
+	labelSRTPSalt        = 0x02
+	labelSRTCPEncryption = 0x03
+	labelSRTCPAuth       = 0x04
+	labelSRTCPSalt       = 0x05
+
+	srtcpIndexLength = 4 //E(1) | SRTCP index(31)
+	srtcpMaxIndex    = 0x7FFFFFFF
+	srtpReplayWindow = 64
+)
+
+type srtpProfileParams struct {
+	name              string
+	keyLength         int
+	saltLength        int
+	authKeyLength     int
+	authTagLength     int
+	rtcpAuthTagLength int
+	aead              bool
+}
+
+var srtpProfiles = map[SRTPProfile]srtpProfileParams{
+	SRTPProfileAes128CmHmacSha1_80: {"AES_CM_128_HMAC_SHA1_80", 16, 14, 20, 10, 10, false},
+	//SRTCP的认证标签固定为80位
+	SRTPProfileAes128CmHmacSha1_32: {"AES_CM_128_HMAC_SHA1_32", 16, 14, 20, 4, 10, false},
+	SRTPProfileAeadAes128Gcm:       {"AEAD_AES_128_GCM", 16, 12, 0, 16, 16, true},
+	SRTPProfileAeadAes256Gcm:       {"AEAD_AES_256_GCM", 32, 12, 0, 16, 16, true},
+}
+
+func (p SRTPProfile) String() string {
+	return srtpProfiles[p].name
+}
+
+// KeyLength master key长度
+func (p SRTPProfile) KeyLength() int {
+	return srtpProfiles[p].keyLength
+}
+
+// SaltLength master salt长度
+func (p SRTPProfile) SaltLength() int {
+	return srtpProfiles[p].saltLength
+}
+
+// ParseSRTPProfile 按SDES的crypto-suite名查找
+func ParseSRTPProfile(name string) (SRTPProfile, error) {
+	for profile, params := range srtpProfiles {
+		if params.name == name {
+			return profile, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported srtp profile %s", name)
+}
+
+// GenerateSRTPMasterKey 随机生成master key和master salt, 用于SDES
+func GenerateSRTPMasterKey(profile SRTPProfile) (key, salt []byte, err error) {
+	params, ok := srtpProfiles[profile]
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported srtp profile %d", profile)
+	}
+
+	material := make([]byte, params.keyLength+params.saltLength)
+	if _, err = rand.Read(material); err != nil {
+		return nil, nil, err
+	}
+	return material[:params.keyLength], material[params.keyLength:], nil
+}
+
+// replayWindow RFC 3711 3.3.2 滑动窗口, 最高位之前64个index
+type replayWindow struct {
+	started bool
+	highest uint64
+	mask    uint64
+}
+
+func (w *replayWindow) check(index uint64) bool {
+	if !w.started || index > w.highest {
+		return true
+	}
+
+	diff := w.highest - index
+	return diff < srtpReplayWindow && w.mask&(1<<diff) == 0
+}
+
+// accept 认证通过后记录
+func (w *replayWindow) accept(index uint64) {
+	if !w.started {
+		w.started = true
+		w.highest = index
+		w.mask = 1
+	} else if index > w.highest {
+		if shift := index - w.highest; shift >= srtpReplayWindow {
+			w.mask = 1
+		} else {
+			w.mask = w.mask<<shift | 1
+		}
+		w.highest = index
+	} else {
+		w.mask |= 1 << (w.highest - index)
+	}
+}
+
+// srtpStream 每个SSRC的ROC/序号和重放窗口
+type srtpStream struct {
+	started bool
+	roc     uint32
+	seq     uint16 //最大的序号s_l
+
+	replay     replayWindow
+	rtcpIndex  uint32 //发送的SRTCP index
+	rtcpReplay replayWindow
+}
+
+// estimateROC RFC 3711 附录A, 根据最大序号推测包的ROC
+func (s *srtpStream) estimateROC(seq uint16) uint32 {
+	if !s.started {
+		return s.roc
+	}
+
+	if s.seq < 0x8000 {
+		if int(seq)-int(s.seq) > 0x8000 && s.roc > 0 {
+			return s.roc - 1
+		}
+	} else if int(s.seq)-0x8000 > int(seq) {
+		return s.roc + 1
+	}
+	return s.roc
+}
+
+func (s *srtpStream) update(seq uint16, roc uint32) {
+	if !s.started {
+		s.started = true
+		s.roc = roc
+		s.seq = seq
+	} else if roc == s.roc+1 || (roc == s.roc && seq > s.seq) {
+		s.roc = roc
+		s.seq = seq
+	}
+}
+
+// srtpSessionKeys RTP或RTCP的会话密钥
+type srtpSessionKeys struct {
+	salt  []byte
+	block cipher.Block
+	gcm   cipher.AEAD
+	auth  hash.Hash
+}
+
+func newSRTPSessionKeys(params srtpProfileParams, master cipher.Block, masterSalt []byte, encryption, auth, salt byte) (*srtpSessionKeys, error) {
+	keys := &srtpSessionKeys{salt: deriveSessionKey(master, masterSalt, salt, params.saltLength)}
+	var err error
+	if keys.block, err = aes.NewCipher(deriveSessionKey(master, masterSalt, encryption, params.keyLength)); err != nil {
+		return nil, err
+	}
+
+	if params.aead {
+		keys.gcm, err = cipher.NewGCM(keys.block)
+	} else {
+		keys.auth = hmac.New(sha1.New, deriveSessionKey(master, masterSalt, auth, params.authKeyLength))
+	}
+	return keys, err
+}
+
+// deriveSessionKey RFC 3711 4.3.1 AES-CM PRF, key derivation rate为0
+func deriveSessionKey(master cipher.Block, masterSalt []byte, label byte, length int) []byte {
+	iv := make([]byte, aes.BlockSize)
+	copy(iv, masterSalt)
+	iv[7] ^= label
+
+	key := make([]byte, length)
+	cipher.NewCTR(master, iv).XORKeyStream(key, key)
+	return key
+}
+
+// counterIV RFC 3711 4.1.1 IV = (salt * 2^16) XOR (SSRC * 2^64) XOR (index * 2^16)
+func (k *srtpSessionKeys) counterIV(ssrc uint32, index uint64) []byte {
+	iv := make([]byte, aes.BlockSize)
+	copy(iv, k.salt)
+	for i := 0; i < 4; i++ {
+		iv[4+i] ^= byte(ssrc >> (24 - 8*i))
+	}
+	for i := 0; i < 6; i++ {
+		iv[8+i] ^= byte(index >> (40 - 8*i))
+	}
+	return iv
+}
+
+// rtpIV RFC 7714 8.1 IV = (00 00 || SSRC || ROC || SEQ) XOR salt
+func (k *srtpSessionKeys) rtpIV(ssrc, roc uint32, seq uint16) []byte {
+	iv := make([]byte, 12)
+	utils.WriteDWORD(iv[2:], ssrc)
+	utils.WriteDWORD(iv[6:], roc)
+	utils.WriteWORD(iv[10:], seq)
+	return k.xorSalt(iv)
+}
+
+// rtcpIV RFC 7714 9.1 IV = (00 00 || SSRC || 00 00 || SRTCP index) XOR salt
+func (k *srtpSessionKeys) rtcpIV(ssrc, index uint32) []byte {
+	iv := make([]byte, 12)
+	utils.WriteDWORD(iv[2:], ssrc)
+	utils.WriteDWORD(iv[8:], index)
+	return k.xorSalt(iv)
+}
+
+func (k *srtpSessionKeys) xorSalt(iv []byte) []byte {
+	for i := range iv {
+		iv[i] ^= k.salt[i]
+	}
+	return iv
+}
+
+// authTag HMAC-SHA1(data || roc)
+func (k *srtpSessionKeys) authTag(length int, data ...[]byte) []byte {
+	k.auth.Reset()
+	for _, bytes := range data {
+		k.auth.Write(bytes)
+	}
+	return k.auth.Sum(nil)[:length]
+}
+
+// SRTPContext 一个方向的SRTP/SRTCP上下文, 每个SSRC单独维护ROC和重放窗口
+// 发送和接收需要使用不同的上下文, 线程安全
+type SRTPContext struct {
+	lock    sync.Mutex
+	profile SRTPProfile
+	params  srtpProfileParams
+	rtp     *srtpSessionKeys
+	rtcp    *srtpSessionKeys
+	streams map[uint32]*srtpStream
+}
+
+// NewSRTPContext master key/salt来自SDES或DTLS-SRTP等外部密钥交换
+func NewSRTPContext(profile SRTPProfile, masterKey, masterSalt []byte) (*SRTPContext, error) {
+	params, ok := srtpProfiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("unsupported srtp profile %d", profile)
+	} else if len(masterKey) != params.keyLength {
+		return nil, fmt.Errorf("invalid srtp master key length %d, expected %d", len(masterKey), params.keyLength)
+	} else if len(masterSalt) != params.saltLength {
+		return nil, fmt.Errorf("invalid srtp master salt length %d, expected %d", len(masterSalt), params.saltLength)
+	}
+
+	master, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	context := &SRTPContext{profile: profile, params: params, streams: make(map[uint32]*srtpStream, 2)}
+	if context.rtp, err = newSRTPSessionKeys(params, master, masterSalt, labelSRTPEncryption, labelSRTPAuth, labelSRTPSalt); err != nil {
+		return nil, err
+	} else if context.rtcp, err = newSRTPSessionKeys(params, master, masterSalt, labelSRTCPEncryption, labelSRTCPAuth, labelSRTCPSalt); err != nil {
+		return nil, err
+	}
+	return context, nil
+}
+
+// NewSRTPContextWithCrypto 使用SDES a=crypto中的第一个密钥, 不支持MKI
+func NewSRTPContextWithCrypto(crypto *sdp.Crypto) (*SRTPContext, error) {
+	profile, err := ParseSRTPProfile(crypto.Suite)
+	if err != nil {
+		return nil, err
+	} else if len(crypto.KeyParams) == 0 {
+		return nil, fmt.Errorf("the crypto attribute has no key")
+	}
+
+	key := crypto.KeyParams[0]
+	if key.MKILength > 0 {
+		return nil, fmt.Errorf("unsupported srtp mki")
+	} else if len(key.Key) != profile.KeyLength()+profile.SaltLength() {
+		return nil, fmt.Errorf("invalid srtp key length %d", len(key.Key))
+	}
+	return NewSRTPContext(profile, key.Key[:profile.KeyLength()], key.Key[profile.KeyLength():])
+}
+
+func (c *SRTPContext) Profile() SRTPProfile {
+	return c.profile
+}
+
+func (c *SRTPContext) stream(ssrc uint32) *srtpStream {
+	stream, ok := c.streams[ssrc]
+	if !ok {
+		stream = &srtpStream{}
+		c.streams[ssrc] = stream
+	}
+	return stream
+}
+
+// SetROC 设置SSRC的ROC, 例如中途加入时由密钥交换告知
+func (c *SRTPContext) SetROC(ssrc, roc uint32) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stream := c.stream(ssrc)
+	stream.roc = roc
+}
+
+func (c *SRTPContext) ROC(ssrc uint32) (uint32, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stream, ok := c.streams[ssrc]
+	if !ok {
+		return 0, false
+	}
+	return stream.roc, true
+}
+
+// ProtectRTP 加密RTP包并添加认证标签, 返回新的缓冲区
+func (c *SRTPContext) ProtectRTP(packet []byte) ([]byte, error) {
+	var header Header
+	n, err := header.Unmarshal(packet)
+	if err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stream := c.stream(header.ssrc)
+	seq := uint16(header.seq)
+	roc := stream.estimateROC(seq)
+	stream.update(seq, roc)
+
+	dst := make([]byte, len(packet), len(packet)+c.params.authTagLength)
+	copy(dst, packet[:n])
+	if c.params.aead {
+		//头部作为附加认证数据
+		return c.rtp.gcm.Seal(dst[:n], c.rtp.rtpIV(header.ssrc, roc, seq), packet[n:], packet[:n]), nil
+	}
+
+	index := uint64(roc)<<16 | uint64(seq)
+	cipher.NewCTR(c.rtp.block, c.rtp.counterIV(header.ssrc, index)).XORKeyStream(dst[n:], packet[n:])
+	return append(dst, c.rtp.authTag(c.params.authTagLength, dst, rocBytes(roc))...), nil
+}
+
+// UnprotectRTP 校验认证标签和重放后解密, 返回新的缓冲区
+func (c *SRTPContext) UnprotectRTP(packet []byte) ([]byte, error) {
+	var header Header
+	n, err := header.Unmarshal(packet)
+	if err != nil {
+		return nil, err
+	} else if len(packet) < n+c.params.authTagLength {
+		return nil, fmt.Errorf("the srtp packet is too short %d", len(packet))
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stream := c.stream(header.ssrc)
+	seq := uint16(header.seq)
+	roc := stream.estimateROC(seq)
+	index := uint64(roc)<<16 | uint64(seq)
+	if !stream.replay.check(index) {
+		return nil, fmt.Errorf("replayed srtp packet ssrc:%x seq:%d", header.ssrc, seq)
+	}
+
+	var dst []byte
+	if c.params.aead {
+		dst = make([]byte, n, len(packet)-c.params.authTagLength)
+		copy(dst, packet[:n])
+		if dst, err = c.rtp.gcm.Open(dst, c.rtp.rtpIV(header.ssrc, roc, seq), packet[n:], packet[:n]); err != nil {
+			return nil, fmt.Errorf("failed to authenticate srtp packet ssrc:%x seq:%d", header.ssrc, seq)
+		}
+	} else {
+		length := len(packet) - c.params.authTagLength
+		tag := c.rtp.authTag(c.params.authTagLength, packet[:length], rocBytes(roc))
+		if subtle.ConstantTimeCompare(tag, packet[length:]) != 1 {
+			return nil, fmt.Errorf("failed to authenticate srtp packet ssrc:%x seq:%d", header.ssrc, seq)
+		}
+
+		dst = make([]byte, length)
+		copy(dst, packet[:n])
+		cipher.NewCTR(c.rtp.block, c.rtp.counterIV(header.ssrc, index)).XORKeyStream(dst[n:], packet[n:length])
+	}
+
+	stream.update(seq, roc)
+	stream.replay.accept(index)
+	return dst, nil
+}
+
+// ProtectRTCP 加密复合包中第一个包SSRC之后的数据, 添加E位和SRTCP index
+func (c *SRTPContext) ProtectRTCP(packet []byte) ([]byte, error) {
+	if len(packet) < 8 {
+		return nil, fmt.Errorf("the rtcp packet is too short %d", len(packet))
+	}
+
+	ssrc := utils.BytesToUInt32(packet[4], packet[5], packet[6], packet[7])
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stream := c.stream(ssrc)
+	index := stream.rtcpIndex
+	stream.rtcpIndex = (stream.rtcpIndex + 1) & srtcpMaxIndex
+	trailer := make([]byte, srtcpIndexLength)
+	utils.WriteDWORD(trailer, 0x80000000|index)
+
+	dst := make([]byte, len(packet), len(packet)+srtcpIndexLength+c.params.rtcpAuthTagLength)
+	copy(dst, packet[:8])
+	if c.params.aead {
+		//附加认证数据为前8个字节和E|index
+		aad := append(append([]byte(nil), packet[:8]...), trailer...)
+		dst = c.rtcp.gcm.Seal(dst[:8], c.rtcp.rtcpIV(ssrc, index), packet[8:], aad)
+		return append(dst, trailer...), nil
+	}
+
+	cipher.NewCTR(c.rtcp.block, c.rtcp.counterIV(ssrc, uint64(index))).XORKeyStream(dst[8:], packet[8:])
+	dst = append(dst, trailer...)
+	return append(dst, c.rtcp.authTag(c.params.rtcpAuthTagLength, dst)...), nil
+}
+
+// UnprotectRTCP 校验认证标签和重放后解密, E位为0时不解密
+func (c *SRTPContext) UnprotectRTCP(packet []byte) ([]byte, error) {
+	tagLength := c.params.rtcpAuthTagLength
+	if c.params.aead {
+		tagLength = 0
+	}
+	if len(packet) < 8+srtcpIndexLength+tagLength {
+		return nil, fmt.Errorf("the srtcp packet is too short %d", len(packet))
+	}
+
+	ssrc := utils.BytesToUInt32(packet[4], packet[5], packet[6], packet[7])
+	end := len(packet) - tagLength - srtcpIndexLength
+	trailer := packet[end : end+srtcpIndexLength]
+	encrypted := trailer[0]&0x80 != 0
+	index := utils.BytesToUInt32(trailer[0], trailer[1], trailer[2], trailer[3]) & srtcpMaxIndex
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stream := c.stream(ssrc)
+	if !stream.rtcpReplay.check(uint64(index)) {
+		return nil, fmt.Errorf("replayed srtcp packet ssrc:%x index:%d", ssrc, index)
+	}
+
+	var dst []byte
+	if c.params.aead {
+		if !encrypted {
+			return nil, fmt.Errorf("unsupported unencrypted srtcp")
+		}
+
+		aad := append(append([]byte(nil), packet[:8]...), trailer...)
+		var err error
+		if dst, err = c.rtcp.gcm.Open(append([]byte(nil), packet[:8]...), c.rtcp.rtcpIV(ssrc, index), packet[8:end], aad); err != nil {
+			return nil, fmt.Errorf("failed to authenticate srtcp packet ssrc:%x index:%d", ssrc, index)
+		}
+	} else {
+		tag := c.rtcp.authTag(tagLength, packet[:end+srtcpIndexLength])
+		if subtle.ConstantTimeCompare(tag, packet[end+srtcpIndexLength:]) != 1 {
+			return nil, fmt.Errorf("failed to authenticate srtcp packet ssrc:%x index:%d", ssrc, index)
+		}
+
+		dst = make([]byte, end)
+		copy(dst, packet[:end])
+		if encrypted {
+			cipher.NewCTR(c.rtcp.block, c.rtcp.counterIV(ssrc, uint64(index))).XORKeyStream(dst[8:], packet[8:end])
+		}
+	}
+
+	stream.rtcpReplay.accept(uint64(index))
+	return dst, nil
+}
+
+func rocBytes(roc uint32) []byte {
+	bytes := make([]byte, 4)
+	utils.WriteDWORD(bytes, roc)
+	return bytes
+}
@@ -0,0 +1,171 @@
+package librtp
+
+import (
+	"avformat/librtsp/sdp"
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"testing"
+)
+
+func decodeHex(t *testing.T, s string) []byte {
+	bytes, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes
+}
+
+func TestSRTPKeyDerivation(t *testing.T) {
+	//RFC 3711 B.3
+	master, _ := aes.NewCipher(decodeHex(t, "E1F97A0D3E018BE0D64FA32C06DE4139"))
+	salt := decodeHex(t, "0EC675AD498AFEEBB6960B3AABE6")
+
+	if key := deriveSessionKey(master, salt, labelSRTPEncryption, 16); !bytes.Equal(key, decodeHex(t, "C61E7A93744F39EE10734AFE3FF7A087")) {
+		t.Fatalf("unexpected cipher key %x", key)
+	} else if key = deriveSessionKey(master, salt, labelSRTPSalt, 14); !bytes.Equal(key, decodeHex(t, "30CBBC08863D8C85D49DB34A9AE1")) {
+		t.Fatalf("unexpected cipher salt %x", key)
+	} else if key = deriveSessionKey(master, salt, labelSRTPAuth, 20); !bytes.Equal(key, decodeHex(t, "CEBE321F6FF7716B6FD4AB49AF256A156D38BAA4")) {
+		t.Fatalf("unexpected auth key %x", key)
+	}
+}
+
+func TestSRTPTestVector(t *testing.T) {
+	//libsrtp的AES_CM_128_HMAC_SHA1_80测试向量
+	key := decodeHex(t, "E1F97A0D3E018BE0D64FA32C06DE41390EC675AD498AFEEBB6960B3AABE6")
+	plaintext := decodeHex(t, "800F1234DECAFBADCAFEBABEABABABABABABABABABABABABABABABAB")
+	ciphertext := decodeHex(t, "800F1234DECAFBADCAFEBABE4E55DC4CE79978D88CA4D215949D2402B78D6ACC99EA179B8DBB")
+
+	sender, err := NewSRTPContext(SRTPProfileAes128CmHmacSha1_80, key[:16], key[16:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if protected, err := sender.ProtectRTP(plaintext); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(protected, ciphertext) {
+		t.Fatalf("unexpected srtp packet %x", protected)
+	}
+
+	receiver, _ := NewSRTPContext(SRTPProfileAes128CmHmacSha1_80, key[:16], key[16:])
+	if unprotected, err := receiver.UnprotectRTP(ciphertext); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(unprotected, plaintext) {
+		t.Fatalf("unexpected rtp packet %x", unprotected)
+	}
+}
+
+func TestSRTPProfiles(t *testing.T) {
+	for _, profile := range []SRTPProfile{SRTPProfileAes128CmHmacSha1_80, SRTPProfileAes128CmHmacSha1_32, SRTPProfileAeadAes128Gcm, SRTPProfileAeadAes256Gcm} {
+		key, salt, err := GenerateSRTPMasterKey(profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		//经过SDES传递密钥
+		crypto := sdp.NewCrypto(1, profile.String(), append(append([]byte(nil), key...), salt...))
+		var parsed sdp.Crypto
+		if err = parsed.Unmarshal(crypto.Marshal()); err != nil {
+			t.Fatal(err)
+		}
+		sender, err := NewSRTPContextWithCrypto(&parsed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		receiver, _ := NewSRTPContext(profile, key, salt)
+
+		//序号回绕后ROC加1, 65534和65535乱序到达
+		var protected [][]byte
+		var plaintext [][]byte
+		for i, seq := range []uint16{65533, 65534, 65535, 0, 1} {
+			packet := makePacket(seq, uint32(i)*3000, false, bytes.Repeat([]byte{byte(i)}, 50+i)...)
+			srtp, err := sender.ProtectRTP(packet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			plaintext = append(plaintext, packet)
+			protected = append(protected, srtp)
+		}
+		if roc, _ := sender.ROC(0x12345678); roc != 1 {
+			t.Fatalf("%s: unexpected sender roc %d", profile, roc)
+		}
+
+		for _, i := range []int{0, 2, 1, 3, 4} {
+			packet, err := receiver.UnprotectRTP(protected[i])
+			if err != nil {
+				t.Fatalf("%s: %s", profile, err.Error())
+			} else if !bytes.Equal(packet, plaintext[i]) {
+				t.Fatalf("%s: failed to decrypt packet %d", profile, i)
+			}
+		}
+		if roc, _ := receiver.ROC(0x12345678); roc != 1 {
+			t.Fatalf("%s: unexpected receiver roc %d", profile, roc)
+		}
+
+		//重放和篡改
+		if _, err = receiver.UnprotectRTP(protected[3]); err == nil {
+			t.Fatalf("%s: accepted a replayed packet", profile)
+		}
+		tampered, _ := sender.ProtectRTP(makePacket(2, 0, false, 1, 2, 3))
+		tampered[FixedHeaderLength] ^= 1
+		if _, err = receiver.UnprotectRTP(tampered); err == nil {
+			t.Fatalf("%s: accepted a tampered packet", profile)
+		}
+
+		//SRTCP
+		rtcp := decodeHex(t, "80C8000612345678E1F97A0D3E018BE00000000100000001000000A0")
+		srtcp, err := sender.ProtectRTCP(rtcp)
+		if err != nil {
+			t.Fatal(err)
+		} else if bytes.Equal(srtcp[8:len(rtcp)], rtcp[8:]) {
+			t.Fatalf("%s: the rtcp packet is not encrypted", profile)
+		}
+		if packet, err := receiver.UnprotectRTCP(srtcp); err != nil {
+			t.Fatalf("%s: %s", profile, err.Error())
+		} else if !bytes.Equal(packet, rtcp) {
+			t.Fatalf("%s: failed to decrypt rtcp packet %x", profile, packet)
+		}
+		if _, err = receiver.UnprotectRTCP(srtcp); err == nil {
+			t.Fatalf("%s: accepted a replayed rtcp packet", profile)
+		}
+	}
+}
+
+func TestMIKEY(t *testing.T) {
+	for _, profile := range []SRTPProfile{SRTPProfileAes128CmHmacSha1_80, SRTPProfileAes128CmHmacSha1_32, SRTPProfileAeadAes128Gcm, SRTPProfileAeadAes256Gcm} {
+		local, err := NewMIKEY(profile, 0x12345678)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := local.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		//HDR | T | RAND | SP | KEMAC
+		if data[0] != 1 || data[2] != mikeyPayloadT || !bytes.Equal(data[11:15], []byte{0x12, 0x34, 0x56, 0x78}) {
+			t.Fatalf("%s: unexpected mikey header % x", profile, data[:19])
+		}
+		remote, err := ParseMIKEY(data)
+		if err != nil {
+			t.Fatal(err)
+		} else if remote.Profile != profile || remote.SSRC != local.SSRC || !bytes.Equal(remote.Key, local.Key) || !bytes.Equal(remote.Salt, local.Salt) {
+			t.Fatalf("%s: unexpected mikey %+v", profile, remote)
+		}
+
+		//对端使用MIKEY中的密钥解密
+		sender, _ := local.NewSRTPContext()
+		receiver, err := remote.NewSRTPContext()
+		if err != nil {
+			t.Fatal(err)
+		}
+		packet := makePacket(1, 3000, true, 0x01, 0x02, 0x03)
+		srtp, _ := sender.ProtectRTP(packet)
+		if unprotected, err := receiver.UnprotectRTP(srtp); err != nil || !bytes.Equal(unprotected, packet) {
+			t.Fatalf("%s: unprotect failed %v", profile, err)
+		}
+	}
+
+	if _, err := ParseMIKEY([]byte{1, 0, 0}); err == nil {
+		t.Fatalf("the truncated message must fail")
+	}
+}
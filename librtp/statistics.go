@@ -0,0 +1,150 @@
+package librtp
+
+import (
+	"avformat/librtcp"
+	"time"
+)
+
+const (
+	rtpSeqMod   = 1 << 16
+	maxDropout  = 3000
+	maxMisorder = 100
+)
+
+// ReceiverStatistics RFC 3550 Appendix A.1/A.3/A.8, 用于生成RR的report block
+type ReceiverStatistics struct {
+	ssrc          uint32
+	clockRate     int
+	initialized   bool
+	baseSeq       uint16
+	maxSeq        uint16
+	cycles        uint32
+	received      uint32
+	expectedPrior uint32
+	receivedPrior uint32
+	transit       uint32
+	jitter        float64
+	baseArrival   time.Time //第一个包的到达时间
+
+	lastSR     uint32 //middle 32 bits of the NTP timestamp in the last SR
+	lastSRTime time.Time
+}
+
+// NewReceiverStatistics clockRate小于等于0时不计算jitter
+func NewReceiverStatistics(clockRate int) *ReceiverStatistics {
+	return &ReceiverStatistics{clockRate: clockRate}
+}
+
+// Update 收到一个RTP包, 在重排序之前按到达顺序调用
+func (r *ReceiverStatistics) Update(h *Header, arrival time.Time) {
+	seq := uint16(h.seq)
+	if !r.initialized || r.ssrc != h.ssrc {
+		*r = ReceiverStatistics{ssrc: h.ssrc, clockRate: r.clockRate, initialized: true, baseSeq: seq, maxSeq: seq}
+	} else if delta := seq - r.maxSeq; delta < maxDropout {
+		//in order, with permissible gap
+		if seq < r.maxSeq {
+			r.cycles += rtpSeqMod
+		}
+		r.maxSeq = seq
+	} else if delta <= rtpSeqMod-maxMisorder {
+		//the sequence number made a very large jump
+		*r = ReceiverStatistics{ssrc: h.ssrc, clockRate: r.clockRate, initialized: true, baseSeq: seq, maxSeq: seq,
+			lastSR: r.lastSR, lastSRTime: r.lastSRTime}
+	}
+	r.received++
+
+	if r.clockRate <= 0 {
+		return
+	}
+
+	//以第一个包为基准, 避免UnixNano乘以时钟频率溢出
+	if r.received == 1 {
+		r.baseArrival = arrival
+	}
+	elapsed := arrival.Sub(r.baseArrival)
+	arrivalTS := int64(elapsed/time.Second)*int64(r.clockRate) + int64(elapsed%time.Second)*int64(r.clockRate)/int64(time.Second)
+	//按32位回绕计算, 时间戳回绕时差值仍然正确
+	transit := uint32(arrivalTS) - h.timestamp
+	if r.received > 1 {
+		d := int64(int32(transit - r.transit))
+		if d < 0 {
+			d = -d
+		}
+		r.jitter += (float64(d) - r.jitter) / 16
+	}
+	r.transit = transit
+}
+
+// OnSenderReport 收到对端的SR, 用于计算LSR和DLSR
+func (r *ReceiverStatistics) OnSenderReport(ntp uint64, arrival time.Time) {
+	r.lastSR = uint32(ntp >> 16)
+	r.lastSRTime = arrival
+}
+
+// Initialized 是否收到过RTP包
+func (r *ReceiverStatistics) Initialized() bool {
+	return r.initialized
+}
+
+func (r *ReceiverStatistics) SSRC() uint32 {
+	return r.ssrc
+}
+
+// ExtendedHighestSequenceNumber 扩展的最大序号, 高16位为回绕次数
+func (r *ReceiverStatistics) ExtendedHighestSequenceNumber() uint32 {
+	return r.cycles + uint32(r.maxSeq)
+}
+
+// Received 收到的包数, 包含重复包
+func (r *ReceiverStatistics) Received() uint32 {
+	return r.received
+}
+
+// Lost 累计丢包数, 重复包可能导致负数
+func (r *ReceiverStatistics) Lost() int64 {
+	expected := r.ExtendedHighestSequenceNumber() - uint32(r.baseSeq) + 1
+	return int64(expected) - int64(r.received)
+}
+
+// Jitter interarrival jitter, 单位为时钟频率
+func (r *ReceiverStatistics) Jitter() float64 {
+	return r.jitter
+}
+
+// ReceptionReport 生成report block, 同时开始新的丢包率统计区间
+func (r *ReceiverStatistics) ReceptionReport(now time.Time) librtcp.ReceptionReport {
+	extendedMax := r.ExtendedHighestSequenceNumber()
+	expected := extendedMax - uint32(r.baseSeq) + 1
+	lost := r.Lost()
+	//clamp to 24 bits signed
+	if lost > 0x7FFFFF {
+		lost = 0x7FFFFF
+	} else if lost < -0x800000 {
+		lost = -0x800000
+	}
+
+	expectedInterval := expected - r.expectedPrior
+	receivedInterval := r.received - r.receivedPrior
+	r.expectedPrior = expected
+	r.receivedPrior = r.received
+	var fraction byte
+	if lostInterval := int64(expectedInterval) - int64(receivedInterval); expectedInterval != 0 && lostInterval > 0 {
+		fraction = byte((lostInterval << 8) / int64(expectedInterval))
+	}
+
+	var dlsr uint32
+	if !r.lastSRTime.IsZero() {
+		//units of 1/65536 seconds
+		dlsr = uint32(now.Sub(r.lastSRTime) * 65536 / time.Second)
+	}
+
+	return librtcp.ReceptionReport{
+		SSRC:               r.ssrc,
+		FractionLost:       fraction,
+		TotalLost:          int32(lost),
+		LastSequenceNumber: extendedMax,
+		Jitter:             uint32(r.jitter),
+		LastSenderReport:   r.lastSR,
+		Delay:              dlsr,
+	}
+}
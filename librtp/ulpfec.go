@@ -0,0 +1,233 @@
+package librtp
+
+import (
+	"avformat/utils"
+	"fmt"
+)
+
+const (
+	// ULPFECMaxGroupSize L=1时的掩码长度
+	ULPFECMaxGroupSize = 48
+
+	ulpfecHeaderLength = 10
+	//level 0 header, protection length(16) + mask
+	ulpfecLevelHeaderLength = 2
+	ulpfecShortMaskLength   = 2
+	ulpfecLongMaskLength    = 6
+)
+
+// ULPFECEncoder RFC 5109 ULPFEC, 媒体包和FEC包都封装在RFC 2198 RED中, 使用同一个SSRC和序号空间
+// 只生成level 0, 每groupSize个媒体包生成一个FEC包
+// 非线程安全
+type ULPFECEncoder struct {
+	redPayloadType int
+	fecPayloadType int
+	groupSize      int
+	handler        func(packet []byte)
+
+	started bool
+	seq     uint16
+	group   [][]byte //当前组内重新编号后的媒体包
+}
+
+// NewULPFECEncoder groupSize范围为1-48
+// @handler 回调RED包, 包括媒体包和FEC包
+func NewULPFECEncoder(redPayloadType, fecPayloadType, groupSize int, handler func(packet []byte)) *ULPFECEncoder {
+	if groupSize < 1 {
+		groupSize = 1
+	} else if groupSize > ULPFECMaxGroupSize {
+		groupSize = ULPFECMaxGroupSize
+	}
+
+	return &ULPFECEncoder{redPayloadType: redPayloadType, fecPayloadType: fecPayloadType, groupSize: groupSize, handler: handler}
+}
+
+// Input 输入媒体包. FEC包占用序号, 媒体包从第一个包的序号开始重新编号
+func (e *ULPFECEncoder) Input(data []byte) error {
+	var packet Packet
+	if err := packet.Unmarshal(data); err != nil {
+		return err
+	}
+
+	if !e.started {
+		e.started = true
+		e.seq = uint16(packet.seq)
+	}
+	packet.seq = int(e.seq)
+	e.seq++
+
+	media, err := packet.Marshal()
+	if err != nil {
+		return err
+	}
+	e.group = append(e.group, media)
+
+	red, err := e.wrap(&packet, int(packet.pt), packet.Payload)
+	if err != nil {
+		return err
+	}
+	e.handler(red)
+
+	if len(e.group) >= e.groupSize {
+		return e.Flush()
+	}
+	return nil
+}
+
+// Flush 为当前组生成FEC包, 例如在一帧的最后一个包之后调用
+func (e *ULPFECEncoder) Flush() error {
+	if len(e.group) == 0 {
+		return nil
+	}
+
+	group := e.group
+	e.group = e.group[:0]
+
+	var last Packet
+	if err := last.Unmarshal(group[len(group)-1]); err != nil {
+		return err
+	}
+
+	fec := xorPackets(group)
+	packet := NewPacket(0, e.seq, last.Timestamp(), last.SSRC(), nil)
+	e.seq++
+	red, err := e.wrap(packet, e.fecPayloadType, marshalULPFEC(uint16(last.seq)-uint16(len(group)-1), len(group), fec))
+	if err != nil {
+		return err
+	}
+	e.handler(red)
+	return nil
+}
+
+// wrap 只有主块的RED包
+func (e *ULPFECEncoder) wrap(packet *Packet, payloadType int, payload []byte) ([]byte, error) {
+	red, err := MarshalRED([]REDBlock{{PayloadType: payloadType, Payload: payload}})
+	if err != nil {
+		return nil, err
+	}
+
+	packet.pt = byte(e.redPayloadType)
+	packet.Payload = red
+	return packet.Marshal()
+}
+
+// marshalULPFEC FEC头 + level 0头 + FEC负载, 被保护的包从base开始连续
+func marshalULPFEC(base uint16, count int, fec *fecPacket) []byte {
+	maskLength := ulpfecShortMaskLength
+	if count > ulpfecShortMaskLength*8 {
+		maskLength = ulpfecLongMaskLength
+	}
+
+	offset := ulpfecHeaderLength + ulpfecLevelHeaderLength + maskLength
+	data := make([]byte, offset+len(fec.payload))
+	//E(1) | L(1) | P | X | CC
+	data[0] = fec.header[0] & 0x3F
+	if maskLength == ulpfecLongMaskLength {
+		data[0] |= 0x40
+	}
+	data[1] = fec.header[1]
+	utils.WriteWORD(data[2:], base)
+	utils.WriteDWORD(data[4:], fec.timestamp)
+	utils.WriteWORD(data[8:], fec.length)
+	utils.WriteWORD(data[10:], uint16(len(fec.payload)))
+	for i := 0; i < count; i++ {
+		data[12+i/8] |= 0x80 >> (i % 8)
+	}
+	copy(data[offset:], fec.payload)
+	return data
+}
+
+// parseULPFEC 只使用level 0
+// @ssrc FEC包的SSRC, 与被保护的流相同
+func parseULPFEC(data []byte, ssrc uint32) (*fecPacket, error) {
+	if len(data) < ulpfecHeaderLength+ulpfecLevelHeaderLength+ulpfecShortMaskLength {
+		return nil, fmt.Errorf("invalid ulpfec length %d", len(data))
+	} else if data[0]&0x80 != 0 {
+		return nil, fmt.Errorf("unsupported ulpfec extension flag")
+	}
+
+	maskLength := ulpfecShortMaskLength
+	if data[0]&0x40 != 0 {
+		maskLength = ulpfecLongMaskLength
+	}
+	offset := ulpfecHeaderLength + ulpfecLevelHeaderLength + maskLength
+	if len(data) < offset {
+		return nil, fmt.Errorf("invalid ulpfec length %d", len(data))
+	}
+	protectionLength := int(utils.BytesToUInt16(data[10], data[11]))
+	if len(data) < offset+protectionLength {
+		return nil, fmt.Errorf("invalid ulpfec protection length %d", protectionLength)
+	}
+
+	var mask uint64
+	for _, b := range data[12 : 12+maskLength] {
+		mask = mask<<8 | uint64(b)
+	}
+
+	base := utils.BytesToUInt16(data[2], data[3])
+	return &fecPacket{
+		ssrc:      ssrc,
+		protected: appendMask(nil, base, mask, maskLength*8),
+		header:    [2]byte{data[0] & 0x3F, data[1]},
+		timestamp: utils.BytesToUInt32(data[4], data[5], data[6], data[7]),
+		length:    utils.BytesToUInt16(data[8], data[9]),
+		payload:   append([]byte(nil), data[offset:offset+protectionLength]...),
+	}, nil
+}
+
+// ULPFECDecoder 解封装RED并使用ULPFEC恢复丢失的媒体包
+// 恢复的包在恢复时回调, 与收到的包不保证顺序, 需要后接JitterBuffer
+// FEC包占用的序号在媒体流中表现为间隔
+// 非线程安全
+type ULPFECDecoder struct {
+	redPayloadType int
+	fecPayloadType int
+	recovery       *fecRecovery
+}
+
+// NewULPFECDecoder
+// @handler 回调去除RED封装的媒体包
+func NewULPFECDecoder(redPayloadType, fecPayloadType int, handler func(packet []byte)) *ULPFECDecoder {
+	return &ULPFECDecoder{redPayloadType: redPayloadType, fecPayloadType: fecPayloadType, recovery: newFECRecovery(handler)}
+}
+
+// Recovered 已恢复的包数
+func (d *ULPFECDecoder) Recovered() uint32 {
+	return d.recovery.recovered
+}
+
+// Input 输入RED包, 非RED的包直接作为媒体包
+func (d *ULPFECDecoder) Input(data []byte) error {
+	var packet Packet
+	if err := packet.Unmarshal(data); err != nil {
+		return err
+	} else if int(packet.pt) != d.redPayloadType {
+		d.recovery.addMedia(append([]byte(nil), data...))
+		return nil
+	}
+
+	blocks, err := UnmarshalRED(packet.Payload)
+	if err != nil {
+		return err
+	}
+
+	//冗余块没有序号, 只使用主块
+	primary := blocks[len(blocks)-1]
+	if primary.PayloadType == d.fecPayloadType {
+		fec, err := parseULPFEC(primary.Payload, packet.SSRC())
+		if err != nil {
+			return err
+		}
+		d.recovery.addFEC(fec)
+		return nil
+	}
+
+	packet.pt = byte(primary.PayloadType)
+	packet.Payload = primary.Payload
+	media, err := packet.Marshal()
+	if err != nil {
+		return err
+	}
+	d.recovery.addMedia(media)
+	return nil
+}
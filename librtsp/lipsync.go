@@ -0,0 +1,52 @@
+package librtsp
+
+import (
+	"sync"
+	"time"
+)
+
+// lipSync 会话内所有track共享的时间基准. 第一个能通过SR换算绝对时间的track作为基准,
+// 其他track按绝对时间的差值调整pts, 避免各track随机的初始时间戳导致音视频不同步
+type lipSync struct {
+	lock        sync.Mutex
+	initialized bool
+	wallClock   time.Time
+	position    time.Duration //基准帧的播放位置
+}
+
+// offset 返回pts需要调整的值, 基准track返回0
+// @wallClock pts对应的发送端绝对时间
+func (l *lipSync) offset(wallClock time.Time, pts int64, clockRate int) int64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	position := ticksToDuration(pts, clockRate)
+	if !l.initialized {
+		l.initialized = true
+		l.wallClock = wallClock
+		l.position = position
+		return 0
+	}
+
+	aligned := l.position + wallClock.Sub(l.wallClock)
+	return durationToTicks(aligned, clockRate) - pts
+}
+
+// ticksToDuration 时间戳转换为时长, 整秒和余数分开计算, 避免长时间播放后乘法溢出int64
+func ticksToDuration(ticks int64, clockRate int) time.Duration {
+	rate := int64(clockRate)
+	return time.Duration(ticks/rate)*time.Second + time.Duration(ticks%rate)*time.Second/time.Duration(rate)
+}
+
+// durationToTicks 时长转换为时间戳, 同ticksToDuration分开计算
+func durationToTicks(d time.Duration, clockRate int) int64 {
+	rate := int64(clockRate)
+	return int64(d/time.Second)*rate + int64(d%time.Second)*rate/int64(time.Second)
+}
+
+// reset PLAY后重新选择基准
+func (l *lipSync) reset() {
+	l.lock.Lock()
+	l.initialized = false
+	l.lock.Unlock()
+}
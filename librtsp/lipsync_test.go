@@ -0,0 +1,72 @@
+package librtsp
+
+import (
+	"avformat/librtcp"
+	"avformat/librtp"
+	"testing"
+	"time"
+)
+
+func TestLipSync(t *testing.T) {
+	session := &lipSync{}
+	newServer := func(clockRate int) *Server {
+		server := &Server{track: &Track{ClockRate: clockRate}, lipSync: session, senderClock: librtp.NewSenderClock(clockRate)}
+		server.play(0, nil)
+		return server
+	}
+
+	//视频和音频的初始时间戳不同, SR中相同的NTP时间
+	now := time.Unix(1700000000, 0)
+	video, audio := newServer(90000), newServer(8000)
+	video.senderClock.Update(librtcp.ToNtpTime(now), 1000)
+	audio.senderClock.Update(librtcp.ToNtpTime(now), 5000)
+
+	//视频的第一帧在1s, 音频的第一帧在0.5s
+	if pts := video.extendTimestamp(1000 + 90000); pts != 0 {
+		t.Fatalf("the reference track must not be shifted %d", pts)
+	}
+	if pts := audio.extendTimestamp(5000 + 4000); pts != -4000 {
+		t.Fatalf("unexpected aligned audio pts %d", pts)
+	}
+	if pts := video.extendTimestamp(1000 + 90000 + 3000); pts != 3000 {
+		t.Fatalf("unexpected video pts %d", pts)
+	}
+
+	if captureTime, ok := audio.captureTime(-4000 + 800); !ok || !captureTime.Equal(now.Add(600*time.Millisecond)) {
+		t.Fatalf("unexpected capture time %v", captureTime)
+	}
+	if captureTime, ok := video.captureTime(0); !ok || !captureTime.Equal(now.Add(time.Second)) {
+		t.Fatalf("unexpected capture time %v", captureTime)
+	}
+}
+
+func TestLipSyncLargePts(t *testing.T) {
+	//90kHz下约55h的pts, pts*time.Second会溢出int64
+	session := &lipSync{}
+	now := time.Unix(1700000000, 0)
+	pts := int64(90000) * 200000
+	if offset := session.offset(now, pts, 90000); offset != 0 {
+		t.Fatalf("the reference track must not be shifted %d", offset)
+	}
+
+	//同一时刻的音频, 对齐到视频的播放位置
+	audioPts := int64(1) << 33
+	expected := int64(8000)*200000 - audioPts
+	if offset := session.offset(now, audioPts, 8000); offset != expected {
+		t.Fatalf("unexpected offset %d, expected %d", offset, expected)
+	}
+	if offset := session.offset(now.Add(time.Second), pts+90000, 90000); offset != 0 {
+		t.Fatalf("unexpected offset %d", offset)
+	}
+}
+
+func TestSenderReportLongElapsed(t *testing.T) {
+	//距离最后一个包超过28h, elapsed*clockRate会溢出int64
+	now := time.Unix(1700000000, 0)
+	s := senderStatistics{timestamp: 1000, time: now}
+	elapsed := 30 * time.Hour
+	report := s.report(90000, now.Add(elapsed))
+	if expected := uint32(1000 + int64(elapsed/time.Second)*90000); report.RTPTime != expected {
+		t.Fatalf("unexpected rtp time %d, expected %d", report.RTPTime, expected)
+	}
+}
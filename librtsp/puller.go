@@ -1,18 +1,20 @@
 package librtsp
 
 import (
+	"avformat/librtcp"
+	"avformat/librtp"
 	"avformat/librtsp/sdp"
 	"avformat/utils"
-	"bufio"
-	bytes2 "bytes"
+	"encoding/base64"
 	"fmt"
+	"math/rand"
 	"net"
 	"net/http"
-	"net/textproto"
 	url2 "net/url"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 type Setup string
@@ -24,10 +26,29 @@ const (
 	SetupPlay     = "4"
 	SetupTeardown = "5"
 	SetupTearAuth = "6"
+	SetupPause    = "7"
+
+	// DefaultSessionTimeout RFC 2326 12.37 Session头未携带timeout时默认60秒
+	DefaultSessionTimeout = 60
+	TeardownTimeout       = 2 * time.Second
+
+	BackchannelRequire = "www.onvif.org/ver20/backchannel"
+
+	// DefaultLatency UDP传输时jitter buffer的等待时间, TCP交织传输不需要重排序
+	DefaultLatency = 100 * time.Millisecond
 )
 
 type OnRTPPacketHandler func(mediaType utils.AVMediaType, data []byte)
 
+// OnTrackHandler DESCRIBE后回调每个track的编码参数, 先于该track的第一帧
+type OnTrackHandler func(index int, track *Track)
+
+// OnFrameHandler 回调完整的帧. H264/H265为AnnexB格式, pts单位为track的时钟频率
+type OnFrameHandler func(index int, data []byte, pts int64, keyFrame bool)
+
+// OnRTCPHandler 回调服务器发送的RTCP复合包
+type OnRTCPHandler func(index int, packets []librtcp.Packet)
+
 type Puller struct {
 	url       string
 	buffer    []byte
@@ -38,15 +59,97 @@ type Puller struct {
 	username  string
 	password  string
 	session   string
-
-	medias    []*Server
-	state     Setup
-	setupLock sync.Mutex
-	handler   OnRTPPacketHandler
+	timeout   int
+
+	tracks         []*Track
+	medias         []*Server
+	setupIndex     int
+	state          Setup
+	setupLock      sync.Mutex
+	mediaLock      sync.Mutex //修改medias时同时持有setupLock, 只读时可以单独使用
+	handler        OnRTPPacketHandler
+	onTrackHandler OnTrackHandler
+	onFrameHandler OnFrameHandler
+	onRTCPHandler  OnRTCPHandler
+
+	cseq         int
+	requests     map[int]*Request
+	recvBuffer   []byte
+	authParams   map[string]string
+	getParameter bool //服务器是否支持GET_PARAMETER保活
+	ssrc         uint32
+	cname        string
+	closed       chan struct{}
+	closeOnce    sync.Once
+	teardownDone chan struct{}
+
+	interleaved      bool //RTP/AVP/TCP, 使用HTTP隧道时必须交织传输
+	multicast        bool
+	backchannel      bool           //ONVIF反向音频
+	iface            *net.Interface //加入组播组的网卡
+	keepAliveStarted bool
+	scale            float64
+	speed            float64
+	playRange        Range
+	latency          time.Duration
+	lipSync          *lipSync
 }
 
 func NewPuller(h OnRTPPacketHandler) *Puller {
-	return &Puller{buffer: make([]byte, 1024), handler: h}
+	ssrc := rand.Uint32()
+	return &Puller{
+		buffer:       make([]byte, 1024*4),
+		handler:      h,
+		requests:     make(map[int]*Request, 8),
+		ssrc:         ssrc,
+		cname:        fmt.Sprintf("%08x@avformat", ssrc),
+		closed:       make(chan struct{}),
+		teardownDone: make(chan struct{}, 1),
+		latency:      DefaultLatency,
+		lipSync:      &lipSync{},
+	}
+}
+
+func (p *Puller) SetOnTrackHandler(handler OnTrackHandler) {
+	p.onTrackHandler = handler
+}
+
+func (p *Puller) SetOnFrameHandler(handler OnFrameHandler) {
+	p.onFrameHandler = handler
+}
+
+func (p *Puller) SetOnRTCPHandler(handler OnRTCPHandler) {
+	p.onRTCPHandler = handler
+}
+
+// EnableBackchannel 请求ONVIF反向音频通道, 在Open之前调用
+// 服务器SDP中sendonly的track为反向通道, 使用WriteFrame发送
+func (p *Puller) EnableBackchannel() {
+	p.backchannel = true
+}
+
+// isBackchannel ONVIF服务器中sendonly表示由客户端发送
+func (p *Puller) isBackchannel(track *Track) bool {
+	return p.backchannel && track.Direction == sdp.DirectionSendOnly
+}
+
+// requireHeader DESCRIBE/SETUP/PLAY携带Require: www.onvif.org/ver20/backchannel
+func (p *Puller) requireHeader(header map[string]string) map[string]string {
+	if p.backchannel {
+		header["Require"] = BackchannelRequire
+	}
+	return header
+}
+
+// SetLatency 设置UDP传输时jitter buffer的等待时间, 在Open之前调用. 为0时不等待乱序的包
+func (p *Puller) SetLatency(latency time.Duration) {
+	p.latency = latency
+}
+
+// SetMulticast 请求组播传输, 在Open之前调用. iface为nil时由系统选择网卡
+func (p *Puller) SetMulticast(iface *net.Interface) {
+	p.multicast = true
+	p.iface = iface
 }
 
 func parseTransportHeader(header string) map[string]string {
@@ -59,7 +162,7 @@ func parseTransportHeader(header string) map[string]string {
 			space := strings.TrimSpace(s)
 			index := strings.Index(strings.TrimSpace(space), "=")
 			if index == -1 {
-				params["space"] = ""
+				params[space] = ""
 			} else {
 				params[space[:index]] = space[index+1:]
 			}
@@ -69,78 +172,142 @@ func parseTransportHeader(header string) map[string]string {
 	return params
 }
 
+// parsePortPair server_port=6970-6971 or interleaved=0-1
+func parsePortPair(value string) ([2]int, error) {
+	var pair [2]int
+	split := strings.Split(value, "-")
+	if len(split) != 2 {
+		return pair, fmt.Errorf("invalid format of the port pair:%s", value)
+	}
+
+	for i := 0; i < 2; i++ {
+		port, err := strconv.Atoi(strings.TrimSpace(split[i]))
+		if err != nil {
+			return pair, err
+		}
+		pair[i] = port
+	}
+	return pair, nil
+}
+
 func (p *Puller) OnPacketHandler(_ net.Conn, data []byte) {
 	p.setupLock.Lock()
 	defer p.setupLock.Unlock()
 
-	reader := bufio.NewReader(bytes2.NewReader(data))
-	tp := textproto.NewReader(reader)
-	line, err := tp.ReadLine()
-	split := strings.Split(line, " ")
-	if len(split) < 3 {
-		panic(fmt.Errorf("unknow response line of response:%s", line))
+	p.recvBuffer = append(p.recvBuffer, data...)
+	for len(p.recvBuffer) > 0 {
+		//交织的RTP/RTCP
+		if p.recvBuffer[0] == '$' {
+			channel, payload, n := readInterleavedFrame(p.recvBuffer)
+			if n == 0 {
+				return
+			}
+
+			p.onInterleavedFrame(channel, payload)
+			p.recvBuffer = p.recvBuffer[:copy(p.recvBuffer, p.recvBuffer[n:])]
+			continue
+		}
+
+		response, n, err := readResponse(p.recvBuffer)
+		if err != nil {
+			p.recvBuffer = p.recvBuffer[:0]
+			p.abort(err)
+			return
+		} else if n == 0 {
+			return
+		}
+
+		if err = p.onResponse(response); err != nil {
+			p.abort(err)
+		}
+		p.recvBuffer = p.recvBuffer[:copy(p.recvBuffer, p.recvBuffer[n:])]
 	}
-	protocol := split[0]
-	code := split[1]
-	reason := split[2]
+}
 
-	println(protocol)
-	println(code)
-	println(reason)
+func (p *Puller) onInterleavedFrame(channel int, data []byte) {
+	for _, media := range p.medias {
+		if !media.interleaved {
+			continue
+		} else if channel == media.channels[0] {
+			media.input(data)
+			return
+		} else if channel == media.channels[1] {
+			media.onRTCPPacket(data)
+			return
+		}
+	}
+}
 
-	header, err := tp.ReadMIMEHeader()
+func (p *Puller) onResponse(response *Response) error {
+	cseq, err := strconv.Atoi(strings.TrimSpace(response.Header("CSeq")))
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("invalid CSeq of response:%s", response.Header("CSeq"))
+	}
+	request, ok := p.requests[cseq]
+	if !ok {
+		return nil
 	}
+	delete(p.requests, cseq)
 
-	if p.state == SetupOptions {
-		p.describe("")
-	} else if p.state == SetupDescribe {
-		if code == strconv.Itoa(http.StatusUnauthorized) {
-			if params, err := parseWWWAuthenticateHeader(header.Get("www-Authenticate")); err != nil {
-				panic(err)
-			} else {
-				params["uri"] = p.url
-				params["username"] = p.username
-				credentials, err := generateCredentials(params, p.password)
-				if err != nil {
-					panic(err)
-				}
-				p.describe(credentials)
-			}
+	if response.Code() == http.StatusUnauthorized && p.authParams == nil {
+		params, err := parseWWWAuthenticateHeader(response.Header("WWW-Authenticate"))
+		if err != nil {
+			return err
+		}
+		params["username"] = p.username
+		p.authParams = params
+		return p.request(request.method, request.url, request.header)
+	}
 
-		} else {
-			p.setup(data[len(data)-reader.Buffered():])
-		}
-	} else if p.state == SetupSetup {
-		transport := header.Get("Transport")
-		session := header.Get("Session")
-		//if session == "" {
-		//	panic("session must be contained in the setup response")
-		//}
-		params := parseTransportHeader(transport)
-		if strings.Contains(strings.ToUpper(params["mediaProtocol"]), "TCP") {
-
-		}
-		_ = params["transportProtocol"]
-		_ = params["destination"]
-		source := params["source"]
-		serverPort := params["server_port"]
-		clientPort := params["client_port"]
-		if params["transportProtocol"] != "unicast" || serverPort == "" {
-			p.teardown(session)
-		} else {
-			p.session = session
-			if err := p.play(source, serverPort, clientPort, session); err != nil {
-				println(err)
-				p.teardown(session)
-			}
+	switch request.method {
+	case "OPTIONS":
+		p.getParameter = strings.Contains(response.Header("Public"), "GET_PARAMETER")
+		if p.state == SetupOptions {
+			return p.describe()
+		}
+		break
+	case "DESCRIBE":
+		if response.Code() != http.StatusOK {
+			return fmt.Errorf("describe failed. code:%d", response.Code())
 		}
+		return p.onDescribe(response)
+	case "SETUP":
+		if response.Code() != http.StatusOK {
+			return fmt.Errorf("setup failed. code:%d", response.Code())
+		}
+		return p.onSetup(response)
+	case "PLAY":
+		return p.onPlay(response)
+	case "PAUSE":
+		if response.Code() != http.StatusOK {
+			println(fmt.Sprintf("pause failed. code:%d", response.Code()))
+			p.state = SetupPlay
+		}
+		break
+	case "GET_PARAMETER":
+		if response.Code() == http.StatusMethodNotAllowed || response.Code() == http.StatusNotImplemented {
+			p.getParameter = false
+		}
+		break
+	case "TEARDOWN":
+		p.teardownDone <- struct{}{}
+		break
 	}
+
+	return nil
 }
 
-func (p *Puller) OnDisconnectedHandler(conn net.Conn, err error) {
+// abort 出错后释放会话. 在读协程中调用, 不能阻塞等待TEARDOWN应答
+func (p *Puller) abort(err error) {
+	println(err.Error())
+	if p.session != "" && p.state != SetupTeardown {
+		_ = p.teardown()
+	}
+	go p.release()
+}
 
+func (p *Puller) OnDisconnectedHandler(conn net.Conn, err error) {
+	p.release()
 }
 
 func (p *Puller) Open(url string) error {
@@ -149,6 +316,12 @@ func (p *Puller) Open(url string) error {
 		return err
 	}
 	p.version = "1.0"
+	//rtsp+http://或rtsph://, 使用HTTP隧道
+	tunnel := strings.EqualFold(parse.Scheme, "rtsp+http") || strings.EqualFold(parse.Scheme, "rtsph")
+	if tunnel {
+		url = "rtsp" + url[len(parse.Scheme):]
+		p.interleaved = true
+	}
 	//rtsp 2.0
 	if parse.User != nil {
 		password, b := parse.User.Password()
@@ -165,7 +338,9 @@ func (p *Puller) Open(url string) error {
 	}
 
 	port := parse.Port()
-	if port == "" {
+	if port == "" && tunnel {
+		p.port = DefaultTunnelPort
+	} else if port == "" {
 		p.port = DefaultPort
 	} else {
 		i, err := strconv.Atoi(port)
@@ -176,7 +351,12 @@ func (p *Puller) Open(url string) error {
 	}
 	p.host = parse.Hostname()
 	p.url = url
-	client, err := utils.NewTCPClient(nil, p.host, p.port)
+	var client utils.Transport
+	if tunnel {
+		client, err = NewHTTPTunnel(p.host, p.port, parse.EscapedPath())
+	} else {
+		client, err = utils.NewTCPClient(nil, p.host, p.port)
+	}
 	if err != nil {
 		return err
 	}
@@ -185,172 +365,622 @@ func (p *Puller) Open(url string) error {
 	client.SetOnDisconnectedHandler(p.OnDisconnectedHandler)
 	go client.Read()
 	p.transport = client
+
+	p.setupLock.Lock()
+	defer p.setupLock.Unlock()
+	p.state = SetupOptions
 	return p.options()
 }
 
-func (p *Puller) options() error {
-	p.state = SetupOptions
-	request := Request{
-		method:  "OPTIONS",
-		url:     p.url,
-		version: p.version,
-		header:  make(map[string]string, 2),
+// Close 发送TEARDOWN, 并释放所有媒体端口
+func (p *Puller) Close() error {
+	var err error
+	p.setupLock.Lock()
+	wait := p.session != "" && p.state != SetupTeardown
+	if wait {
+		err = p.teardown()
+		wait = err == nil
+	}
+	p.setupLock.Unlock()
+
+	if wait {
+		select {
+		case <-p.teardownDone:
+			break
+		case <-time.After(TeardownTimeout):
+			break
+		}
 	}
-	request.header["CSeq"] = SetupOptions
-	request.header["User-Agent"] = "avformat/librtsp"
 
-	bytes := request.toBytes(p.buffer)
-	_, err := p.transport.Write(p.buffer[:bytes])
+	p.release()
 	return err
 }
 
-func (p *Puller) describe(authHeader string) error {
-	p.state = SetupDescribe
-	request := Request{
-		method:  "DESCRIBE",
-		url:     p.url,
+func (p *Puller) release() {
+	p.closeOnce.Do(func() {
+		close(p.closed)
+		p.setupLock.Lock()
+		p.mediaLock.Lock()
+		medias := p.medias
+		p.medias = nil
+		p.mediaLock.Unlock()
+		p.setupLock.Unlock()
+
+		for _, media := range medias {
+			media.Close()
+		}
+		if p.transport != nil {
+			p.transport.Close()
+		}
+	})
+}
+
+// request 发送请求. 自动添加CSeq/Session/Authorization
+func (p *Puller) request(method, url string, header map[string]string) error {
+	p.cseq++
+	request := &Request{
+		method:  method,
+		url:     url,
 		version: p.version,
-		header:  make(map[string]string, 2),
+		header:  make(map[string]string, len(header)+4),
 	}
-	request.header["CSeq"] = SetupDescribe
-	request.header["Accept"] = "application/sdp"
-	if authHeader != "" {
-		request.header["Authorization"] = authHeader
+	for k, v := range header {
+		request.header[k] = v
+	}
+	request.header["CSeq"] = strconv.Itoa(p.cseq)
+	request.header["User-Agent"] = "avformat/librtsp"
+	if p.session != "" {
+		request.header["Session"] = p.session
+	}
+	if p.authParams != nil {
+		p.authParams["uri"] = url
+		credentials, err := generateCredentials(method, p.authParams, p.password)
+		if err != nil {
+			return err
+		}
+		request.header["Authorization"] = credentials
 	}
 
+	//重新认证时沿用原始头
+	original := &Request{method: method, url: url, header: header}
+	p.requests[p.cseq] = original
 	bytes := request.toBytes(p.buffer)
 	_, err := p.transport.Write(p.buffer[:bytes])
 	return err
 }
 
-func (p *Puller) setup(body []byte) error {
+func (p *Puller) options() error {
+	return p.request("OPTIONS", p.url, nil)
+}
+
+func (p *Puller) describe() error {
+	p.state = SetupDescribe
+	return p.request("DESCRIBE", p.url, p.requireHeader(map[string]string{"Accept": "application/sdp"}))
+}
+
+// resolveControl 将a=control转换为绝对地址
+func resolveControl(base, control string) string {
+	if control == "" || control == "*" {
+		return base
+	} else if strings.HasPrefix(strings.ToLower(control), "rtsp://") {
+		return control
+	}
+
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	return base + control
+}
+
+func (p *Puller) onDescribe(response *Response) error {
 	var sd sdp.SessionDescription
-	err := sd.Unmarshal(body)
-	if err != nil {
+	if err := sd.Unmarshal(response.Body()); err != nil {
 		return err
 	}
 
-	var tracks []struct {
-		url       string
-		mediaType utils.AVMediaType
+	base := response.Header("Content-Base")
+	if base == "" {
+		base = response.Header("Content-Location")
 	}
+	if base == "" {
+		base = p.url
+	}
+
 	if len(sd.MediaDescriptions) == 0 {
-		tracks = append(tracks, struct {
-			url       string
-			mediaType utils.AVMediaType
-		}{url: p.url, mediaType: utils.AVMediaTypeVideo})
-	} else {
-		for _, description := range sd.MediaDescriptions {
-			for _, attribute := range description.Attributes {
-				if "control" == attribute.Key {
-					var mediaType utils.AVMediaType
-					if "video" == strings.ToLower(description.MediaName.Media) {
-						mediaType = utils.AVMediaTypeVideo
-					} else {
-						mediaType = utils.AVMediaTypeAudio
-					}
-					tracks = append(tracks, struct {
-						url       string
-						mediaType utils.AVMediaType
-					}{url: attribute.Value, mediaType: mediaType})
-					break
-				}
+		p.tracks = append(p.tracks, &Track{MediaType: utils.AVMediaTypeVideo, ClockRate: 90000, control: p.url})
+	}
+
+	for _, description := range sd.MediaDescriptions {
+		control, ok := description.Attribute("control")
+		if !ok {
+			continue
+		}
+
+		track, err := newTrack(description)
+		if err != nil {
+			return err
+		}
+		track.control = resolveControl(base, control)
+		track.destination, track.port = multicastDestination(&sd, description)
+		track.Direction = mediaDirection(&sd, description)
+		p.tracks = append(p.tracks, track)
+	}
+
+	if len(p.tracks) == 0 {
+		return fmt.Errorf("no media in the sdp")
+	}
+
+	if p.onTrackHandler != nil {
+		for i, track := range p.tracks {
+			p.onTrackHandler(i, track)
+		}
+	}
+	return p.setup()
+}
+
+// setup 按顺序建立每个track. 第一个track应答后的Session用于后续请求
+func (p *Puller) setup() error {
+	p.state = SetupSetup
+	track := p.tracks[p.setupIndex]
+	var server *Server
+	var err error
+	if p.interleaved {
+		server = createInterleavedServer(p.transport, p.setupIndex*2)
+	} else if p.multicast && !p.isBackchannel(track) {
+		//应答后再加入组播组
+		server = &Server{multicast: true}
+	} else if server, err = CreateServer(); err != nil {
+		return err
+	}
+
+	server.index = p.setupIndex
+	server.track = track
+	server.mediaType = track.MediaType
+	latency := p.latency
+	if server.interleaved {
+		latency = 0
+	}
+	server.setJitterBuffer(track.ClockRate, latency)
+	if track.ClockRate > 0 {
+		server.senderClock = librtp.NewSenderClock(track.ClockRate)
+		server.lipSync = p.lipSync
+	}
+	p.mediaLock.Lock()
+	p.medias = append(p.medias, server)
+	p.mediaLock.Unlock()
+	if p.onRTCPHandler != nil {
+		server.onRTCPHandler = func(packets []librtcp.Packet) {
+			p.onRTCPHandler(server.index, packets)
+		}
+	}
+
+	if p.isBackchannel(track) {
+		if server.packetizer, err = track.newPacketizer(p.ssrc, func(data []byte, timestamp uint32) {
+			if err := server.WriteRTP(data); err != nil {
+				println(err.Error())
 			}
+		}); err != nil {
+			println(err.Error())
+		}
+	} else if p.onFrameHandler != nil {
+		if server.depacketizer, err = track.newDepacketizer(func(data []byte, timestamp uint32, keyFrame bool) {
+			p.onFrameHandler(server.index, data, server.extendTimestamp(timestamp), keyFrame)
+		}); err != nil {
+			println(err.Error())
 		}
 	}
 
-	for _, track := range tracks {
-		p.state = SetupSetup
-		request := Request{
-			method:  "SETUP",
-			url:     track.url,
-			version: p.version,
-			header:  make(map[string]string, 2),
+	server.dispatch = func(data []byte) {
+		if p.handler != nil {
+			p.handler(server.mediaType, data)
 		}
-		request.header["CSeq"] = SetupSetup
-		request.header["Accept"] = "application/sdp"
+		if server.depacketizer != nil {
+			_ = server.depacketizer.Input(data)
+		}
+	}
 
-		server, err := CreateServer()
+	//SDES的a=crypto为服务器发送使用的密钥. RFC 4568每个方向使用单独的密钥,
+	//本端随机生成发送的密钥, 通过SETUP请求KeyMgmt头(RFC 7826 18.29)中的MIKEY告知服务器
+	profile := "RTP/AVP"
+	header := map[string]string{}
+	if track.crypto != nil {
+		receive, err := librtp.NewSRTPContextWithCrypto(track.crypto)
 		if err != nil {
 			return err
 		}
-		transport := fmt.Sprintf("%s;%s;client_port=%d-%d", "RTP/AVP", "unicast", server.rtp.ListenPort(), server.rtcp.ListenPort())
-		request.header["Transport"] = transport
-		bytes := request.toBytes(p.buffer)
-		if _, err = p.transport.Write(p.buffer[:bytes]); err != nil {
+		local, err := librtp.NewMIKEY(receive.Profile(), p.ssrc)
+		if err != nil {
+			return err
+		}
+		send, err := local.NewSRTPContext()
+		if err != nil {
 			return err
 		}
+		data, err := local.Marshal()
+		if err != nil {
+			return err
+		}
+
+		header["KeyMgmt"] = fmt.Sprintf("prot=mikey; uri=\"%s\"; data=\"%s\"", track.control, base64.StdEncoding.EncodeToString(data))
+		server.EnableSRTP(send, receive)
+		profile = "RTP/SAVP"
+	}
 
-		server.mediaType = track.mediaType
-		p.medias = append(p.medias, server)
+	var transport string
+	if server.interleaved {
+		transport = fmt.Sprintf("%s;%s;interleaved=%d-%d", profile+"/TCP", "unicast", server.channels[0], server.channels[1])
+	} else if server.multicast {
+		transport = fmt.Sprintf("%s;%s", profile, "multicast")
+		if track.destination != "" && track.port > 0 {
+			transport += fmt.Sprintf(";destination=%s;port=%d-%d", track.destination, track.port, track.port+1)
+		}
+	} else {
 		server.rtp.SetOnPacketHandler(func(conn net.Conn, data []byte) {
-			if p.handler != nil {
-				p.handler(server.mediaType, data)
-			}
+			server.input(data)
 		})
-		return err
+		transport = fmt.Sprintf("%s;%s;client_port=%d-%d", profile, "unicast", server.rtp.ListenPort(), server.rtcp.ListenPort())
 	}
-	return nil
+	header["Transport"] = transport
+	return p.request("SETUP", track.control, p.requireHeader(header))
 }
 
-func (p *Puller) play(serverAddr, serverPort, clientPort, session string) error {
-	split := strings.Split(serverPort, "-")
-	if len(split) != 2 {
-		return fmt.Errorf("invalid format of the server port:%s", serverPort)
+func (p *Puller) onSetup(response *Response) error {
+	session, timeout := parseSessionHeader(response.Header("Session"))
+	if session == "" && p.session == "" {
+		return fmt.Errorf("session must be contained in the setup response")
+	} else if session != "" {
+		p.session = session
+	}
+	if timeout > 0 {
+		p.timeout = timeout
+	}
+
+	params := parseTransportHeader(response.Header("Transport"))
+	media := p.medias[p.setupIndex]
+	if params["transportProtocol"] != "unicast" && !(media.multicast && params["transportProtocol"] == "multicast") {
+		return fmt.Errorf("unsupported transport:%s", response.Header("Transport"))
 	}
 
-	var count int
-	for j := 0; j < len(p.medias); j++ {
-		media := p.medias[j]
-		if media.serverPort[0] != 0 {
-			count++
+	if media.multicast {
+		destination, ports, err := p.parseMulticastTransport(params, media.track)
+		if err != nil {
+			return err
 		}
-		if media.clientPort != clientPort {
-			continue
+		if err = media.joinMulticast(p.iface, destination, ports); err != nil {
+			return err
 		}
-		for i := 0; i < 2; i++ {
-			atoi, err := strconv.Atoi(split[i])
+	} else if media.interleaved {
+		//服务器可能修改通道号
+		if value, ok := params["interleaved"]; ok {
+			channels, err := parsePortPair(value)
 			if err != nil {
 				return err
 			}
-			media.serverPort[i] = atoi
+			media.channels = channels
+		}
+	} else {
+		serverPort, ok := params["server_port"]
+		if !ok {
+			return fmt.Errorf("unsupported transport:%s", response.Header("Transport"))
+		}
+
+		var err error
+		if media.serverPort, err = parsePortPair(serverPort); err != nil {
+			return err
 		}
 
-		media.serverAddr = serverAddr
+		media.serverAddr = params["source"]
+		if media.serverAddr == "" {
+			media.serverAddr = p.transport.Conn().RemoteAddr().(*net.TCPAddr).IP.String()
+		}
 		media.traversal()
-		count++
 	}
 
-	if count == len(p.medias) {
-		p.state = SetupPlay
-		request := Request{
-			method:  "PLAY",
-			url:     p.url,
-			version: p.version,
-			header:  make(map[string]string, 2),
+	p.setupIndex++
+	if p.setupIndex < len(p.tracks) {
+		return p.setup()
+	}
+	return p.play()
+}
+
+// parseMulticastTransport RTP/AVP;multicast;destination=232.0.0.1;port=5000-5001;ttl=16
+// 应答未携带destination/port时使用SDP的c=和m=
+func (p *Puller) parseMulticastTransport(params map[string]string, track *Track) (string, [2]int, error) {
+	destination := params["destination"]
+	if destination == "" {
+		destination = track.destination
+	}
+
+	var ports [2]int
+	if value, ok := params["port"]; ok && !strings.Contains(value, "-") {
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			return "", ports, err
 		}
-		request.header["CSeq"] = SetupPlay
-		request.header["Session"] = session
-		bytes := request.toBytes(p.buffer)
-		if _, err := p.transport.Write(p.buffer[:bytes]); err != nil {
-			return err
+		ports = [2]int{port, port + 1}
+	} else if ok {
+		var err error
+		if ports, err = parsePortPair(value); err != nil {
+			return "", ports, err
 		}
+	} else if track.port > 0 {
+		ports = [2]int{track.port, track.port + 1}
+	}
+
+	if destination == "" || ports[0] == 0 {
+		return "", ports, fmt.Errorf("the multicast destination is unknown")
+	}
+	return destination, ports, nil
+}
+
+func (p *Puller) play() error {
+	return p.playWithRange(formatNpt(0))
+}
+
+// playWithRange 发送PLAY. range为空时从当前位置继续
+func (p *Puller) playWithRange(r string) error {
+	header := p.requireHeader(make(map[string]string, 4))
+	if r != "" {
+		header["Range"] = r
+	}
+	if p.scale != 0 {
+		header["Scale"] = strconv.FormatFloat(p.scale, 'f', -1, 64)
+	}
+	if p.speed != 0 {
+		header["Speed"] = strconv.FormatFloat(p.speed, 'f', -1, 64)
+	}
+
+	for _, media := range p.medias {
+		media.waitPlay()
+	}
+	p.state = SetupPlay
+	return p.request("PLAY", p.url, header)
+}
+
+func (p *Puller) onPlay(response *Response) error {
+	if response.Code() != http.StatusOK {
+		if !p.keepAliveStarted {
+			return fmt.Errorf("play failed. code:%d", response.Code())
+		}
+
+		//seek失败, 沿用之前的时间轴
+		println(fmt.Sprintf("play failed. code:%d", response.Code()))
+		for _, media := range p.medias {
+			media.resume()
+		}
+		return nil
+	}
+
+	if value := response.Header("Range"); value != "" {
+		if r, err := parseRange(value); err == nil {
+			p.playRange = r
+		} else {
+			println(err.Error())
+		}
+	}
+
+	p.lipSync.reset()
+	infos := parseRTPInfo(response.Header("RTP-Info"))
+	for i, media := range p.medias {
+		var info *rtpInfo
+		for j := range infos {
+			if matchControl(infos[j].url, media.track.control) {
+				info = &infos[j]
+				break
+			}
+		}
+		if info == nil && len(infos) == len(p.medias) {
+			info = &infos[i]
+		}
+
+		var startPts int64
+		if !p.playRange.IsClock() {
+			startPts = int64(p.playRange.Start.Seconds() * float64(media.track.ClockRate))
+		}
+		media.play(startPts, info)
+	}
+
+	if !p.keepAliveStarted {
+		p.keepAliveStarted = true
+		go p.keepAlive()
 	}
 	return nil
 }
 
-func (p *Puller) teardown(session string) error {
+func (p *Puller) checkPlaying() error {
+	if p.state != SetupPlay && p.state != SetupPause {
+		return fmt.Errorf("the session is not playing")
+	}
+	return nil
+}
+
+// Pause 暂停播放, 会话保持
+func (p *Puller) Pause() error {
+	p.setupLock.Lock()
+	defer p.setupLock.Unlock()
+	if err := p.checkPlaying(); err != nil {
+		return err
+	}
+
+	p.state = SetupPause
+	return p.request("PAUSE", p.url, nil)
+}
+
+// Resume 从暂停的位置继续播放
+func (p *Puller) Resume() error {
+	p.setupLock.Lock()
+	defer p.setupLock.Unlock()
+	if err := p.checkPlaying(); err != nil {
+		return err
+	}
+
+	return p.playWithRange("")
+}
+
+// Seek 跳转到npt时间
+func (p *Puller) Seek(npt time.Duration) error {
+	p.setupLock.Lock()
+	defer p.setupLock.Unlock()
+	if err := p.checkPlaying(); err != nil {
+		return err
+	}
+
+	return p.playWithRange(formatNpt(npt))
+}
+
+// SeekClock 跳转到绝对时间, 用于NVR录像回放
+func (p *Puller) SeekClock(clock time.Time) error {
+	p.setupLock.Lock()
+	defer p.setupLock.Unlock()
+	if err := p.checkPlaying(); err != nil {
+		return err
+	}
+
+	return p.playWithRange(formatClock(clock))
+}
+
+// SetScale 设置播放倍速(Scale头), 负数为倒放. 播放中调用时立即生效
+func (p *Puller) SetScale(scale float64) error {
+	p.setupLock.Lock()
+	defer p.setupLock.Unlock()
+	p.scale = scale
+	if p.checkPlaying() != nil {
+		return nil
+	}
+
+	return p.playWithRange("")
+}
+
+// SetSpeed 设置传输速度(Speed头), 不改变媒体时间. 播放中调用时立即生效
+func (p *Puller) SetSpeed(speed float64) error {
+	p.setupLock.Lock()
+	defer p.setupLock.Unlock()
+	p.speed = speed
+	if p.checkPlaying() != nil {
+		return nil
+	}
+
+	return p.playWithRange("")
+}
+
+// SendRTCP 向服务器发送RTCP反馈, 例如请求关键帧的PLI
+func (p *Puller) SendRTCP(index int, packets ...librtcp.Packet) error {
+	p.setupLock.Lock()
+	if index < 0 || index >= len(p.medias) {
+		p.setupLock.Unlock()
+		return fmt.Errorf("invalid track index %d", index)
+	}
+	media := p.medias[index]
+	p.setupLock.Unlock()
+	return media.SendRTCP(packets...)
+}
+
+// WriteFrame 向反向通道的track发送一帧. G.711为原始采样, AAC为不带ADTS头的帧
+// @pts 单位为track的时钟频率
+func (p *Puller) WriteFrame(index int, data []byte, pts int64) error {
+	p.setupLock.Lock()
+	if p.state != SetupPlay && p.state != SetupPause {
+		p.setupLock.Unlock()
+		return fmt.Errorf("the session is not playing")
+	} else if index < 0 || index >= len(p.medias) || p.medias[index].packetizer == nil {
+		p.setupLock.Unlock()
+		return fmt.Errorf("the track %d is not a backchannel", index)
+	}
+	media := p.medias[index]
+	p.setupLock.Unlock()
+
+	media.lock.Lock()
+	defer media.lock.Unlock()
+	return media.packetizer.Input(data, uint32(pts))
+}
+
+// Position 当前的播放位置, 由第一个track最后输出的帧计算. 可以在OnFrameHandler中调用
+func (p *Puller) Position() time.Duration {
+	p.mediaLock.Lock()
+	if len(p.medias) == 0 || p.medias[0].track.ClockRate <= 0 {
+		p.mediaLock.Unlock()
+		return 0
+	}
+	media := p.medias[0]
+	p.mediaLock.Unlock()
+	return ticksToDuration(media.position(), media.track.ClockRate)
+}
+
+// CaptureTime 帧的采集时间, 由服务器的RTCP SR换算. 收到SR之前返回false. 可以在OnFrameHandler中调用
+// @pts OnFrameHandler回调的pts
+func (p *Puller) CaptureTime(index int, pts int64) (time.Time, bool) {
+	p.mediaLock.Lock()
+	if index < 0 || index >= len(p.medias) {
+		p.mediaLock.Unlock()
+		return time.Time{}, false
+	}
+	media := p.medias[index]
+	p.mediaLock.Unlock()
+	return media.captureTime(pts)
+}
+
+// Clock 当前播放位置对应的绝对时间, 仅在以clock=播放时有效
+func (p *Puller) Clock() time.Time {
+	position := p.Position()
+	p.setupLock.Lock()
+	defer p.setupLock.Unlock()
+	if !p.playRange.IsClock() {
+		return time.Time{}
+	}
+	return p.playRange.Clock.Add(position)
+}
+
+func (p *Puller) teardown() error {
 	p.state = SetupTeardown
-	request := Request{
-		method:  "TEARDOWN",
-		url:     p.url,
-		version: p.version,
-		header:  make(map[string]string, 2),
+	for _, media := range p.medias {
+		if err := media.SendGoodbye(p.ssrc, p.cname, "teardown"); err != nil {
+			println(err.Error())
+		}
 	}
-	request.header["CSeq"] = SetupTeardown
-	request.header["Session"] = session
+	return p.request("TEARDOWN", p.url, nil)
+}
 
-	bytes := request.toBytes(p.buffer)
-	_, err := p.transport.Write(p.buffer[:bytes])
-	return err
+// keepAlive 在会话超时前发送GET_PARAMETER(不支持时发送OPTIONS), 并周期发送RTCP RR. 反向通道发送SR
+func (p *Puller) keepAlive() {
+	timeout := p.timeout
+	if timeout <= 0 {
+		timeout = DefaultSessionTimeout
+	}
+
+	keepAliveTicker := time.NewTicker(time.Duration(timeout) * time.Second / 2)
+	reportTicker := time.NewTicker(ReceiverReportInterval)
+	defer keepAliveTicker.Stop()
+	defer reportTicker.Stop()
+
+	for {
+		select {
+		case <-p.closed:
+			return
+		case <-keepAliveTicker.C:
+			p.setupLock.Lock()
+			var err error
+			if p.state == SetupPlay || p.state == SetupPause {
+				if p.getParameter {
+					err = p.request("GET_PARAMETER", p.url, nil)
+				} else {
+					err = p.options()
+				}
+			}
+			p.setupLock.Unlock()
+			if err != nil {
+				println(err.Error())
+			}
+			break
+		case <-reportTicker.C:
+			p.setupLock.Lock()
+			medias := p.medias
+			p.setupLock.Unlock()
+			for _, media := range medias {
+				if err := media.SendReport(p.ssrc, p.cname); err != nil {
+					println(err.Error())
+				}
+			}
+			break
+		}
+	}
 }
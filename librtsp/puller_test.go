@@ -4,6 +4,7 @@ import (
 	"avformat/utils"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestPuller(t *testing.T) {
@@ -21,3 +22,29 @@ func TestPuller(t *testing.T) {
 	puller.Open(url)
 	select {}
 }
+
+func TestPositionInFrameCallback(t *testing.T) {
+	server := &Server{track: &Track{ClockRate: 90000}}
+	puller := &Puller{medias: []*Server{server}}
+	server.play(90000*10, nil)
+
+	//UDP接收时持有Server.lock回调帧, PLAY/PAUSE处理时持有setupLock
+	positions := make(chan time.Duration, 1)
+	server.dispatch = func(data []byte) {
+		server.extendTimestamp(1000)
+		server.extendTimestamp(1000 + 90000)
+		positions <- puller.Position()
+	}
+	puller.setupLock.Lock()
+	defer puller.setupLock.Unlock()
+	go server.input(make([]byte, 12))
+
+	select {
+	case position := <-positions:
+		if position != 11*time.Second {
+			t.Fatalf("unexpected position %v", position)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Position deadlocks in the frame callback")
+	}
+}
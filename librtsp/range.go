@@ -0,0 +1,151 @@
+package librtsp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const clockLayout = "20060102T150405Z"
+
+// Range RFC 2326 12.29, 支持npt和clock(绝对时间)
+type Range struct {
+	Start time.Duration
+	End   time.Duration //0表示直到结束
+	Clock time.Time     //clock=时的开始时间
+}
+
+func (r Range) IsClock() bool {
+	return !r.Clock.IsZero()
+}
+
+// formatNpt npt=10.500-
+func formatNpt(start time.Duration) string {
+	return fmt.Sprintf("npt=%.3f-", start.Seconds())
+}
+
+// formatClock clock=19961108T142300Z-
+func formatClock(start time.Time) string {
+	return "clock=" + start.UTC().Format(clockLayout) + "-"
+}
+
+// parseNptTime npt-sec 123.45 or npt-hhmmss 12:05:35.3
+func parseNptTime(value string) (time.Duration, error) {
+	if value == "" || value == "now" {
+		return 0, nil
+	}
+
+	var seconds float64
+	for _, field := range strings.Split(value, ":") {
+		f, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid npt time:%s", value)
+		}
+		seconds = seconds*60 + f
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// parseClockTime 19961108T142300.25Z
+func parseClockTime(value string) (time.Time, error) {
+	if index := strings.Index(value, "."); index > 0 {
+		fraction, err := strconv.ParseFloat("0"+strings.TrimSuffix(value[index:], "Z"), 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid clock time:%s", value)
+		}
+		t, err := time.Parse(clockLayout, value[:index]+"Z")
+		return t.Add(time.Duration(fraction * float64(time.Second))), err
+	}
+	return time.Parse(clockLayout, value)
+}
+
+func parseRange(header string) (Range, error) {
+	var r Range
+	//npt=0.000-10.000;time=19970123T143720Z
+	header = strings.TrimSpace(strings.Split(header, ";")[0])
+	index := strings.Index(header, "=")
+	if index < 0 {
+		return r, fmt.Errorf("invalid range:%s", header)
+	}
+
+	unit := strings.ToLower(strings.TrimSpace(header[:index]))
+	split := strings.SplitN(header[index+1:], "-", 2)
+	if len(split) != 2 {
+		return r, fmt.Errorf("invalid range:%s", header)
+	}
+
+	var err error
+	switch unit {
+	case "npt":
+		if r.Start, err = parseNptTime(strings.TrimSpace(split[0])); err == nil {
+			r.End, err = parseNptTime(strings.TrimSpace(split[1]))
+		}
+		break
+	case "clock":
+		if r.Clock, err = parseClockTime(strings.TrimSpace(split[0])); err == nil && strings.TrimSpace(split[1]) != "" {
+			var end time.Time
+			if end, err = parseClockTime(strings.TrimSpace(split[1])); err == nil {
+				r.End = end.Sub(r.Clock)
+			}
+		}
+		break
+	default:
+		err = fmt.Errorf("unsupported range unit:%s", unit)
+	}
+
+	return r, err
+}
+
+// rtpInfo RFC 2326 12.33 RTP-Info: url=rtsp://foo.com/bar.avi/streamid=0;seq=45102;rtptime=12345678,url=...
+type rtpInfo struct {
+	url          string
+	seq          uint16
+	rtptime      uint32
+	seqExist     bool
+	rtptimeExist bool
+}
+
+func parseRTPInfo(header string) []rtpInfo {
+	var infos []rtpInfo
+	for _, stream := range strings.Split(header, ",") {
+		var info rtpInfo
+		for _, param := range strings.Split(stream, ";") {
+			param = strings.TrimSpace(param)
+			index := strings.Index(param, "=")
+			if index < 0 {
+				continue
+			}
+
+			value := param[index+1:]
+			switch strings.ToLower(param[:index]) {
+			case "url":
+				info.url = value
+				break
+			case "seq":
+				if seq, err := strconv.ParseUint(value, 10, 16); err == nil {
+					info.seq = uint16(seq)
+					info.seqExist = true
+				}
+				break
+			case "rtptime":
+				if rtptime, err := strconv.ParseUint(value, 10, 32); err == nil {
+					info.rtptime = uint32(rtptime)
+					info.rtptimeExist = true
+				}
+				break
+			}
+		}
+
+		if info.url != "" {
+			infos = append(infos, info)
+		}
+	}
+
+	return infos
+}
+
+// matchControl RTP-Info中的url可能是绝对地址或相对地址
+func matchControl(url, control string) bool {
+	return url == control || strings.HasSuffix(control, "/"+url) || strings.HasSuffix(url, "/"+control)
+}
@@ -0,0 +1,54 @@
+package librtsp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRange(t *testing.T) {
+	r, err := parseRange("npt=10.5-20")
+	if err != nil || r.Start != 10500*time.Millisecond || r.End != 20*time.Second || r.IsClock() {
+		t.Fatalf("parse npt range failed %v %v", r, err)
+	}
+
+	r, err = parseRange("npt=0:01:05.5-")
+	if err != nil || r.Start != 65500*time.Millisecond || r.End != 0 {
+		t.Fatalf("parse npt hhmmss range failed %v %v", r, err)
+	}
+
+	r, err = parseRange("clock=19961108T142300.5Z-19961108T143000Z")
+	if err != nil || !r.IsClock() || r.End != 6*time.Minute+59500*time.Millisecond {
+		t.Fatalf("parse clock range failed %v %v", r, err)
+	}
+
+	if formatNpt(1500*time.Millisecond) != "npt=1.500-" {
+		t.Fatalf("format npt failed")
+	}
+	if formatClock(time.Date(1996, 11, 8, 14, 23, 0, 0, time.UTC)) != "clock=19961108T142300Z-" {
+		t.Fatalf("format clock failed")
+	}
+
+	if _, err = parseRange("smpte=10:07:00-"); err == nil {
+		t.Fatalf("unsupported unit must fail")
+	}
+}
+
+func TestRTPInfo(t *testing.T) {
+	infos := parseRTPInfo("url=rtsp://foo.com/bar.avi/streamid=0;seq=45102;rtptime=12345678, url=streamid=1;seq=30211")
+	if len(infos) != 2 {
+		t.Fatalf("parse rtp-info failed %v", infos)
+	}
+	if infos[0].seq != 45102 || infos[0].rtptime != 12345678 || !infos[0].rtptimeExist {
+		t.Fatalf("parse rtp-info failed %v", infos[0])
+	}
+	if infos[1].rtptimeExist || !infos[1].seqExist || infos[1].seq != 30211 {
+		t.Fatalf("parse rtp-info failed %v", infos[1])
+	}
+
+	if !matchControl(infos[0].url, "rtsp://foo.com/bar.avi/streamid=0") || !matchControl(infos[1].url, "rtsp://foo.com/bar.avi/streamid=1") {
+		t.Fatalf("match control failed")
+	}
+	if matchControl(infos[1].url, "rtsp://foo.com/bar.avi/streamid=0") {
+		t.Fatalf("match control failed")
+	}
+}
@@ -0,0 +1,98 @@
+package librtsp
+
+import (
+	"bufio"
+	bytes2 "bytes"
+	"fmt"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+type Response struct {
+	//status line
+	version string
+	code    int
+	reason  string
+	header  textproto.MIMEHeader
+	body    []byte
+}
+
+func (r *Response) Code() int {
+	return r.code
+}
+
+func (r *Response) Header(key string) string {
+	return r.header.Get(key)
+}
+
+func (r *Response) Body() []byte {
+	return r.body
+}
+
+// readResponse 解析一个完整的应答. 数据不足时返回0, 等待下次读取
+// @return 消耗的字节数
+func readResponse(data []byte) (*Response, int, error) {
+	end := bytes2.Index(data, []byte("\r\n\r\n"))
+	if end < 0 {
+		return nil, 0, nil
+	}
+	end += 4
+
+	tp := textproto.NewReader(bufio.NewReader(bytes2.NewReader(data[:end])))
+	line, err := tp.ReadLine()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	//RTSP/1.0 200 OK
+	split := strings.SplitN(line, " ", 3)
+	if len(split) < 2 || !strings.HasPrefix(split[0], "RTSP/") {
+		return nil, 0, fmt.Errorf("unknow response line of response:%s", line)
+	}
+
+	response := &Response{version: strings.TrimPrefix(split[0], "RTSP/")}
+	if response.code, err = strconv.Atoi(split[1]); err != nil {
+		return nil, 0, fmt.Errorf("invalid status code:%s", split[1])
+	}
+	if len(split) > 2 {
+		response.reason = split[2]
+	}
+
+	if response.header, err = tp.ReadMIMEHeader(); err != nil {
+		return nil, 0, err
+	}
+
+	var contentLength int
+	if value := response.header.Get("Content-Length"); value != "" {
+		if contentLength, err = strconv.Atoi(strings.TrimSpace(value)); err != nil || contentLength < 0 {
+			return nil, 0, fmt.Errorf("invalid content length:%s", value)
+		}
+	}
+
+	if len(data)-end < contentLength {
+		return nil, 0, nil
+	}
+
+	response.body = data[end : end+contentLength]
+	return response, end + contentLength, nil
+}
+
+// parseSessionHeader Session: 12345678;timeout=60
+// @return session id和超时时间(秒), 未携带timeout返回0
+func parseSessionHeader(header string) (string, int) {
+	split := strings.Split(header, ";")
+	session := strings.TrimSpace(split[0])
+	for _, param := range split[1:] {
+		param = strings.TrimSpace(param)
+		if !strings.HasPrefix(strings.ToLower(param), "timeout=") {
+			continue
+		}
+
+		if timeout, err := strconv.Atoi(param[len("timeout="):]); err == nil && timeout > 0 {
+			return session, timeout
+		}
+	}
+
+	return session, 0
+}
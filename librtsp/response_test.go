@@ -0,0 +1,76 @@
+package librtsp
+
+import (
+	"avformat/librtcp"
+	"avformat/librtp"
+	"testing"
+	"time"
+)
+
+func TestReadResponse(t *testing.T) {
+	data := []byte("RTSP/1.0 200 OK\r\nCSeq: 3\r\nSession: 12345678;timeout=30\r\nContent-Length: 4\r\n\r\nv=0\n")
+	if _, n, err := readResponse(data[:len(data)-2]); err != nil || n != 0 {
+		t.Fatalf("incomplete response must wait for more data. n:%d err:%v", n, err)
+	}
+
+	response, n, err := readResponse(data)
+	if err != nil {
+		t.Fatal(err)
+	} else if n != len(data) {
+		t.Fatalf("consumed %d bytes, expected %d", n, len(data))
+	} else if response.Code() != 200 || string(response.Body()) != "v=0\n" {
+		t.Fatalf("unexpected response %d %q", response.Code(), response.Body())
+	}
+
+	session, timeout := parseSessionHeader(response.Header("Session"))
+	if session != "12345678" || timeout != 30 {
+		t.Fatalf("unexpected session %s timeout %d", session, timeout)
+	}
+}
+
+func TestReceiverReport(t *testing.T) {
+	statistics := librtp.NewReceiverStatistics(90000)
+	now := time.Now()
+	for _, seq := range []uint16{65534, 65535, 1, 2} {
+		statistics.Update(&librtp.NewPacket(0, seq, 0, 1, nil).Header, now)
+	}
+
+	data, err := librtcp.Marshal(reportPackets(0x11223344, "test", &senderStatistics{}, statistics, 90000, now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets, err := librtcp.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr, ok := packets[0].(*librtcp.ReceiverReport)
+	if !ok || len(packets) != 2 || rr.SSRC != 0x11223344 || len(rr.Reports) != 1 {
+		t.Fatalf("invalid receiver report % x", data)
+	}
+	if cname, _ := packets[1].(*librtcp.SourceDescription).CNAME(0x11223344); cname != "test" {
+		t.Fatalf("unexpected cname %s", cname)
+	}
+
+	//1 packet lost, extended highest sequence number 65536+2
+	if block := rr.Reports[0]; block.TotalLost != 1 || block.LastSequenceNumber != 65538 || block.SSRC != 1 {
+		t.Fatalf("unexpected report block %+v", block)
+	}
+}
+
+func TestSenderReport(t *testing.T) {
+	var sender senderStatistics
+	now := time.Now()
+	rtp, _ := librtp.NewPacket(0, 1, 8000, 0x55667788, make([]byte, 160)).Marshal()
+	sender.onRTPPacket(rtp, now)
+	sender.onRTPPacket(rtp, now)
+
+	packets := reportPackets(0x11223344, "test", &sender, nil, 8000, now.Add(time.Second))
+	sr, ok := packets[0].(*librtcp.SenderReport)
+	if !ok || sr.SSRC != 0x55667788 || sr.PacketCount != 2 || sr.OctetCount != 320 {
+		t.Fatalf("unexpected sender report %+v", packets[0])
+	} else if sr.RTPTime != 16000 || len(sr.Reports) != 0 {
+		t.Fatalf("unexpected rtp time %d", sr.RTPTime)
+	} else if diff := librtcp.FromNtpTime(sr.NTPTime).Sub(now.Add(time.Second)); diff < -time.Microsecond || diff > time.Microsecond {
+		t.Fatalf("unexpected ntp time %x", sr.NTPTime)
+	}
+}
@@ -0,0 +1,73 @@
+package librtsp
+
+import (
+	"avformat/librtcp"
+	"avformat/librtp"
+	"time"
+)
+
+const (
+	// ReceiverReportInterval RFC 3550 6.2 建议的最小发送间隔
+	ReceiverReportInterval = 5 * time.Second
+)
+
+// senderStatistics 发送端的SR统计
+type senderStatistics struct {
+	ssrc        uint32
+	started     bool
+	packetCount uint32
+	octetCount  uint32
+	timestamp   uint32    //最后发送的RTP时间戳
+	time        time.Time //最后发送的时间
+}
+
+func (s *senderStatistics) onRTPPacket(data []byte, now time.Time) {
+	var packet librtp.Packet
+	if err := packet.Unmarshal(data); err != nil {
+		return
+	}
+
+	s.started = true
+	s.ssrc = packet.SSRC()
+	s.packetCount++
+	s.octetCount += uint32(len(packet.Payload))
+	s.timestamp = packet.Timestamp()
+	s.time = now
+}
+
+// report 按时钟频率将最后的RTP时间戳推算到当前时间
+func (s *senderStatistics) report(clockRate int, now time.Time) *librtcp.SenderReport {
+	timestamp := s.timestamp
+	if clockRate > 0 {
+		timestamp += uint32(durationToTicks(now.Sub(s.time), clockRate))
+	}
+
+	return &librtcp.SenderReport{
+		SSRC:        s.ssrc,
+		NTPTime:     librtcp.ToNtpTime(now),
+		RTPTime:     timestamp,
+		PacketCount: s.packetCount,
+		OctetCount:  s.octetCount,
+	}
+}
+
+// reportPackets 生成SR或RR+SDES(CNAME). 发送过RTP包时使用SR, SSRC为发送包的SSRC
+// @receiver 没有接收时为nil
+func reportPackets(ssrc uint32, cname string, sender *senderStatistics, receiver *librtp.ReceiverStatistics, clockRate int, now time.Time) []librtcp.Packet {
+	var reports []librtcp.ReceptionReport
+	if receiver != nil && receiver.Initialized() {
+		reports = append(reports, receiver.ReceptionReport(now))
+	}
+
+	var report librtcp.Packet
+	if sender.started {
+		sr := sender.report(clockRate, now)
+		sr.Reports = reports
+		ssrc = sr.SSRC
+		report = sr
+	} else {
+		report = &librtcp.ReceiverReport{SSRC: ssrc, Reports: reports}
+	}
+
+	return []librtcp.Packet{report, librtcp.NewCNAMESourceDescription(ssrc, cname)}
+}
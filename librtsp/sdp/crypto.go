@@ -0,0 +1,163 @@
+package sdp
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CryptoKeyParams represents an inline key-param of an RFC 4568 crypto attribute
+type CryptoKeyParams struct {
+	// Key is the concatenated master key and master salt
+	Key []byte
+	// Lifetime is the optional master key lifetime, e.g. "2^31"
+	Lifetime string
+	// MKI and MKILength are present only when MKILength is greater than 0
+	MKI       uint64
+	MKILength int
+}
+
+func (k CryptoKeyParams) string() string {
+	output := "inline:" + base64.StdEncoding.EncodeToString(k.Key)
+	if k.Lifetime != "" {
+		output += "|" + k.Lifetime
+	}
+	if k.MKILength > 0 {
+		output += fmt.Sprintf("|%d:%d", k.MKI, k.MKILength)
+	}
+	return output
+}
+
+func parseCryptoKeyParams(raw string) (CryptoKeyParams, error) {
+	var params CryptoKeyParams
+	if !strings.HasPrefix(raw, "inline:") {
+		return params, fmt.Errorf("%w: unsupported key method %v", errSyntaxError, raw)
+	}
+
+	parts := strings.Split(raw[len("inline:"):], "|")
+	key, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		// some implementations omit the base64 padding
+		if key, err = base64.RawStdEncoding.DecodeString(parts[0]); err != nil {
+			return params, fmt.Errorf("%w: %v", errSyntaxError, parts[0])
+		}
+	}
+	params.Key = key
+
+	for _, part := range parts[1:] {
+		// MKI:length, otherwise the lifetime
+		if mki := strings.SplitN(part, ":", 2); len(mki) == 2 {
+			value, err := strconv.ParseUint(mki[0], 10, 64)
+			if err != nil {
+				return params, fmt.Errorf("%w: %v", errSyntaxError, part)
+			}
+			length, err := strconv.Atoi(mki[1])
+			if err != nil || length < 1 || length > 128 {
+				return params, fmt.Errorf("%w: %v", errSyntaxError, part)
+			}
+			params.MKI = value
+			params.MKILength = length
+		} else {
+			params.Lifetime = part
+		}
+	}
+	return params, nil
+}
+
+// Crypto represents an RFC 4568 SDES crypto attribute
+// a=crypto:<tag> <crypto-suite> <key-params> [<session-params>]
+type Crypto struct {
+	Tag           int
+	Suite         string
+	KeyParams     []CryptoKeyParams
+	SessionParams []string
+}
+
+// NewCrypto creates a crypto attribute with a single inline key
+func NewCrypto(tag int, suite string, key []byte) Crypto {
+	return Crypto{Tag: tag, Suite: suite, KeyParams: []CryptoKeyParams{{Key: key}}}
+}
+
+// Clone converts this object to an Attribute
+func (c *Crypto) Clone() Attribute {
+	return Attribute{Key: c.Name(), Value: c.string()}
+}
+
+// Unmarshal creates a Crypto from a string
+func (c *Crypto) Unmarshal(raw string) error {
+	parts := strings.SplitN(raw, ":", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("%w: %v", errSyntaxError, raw)
+	}
+
+	fields := strings.Fields(parts[1])
+	if len(fields) < 3 {
+		return fmt.Errorf("%w: %v", errSyntaxError, raw)
+	}
+
+	tag, err := strconv.Atoi(fields[0])
+	if err != nil || tag < 0 || tag > 999999999 {
+		return fmt.Errorf("%w: %v -- crypto tag must be 1-9 digits", errSyntaxError, fields[0])
+	}
+
+	var keyParams []CryptoKeyParams
+	for _, raw := range strings.Split(fields[2], ";") {
+		params, err := parseCryptoKeyParams(raw)
+		if err != nil {
+			return err
+		}
+		keyParams = append(keyParams, params)
+	}
+
+	c.Tag = tag
+	c.Suite = fields[1]
+	c.KeyParams = keyParams
+	c.SessionParams = fields[3:]
+	return nil
+}
+
+// Marshal creates a string from a Crypto
+func (c *Crypto) Marshal() string {
+	return c.Name() + ":" + c.string()
+}
+
+func (c *Crypto) string() string {
+	keyParams := make([]string, 0, len(c.KeyParams))
+	for _, params := range c.KeyParams {
+		keyParams = append(keyParams, params.string())
+	}
+
+	output := fmt.Sprintf("%d %s %s", c.Tag, c.Suite, strings.Join(keyParams, ";"))
+	if len(c.SessionParams) > 0 {
+		output += " " + strings.Join(c.SessionParams, " ")
+	}
+	return output
+}
+
+// Name returns the constant name of this object
+func (c *Crypto) Name() string {
+	return AttrKeyCrypto
+}
+
+// WithCrypto adds an SDES crypto attribute to the media description
+func (d *MediaDescription) WithCrypto(c Crypto) *MediaDescription {
+	return d.WithValueAttribute(c.Name(), c.string())
+}
+
+// Cryptos returns all crypto attributes of the media description in order of preference
+func (d *MediaDescription) Cryptos() ([]Crypto, error) {
+	var cryptos []Crypto
+	for _, attribute := range d.Attributes {
+		if attribute.Key != AttrKeyCrypto {
+			continue
+		}
+
+		var crypto Crypto
+		if err := crypto.Unmarshal(AttrKeyCrypto + ":" + attribute.Value); err != nil {
+			return nil, err
+		}
+		cryptos = append(cryptos, crypto)
+	}
+	return cryptos, nil
+}
@@ -0,0 +1,46 @@
+package sdp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCrypto(t *testing.T) {
+	passingtests := []string{
+		"crypto:1 AES_CM_128_HMAC_SHA1_80 inline:WVNfX19zZW1jdGwgKCkgewkyMjA7fQp9CnVubGVz|2^20|1:4",
+		"crypto:2 AES_CM_128_HMAC_SHA1_32 inline:NzB4d1BINUAvLEw6UzF3WSJ+PSdFcGdUJShpX1Zj|2^20|1:32;inline:QUJjZGVmMTIzNDU2Nzg5QUJDREUwMTIzNDU2Nzg5|2^20|2:32",
+		"crypto:3 AEAD_AES_128_GCM inline:7jU0STlFO1WEGQiOYpSm7KaDe/JZA6UTHKXqZA== KDR=1 UNENCRYPTED_SRTCP",
+	}
+	for i, raw := range passingtests {
+		actual := Crypto{}
+		assert.NoError(t, actual.Unmarshal(raw))
+		assert.Equal(t, raw, actual.Marshal(), "%d", i)
+	}
+
+	crypto := Crypto{}
+	assert.NoError(t, crypto.Unmarshal(passingtests[1]))
+	assert.Equal(t, 2, crypto.Tag)
+	assert.Equal(t, "AES_CM_128_HMAC_SHA1_32", crypto.Suite)
+	assert.Len(t, crypto.KeyParams, 2)
+	assert.Len(t, crypto.KeyParams[1].Key, 30)
+	assert.Equal(t, uint64(2), crypto.KeyParams[1].MKI)
+	assert.Equal(t, 32, crypto.KeyParams[1].MKILength)
+	assert.Equal(t, "2^20", crypto.KeyParams[1].Lifetime)
+
+	failingtests := []string{
+		"crypto:1 AES_CM_128_HMAC_SHA1_80",
+		"crypto:a AES_CM_128_HMAC_SHA1_80 inline:WVNfX19zZW1jdGwgKCkgewkyMjA7fQp9CnVubGVz",
+		"crypto:1 AES_CM_128_HMAC_SHA1_80 uri:https://example.com/key",
+		"crypto:1 AES_CM_128_HMAC_SHA1_80 inline:!!!",
+	}
+	for _, raw := range failingtests {
+		actual := Crypto{}
+		assert.Error(t, actual.Unmarshal(raw), raw)
+	}
+
+	md := (&MediaDescription{}).WithCrypto(NewCrypto(1, "AES_CM_128_HMAC_SHA1_80", make([]byte, 30)))
+	cryptos, err := md.Cryptos()
+	assert.NoError(t, err)
+	assert.Equal(t, []Crypto{{Tag: 1, Suite: "AES_CM_128_HMAC_SHA1_80", KeyParams: []CryptoKeyParams{{Key: make([]byte, 30)}}, SessionParams: []string{}}}, cryptos)
+}
@@ -14,11 +14,13 @@ const (
 	DefExtMapValueSDESMid         = 3
 	DefExtMapValueSDESRTPStreamID = 4
 
-	ABSSendTimeURI     = "http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time"
-	TransportCCURI     = "http://www.ietf.org/id/draft-holmer-rmcat-transport-wide-cc-extensions-01"
-	SDESMidURI         = "urn:ietf:params:rtp-hdrext:sdes:mid"
-	SDESRTPStreamIDURI = "urn:ietf:params:rtp-hdrext:sdes:rtp-stream-id"
-	AudioLevelURI      = "urn:ietf:params:rtp-hdrext:ssrc-audio-level"
+	ABSSendTimeURI      = "http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time"
+	TransportCCURI      = "http://www.ietf.org/id/draft-holmer-rmcat-transport-wide-cc-extensions-01"
+	SDESMidURI          = "urn:ietf:params:rtp-hdrext:sdes:mid"
+	SDESRTPStreamIDURI  = "urn:ietf:params:rtp-hdrext:sdes:rtp-stream-id"
+	AudioLevelURI       = "urn:ietf:params:rtp-hdrext:ssrc-audio-level"
+	TransportCC02URI    = "http://www.webrtc.org/experiments/rtp-hdrext/transport-wide-cc-02"
+	VideoOrientationURI = "urn:3gpp:video-orientation"
 )
 
 // ExtMap represents the activation of a single RTP header extension
@@ -0,0 +1,127 @@
+package sdp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Group represents a grouping of media lines by their mid, e.g. BUNDLE or LS
+// https://tools.ietf.org/html/rfc5888#section-5
+//
+// a=group:<semantics> <mid> ...
+type Group struct {
+	Semantics string
+	MIDs      []string
+}
+
+// Clone converts this object to an Attribute
+func (g *Group) Clone() Attribute {
+	return Attribute{Key: AttrKeyGroup, Value: g.string()}
+}
+
+// Unmarshal creates a Group from a string
+func (g *Group) Unmarshal(raw string) error {
+	value, err := attributeValue(raw, AttrKeyGroup)
+	if err != nil {
+		return err
+	}
+
+	fields := strings.Fields(value)
+	if len(fields) < 1 {
+		return fmt.Errorf("%w: %v", errSyntaxError, raw)
+	}
+
+	g.Semantics = fields[0]
+	g.MIDs = fields[1:]
+	return nil
+}
+
+// Marshal creates a string from a Group
+func (g *Group) Marshal() string {
+	return g.Name() + ":" + g.string()
+}
+
+func (g *Group) string() string {
+	return strings.Join(append([]string{g.Semantics}, g.MIDs...), " ")
+}
+
+// Name returns the constant name of this object
+func (g *Group) Name() string {
+	return AttrKeyGroup
+}
+
+// Contains returns true if the mid is in the group
+func (g *Group) Contains(mid string) bool {
+	for _, m := range g.MIDs {
+		if m == mid {
+			return true
+		}
+	}
+	return false
+}
+
+// Tag returns the first mid, which is the BUNDLE-tag of a BUNDLE group
+// https://tools.ietf.org/html/rfc8843#section-7.2.1
+func (g *Group) Tag() (string, bool) {
+	if len(g.MIDs) == 0 {
+		return "", false
+	}
+	return g.MIDs[0], true
+}
+
+// Groups returns all group attributes of the session description
+func (s *SessionDescription) Groups() ([]Group, error) {
+	var groups []Group
+	for _, a := range s.Attributes {
+		if a.Key != AttrKeyGroup {
+			continue
+		}
+
+		var group Group
+		if err := group.Unmarshal(a.String()); err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// BundleGroup returns the BUNDLE group which contains the mid and if it exists
+func (s *SessionDescription) BundleGroup(mid string) (*Group, bool) {
+	groups, err := s.Groups()
+	if err != nil {
+		return nil, false
+	}
+	for i := range groups {
+		if groups[i].Semantics == SemanticTokenBundle && groups[i].Contains(mid) {
+			return &groups[i], true
+		}
+	}
+	return nil, false
+}
+
+// MediaDescriptionByMID returns the media description with the mid and if it exists
+func (s *SessionDescription) MediaDescriptionByMID(mid string) (*MediaDescription, bool) {
+	for _, d := range s.MediaDescriptions {
+		if m, ok := d.MID(); ok && m == mid {
+			return d, true
+		}
+	}
+	return nil, false
+}
+
+// WithGroup adds a group attribute to the session description
+func (s *SessionDescription) WithGroup(g Group) *SessionDescription {
+	s.Attributes = append(s.Attributes, g.Clone())
+	return s
+}
+
+// MID returns the mid attribute and if it exists
+func (d *MediaDescription) MID() (string, bool) {
+	return d.Attribute(AttrKeyMID)
+}
+
+// WithMID adds a mid attribute to the media description
+func (d *MediaDescription) WithMID(mid string) *MediaDescription {
+	return d.WithValueAttribute(AttrKeyMID, mid)
+}
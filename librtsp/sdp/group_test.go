@@ -0,0 +1,48 @@
+package sdp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroup(t *testing.T) {
+	g := Group{}
+	assert.NoError(t, g.Unmarshal("group:BUNDLE 0 1"))
+	assert.Equal(t, Group{Semantics: SemanticTokenBundle, MIDs: []string{"0", "1"}}, g)
+	assert.Equal(t, "group:BUNDLE 0 1", g.Marshal())
+	assert.True(t, g.Contains("1"))
+	assert.False(t, g.Contains("2"))
+
+	tag, ok := g.Tag()
+	assert.True(t, ok)
+	assert.Equal(t, "0", tag)
+
+	assert.Error(t, g.Unmarshal("group:"))
+	assert.Error(t, g.Unmarshal("mid:0"))
+}
+
+func TestSessionDescriptionGroups(t *testing.T) {
+	s := &SessionDescription{}
+	s.WithGroup(Group{Semantics: SemanticTokenLipSynchronization, MIDs: []string{"a", "v"}}).
+		WithGroup(Group{Semantics: SemanticTokenBundle, MIDs: []string{"v", "a"}}).
+		WithMedia((&MediaDescription{MediaName: MediaName{Media: "audio"}}).WithMID("a")).
+		WithMedia((&MediaDescription{MediaName: MediaName{Media: "video"}}).WithMID("v"))
+
+	groups, err := s.Groups()
+	assert.NoError(t, err)
+	assert.Len(t, groups, 2)
+
+	bundle, ok := s.BundleGroup("a")
+	assert.True(t, ok)
+	tag, _ := bundle.Tag()
+	assert.Equal(t, "v", tag)
+	_, ok = s.BundleGroup("x")
+	assert.False(t, ok)
+
+	d, ok := s.MediaDescriptionByMID(tag)
+	assert.True(t, ok)
+	assert.Equal(t, "video", d.MediaName.Media)
+	mid, _ := d.MID()
+	assert.Equal(t, "v", mid)
+}
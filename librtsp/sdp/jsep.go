@@ -28,6 +28,10 @@ const (
 	AttrKeySendRecv         = "sendrecv"
 	AttrKeyExtMap           = "extmap"
 	AttrKeyExtMapAllowMixed = "extmap-allow-mixed"
+	AttrKeyBundleOnly       = "bundle-only"
+	AttrKeyRID              = "rid"
+	AttrKeySimulcast        = "simulcast"
+	AttrKeyCrypto           = "crypto"
 )
 
 // Constants for semantic tokens used in JSEP
@@ -36,6 +40,9 @@ const (
 	SemanticTokenFlowIdentification     = "FID"
 	SemanticTokenForwardErrorCorrection = "FEC"
 	SemanticTokenWebRTCMediaStreams     = "WMS"
+	SemanticTokenBundle                 = "BUNDLE"
+	SemanticTokenSimulcast              = "SIM"
+	SemanticTokenFECFramework           = "FEC-FR"
 )
 
 // Constants for extmap key
@@ -175,6 +182,21 @@ func (d *MediaDescription) WithCodec(payloadType uint8, name string, clockrate u
 	return d
 }
 
+// WithRTXCodec adds an RFC 4588 retransmission payload type associated with apt.
+// rtx-time is omitted when rtxTime is 0
+func (d *MediaDescription) WithRTXCodec(payloadType, apt uint8, clockrate, rtxTime uint32) *MediaDescription {
+	fmtp := fmt.Sprintf("apt=%d", apt)
+	if rtxTime > 0 {
+		fmtp += fmt.Sprintf(";rtx-time=%d", rtxTime)
+	}
+	return d.WithCodec(payloadType, "rtx", clockrate, 0, fmtp)
+}
+
+// WithRTCPFeedback adds an rtcp-fb attribute, e.g. "nack" or "nack pli"
+func (d *MediaDescription) WithRTCPFeedback(payloadType uint8, feedback string) *MediaDescription {
+	return d.WithValueAttribute("rtcp-fb", fmt.Sprintf("%d %s", payloadType, feedback))
+}
+
 // WithMediaSource adds media source information to the media description
 func (d *MediaDescription) WithMediaSource(ssrc uint32, cname, streamLabel, label string) *MediaDescription {
 	return d.
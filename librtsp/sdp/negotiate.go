@@ -0,0 +1,535 @@
+package sdp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var errNilOffer = errors.New("offer is nil")
+
+// MediaCapability describes what the local endpoint supports for one media type
+type MediaCapability struct {
+	// Media is the media type of the m= line, e.g. "audio" or "video"
+	Media string
+
+	// Direction is the local direction, the zero value means sendrecv
+	Direction Direction
+
+	// Codecs are in order of preference. PayloadType is ignored since the answer
+	// reuses the payload types of the offer. RTCPFeedback lists the supported
+	// feedback types, a Codec named "rtx" enables RFC 4588 retransmission
+	Codecs []Codec
+
+	// ExtMaps are the URIs of the supported RTP header extensions
+	ExtMaps []string
+}
+
+// staticCodecs are the RFC 3551 payload types that may be offered without rtpmap
+var staticCodecs = map[uint8]Codec{
+	0:  {Name: "PCMU", ClockRate: 8000},
+	3:  {Name: "GSM", ClockRate: 8000},
+	4:  {Name: "G723", ClockRate: 8000},
+	8:  {Name: "PCMA", ClockRate: 8000},
+	9:  {Name: "G722", ClockRate: 8000},
+	10: {Name: "L16", ClockRate: 44100, EncodingParameters: "2"},
+	11: {Name: "L16", ClockRate: 44100},
+	14: {Name: "MPA", ClockRate: 90000},
+	18: {Name: "G729", ClockRate: 8000},
+	26: {Name: "JPEG", ClockRate: 90000},
+	32: {Name: "MPV", ClockRate: 90000},
+	33: {Name: "MP2T", ClockRate: 90000},
+}
+
+// fmtpIdentifiers are the fmtp parameters which identify a payload format rather
+// than a receiver preference, with their default values. Parameters without a
+// default value are only compared when both sides specify them
+var fmtpIdentifiers = map[string]map[string]string{
+	"VP9":           {"profile-id": "0"},
+	"AV1":           {"profile": "0"},
+	"H265":          {"profile-id": "1"},
+	"MPEG4-GENERIC": {"mode": "", "sizelength": "", "indexlength": "", "indexdeltalength": "", "config": ""},
+	"MP4A-LATM":     {"cpresent": "1", "config": ""},
+}
+
+// Codecs returns the codecs of the media description in the order of the m= line.
+// Static payload types without rtpmap are resolved by RFC 3551, and the
+// feedbacks of rtcp-fb:* are added to every codec
+func (d *MediaDescription) Codecs() []Codec {
+	codecs := make(map[uint8]Codec)
+	var wildcard []string
+	for _, a := range d.Attributes {
+		attr := a.String()
+		var codec Codec
+		var err error
+		switch {
+		case strings.HasPrefix(attr, "rtpmap:"):
+			codec, err = parseRtpmap(attr)
+		case strings.HasPrefix(attr, "fmtp:"):
+			codec, err = parseFmtp(attr)
+		case strings.HasPrefix(attr, "rtcp-fb:* "):
+			wildcard = append(wildcard, strings.TrimPrefix(attr, "rtcp-fb:* "))
+			continue
+		case strings.HasPrefix(attr, "rtcp-fb:"):
+			codec, err = parseRtcpFb(attr)
+		default:
+			continue
+		}
+		if err == nil {
+			mergeCodecs(codec, codecs)
+		}
+	}
+
+	result := make([]Codec, 0, len(d.MediaName.Formats))
+	for _, format := range d.MediaName.Formats {
+		payloadType, err := strconv.ParseUint(format, 10, 8)
+		if err != nil {
+			continue
+		}
+
+		codec := codecs[uint8(payloadType)]
+		if static, ok := staticCodecs[uint8(payloadType)]; ok && codec.Name == "" {
+			codec.Name, codec.ClockRate, codec.EncodingParameters = static.Name, static.ClockRate, static.EncodingParameters
+		}
+		if codec.Name == "" {
+			continue
+		}
+
+		codec.PayloadType = uint8(payloadType)
+		codec.RTCPFeedback = append(append([]string(nil), wildcard...), codec.RTCPFeedback...)
+		result = append(result, codec)
+	}
+	return result
+}
+
+// directionOf returns the direction attribute, or 0 if there is none
+func directionOf(attributes []Attribute) Direction {
+	for _, a := range attributes {
+		if direction, err := NewDirection(a.Key); err == nil {
+			return direction
+		}
+	}
+	return Direction(unknown)
+}
+
+func (t Direction) sends() bool {
+	return t == DirectionSendRecv || t == DirectionSendOnly
+}
+
+func (t Direction) receives() bool {
+	return t == DirectionSendRecv || t == DirectionRecvOnly
+}
+
+// answerDirection https://tools.ietf.org/html/rfc3264#section-6.1
+func answerDirection(offer, local Direction) Direction {
+	send := local.sends() && offer.receives()
+	recv := local.receives() && offer.sends()
+	switch {
+	case send && recv:
+		return DirectionSendRecv
+	case send:
+		return DirectionSendOnly
+	case recv:
+		return DirectionRecvOnly
+	default:
+		return DirectionInactive
+	}
+}
+
+// reverse swaps sendonly and recvonly, used for the direction of extmaps
+func (t Direction) reverse() Direction {
+	switch t {
+	case DirectionSendOnly:
+		return DirectionRecvOnly
+	case DirectionRecvOnly:
+		return DirectionSendOnly
+	default:
+		return t
+	}
+}
+
+func parseFmtpParameters(fmtp string) map[string]string {
+	params := make(map[string]string)
+	for _, param := range strings.Split(fmtp, ";") {
+		split := strings.SplitN(strings.TrimSpace(param), "=", 2)
+		if len(split) == 2 {
+			params[strings.ToLower(split[0])] = split[1]
+		}
+	}
+	return params
+}
+
+// setFmtpParameter replaces or appends key=value, keeping the order of the other parameters
+func setFmtpParameter(fmtp, key, value string) string {
+	var params []string
+	found := false
+	for _, param := range strings.Split(fmtp, ";") {
+		if param = strings.TrimSpace(param); param == "" {
+			continue
+		}
+		if split := strings.SplitN(param, "=", 2); strings.EqualFold(split[0], key) {
+			param = key + "=" + value
+			found = true
+		}
+		params = append(params, param)
+	}
+	if !found {
+		params = append(params, key+"="+value)
+	}
+	return strings.Join(params, ";")
+}
+
+func fmtpValue(params map[string]string, key, def string) string {
+	if value, ok := params[key]; ok {
+		return value
+	}
+	return def
+}
+
+// h264Profile https://tools.ietf.org/html/rfc6184#section-8.1
+type h264Profile int
+
+const (
+	h264ProfileConstrainedBaseline h264Profile = iota + 1
+	h264ProfileBaseline
+	h264ProfileMain
+	h264ProfileConstrainedHigh
+	h264ProfileHigh
+	h264ProfilePredictiveHigh444
+)
+
+// h264DefaultProfileLevelId is used when profile-level-id is absent, Baseline level 1
+const h264DefaultProfileLevelId = "42000a"
+
+// h264ProfilePatterns match profile_idc and the profile-iop constraint flags
+var h264ProfilePatterns = []struct {
+	profile h264Profile
+	idc     byte
+	mask    byte
+	value   byte
+}{
+	{h264ProfileConstrainedBaseline, 0x42, 0x4F, 0x40},
+	{h264ProfileConstrainedBaseline, 0x4D, 0x8F, 0x80},
+	{h264ProfileConstrainedBaseline, 0x58, 0xCF, 0xC0},
+	{h264ProfileBaseline, 0x42, 0x4F, 0x00},
+	{h264ProfileBaseline, 0x58, 0xCF, 0x80},
+	{h264ProfileMain, 0x4D, 0xAF, 0x00},
+	{h264ProfileHigh, 0x64, 0xFF, 0x00},
+	{h264ProfileConstrainedHigh, 0x64, 0xFF, 0x0C},
+	{h264ProfilePredictiveHigh444, 0xF4, 0xFF, 0x00},
+}
+
+// h264ProfileLevelID is the profile_idc, profile-iop and level_idc of profile-level-id
+type h264ProfileLevelID [3]byte
+
+func parseH264ProfileLevelID(value string) (h264ProfileLevelID, error) {
+	var id h264ProfileLevelID
+	if len(value) != 6 {
+		return id, fmt.Errorf("%w: profile-level-id %v", errSyntaxError, value)
+	}
+	for i := range id {
+		b, err := strconv.ParseUint(value[i*2:i*2+2], 16, 8)
+		if err != nil {
+			return id, fmt.Errorf("%w: profile-level-id %v", errSyntaxError, value)
+		}
+		id[i] = byte(b)
+	}
+	return id, nil
+}
+
+func (id h264ProfileLevelID) profile() h264Profile {
+	for _, pattern := range h264ProfilePatterns {
+		if id[0] == pattern.idc && id[1]&pattern.mask == pattern.value {
+			return pattern.profile
+		}
+	}
+	return h264Profile(unknown)
+}
+
+// sameProfile compares the profiles, unknown profiles must have the same profile_idc and profile-iop
+func (id h264ProfileLevelID) sameProfile(other h264ProfileLevelID) bool {
+	if profile := id.profile(); profile != h264Profile(unknown) {
+		return profile == other.profile()
+	}
+	return id[0] == other[0] && id[1] == other[1]
+}
+
+// negotiateH264 https://tools.ietf.org/html/rfc6184#section-8.2.2
+// The profile and packetization-mode must be the same. The answer level is the
+// lower one, or the local level when both sides allow level asymmetry
+func negotiateH264(offered, local string) (string, bool) {
+	offerParams, localParams := parseFmtpParameters(offered), parseFmtpParameters(local)
+	if fmtpValue(offerParams, "packetization-mode", "0") != fmtpValue(localParams, "packetization-mode", "0") {
+		return "", false
+	}
+
+	offerID, err := parseH264ProfileLevelID(fmtpValue(offerParams, "profile-level-id", h264DefaultProfileLevelId))
+	if err != nil {
+		return "", false
+	}
+	localID, err := parseH264ProfileLevelID(fmtpValue(localParams, "profile-level-id", h264DefaultProfileLevelId))
+	if err != nil || !offerID.sameProfile(localID) {
+		return "", false
+	}
+
+	level := localID[2]
+	asymmetry := offerParams["level-asymmetry-allowed"] == "1" && localParams["level-asymmetry-allowed"] == "1"
+	if !asymmetry && offerID[2] < level {
+		level = offerID[2]
+	}
+	return setFmtpParameter(local, "profile-level-id", fmt.Sprintf("%02x%02x%02x", offerID[0], offerID[1], level)), true
+}
+
+func channelsOf(codec Codec) string {
+	if codec.EncodingParameters == "" {
+		return "1"
+	}
+	return codec.EncodingParameters
+}
+
+// negotiateCodec matches the rtpmap and the identifying fmtp parameters
+// and returns the fmtp of the answer
+func negotiateCodec(offered, local Codec) (string, bool) {
+	if !strings.EqualFold(offered.Name, local.Name) || offered.ClockRate != local.ClockRate || channelsOf(offered) != channelsOf(local) {
+		return "", false
+	}
+
+	name := strings.ToUpper(offered.Name)
+	if name == "H264" {
+		return negotiateH264(offered.Fmtp, local.Fmtp)
+	}
+
+	offerParams, localParams := parseFmtpParameters(offered.Fmtp), parseFmtpParameters(local.Fmtp)
+	for key, def := range fmtpIdentifiers[name] {
+		offerValue, offerOK := offerParams[key]
+		if !offerOK && def != "" {
+			offerValue, offerOK = def, true
+		}
+		localValue, localOK := localParams[key]
+		if !localOK && def != "" {
+			localValue, localOK = def, true
+		}
+		if offerOK && localOK && !strings.EqualFold(offerValue, localValue) {
+			return "", false
+		}
+	}
+
+	if local.Fmtp == "" {
+		return offered.Fmtp, true
+	}
+	return local.Fmtp, true
+}
+
+func intersectFeedbacks(offered, local []string) []string {
+	var feedbacks []string
+	for _, feedback := range local {
+		for _, f := range offered {
+			if f == feedback {
+				feedbacks = append(feedbacks, feedback)
+				break
+			}
+		}
+	}
+	return feedbacks
+}
+
+// negotiateCodecs returns the offered codecs supported locally, in the order of
+// the local preference. RTX codecs follow and are kept only if apt was negotiated
+func negotiateCodecs(offered, local []Codec) []Codec {
+	var answer []Codec
+	negotiated := make(map[string]bool)
+	for _, l := range local {
+		if strings.EqualFold(l.Name, "rtx") {
+			continue
+		}
+
+		for _, o := range offered {
+			if negotiated[strconv.Itoa(int(o.PayloadType))] {
+				continue
+			}
+			fmtp, ok := negotiateCodec(o, l)
+			if !ok {
+				continue
+			}
+
+			negotiated[strconv.Itoa(int(o.PayloadType))] = true
+			answer = append(answer, Codec{
+				PayloadType:        o.PayloadType,
+				Name:               o.Name,
+				ClockRate:          o.ClockRate,
+				EncodingParameters: o.EncodingParameters,
+				Fmtp:               fmtp,
+				RTCPFeedback:       intersectFeedbacks(o.RTCPFeedback, l.RTCPFeedback),
+			})
+		}
+	}
+
+	for _, l := range local {
+		if !strings.EqualFold(l.Name, "rtx") {
+			continue
+		}
+
+		for _, o := range offered {
+			if strings.EqualFold(o.Name, "rtx") && o.ClockRate == l.ClockRate && negotiated[parseFmtpParameters(o.Fmtp)["apt"]] {
+				answer = append(answer, Codec{PayloadType: o.PayloadType, Name: o.Name, ClockRate: o.ClockRate, Fmtp: o.Fmtp})
+			}
+		}
+		break
+	}
+	return answer
+}
+
+// rejectMedia https://tools.ietf.org/html/rfc3264#section-6
+// A rejected stream has port 0 and at least one media format
+func rejectMedia(offered *MediaDescription) *MediaDescription {
+	formats := offered.MediaName.Formats
+	if len(formats) > 1 {
+		formats = formats[:1]
+	}
+
+	d := &MediaDescription{
+		MediaName: MediaName{
+			Media:   offered.MediaName.Media,
+			Port:    RangedPort{Value: 0},
+			Protos:  append([]string(nil), offered.MediaName.Protos...),
+			Formats: append([]string(nil), formats...),
+		},
+	}
+	if mid, ok := offered.MID(); ok {
+		d.WithMID(mid)
+	}
+	return d
+}
+
+// answerMedia returns nil if no codec could be negotiated
+func answerMedia(offer *SessionDescription, offered *MediaDescription, capability *MediaCapability) *MediaDescription {
+	codecs := negotiateCodecs(offered.Codecs(), capability.Codecs)
+	if len(codecs) == 0 {
+		return nil
+	}
+
+	d := NewJSEPMediaDescription(offered.MediaName.Media, nil)
+	d.MediaName.Protos = append([]string(nil), offered.MediaName.Protos...)
+	if mid, ok := offered.MID(); ok {
+		d.WithMID(mid)
+	}
+
+	for _, codec := range codecs {
+		channels, _ := strconv.ParseUint(codec.EncodingParameters, 10, 16)
+		d.WithCodec(codec.PayloadType, codec.Name, codec.ClockRate, uint16(channels), codec.Fmtp)
+	}
+	for _, codec := range codecs {
+		for _, feedback := range codec.RTCPFeedback {
+			d.WithRTCPFeedback(codec.PayloadType, feedback)
+		}
+	}
+
+	// extmaps may also be declared at the session level
+	for _, attributes := range [][]Attribute{offer.Attributes, offered.Attributes} {
+		for _, a := range attributes {
+			if a.Key != AttrKeyExtMap {
+				continue
+			}
+
+			var e ExtMap
+			if err := e.Unmarshal(a.String()); err != nil {
+				continue
+			}
+			for _, uri := range capability.ExtMaps {
+				if uri == e.URI.String() {
+					answer := ExtMap{Value: e.Value, Direction: e.Direction.reverse(), URI: e.URI}
+					d.Attributes = append(d.Attributes, answer.Clone())
+					break
+				}
+			}
+		}
+	}
+
+	for _, key := range []string{AttrKeyRTCPMux, AttrKeyRTCPRsize} {
+		if _, ok := offered.Attribute(key); ok {
+			d.WithPropertyAttribute(key)
+		}
+	}
+
+	offerDirection := directionOf(offered.Attributes)
+	if offerDirection == Direction(unknown) {
+		offerDirection = directionOf(offer.Attributes)
+	}
+	if offerDirection == Direction(unknown) {
+		offerDirection = DirectionSendRecv
+	}
+	localDirection := capability.Direction
+	if localDirection == Direction(unknown) {
+		localDirection = DirectionSendRecv
+	}
+	return d.WithPropertyAttribute(answerDirection(offerDirection, localDirection).String())
+}
+
+// NewJSEPAnswer creates an answer to the remote offer following RFC 3264.
+//
+// Every offered m= line gets an m= line in the answer. It is rejected with port 0
+// when the offer rejected it, when there is no capability for its media type or
+// when no codec could be negotiated. Accepted m= lines reuse the payload types of
+// the offer, mirror the direction, and keep the supported extmaps and rtcp-fb.
+// Rejected mids are removed from BUNDLE and other groups.
+//
+// ICE credentials, fingerprints and candidates are not added, use the With*
+// methods of the returned SessionDescription.
+func NewJSEPAnswer(offer *SessionDescription, capabilities []MediaCapability) (*SessionDescription, error) {
+	if offer == nil {
+		return nil, errNilOffer
+	}
+
+	answer, err := NewJSEPSessionDescription(false)
+	if err != nil {
+		return nil, err
+	}
+
+	accepted := make(map[string]bool)
+	for _, offered := range offer.MediaDescriptions {
+		var capability *MediaCapability
+		for i := range capabilities {
+			if capabilities[i].Media == offered.MediaName.Media {
+				capability = &capabilities[i]
+				break
+			}
+		}
+
+		// bundle-only m= lines have port 0 but are not rejected
+		_, bundleOnly := offered.Attribute(AttrKeyBundleOnly)
+		var d *MediaDescription
+		if capability != nil && (offered.MediaName.Port.Value != 0 || bundleOnly) {
+			d = answerMedia(offer, offered, capability)
+		}
+
+		if d == nil {
+			d = rejectMedia(offered)
+		} else if mid, ok := offered.MID(); ok {
+			accepted[mid] = true
+		}
+		answer.WithMedia(d)
+	}
+
+	for _, a := range offer.Attributes {
+		if a.Key != AttrKeyGroup {
+			continue
+		}
+
+		// malformed groups are skipped rather than failing the answer
+		var group Group
+		if err := group.Unmarshal(a.String()); err != nil {
+			continue
+		}
+		answerGroup := Group{Semantics: group.Semantics}
+		for _, mid := range group.MIDs {
+			if accepted[mid] {
+				answerGroup.MIDs = append(answerGroup.MIDs, mid)
+			}
+		}
+		if len(answerGroup.MIDs) > 0 {
+			answer.WithGroup(answerGroup)
+		}
+	}
+	return answer, nil
+}
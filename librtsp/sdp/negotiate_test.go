@@ -0,0 +1,160 @@
+package sdp
+
+import (
+	"reflect"
+	"testing"
+)
+
+const negotiateOffer = "v=0\r\n" +
+	"o=- 4215775240449105457 2 IN IP4 127.0.0.1\r\n" +
+	"s=-\r\n" +
+	"t=0 0\r\n" +
+	"a=group:BUNDLE 0 1 2\r\n" +
+	"a=group:\r\n" +
+	"a=extmap:3 urn:ietf:params:rtp-hdrext:sdes:mid\r\n" +
+	"m=audio 9 UDP/TLS/RTP/SAVPF 111 0\r\n" +
+	"c=IN IP4 0.0.0.0\r\n" +
+	"a=mid:0\r\n" +
+	"a=extmap:1 urn:ietf:params:rtp-hdrext:ssrc-audio-level\r\n" +
+	"a=sendonly\r\n" +
+	"a=rtcp-mux\r\n" +
+	"a=rtpmap:111 opus/48000/2\r\n" +
+	"a=rtcp-fb:111 transport-cc\r\n" +
+	"a=fmtp:111 minptime=10;useinbandfec=1\r\n" +
+	"m=video 9 UDP/TLS/RTP/SAVPF 96 97 98 99 100\r\n" +
+	"c=IN IP4 0.0.0.0\r\n" +
+	"a=mid:1\r\n" +
+	"a=extmap:2/sendonly urn:3gpp:video-orientation\r\n" +
+	"a=rtcp-mux\r\n" +
+	"a=rtcp-rsize\r\n" +
+	"a=rtcp-fb:* nack\r\n" +
+	"a=rtpmap:96 H264/90000\r\n" +
+	"a=rtcp-fb:96 nack pli\r\n" +
+	"a=rtcp-fb:96 goog-remb\r\n" +
+	"a=fmtp:96 level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f\r\n" +
+	"a=rtpmap:97 rtx/90000\r\n" +
+	"a=fmtp:97 apt=96\r\n" +
+	"a=rtpmap:98 H264/90000\r\n" +
+	"a=fmtp:98 packetization-mode=0;profile-level-id=42e01f\r\n" +
+	"a=rtpmap:99 H264/90000\r\n" +
+	"a=fmtp:99 packetization-mode=1;profile-level-id=640c1f\r\n" +
+	"a=rtpmap:100 rtx/90000\r\n" +
+	"a=fmtp:100 apt=99\r\n" +
+	"m=application 9 UDP/DTLS/SCTP webrtc-datachannel\r\n" +
+	"c=IN IP4 0.0.0.0\r\n" +
+	"a=mid:2\r\n" +
+	"m=video 0 RTP/AVP 26\r\n" +
+	"a=mid:3\r\n"
+
+func TestNewJSEPAnswer(t *testing.T) {
+	var offer SessionDescription
+	if err := offer.Unmarshal([]byte(negotiateOffer)); err != nil {
+		t.Fatal(err)
+	}
+
+	capabilities := []MediaCapability{
+		{
+			Media:   "audio",
+			Codecs:  []Codec{{Name: "PCMU", ClockRate: 8000}, {Name: "opus", ClockRate: 48000, EncodingParameters: "2", RTCPFeedback: []string{"transport-cc"}}},
+			ExtMaps: []string{AudioLevelURI},
+		},
+		{
+			Media:     "video",
+			Direction: DirectionRecvOnly,
+			Codecs: []Codec{
+				{Name: "H264", ClockRate: 90000, Fmtp: "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e028", RTCPFeedback: []string{"nack", "nack pli"}},
+				{Name: "rtx", ClockRate: 90000},
+			},
+			ExtMaps: []string{SDESMidURI, VideoOrientationURI},
+		},
+	}
+
+	answer, err := NewJSEPAnswer(&offer, capabilities)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := answer.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed SessionDescription
+	if err = parsed.Unmarshal(raw); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(parsed.MediaDescriptions) != 4 {
+		t.Fatalf("unexpected media count %d", len(parsed.MediaDescriptions))
+	} else if group, _ := parsed.Attribute(AttrKeyGroup); group != "BUNDLE 0 1" {
+		t.Fatalf("unexpected group %q", group)
+	}
+
+	audio := parsed.MediaDescriptions[0]
+	if !reflect.DeepEqual(audio.MediaName.Formats, []string{"0", "111"}) {
+		t.Fatalf("unexpected audio formats %v", audio.MediaName.Formats)
+	} else if _, ok := audio.Attribute("recvonly"); !ok {
+		t.Fatalf("sendonly offer must be answered with recvonly")
+	} else if extmap, _ := audio.Attribute(AttrKeyExtMap); extmap != "1 "+AudioLevelURI {
+		t.Fatalf("unexpected audio extmap %q", extmap)
+	}
+	if codecs := audio.Codecs(); codecs[1].Fmtp != "minptime=10;useinbandfec=1" || !reflect.DeepEqual(codecs[1].RTCPFeedback, []string{"transport-cc"}) {
+		t.Fatalf("unexpected opus codec %v", codecs[1])
+	}
+
+	// 98 has another packetization-mode, 99 another profile and 100 refers to 99
+	video := parsed.MediaDescriptions[1]
+	if !reflect.DeepEqual(video.MediaName.Formats, []string{"96", "97"}) {
+		t.Fatalf("unexpected video formats %v", video.MediaName.Formats)
+	} else if _, ok := video.Attribute("recvonly"); !ok {
+		t.Fatalf("unexpected video direction")
+	} else if _, ok = video.Attribute(AttrKeyRTCPRsize); !ok {
+		t.Fatalf("missing rtcp-rsize")
+	}
+
+	codecs := video.Codecs()
+	if codecs[0].Fmtp != "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e028" {
+		t.Fatalf("unexpected h264 fmtp %q", codecs[0].Fmtp)
+	} else if !reflect.DeepEqual(codecs[0].RTCPFeedback, []string{"nack", "nack pli"}) {
+		t.Fatalf("unexpected h264 feedbacks %v", codecs[0].RTCPFeedback)
+	} else if codecs[1].Name != "rtx" || codecs[1].Fmtp != "apt=96" {
+		t.Fatalf("unexpected rtx codec %v", codecs[1])
+	}
+
+	var extmaps []string
+	for _, a := range video.Attributes {
+		if a.Key == AttrKeyExtMap {
+			extmaps = append(extmaps, a.Value)
+		}
+	}
+	if !reflect.DeepEqual(extmaps, []string{"3 " + SDESMidURI, "2/recvonly " + VideoOrientationURI}) {
+		t.Fatalf("unexpected video extmaps %v", extmaps)
+	}
+
+	for i, m := range parsed.MediaDescriptions[2:] {
+		if m.MediaName.Port.Value != 0 || len(m.MediaName.Formats) != 1 {
+			t.Fatalf("m= line %d must be rejected", i+2)
+		}
+	}
+}
+
+func TestNegotiateH264(t *testing.T) {
+	for i, test := range []struct {
+		offered  string
+		local    string
+		expected string
+		ok       bool
+	}{
+		{"profile-level-id=42e01f;packetization-mode=1", "packetization-mode=1;profile-level-id=42e028", "packetization-mode=1;profile-level-id=42e01f", true},
+		{"profile-level-id=4d801f", "profile-level-id=42e01f", "profile-level-id=4d801f", true},
+		{"profile-level-id=42001f", "profile-level-id=42e01f", "", false},
+		{"profile-level-id=640c1f", "profile-level-id=64001f", "", false},
+		{"packetization-mode=1", "packetization-mode=1", "packetization-mode=1;profile-level-id=42000a", true},
+		{"profile-level-id=42e01f", "profile-level-id=42e01f;packetization-mode=1", "", false},
+		{"profile-level-id=42e0", "profile-level-id=42e01f", "", false},
+	} {
+		fmtp, ok := negotiateH264(test.offered, test.local)
+		if ok != test.ok || fmtp != test.expected {
+			t.Fatalf("%d: unexpected result %q %v", i, fmtp, ok)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package sdp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RIDDirection is the direction of a restriction identifier
+type RIDDirection string
+
+const (
+	// RIDDirectionSend is for streams sent by the endpoint of the description
+	RIDDirectionSend RIDDirection = "send"
+	// RIDDirectionRecv is for streams received by the endpoint of the description
+	RIDDirectionRecv RIDDirection = "recv"
+)
+
+// Common RID restrictions
+// https://tools.ietf.org/html/rfc8851#section-5
+const (
+	RIDRestrictionMaxWidth  = "max-width"
+	RIDRestrictionMaxHeight = "max-height"
+	RIDRestrictionMaxFPS    = "max-fps"
+	RIDRestrictionMaxFS     = "max-fs"
+	RIDRestrictionMaxBR     = "max-br"
+	RIDRestrictionMaxPPS    = "max-pps"
+	RIDRestrictionMaxBPP    = "max-bpp"
+	RIDRestrictionDepend    = "depend"
+)
+
+// RIDRestriction is a key=value restriction of a RID, Value is empty for keys without value
+type RIDRestriction struct {
+	Key   string
+	Value string
+}
+
+// RID represents a restriction identifier
+// https://tools.ietf.org/html/rfc8851#section-10
+//
+// a=rid:<rid-id> <send|recv> [pt=<fmt>,...;]<restriction>=<value>;...
+type RID struct {
+	ID           string
+	Direction    RIDDirection
+	PayloadTypes []uint8
+	Restrictions []RIDRestriction
+}
+
+// validRIDID rid-id = 1*(alpha-numeric / "-" / "_")
+func validRIDID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// attributeValue strips "key:" from a marshaled attribute
+func attributeValue(raw, key string) (string, error) {
+	if !strings.HasPrefix(raw, key+":") {
+		return "", fmt.Errorf("%w: %v", errSyntaxError, raw)
+	}
+	return raw[len(key)+1:], nil
+}
+
+// Clone converts this object to an Attribute
+func (r *RID) Clone() Attribute {
+	return Attribute{Key: AttrKeyRID, Value: r.string()}
+}
+
+// Unmarshal creates a RID from a string
+func (r *RID) Unmarshal(raw string) error {
+	value, err := attributeValue(raw, AttrKeyRID)
+	if err != nil {
+		return err
+	}
+
+	fields := strings.Fields(value)
+	if len(fields) < 2 || len(fields) > 3 || !validRIDID(fields[0]) {
+		return fmt.Errorf("%w: %v", errSyntaxError, raw)
+	}
+
+	direction := RIDDirection(fields[1])
+	if direction != RIDDirectionSend && direction != RIDDirectionRecv {
+		return fmt.Errorf("%w: %v -- rid direction must be send or recv", errSyntaxError, fields[1])
+	}
+
+	var payloadTypes []uint8
+	var restrictions []RIDRestriction
+	if len(fields) == 3 {
+		for i, param := range strings.Split(fields[2], ";") {
+			split := strings.SplitN(param, "=", 2)
+			if split[0] == "" {
+				return fmt.Errorf("%w: %v", errSyntaxError, raw)
+			}
+
+			// pt= must be the first parameter
+			if split[0] == "pt" && i == 0 && len(split) == 2 {
+				for _, format := range strings.Split(split[1], ",") {
+					pt, err := strconv.ParseUint(format, 10, 8)
+					if err != nil {
+						return fmt.Errorf("%w: %v", errSyntaxError, format)
+					}
+					payloadTypes = append(payloadTypes, uint8(pt))
+				}
+				continue
+			}
+
+			restriction := RIDRestriction{Key: split[0]}
+			if len(split) == 2 {
+				restriction.Value = split[1]
+			}
+			restrictions = append(restrictions, restriction)
+		}
+	}
+
+	r.ID = fields[0]
+	r.Direction = direction
+	r.PayloadTypes = payloadTypes
+	r.Restrictions = restrictions
+	return nil
+}
+
+// Marshal creates a string from a RID
+func (r *RID) Marshal() string {
+	return r.Name() + ":" + r.string()
+}
+
+func (r *RID) string() string {
+	output := r.ID + " " + string(r.Direction)
+
+	var params []string
+	if len(r.PayloadTypes) > 0 {
+		formats := make([]string, 0, len(r.PayloadTypes))
+		for _, pt := range r.PayloadTypes {
+			formats = append(formats, strconv.Itoa(int(pt)))
+		}
+		params = append(params, "pt="+strings.Join(formats, ","))
+	}
+	for _, restriction := range r.Restrictions {
+		if restriction.Value == "" {
+			params = append(params, restriction.Key)
+		} else {
+			params = append(params, restriction.Key+"="+restriction.Value)
+		}
+	}
+
+	if len(params) > 0 {
+		output += " " + strings.Join(params, ";")
+	}
+	return output
+}
+
+// Name returns the constant name of this object
+func (r *RID) Name() string {
+	return AttrKeyRID
+}
+
+// Restriction returns the value of a restriction and if it exists
+func (r *RID) Restriction(key string) (string, bool) {
+	for _, restriction := range r.Restrictions {
+		if restriction.Key == key {
+			return restriction.Value, true
+		}
+	}
+	return "", false
+}
+
+// SimulcastStream is a RID in a simulcast stream list, Paused is marshaled as a "~" prefix
+type SimulcastStream struct {
+	RID    string
+	Paused bool
+}
+
+// Simulcast represents the simulcast attribute
+// https://tools.ietf.org/html/rfc8853#section-5.1
+//
+// a=simulcast:send 1;~2,3 recv 4
+//
+// Each entry of Send and Recv is a simulcast stream, which is a list of alternative RIDs
+type Simulcast struct {
+	Send [][]SimulcastStream
+	Recv [][]SimulcastStream
+}
+
+// Clone converts this object to an Attribute
+func (s *Simulcast) Clone() Attribute {
+	return Attribute{Key: AttrKeySimulcast, Value: s.string()}
+}
+
+func parseSimulcastStreams(list string) ([][]SimulcastStream, error) {
+	var streams [][]SimulcastStream
+	for _, alternatives := range strings.Split(list, ";") {
+		var stream []SimulcastStream
+		for _, id := range strings.Split(alternatives, ",") {
+			paused := strings.HasPrefix(id, "~")
+			id = strings.TrimPrefix(id, "~")
+			if !validRIDID(id) {
+				return nil, fmt.Errorf("%w: %v", errSyntaxError, list)
+			}
+			stream = append(stream, SimulcastStream{RID: id, Paused: paused})
+		}
+		streams = append(streams, stream)
+	}
+	return streams, nil
+}
+
+// Unmarshal creates a Simulcast from a string
+func (s *Simulcast) Unmarshal(raw string) error {
+	value, err := attributeValue(raw, AttrKeySimulcast)
+	if err != nil {
+		return err
+	}
+
+	fields := strings.Fields(value)
+	if len(fields) != 2 && len(fields) != 4 {
+		return fmt.Errorf("%w: %v", errSyntaxError, raw)
+	}
+
+	var send, recv [][]SimulcastStream
+	for i := 0; i < len(fields); i += 2 {
+		streams, err := parseSimulcastStreams(fields[i+1])
+		if err != nil {
+			return err
+		}
+
+		switch RIDDirection(fields[i]) {
+		case RIDDirectionSend:
+			if send != nil {
+				return fmt.Errorf("%w: %v -- duplicated send", errSyntaxError, raw)
+			}
+			send = streams
+		case RIDDirectionRecv:
+			if recv != nil {
+				return fmt.Errorf("%w: %v -- duplicated recv", errSyntaxError, raw)
+			}
+			recv = streams
+		default:
+			return fmt.Errorf("%w: %v -- simulcast direction must be send or recv", errSyntaxError, fields[i])
+		}
+	}
+
+	s.Send = send
+	s.Recv = recv
+	return nil
+}
+
+// Marshal creates a string from a Simulcast
+func (s *Simulcast) Marshal() string {
+	return s.Name() + ":" + s.string()
+}
+
+func marshalSimulcastStreams(streams [][]SimulcastStream) string {
+	list := make([]string, 0, len(streams))
+	for _, stream := range streams {
+		alternatives := make([]string, 0, len(stream))
+		for _, alternative := range stream {
+			if alternative.Paused {
+				alternatives = append(alternatives, "~"+alternative.RID)
+			} else {
+				alternatives = append(alternatives, alternative.RID)
+			}
+		}
+		list = append(list, strings.Join(alternatives, ","))
+	}
+	return strings.Join(list, ";")
+}
+
+func (s *Simulcast) string() string {
+	var output []string
+	if len(s.Send) > 0 {
+		output = append(output, string(RIDDirectionSend), marshalSimulcastStreams(s.Send))
+	}
+	if len(s.Recv) > 0 {
+		output = append(output, string(RIDDirectionRecv), marshalSimulcastStreams(s.Recv))
+	}
+	return strings.Join(output, " ")
+}
+
+// Name returns the constant name of this object
+func (s *Simulcast) Name() string {
+	return AttrKeySimulcast
+}
+
+// RIDs returns all rid attributes of the media description
+func (d *MediaDescription) RIDs() ([]RID, error) {
+	var rids []RID
+	for _, a := range d.Attributes {
+		if a.Key != AttrKeyRID {
+			continue
+		}
+
+		var rid RID
+		if err := rid.Unmarshal(a.String()); err != nil {
+			return nil, err
+		}
+		rids = append(rids, rid)
+	}
+	return rids, nil
+}
+
+// RID returns the rid attribute with the id and if it exists
+func (d *MediaDescription) RID(id string) (*RID, bool) {
+	rids, err := d.RIDs()
+	if err != nil {
+		return nil, false
+	}
+	for i := range rids {
+		if rids[i].ID == id {
+			return &rids[i], true
+		}
+	}
+	return nil, false
+}
+
+// Simulcast returns the simulcast attribute, or nil if there is none
+func (d *MediaDescription) Simulcast() (*Simulcast, error) {
+	value, ok := d.Attribute(AttrKeySimulcast)
+	if !ok {
+		return nil, nil //nolint:nilnil
+	}
+
+	s := &Simulcast{}
+	if err := s.Unmarshal(AttrKeySimulcast + ":" + value); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// WithRID adds a rid attribute to the media description
+func (d *MediaDescription) WithRID(r RID) *MediaDescription {
+	d.Attributes = append(d.Attributes, r.Clone())
+	return d
+}
+
+// WithSimulcast adds a simulcast attribute to the media description
+func (d *MediaDescription) WithSimulcast(s Simulcast) *MediaDescription {
+	d.Attributes = append(d.Attributes, s.Clone())
+	return d
+}
@@ -0,0 +1,108 @@
+package sdp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRID(t *testing.T) {
+	passingtests := []struct {
+		parameter string
+		expected  RID
+	}{
+		{"rid:f send", RID{ID: "f", Direction: RIDDirectionSend}},
+		{"rid:h send pt=96,97;max-width=640;max-height=360", RID{
+			ID:           "h",
+			Direction:    RIDDirectionSend,
+			PayloadTypes: []uint8{96, 97},
+			Restrictions: []RIDRestriction{{RIDRestrictionMaxWidth, "640"}, {RIDRestrictionMaxHeight, "360"}},
+		}},
+		{"rid:q_1 recv max-fps=15;depend=h", RID{
+			ID:           "q_1",
+			Direction:    RIDDirectionRecv,
+			Restrictions: []RIDRestriction{{RIDRestrictionMaxFPS, "15"}, {RIDRestrictionDepend, "h"}},
+		}},
+	}
+	failingtests := []string{
+		"rid:f",
+		"rid:f sendrecv",
+		"rid:f.1 send",
+		"rid:f send pt=96,abc",
+		"extmap:f send",
+	}
+
+	for i, u := range passingtests {
+		actual := RID{}
+		assert.NoError(t, actual.Unmarshal(u.parameter))
+		assert.Equal(t, u.expected, actual, "%d: %+v", i, u)
+		assert.Equal(t, u.parameter, actual.Marshal())
+	}
+
+	for _, u := range failingtests {
+		actual := RID{}
+		assert.Error(t, actual.Unmarshal(u), u)
+	}
+}
+
+func TestSimulcast(t *testing.T) {
+	passingtests := []struct {
+		parameter string
+		expected  Simulcast
+	}{
+		{"simulcast:send h;~m;l", Simulcast{
+			Send: [][]SimulcastStream{{{RID: "h"}}, {{RID: "m", Paused: true}}, {{RID: "l"}}},
+		}},
+		{"simulcast:send 1,2;3 recv 4", Simulcast{
+			Send: [][]SimulcastStream{{{RID: "1"}, {RID: "2"}}, {{RID: "3"}}},
+			Recv: [][]SimulcastStream{{{RID: "4"}}},
+		}},
+	}
+	failingtests := []string{
+		"simulcast:send",
+		"simulcast:sendrecv 1",
+		"simulcast:send 1 send 2",
+		"simulcast:send 1;;2",
+	}
+
+	for i, u := range passingtests {
+		actual := Simulcast{}
+		assert.NoError(t, actual.Unmarshal(u.parameter))
+		assert.Equal(t, u.expected, actual, "%d: %+v", i, u)
+		assert.Equal(t, u.parameter, actual.Marshal())
+	}
+
+	// recv before send is normalized
+	actual := Simulcast{}
+	assert.NoError(t, actual.Unmarshal("simulcast:recv 4 send 1"))
+	assert.Equal(t, "simulcast:send 1 recv 4", actual.Marshal())
+
+	for _, u := range failingtests {
+		actual := Simulcast{}
+		assert.Error(t, actual.Unmarshal(u), u)
+	}
+}
+
+func TestMediaDescriptionSimulcast(t *testing.T) {
+	d := &MediaDescription{}
+	s, err := d.Simulcast()
+	assert.NoError(t, err)
+	assert.Nil(t, s)
+
+	d.WithRID(RID{ID: "h", Direction: RIDDirectionSend}).
+		WithRID(RID{ID: "l", Direction: RIDDirectionSend, Restrictions: []RIDRestriction{{RIDRestrictionMaxWidth, "320"}}}).
+		WithSimulcast(Simulcast{Send: [][]SimulcastStream{{{RID: "h"}}, {{RID: "l", Paused: true}}}})
+
+	rids, err := d.RIDs()
+	assert.NoError(t, err)
+	assert.Len(t, rids, 2)
+
+	rid, ok := d.RID("l")
+	assert.True(t, ok)
+	width, _ := rid.Restriction(RIDRestrictionMaxWidth)
+	assert.Equal(t, "320", width)
+
+	s, err = d.Simulcast()
+	assert.NoError(t, err)
+	assert.Equal(t, "simulcast:send h;~l", s.Marshal())
+}
@@ -0,0 +1,260 @@
+package sdp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SSRC represents a source-level attribute
+// https://tools.ietf.org/html/rfc5576#section-4.1
+//
+// a=ssrc:<ssrc-id> <attribute>[:<value>]
+type SSRC struct {
+	ID        uint32
+	Attribute string
+	Value     string
+}
+
+// Clone converts this object to an Attribute
+func (s *SSRC) Clone() Attribute {
+	return Attribute{Key: AttrKeySSRC, Value: s.string()}
+}
+
+// Unmarshal creates a SSRC from a string
+func (s *SSRC) Unmarshal(raw string) error {
+	value, err := attributeValue(raw, AttrKeySSRC)
+	if err != nil {
+		return err
+	}
+
+	split := strings.SplitN(value, " ", 2)
+	if len(split) != 2 || split[1] == "" {
+		return fmt.Errorf("%w: %v", errSyntaxError, raw)
+	}
+
+	id, err := strconv.ParseUint(split[0], 10, 32)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errSyntaxError, split[0])
+	}
+
+	attribute := strings.SplitN(split[1], ":", 2)
+	s.ID = uint32(id)
+	s.Attribute = attribute[0]
+	s.Value = ""
+	if len(attribute) == 2 {
+		s.Value = attribute[1]
+	}
+	return nil
+}
+
+// Marshal creates a string from a SSRC
+func (s *SSRC) Marshal() string {
+	return s.Name() + ":" + s.string()
+}
+
+func (s *SSRC) string() string {
+	output := strconv.FormatUint(uint64(s.ID), 10) + " " + s.Attribute
+	if s.Value != "" {
+		output += ":" + s.Value
+	}
+	return output
+}
+
+// Name returns the constant name of this object
+func (s *SSRC) Name() string {
+	return AttrKeySSRC
+}
+
+// SSRCGroup represents a grouping of sources, e.g. FID for RTX, SIM for simulcast
+// and FEC-FR for forward error correction
+// https://tools.ietf.org/html/rfc5576#section-4.2
+//
+// a=ssrc-group:<semantics> <ssrc-id> ...
+type SSRCGroup struct {
+	Semantics string
+	SSRCs     []uint32
+}
+
+// Clone converts this object to an Attribute
+func (g *SSRCGroup) Clone() Attribute {
+	return Attribute{Key: AttrKeySSRCGroup, Value: g.string()}
+}
+
+// Unmarshal creates a SSRCGroup from a string
+func (g *SSRCGroup) Unmarshal(raw string) error {
+	value, err := attributeValue(raw, AttrKeySSRCGroup)
+	if err != nil {
+		return err
+	}
+
+	fields := strings.Fields(value)
+	if len(fields) < 2 {
+		return fmt.Errorf("%w: %v", errSyntaxError, raw)
+	}
+
+	ssrcs := make([]uint32, 0, len(fields)-1)
+	for _, field := range fields[1:] {
+		ssrc, err := strconv.ParseUint(field, 10, 32)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errSyntaxError, field)
+		}
+		ssrcs = append(ssrcs, uint32(ssrc))
+	}
+
+	g.Semantics = fields[0]
+	g.SSRCs = ssrcs
+	return nil
+}
+
+// Marshal creates a string from a SSRCGroup
+func (g *SSRCGroup) Marshal() string {
+	return g.Name() + ":" + g.string()
+}
+
+func (g *SSRCGroup) string() string {
+	output := g.Semantics
+	for _, ssrc := range g.SSRCs {
+		output += " " + strconv.FormatUint(uint64(ssrc), 10)
+	}
+	return output
+}
+
+// Name returns the constant name of this object
+func (g *SSRCGroup) Name() string {
+	return AttrKeySSRCGroup
+}
+
+// Msid represents the media stream identification
+// https://tools.ietf.org/html/rfc8830#section-2
+//
+// a=msid:<stream id> [<track id>]
+type Msid struct {
+	StreamID string
+	TrackID  string
+}
+
+// Clone converts this object to an Attribute
+func (m *Msid) Clone() Attribute {
+	return Attribute{Key: AttrKeyMsid, Value: m.string()}
+}
+
+// Unmarshal creates a Msid from a string
+func (m *Msid) Unmarshal(raw string) error {
+	value, err := attributeValue(raw, AttrKeyMsid)
+	if err != nil {
+		return err
+	}
+
+	fields := strings.Fields(value)
+	if len(fields) < 1 || len(fields) > 2 {
+		return fmt.Errorf("%w: %v", errSyntaxError, raw)
+	}
+
+	m.StreamID = fields[0]
+	m.TrackID = ""
+	if len(fields) == 2 {
+		m.TrackID = fields[1]
+	}
+	return nil
+}
+
+// Marshal creates a string from a Msid
+func (m *Msid) Marshal() string {
+	return m.Name() + ":" + m.string()
+}
+
+func (m *Msid) string() string {
+	if m.TrackID == "" {
+		return m.StreamID
+	}
+	return m.StreamID + " " + m.TrackID
+}
+
+// Name returns the constant name of this object
+func (m *Msid) Name() string {
+	return AttrKeyMsid
+}
+
+// SSRCs returns all ssrc attributes of the media description
+func (d *MediaDescription) SSRCs() ([]SSRC, error) {
+	var ssrcs []SSRC
+	for _, a := range d.Attributes {
+		if a.Key != AttrKeySSRC {
+			continue
+		}
+
+		var ssrc SSRC
+		if err := ssrc.Unmarshal(a.String()); err != nil {
+			return nil, err
+		}
+		ssrcs = append(ssrcs, ssrc)
+	}
+	return ssrcs, nil
+}
+
+// SSRCAttribute returns the value of an attribute of the source and if it exists, e.g. cname
+func (d *MediaDescription) SSRCAttribute(id uint32, attribute string) (string, bool) {
+	ssrcs, err := d.SSRCs()
+	if err != nil {
+		return "", false
+	}
+	for _, ssrc := range ssrcs {
+		if ssrc.ID == id && ssrc.Attribute == attribute {
+			return ssrc.Value, true
+		}
+	}
+	return "", false
+}
+
+// SSRCGroups returns all ssrc-group attributes of the media description
+func (d *MediaDescription) SSRCGroups() ([]SSRCGroup, error) {
+	var groups []SSRCGroup
+	for _, a := range d.Attributes {
+		if a.Key != AttrKeySSRCGroup {
+			continue
+		}
+
+		var group SSRCGroup
+		if err := group.Unmarshal(a.String()); err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// Msids returns all msid attributes of the media description
+func (d *MediaDescription) Msids() ([]Msid, error) {
+	var msids []Msid
+	for _, a := range d.Attributes {
+		if a.Key != AttrKeyMsid {
+			continue
+		}
+
+		var msid Msid
+		if err := msid.Unmarshal(a.String()); err != nil {
+			return nil, err
+		}
+		msids = append(msids, msid)
+	}
+	return msids, nil
+}
+
+// WithSSRC adds a ssrc attribute to the media description
+func (d *MediaDescription) WithSSRC(s SSRC) *MediaDescription {
+	d.Attributes = append(d.Attributes, s.Clone())
+	return d
+}
+
+// WithSSRCGroup adds a ssrc-group attribute to the media description
+func (d *MediaDescription) WithSSRCGroup(g SSRCGroup) *MediaDescription {
+	d.Attributes = append(d.Attributes, g.Clone())
+	return d
+}
+
+// WithMsid adds a msid attribute to the media description
+func (d *MediaDescription) WithMsid(m Msid) *MediaDescription {
+	d.Attributes = append(d.Attributes, m.Clone())
+	return d
+}
@@ -0,0 +1,96 @@
+package sdp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSSRC(t *testing.T) {
+	passingtests := []struct {
+		parameter string
+		expected  SSRC
+	}{
+		{"ssrc:1234 cname:user@example.com", SSRC{ID: 1234, Attribute: "cname", Value: "user@example.com"}},
+		{"ssrc:4294967295 msid:stream track", SSRC{ID: 4294967295, Attribute: "msid", Value: "stream track"}},
+		{"ssrc:1 previous-ssrc", SSRC{ID: 1, Attribute: "previous-ssrc"}},
+	}
+	failingtests := []string{
+		"ssrc:1234",
+		"ssrc:4294967296 cname:a",
+		"ssrc:abc cname:a",
+	}
+
+	for i, u := range passingtests {
+		actual := SSRC{}
+		assert.NoError(t, actual.Unmarshal(u.parameter))
+		assert.Equal(t, u.expected, actual, "%d: %+v", i, u)
+		assert.Equal(t, u.parameter, actual.Marshal())
+	}
+
+	for _, u := range failingtests {
+		actual := SSRC{}
+		assert.Error(t, actual.Unmarshal(u), u)
+	}
+}
+
+func TestSSRCGroup(t *testing.T) {
+	passingtests := []struct {
+		parameter string
+		expected  SSRCGroup
+	}{
+		{"ssrc-group:FID 1 2", SSRCGroup{Semantics: SemanticTokenFlowIdentification, SSRCs: []uint32{1, 2}}},
+		{"ssrc-group:SIM 1 3 5", SSRCGroup{Semantics: SemanticTokenSimulcast, SSRCs: []uint32{1, 3, 5}}},
+		{"ssrc-group:FEC-FR 1 7", SSRCGroup{Semantics: SemanticTokenFECFramework, SSRCs: []uint32{1, 7}}},
+	}
+	failingtests := []string{
+		"ssrc-group:FID",
+		"ssrc-group:FID 1 x",
+	}
+
+	for i, u := range passingtests {
+		actual := SSRCGroup{}
+		assert.NoError(t, actual.Unmarshal(u.parameter))
+		assert.Equal(t, u.expected, actual, "%d: %+v", i, u)
+		assert.Equal(t, u.parameter, actual.Marshal())
+	}
+
+	for _, u := range failingtests {
+		actual := SSRCGroup{}
+		assert.Error(t, actual.Unmarshal(u), u)
+	}
+}
+
+func TestMediaDescriptionSSRC(t *testing.T) {
+	d := (&MediaDescription{}).
+		WithMsid(Msid{StreamID: "stream", TrackID: "track"}).
+		WithSSRCGroup(SSRCGroup{Semantics: SemanticTokenFlowIdentification, SSRCs: []uint32{1, 2}}).
+		WithSSRC(SSRC{ID: 1, Attribute: "cname", Value: "a"}).
+		WithSSRC(SSRC{ID: 2, Attribute: "cname", Value: "a"}).
+		WithMediaSource(3, "b", "stream", "track")
+
+	msids, err := d.Msids()
+	assert.NoError(t, err)
+	assert.Equal(t, []Msid{{StreamID: "stream", TrackID: "track"}}, msids)
+
+	groups, err := d.SSRCGroups()
+	assert.NoError(t, err)
+	assert.Equal(t, []SSRCGroup{{Semantics: SemanticTokenFlowIdentification, SSRCs: []uint32{1, 2}}}, groups)
+
+	ssrcs, err := d.SSRCs()
+	assert.NoError(t, err)
+	assert.Len(t, ssrcs, 6)
+
+	cname, ok := d.SSRCAttribute(3, "cname")
+	assert.True(t, ok)
+	assert.Equal(t, "b", cname)
+	msid, _ := d.SSRCAttribute(3, "msid")
+	assert.Equal(t, "stream track", msid)
+	_, ok = d.SSRCAttribute(4, "cname")
+	assert.False(t, ok)
+
+	m := Msid{}
+	assert.NoError(t, m.Unmarshal("msid:-"))
+	assert.Equal(t, "msid:-", m.Marshal())
+	assert.Error(t, m.Unmarshal("msid:a b c"))
+}
@@ -302,6 +302,26 @@ func (s *SessionDescription) GetPayloadTypeForCodec(wanted Codec) (uint8, error)
 	return 0, errCodecNotFound
 }
 
+// GetRTXPayloadType scans the SessionDescription for the RFC 4588 retransmission payload type
+// whose apt parameter refers to the given payload type
+func (s *SessionDescription) GetRTXPayloadType(apt uint8) (uint8, error) {
+	codecs := s.buildCodecMap()
+
+	for payloadType, codec := range codecs {
+		if !strings.EqualFold(codec.Name, "rtx") {
+			continue
+		}
+		for _, param := range strings.Split(codec.Fmtp, ";") {
+			split := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(split) == 2 && split[0] == "apt" && split[1] == strconv.Itoa(int(apt)) {
+				return payloadType, nil
+			}
+		}
+	}
+
+	return 0, errPayloadTypeNotFound
+}
+
 type stateFn func(*lexer) (stateFn, error)
 
 type lexer struct {
@@ -144,6 +144,34 @@ func TestGetCodecForPayloadType(t *testing.T) {
 	}
 }
 
+func TestGetRTXPayloadType(t *testing.T) {
+	md := (&MediaDescription{MediaName: MediaName{Media: "video", Protos: []string{"RTP", "AVP"}}}).
+		WithCodec(96, "H264", 90000, 0, "packetization-mode=1").
+		WithRTCPFeedback(96, "nack").
+		WithRTXCodec(97, 96, 90000, 3000)
+	sd := SessionDescription{MediaDescriptions: []*MediaDescription{md}}
+
+	codec, err := sd.GetCodecForPayloadType(97)
+	if err != nil {
+		t.Fatal(err)
+	} else if codec.Name != "rtx" || codec.ClockRate != 90000 || codec.Fmtp != "apt=96;rtx-time=3000" {
+		t.Fatalf("unexpected rtx codec %v", codec)
+	}
+
+	if codec, err = sd.GetCodecForPayloadType(96); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(codec.RTCPFeedback, []string{"nack"}) {
+		t.Fatalf("unexpected rtcp feedback %v", codec.RTCPFeedback)
+	}
+
+	if payloadType, err := sd.GetRTXPayloadType(96); err != nil || payloadType != 97 {
+		t.Fatalf("GetRTXPayloadType(96) = %d, %v", payloadType, err)
+	}
+	if _, err := sd.GetRTXPayloadType(98); !errors.Is(err, errPayloadTypeNotFound) {
+		t.Fatalf("GetRTXPayloadType(98): err=%v, want=%v", err, errPayloadTypeNotFound)
+	}
+}
+
 func TestNewSessionID(t *testing.T) {
 	min := uint64(0x7FFFFFFFFFFFFFFF)
 	max := uint64(0)
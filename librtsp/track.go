@@ -0,0 +1,130 @@
+package librtsp
+
+import (
+	"avformat/librtp"
+	"avformat/librtsp/sdp"
+	"avformat/utils"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Track 由DESCRIBE应答SDP中的rtpmap/fmtp生成的编码参数
+type Track struct {
+	MediaType   utils.AVMediaType
+	CodecId     utils.AVCodecID
+	PayloadType int
+	ClockRate   int
+	Channels    int
+	// ExtraData H264/H265为AnnexB格式的参数集, AAC为AudioSpecificConfig
+	ExtraData   []byte
+	AudioConfig *utils.MPEG4AudioConfig
+	Fmtp        map[string]string
+	// Direction SDP中的a=sendonly等. ONVIF反向音频为sendonly
+	Direction sdp.Direction
+
+	control string
+	format  *librtp.PayloadFormat
+	//SDP中的组播地址和端口, SETUP应答未携带destination/port时使用
+	destination string
+	port        int
+	//RTP/SAVP时SDES a=crypto中选择的密钥
+	crypto *sdp.Crypto
+}
+
+// mediaDirection media级的方向属性优先于session级, 默认sendrecv
+func mediaDirection(sd *sdp.SessionDescription, md *sdp.MediaDescription) sdp.Direction {
+	directions := []sdp.Direction{sdp.DirectionSendRecv, sdp.DirectionSendOnly, sdp.DirectionRecvOnly, sdp.DirectionInactive}
+	for _, direction := range directions {
+		if _, ok := md.Attribute(direction.String()); ok {
+			return direction
+		}
+	}
+	for _, direction := range directions {
+		if _, ok := sd.Attribute(direction.String()); ok {
+			return direction
+		}
+	}
+	return sdp.DirectionSendRecv
+}
+
+// multicastDestination media级的c=优先于session级
+func multicastDestination(sd *sdp.SessionDescription, md *sdp.MediaDescription) (string, int) {
+	connection := md.ConnectionInformation
+	if connection == nil {
+		connection = sd.ConnectionInformation
+	}
+	if connection == nil || connection.Address == nil {
+		return "", 0
+	}
+
+	//224.2.1.1/127/3
+	address := strings.Split(connection.Address.Address, "/")[0]
+	if ip := net.ParseIP(address); ip == nil || !ip.IsMulticast() {
+		return "", 0
+	}
+	return address, md.MediaName.Port.Value
+}
+
+// isSecureProfile RTP/SAVP或RTP/SAVPF
+func isSecureProfile(md *sdp.MediaDescription) bool {
+	for _, proto := range md.MediaName.Protos {
+		if proto == "SAVP" || proto == "SAVPF" {
+			return true
+		}
+	}
+	return false
+}
+
+// selectCrypto 按顺序选择第一个支持的a=crypto
+func selectCrypto(md *sdp.MediaDescription) (*sdp.Crypto, error) {
+	cryptos, err := md.Cryptos()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range cryptos {
+		if _, err := librtp.NewSRTPContextWithCrypto(&cryptos[i]); err == nil {
+			return &cryptos[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no supported crypto in the media %s", md.MediaName.Media)
+}
+
+func newTrack(md *sdp.MediaDescription) (*Track, error) {
+	profile, err := librtp.NewProfile(md)
+	if err != nil {
+		return nil, err
+	}
+
+	//使用m=行中的第一个负载类型
+	format := profile.Formats[0]
+	track := &Track{
+		MediaType:   format.MediaType,
+		CodecId:     format.CodecId,
+		PayloadType: format.PayloadType,
+		ClockRate:   format.ClockRate,
+		Channels:    format.Channels,
+		ExtraData:   format.ExtraData,
+		AudioConfig: format.AudioConfig,
+		Fmtp:        format.Fmtp,
+		format:      format,
+	}
+
+	if isSecureProfile(md) {
+		if track.crypto, err = selectCrypto(md); err != nil {
+			return nil, err
+		}
+	}
+	return track, nil
+}
+
+// newDepacketizer 根据编码创建负载解析器
+func (t *Track) newDepacketizer(handler func(data []byte, timestamp uint32, keyFrame bool)) (librtp.Depacketizer, error) {
+	return t.format.NewDepacketizer(handler)
+}
+
+// newPacketizer 创建反向通道的封装器
+func (t *Track) newPacketizer(ssrc uint32, handler func(data []byte, timestamp uint32)) (librtp.Packetizer, error) {
+	return t.format.NewPacketizer(ssrc, handler)
+}
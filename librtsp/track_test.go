@@ -0,0 +1,103 @@
+package librtsp
+
+import (
+	"avformat/libavc"
+	"avformat/librtsp/sdp"
+	"avformat/utils"
+	"bytes"
+	"testing"
+)
+
+func TestNewTrack(t *testing.T) {
+	description := "v=0\r\no=- 0 0 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\n" +
+		"m=video 0 RTP/AVP 96\r\na=rtpmap:96 H264/90000\r\na=fmtp:96 packetization-mode=1;sprop-parameter-sets=Z0IAKeKQFAe2AtwEBAaQeJEV,aM48gA==\r\na=control:trackID=1\r\n" +
+		"m=audio 0 RTP/AVP 97\r\na=rtpmap:97 MPEG4-GENERIC/44100/2\r\na=fmtp:97 streamtype=5;profile-level-id=15;mode=AAC-hbr;config=1210;sizeLength=13;indexLength=3;indexDeltaLength=3\r\na=control:trackID=2\r\n" +
+		"m=audio 0 RTP/AVP 8\r\na=control:trackID=3\r\n"
+
+	var sd sdp.SessionDescription
+	if err := sd.Unmarshal([]byte(description)); err != nil {
+		t.Fatal(err)
+	}
+
+	video, err := newTrack(sd.MediaDescriptions[0])
+	if err != nil {
+		t.Fatal(err)
+	} else if video.CodecId != utils.AVCodecIdH264 || video.ClockRate != 90000 || !bytes.HasPrefix(video.ExtraData, libavc.StartCode4) {
+		t.Fatalf("unexpected video track %+v", video)
+	}
+
+	audio, err := newTrack(sd.MediaDescriptions[1])
+	if err != nil {
+		t.Fatal(err)
+	} else if audio.CodecId != utils.AVCodecIdAAC || audio.Channels != 2 || audio.AudioConfig == nil || audio.AudioConfig.SampleRate != 44100 {
+		t.Fatalf("unexpected audio track %+v", audio)
+	} else if _, err = audio.newDepacketizer(func(data []byte, timestamp uint32, keyFrame bool) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	pcma, err := newTrack(sd.MediaDescriptions[2])
+	if err != nil {
+		t.Fatal(err)
+	} else if pcma.CodecId != utils.AVCodecIdPCMALAW || pcma.ClockRate != 8000 {
+		t.Fatalf("unexpected static payload track %+v", pcma)
+	}
+
+	if control := resolveControl("rtsp://127.0.0.1/live", "trackID=1"); control != "rtsp://127.0.0.1/live/trackID=1" {
+		t.Fatalf("unexpected control url %s", control)
+	}
+}
+
+func TestBackchannelTrack(t *testing.T) {
+	//ONVIF反向音频为sendonly
+	description := "v=0\r\no=- 0 0 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\n" +
+		"m=video 0 RTP/AVP 96\r\na=rtpmap:96 H264/90000\r\na=control:video\r\na=recvonly\r\n" +
+		"m=audio 0 RTP/AVP 0\r\na=rtpmap:0 PCMU/8000\r\na=control:audioback\r\na=sendonly\r\n"
+
+	var sd sdp.SessionDescription
+	if err := sd.Unmarshal([]byte(description)); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Puller{}
+	p.EnableBackchannel()
+	for i, expected := range []bool{false, true} {
+		track, err := newTrack(sd.MediaDescriptions[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		track.Direction = mediaDirection(&sd, sd.MediaDescriptions[i])
+		if p.isBackchannel(track) != expected {
+			t.Fatalf("unexpected direction %s of the track %d", track.Direction, i)
+		}
+		if expected {
+			if _, err = track.newPacketizer(1, func(data []byte, timestamp uint32) {}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestSecureTrack(t *testing.T) {
+	//不支持的crypto-suite被跳过
+	description := "v=0\r\no=- 0 0 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\n" +
+		"m=video 0 RTP/SAVP 96\r\na=rtpmap:96 H264/90000\r\na=control:trackID=1\r\n" +
+		"a=crypto:1 F8_128_HMAC_SHA1_80 inline:MTIzNDU2Nzg5QUJDREUwMTIzNDU2Nzg5QUJjZGVm\r\n" +
+		"a=crypto:2 AES_CM_128_HMAC_SHA1_80 inline:MTIzNDU2Nzg5QUJDREUwMTIzNDU2Nzg5QUJjZGVm|2^20\r\n" +
+		"m=audio 0 RTP/SAVP 0\r\na=control:trackID=2\r\n"
+
+	var sd sdp.SessionDescription
+	if err := sd.Unmarshal([]byte(description)); err != nil {
+		t.Fatal(err)
+	}
+
+	video, err := newTrack(sd.MediaDescriptions[0])
+	if err != nil {
+		t.Fatal(err)
+	} else if video.crypto == nil || video.crypto.Tag != 2 {
+		t.Fatalf("unexpected crypto %+v", video.crypto)
+	}
+
+	if _, err = newTrack(sd.MediaDescriptions[1]); err == nil {
+		t.Fatalf("the secure profile requires a crypto attribute")
+	}
+}
@@ -1,8 +1,19 @@
 package librtsp
 
 import (
+	"avformat/librtcp"
+	"avformat/librtp"
+	"avformat/librtsp/sdp"
 	"avformat/utils"
 	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// maxPendingPackets 等待PLAY应答期间最多缓存的RTP包
+	maxPendingPackets = 512
 )
 
 var (
@@ -16,6 +27,55 @@ type Server struct {
 	clientPort string //sample:20000-20001
 	serverAddr string
 	serverPort [2]int
+
+	index        int
+	track        *Track
+	depacketizer librtp.Depacketizer
+	lock         sync.Mutex
+	//重排序并统计接收情况, 到播放时间后调用dispatch
+	jitterBuffer *librtp.JitterBuffer
+	releaseTimer *time.Timer
+
+	timestampInitialized bool
+	lastTimestamp        uint32
+	pts                  int64
+
+	//根据SR对齐各track的pts. clockLock保护senderClock, 最后一帧的时间戳和播放位置, 允许在帧回调中查询
+	lipSync         *lipSync
+	aligned         bool
+	clockLock       sync.Mutex
+	senderClock     *librtp.SenderClock
+	anchorTimestamp uint32
+	anchorPts       int64
+	lastPts         int64 //最后一帧的pts, PLAY后为Range的起始位置
+
+	dispatch func(data []byte)
+	playing  bool //PLAY应答已处理, 时间轴已确定
+	pending  [][]byte
+	seq      uint16 //RTP-Info中的第一个序号
+	seqExist bool
+
+	//RTP/AVP/TCP交织传输, 使用RTSP连接收发
+	interleaved bool
+	channels    [2]int
+	tcp         utils.Transport
+
+	//组播, serverAddr/serverPort为组播地址和端口
+	multicast bool
+	ttl       int
+
+	//ONVIF反向通道, 向服务器发送
+	packetizer librtp.Packetizer
+
+	//WriteRTP可能在lock内调用, 发送统计单独加锁
+	senderLock    sync.Mutex
+	sender        senderStatistics
+	retransmitter *librtp.Retransmitter //NACK重传, senderLock保护
+	onRTCPHandler func(packets []librtcp.Packet)
+
+	//RTP/SAVP, 发送和接收使用各自的上下文
+	srtpSend    *librtp.SRTPContext
+	srtpReceive *librtp.SRTPContext
 }
 
 func CreateServer() (*Server, error) {
@@ -51,9 +111,481 @@ func CreateServer() (*Server, error) {
 	return &Server{rtp: transport1, rtcp: transport2, clientPort: fmt.Sprintf("%d-%d", port1, port2)}, err
 }
 
+// createInterleavedServer 交织传输不需要分配端口
+func createInterleavedServer(transport utils.Transport, channel int) *Server {
+	return &Server{interleaved: true, channels: [2]int{channel, channel + 1}, tcp: transport}
+}
+
+// joinMulticast 客户端加入SETUP应答中的组播组, RR也发送到组播组
+func (s *Server) joinMulticast(iface *net.Interface, group string, ports [2]int) error {
+	rtp, err := utils.NewMulticastTransport(iface, group, ports[0])
+	if err != nil {
+		return err
+	}
+	rtcp, err := utils.NewMulticastTransport(iface, group, ports[1])
+	if err != nil {
+		rtp.Close()
+		return err
+	}
+
+	s.rtp = rtp
+	s.rtcp = rtcp
+	s.multicast = true
+	s.serverAddr = group
+	s.serverPort = ports
+	s.rtp.SetOnPacketHandler(func(conn net.Conn, data []byte) {
+		s.input(data)
+	})
+	s.rtcp.SetOnPacketHandler(func(conn net.Conn, data []byte) {
+		s.onRTCPPacket(data)
+	})
+	go s.rtp.Read()
+	go s.rtcp.Read()
+	return nil
+}
+
+// CreateMulticastServer 服务端组播发送, 所有会话共用一个组播地址
+// @iface 出口网卡, nil时由路由决定
+// @ports RTP和RTCP端口
+func CreateMulticastServer(iface *net.Interface, group string, ports [2]int, ttl int) (*Server, error) {
+	if ip := net.ParseIP(group); ip == nil || !ip.IsMulticast() {
+		return nil, fmt.Errorf("invalid multicast address:%s", group)
+	}
+
+	sender, err := utils.NewMulticastSender(iface, ttl)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{rtp: sender, multicast: true, ttl: ttl, serverAddr: group, serverPort: ports}, nil
+}
+
+// WriteRTP 发送RTP包到对端, 组播时发送到组播组
+func (s *Server) WriteRTP(data []byte) error {
+	s.senderLock.Lock()
+	s.sender.onRTPPacket(data, time.Now())
+	if s.retransmitter != nil {
+		s.retransmitter.Push(data)
+	}
+	s.senderLock.Unlock()
+	return s.write(data, 0)
+}
+
+// EnableRetransmission 保存最近发送的RTP包, 收到对端的Generic NACK时重传
+// @historySize 保存的包数, 小于等于0时使用librtp.DefaultHistorySize
+// @rtxPayloadType 大于0时按RFC 4588使用单独的负载类型和rtxSSRC重传, 否则使用原始SSRC
+func (s *Server) EnableRetransmission(historySize, rtxPayloadType int, rtxSSRC uint32) {
+	retransmitter := librtp.NewRetransmitter(historySize, func(packet []byte) {
+		if err := s.write(packet, 0); err != nil {
+			println(err.Error())
+		}
+	})
+	if rtxPayloadType > 0 {
+		retransmitter.EnableRTX(rtxPayloadType, rtxSSRC)
+	}
+
+	s.senderLock.Lock()
+	s.retransmitter = retransmitter
+	s.senderLock.Unlock()
+}
+
+// onNack 重传对端请求的包
+func (s *Server) onNack(nack *librtcp.TransportLayerNack) {
+	s.senderLock.Lock()
+	defer s.senderLock.Unlock()
+	if s.retransmitter != nil {
+		s.retransmitter.OnNack(nack)
+	}
+}
+
+// WriteRTCP 发送RTCP包到对端, 组播时发送到组播组
+func (s *Server) WriteRTCP(data []byte) error {
+	return s.write(data, 1)
+}
+
+// EnableSRTP 加密发送的RTP/RTCP包, 解密收到的包. 在开始收发之前调用
+// 使用原始SSRC重传的包会被对端的重放保护丢弃, 需要重传时使用RTX
+func (s *Server) EnableSRTP(send, receive *librtp.SRTPContext) {
+	s.srtpSend = send
+	s.srtpReceive = receive
+}
+
+func (s *Server) write(data []byte, index int) error {
+	var err error
+	if s.srtpSend != nil {
+		if index == 0 {
+			data, err = s.srtpSend.ProtectRTP(data)
+		} else {
+			data, err = s.srtpSend.ProtectRTCP(data)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.interleaved {
+		frame := make([]byte, 4+len(data))
+		copy(frame[4:], data)
+		_, err = s.tcp.Write(frame[:writeInterleavedHeader(frame, s.channels[index], len(data))])
+	} else if s.multicast || index == 0 {
+		_, err = s.rtp.(*utils.UDPTransport).WriteTo(data, s.serverAddr, s.serverPort[index])
+	} else {
+		_, err = s.rtcp.(*utils.UDPTransport).WriteTo(data, s.serverAddr, s.serverPort[index])
+	}
+	return err
+}
+
+// TransportHeader 组播SETUP应答的Transport头
+func (s *Server) TransportHeader() string {
+	return fmt.Sprintf("RTP/AVP;multicast;destination=%s;port=%d-%d;ttl=%d", s.serverAddr, s.serverPort[0], s.serverPort[1], s.ttl)
+}
+
+// ConnectionInformation 组播DESCRIBE应答SDP的c=
+func (s *Server) ConnectionInformation() *sdp.ConnectionInformation {
+	addressType := "IP4"
+	if net.ParseIP(s.serverAddr).To4() == nil {
+		addressType = "IP6"
+	}
+
+	address := &sdp.Address{Address: s.serverAddr}
+	//IPv6的c=不携带TTL
+	if addressType == "IP4" {
+		ttl := s.ttl
+		address.TTL = &ttl
+	}
+	return &sdp.ConnectionInformation{NetworkType: "IN", AddressType: addressType, Address: address}
+}
+
 func (s *Server) traversal() {
 	bytes := make([]byte, 12)
 	bytes[0] = 0x80
 	s.rtp.(*utils.UDPTransport).WriteTo(bytes, s.serverAddr, s.serverPort[0])
+	s.rtcp.SetOnPacketHandler(func(conn net.Conn, data []byte) {
+		s.onRTCPPacket(data)
+	})
 	go s.rtp.Read()
+	go s.rtcp.Read()
+}
+
+// input 收到RTP包. 回调在锁内执行, 不能在回调中调用Puller的Seek/Pause等方法
+func (s *Server) input(data []byte) {
+	if s.srtpReceive != nil {
+		var err error
+		if data, err = s.srtpReceive.UnprotectRTP(data); err != nil {
+			println(err.Error())
+			return
+		}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	//PLAY应答之前到达的包, 在确定时间轴后再处理
+	if !s.playing {
+		if len(s.pending) < maxPendingPackets {
+			s.pending = append(s.pending, append([]byte(nil), data...))
+		}
+		return
+	}
+
+	s.process(data)
+}
+
+func (s *Server) process(data []byte) {
+	if len(data) < 12 {
+		return
+	}
+
+	//丢弃seek之前的包
+	if s.seqExist {
+		if seq := utils.BytesToUInt16(data[2], data[3]); int16(seq-s.seq) < 0 {
+			return
+		}
+		s.seqExist = false
+	}
+
+	if s.jitterBuffer == nil {
+		s.onPacket(data)
+		return
+	}
+
+	if err := s.jitterBuffer.Push(data, time.Now()); err != nil {
+		println(err.Error())
+		return
+	}
+	s.scheduleRelease()
+}
+
+func (s *Server) onPacket(data []byte) {
+	if s.dispatch != nil {
+		s.dispatch(data)
+	}
+}
+
+// setJitterBuffer 创建jitter buffer. latency为0时只重排序已到达的包和丢弃重复包
+func (s *Server) setJitterBuffer(clockRate int, latency time.Duration) {
+	s.jitterBuffer = librtp.NewJitterBuffer(clockRate, latency, s.onPacket)
+}
+
+// scheduleRelease 在下一个包的播放时间输出, 在锁内调用
+func (s *Server) scheduleRelease() {
+	next, ok := s.jitterBuffer.NextRelease()
+	if !ok {
+		return
+	}
+
+	if s.releaseTimer == nil {
+		s.releaseTimer = time.AfterFunc(time.Until(next), s.onReleaseTimer)
+	} else {
+		s.releaseTimer.Reset(time.Until(next))
+	}
+}
+
+func (s *Server) onReleaseTimer() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.jitterBuffer == nil {
+		return
+	}
+
+	s.jitterBuffer.Release(time.Now())
+	s.scheduleRelease()
+}
+
+// waitPlay 发送PLAY后, 缓存收到的包直到应答
+func (s *Server) waitPlay() {
+	s.lock.Lock()
+	s.playing = false
+	s.lock.Unlock()
+}
+
+// play 根据PLAY应答的Range和RTP-Info重新建立时间轴
+// @startPts Range开始时间, 单位为track的时钟频率
+func (s *Server) play(startPts int64, info *rtpInfo) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.timestampInitialized = false
+	s.pts = startPts
+	s.clockLock.Lock()
+	s.lastPts = startPts
+	s.clockLock.Unlock()
+	//RTP-Info携带rtptime时服务器已经对齐了各track
+	s.aligned = info != nil && info.rtptimeExist
+	//丢弃seek之前缓存的包
+	if s.jitterBuffer != nil {
+		s.jitterBuffer.Reset()
+	}
+	if info != nil {
+		if info.rtptimeExist {
+			s.timestampInitialized = true
+			s.lastTimestamp = info.rtptime
+		}
+		s.seq = info.seq
+		s.seqExist = info.seqExist
+	}
+
+	s.resumeLocked()
+}
+
+// resume 沿用之前的时间轴继续处理
+func (s *Server) resume() {
+	s.lock.Lock()
+	s.resumeLocked()
+	s.lock.Unlock()
+}
+
+func (s *Server) resumeLocked() {
+	s.playing = true
+	pending := s.pending
+	s.pending = nil
+	for _, data := range pending {
+		s.process(data)
+	}
+}
+
+// position 最后一帧的pts
+func (s *Server) position() int64 {
+	s.clockLock.Lock()
+	defer s.clockLock.Unlock()
+	return s.lastPts
+}
+
+// SetOnRTCPHandler 回调收到的RTCP复合包, 例如对端的RR和PLI/NACK等反馈
+func (s *Server) SetOnRTCPHandler(handler func(packets []librtcp.Packet)) {
+	s.lock.Lock()
+	s.onRTCPHandler = handler
+	s.lock.Unlock()
+}
+
+func (s *Server) onRTCPPacket(data []byte) {
+	if s.srtpReceive != nil {
+		var err error
+		if data, err = s.srtpReceive.UnprotectRTCP(data); err != nil {
+			println(err.Error())
+			return
+		}
+	}
+
+	packets, err := librtcp.Unmarshal(data)
+	if len(packets) == 0 {
+		if err != nil {
+			println(err.Error())
+		}
+		return
+	}
+
+	for _, packet := range packets {
+		if nack, ok := packet.(*librtcp.TransportLayerNack); ok {
+			s.onNack(nack)
+		}
+	}
+
+	s.lock.Lock()
+	for _, packet := range packets {
+		sr, ok := packet.(*librtcp.SenderReport)
+		if !ok || s.jitterBuffer == nil {
+			continue
+		}
+
+		statistics := s.jitterBuffer.Statistics()
+		if statistics.Initialized() && statistics.SSRC() != sr.SSRC {
+			continue
+		}
+		statistics.OnSenderReport(sr.NTPTime, time.Now())
+		if s.senderClock != nil {
+			s.clockLock.Lock()
+			s.senderClock.Update(sr.NTPTime, sr.RTPTime)
+			s.clockLock.Unlock()
+		}
+	}
+	handler := s.onRTCPHandler
+	s.lock.Unlock()
+
+	if handler != nil {
+		handler(packets)
+	}
+}
+
+// extendTimestamp 将32位的RTP时间戳扩展为pts, 单位为track的时钟频率. 从PLAY应答的Range开始计算
+func (s *Server) extendTimestamp(timestamp uint32) int64 {
+	if !s.timestampInitialized {
+		s.timestampInitialized = true
+		s.lastTimestamp = timestamp
+	}
+
+	s.pts += int64(int32(timestamp - s.lastTimestamp))
+	s.lastTimestamp = timestamp
+
+	s.clockLock.Lock()
+	defer s.clockLock.Unlock()
+	if s.senderClock != nil {
+		if !s.aligned && s.lipSync != nil {
+			if wallClock, ok := s.senderClock.Time(timestamp); ok {
+				s.pts += s.lipSync.offset(wallClock, s.pts, s.track.ClockRate)
+				s.aligned = true
+			}
+		}
+		s.anchorTimestamp = timestamp
+		s.anchorPts = s.pts
+	}
+	s.lastPts = s.pts
+	return s.pts
+}
+
+// captureTime pts对应的发送端绝对时间, 需要收到过SR
+func (s *Server) captureTime(pts int64) (time.Time, bool) {
+	s.clockLock.Lock()
+	defer s.clockLock.Unlock()
+	if s.senderClock == nil {
+		return time.Time{}, false
+	}
+	return s.senderClock.Time(s.anchorTimestamp + uint32(pts-s.anchorPts))
+}
+
+// SendReport 发送RTCP报告, 发送过RTP包时为SR, 否则为RR
+// @ssrc 本端的SSRC
+func (s *Server) SendReport(ssrc uint32, cname string) error {
+	return s.SendRTCP(s.reportPackets(ssrc, cname)...)
+}
+
+// SendGoodbye 发送包含BYE的复合包, 在TEARDOWN之前调用
+func (s *Server) SendGoodbye(ssrc uint32, cname, reason string) error {
+	packets := s.reportPackets(ssrc, cname)
+	//SR时使用发送包的SSRC
+	source := packets[1].(*librtcp.SourceDescription).Chunks[0].Source
+	return s.SendRTCP(append(packets, &librtcp.Goodbye{Sources: []uint32{source}, Reason: reason})...)
+}
+
+func (s *Server) reportPackets(ssrc uint32, cname string) []librtcp.Packet {
+	var clockRate int
+	if s.track != nil {
+		clockRate = s.track.ClockRate
+	}
+
+	s.lock.Lock()
+	s.senderLock.Lock()
+	defer s.lock.Unlock()
+	defer s.senderLock.Unlock()
+	var receiver *librtp.ReceiverStatistics
+	if s.jitterBuffer != nil {
+		receiver = s.jitterBuffer.Statistics()
+	}
+	return reportPackets(ssrc, cname, &s.sender, receiver, clockRate, time.Now())
+}
+
+// SendRTCP 发送RTCP复合包, 例如PLI/NACK等反馈
+func (s *Server) SendRTCP(packets ...librtcp.Packet) error {
+	data, err := librtcp.Marshal(packets)
+	if err != nil {
+		return err
+	}
+	return s.writeRTCP(data)
+}
+
+// writeRTCP 尚未确定对端RTCP端口时不发送
+func (s *Server) writeRTCP(data []byte) error {
+	if !s.interleaved && s.serverPort[1] == 0 {
+		return nil
+	}
+	return s.WriteRTCP(data)
+}
+
+// Close 释放CreateServer分配的端口
+func (s *Server) Close() {
+	s.lock.Lock()
+	if s.releaseTimer != nil {
+		s.releaseTimer.Stop()
+	}
+	s.jitterBuffer = nil
+	s.lock.Unlock()
+
+	if s.rtp != nil {
+		s.rtp.Close()
+	}
+	if s.rtcp != nil {
+		s.rtcp.Close()
+	}
+}
+
+// writeInterleavedHeader RFC 2326 10.12 '$' + channel + 2字节长度
+// @return 包含头的总长度
+func writeInterleavedHeader(dst []byte, channel, length int) int {
+	dst[0] = '$'
+	dst[1] = byte(channel)
+	utils.WriteWORD(dst[2:], uint16(length))
+	return 4 + length
+}
+
+// readInterleavedFrame 读取交织帧
+// @return 0表示数据不足
+func readInterleavedFrame(data []byte) (channel int, payload []byte, n int) {
+	if len(data) < 4 {
+		return 0, nil, 0
+	}
+
+	length := int(utils.BytesToUInt16(data[2], data[3]))
+	if len(data) < 4+length {
+		return 0, nil, 0
+	}
+	return int(data[1]), data[4 : 4+length], 4 + length
 }
@@ -0,0 +1,118 @@
+package librtsp
+
+import (
+	"avformat/librtsp/sdp"
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMulticastTransport(t *testing.T) {
+	var sd sdp.SessionDescription
+	if err := sd.Unmarshal([]byte("v=0\r\no=- 0 0 IN IP4 10.0.0.1\r\ns=live\r\nc=IN IP4 232.0.0.1/16\r\nt=0 0\r\nm=video 5000 RTP/AVP 96\r\na=rtpmap:96 H264/90000\r\na=control:trackID=0\r\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	track := &Track{}
+	track.destination, track.port = multicastDestination(&sd, sd.MediaDescriptions[0])
+	if track.destination != "232.0.0.1" || track.port != 5000 {
+		t.Fatalf("parse multicast destination failed %s %d", track.destination, track.port)
+	}
+
+	p := &Puller{}
+	destination, ports, err := p.parseMulticastTransport(parseTransportHeader("RTP/AVP;multicast;destination=232.0.0.2;port=6000-6001;ttl=16"), track)
+	if err != nil || destination != "232.0.0.2" || ports != [2]int{6000, 6001} {
+		t.Fatalf("parse multicast transport failed %s %v %v", destination, ports, err)
+	}
+
+	//应答未携带destination和port
+	destination, ports, err = p.parseMulticastTransport(parseTransportHeader("RTP/AVP;multicast;ttl=16"), track)
+	if err != nil || destination != "232.0.0.1" || ports != [2]int{5000, 5001} {
+		t.Fatalf("parse multicast transport failed %s %v %v", destination, ports, err)
+	}
+
+	if _, _, err = p.parseMulticastTransport(parseTransportHeader("RTP/AVP;multicast;ttl=16"), &Track{}); err == nil {
+		t.Fatalf("unknown destination must fail")
+	}
+}
+
+func TestMulticastServer(t *testing.T) {
+	if _, err := CreateMulticastServer(nil, "10.0.0.1", [2]int{5000, 5001}, 16); err == nil {
+		t.Fatalf("unicast address must fail")
+	}
+
+	server, err := CreateMulticastServer(nil, "232.0.0.1", [2]int{5000, 5001}, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	if header := server.TransportHeader(); header != "RTP/AVP;multicast;destination=232.0.0.1;port=5000-5001;ttl=16" {
+		t.Fatalf("unexpected transport header %s", header)
+	}
+	if c := server.ConnectionInformation().String(); c != "IN IP4 232.0.0.1/16" {
+		t.Fatalf("unexpected connection information %s", c)
+	}
+}
+
+// multicastInterface 第一个支持组播的网卡
+func multicastInterface() *net.Interface {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	for i := range ifaces {
+		if ifaces[i].Flags&net.FlagUp != 0 && ifaces[i].Flags&net.FlagMulticast != 0 {
+			if _, err = ifaces[i].Addrs(); err == nil {
+				return &ifaces[i]
+			}
+		}
+	}
+	return nil
+}
+
+func TestMulticastLoopback(t *testing.T) {
+	iface := multicastInterface()
+	if iface == nil {
+		t.Skip("no multicast interface")
+	}
+
+	group, ports := "239.255.42.1", [2]int{45000, 45001}
+	receiver := &Server{}
+	if err := receiver.joinMulticast(iface, group, ports); err != nil {
+		t.Skip(err.Error())
+	}
+	defer receiver.Close()
+
+	packets := make(chan []byte, 1)
+	receiver.playing = true
+	receiver.dispatch = func(data []byte) {
+		packets <- append([]byte(nil), data...)
+	}
+
+	sender, err := CreateMulticastServer(iface, group, ports, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+
+	//组播默认回环到本机, 加入组播组的客户端能收到服务端发送的包
+	packet := []byte{0x80, 96, 0x00, 0x01, 0, 0, 0x03, 0xE8, 0x12, 0x34, 0x56, 0x78, 0xAA, 0xBB}
+	for i := 0; i < 10; i++ {
+		if err = sender.WriteRTP(packet); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case data := <-packets:
+			if !bytes.Equal(data, packet) {
+				t.Fatalf("unexpected packet % x", data)
+			}
+			return
+		case <-time.After(100 * time.Millisecond):
+			break
+		}
+	}
+	t.Fatalf("no packet received from the multicast group")
+}
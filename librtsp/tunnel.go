@@ -0,0 +1,200 @@
+package librtsp
+
+import (
+	"avformat/utils"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultTunnelPort = 80
+	// TunnelContentType QuickTime RTSP over HTTP隧道的Content-Type
+	TunnelContentType = "application/x-rtsp-tunnelled"
+	tunnelDialTimeout = 10 * time.Second
+)
+
+// httpTunnel RTSP over HTTP. GET通道接收应答和交织的RTP/RTCP, POST通道发送base64编码的请求
+// 两个通道使用相同的x-sessioncookie关联
+type httpTunnel struct {
+	onPacketHandler       utils.OnPacketHandler
+	onDisconnectedHandler utils.OnDisconnectedHandler
+	get                   net.Conn
+	post                  net.Conn
+	cancel                context.CancelFunc
+	remain                []byte //读取GET应答时多读的数据
+}
+
+func newSessionCookie() string {
+	const chars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	cookie := make([]byte, 22)
+	for i := range cookie {
+		cookie[i] = chars[rand.Intn(len(chars))]
+	}
+	return string(cookie)
+}
+
+// readHTTPResponseHeader 读取GET通道的HTTP应答头
+// @return 应答头之后的数据
+func readHTTPResponseHeader(conn net.Conn) ([]byte, error) {
+	buffer := make([]byte, 0, 1024)
+	data := make([]byte, 1024)
+	for {
+		n, err := conn.Read(data)
+		if err != nil {
+			return nil, err
+		}
+
+		buffer = append(buffer, data[:n]...)
+		index := bytes.Index(buffer, []byte("\r\n\r\n"))
+		if index < 0 {
+			if len(buffer) > 8192 {
+				return nil, fmt.Errorf("the http response header is too large")
+			}
+			continue
+		}
+
+		//HTTP/1.0 200 OK
+		line := string(buffer[:bytes.Index(buffer, delimiter)])
+		split := strings.SplitN(line, " ", 3)
+		if len(split) < 2 || !strings.HasPrefix(split[0], "HTTP/") {
+			return nil, fmt.Errorf("invalid http response line:%s", line)
+		}
+		if code, err := strconv.Atoi(split[1]); err != nil || code != http.StatusOK {
+			return nil, fmt.Errorf("failed to open the tunnel:%s", line)
+		}
+
+		return buffer[index+4:], nil
+	}
+}
+
+// NewHTTPTunnel 建立GET/POST通道
+// @path 请求路径, 一般和rtsp url的路径相同
+func NewHTTPTunnel(host string, port int, path string) (utils.Transport, error) {
+	if path == "" {
+		path = "/"
+	}
+
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	cookie := newSessionCookie()
+	get, err := net.DialTimeout("tcp", addr, tunnelDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	header := fmt.Sprintf("GET %s HTTP/1.0\r\n"+
+		"User-Agent: avformat/librtsp\r\n"+
+		"x-sessioncookie: %s\r\n"+
+		"Accept: %s\r\n"+
+		"Pragma: no-cache\r\n"+
+		"Cache-Control: no-cache\r\n\r\n", path, cookie, TunnelContentType)
+	if _, err = get.Write([]byte(header)); err != nil {
+		get.Close()
+		return nil, err
+	}
+
+	_ = get.SetReadDeadline(time.Now().Add(tunnelDialTimeout))
+	remain, err := readHTTPResponseHeader(get)
+	if err != nil {
+		get.Close()
+		return nil, err
+	}
+	_ = get.SetReadDeadline(time.Time{})
+
+	post, err := net.DialTimeout("tcp", addr, tunnelDialTimeout)
+	if err != nil {
+		get.Close()
+		return nil, err
+	}
+
+	//POST通道没有应答, Content-Length随意填写一个较大的值
+	header = fmt.Sprintf("POST %s HTTP/1.0\r\n"+
+		"User-Agent: avformat/librtsp\r\n"+
+		"x-sessioncookie: %s\r\n"+
+		"Content-Type: %s\r\n"+
+		"Pragma: no-cache\r\n"+
+		"Cache-Control: no-cache\r\n"+
+		"Content-Length: 32767\r\n"+
+		"Expires: Sun, 9 Jan 1972 00:00:00 GMT\r\n\r\n", path, cookie, TunnelContentType)
+	if _, err = post.Write([]byte(header)); err != nil {
+		get.Close()
+		post.Close()
+		return nil, err
+	}
+
+	return &httpTunnel{get: get, post: post, remain: remain}, nil
+}
+
+func (t *httpTunnel) SetOnPacketHandler(handler utils.OnPacketHandler) {
+	t.onPacketHandler = handler
+}
+
+func (t *httpTunnel) SetOnDisconnectedHandler(handler utils.OnDisconnectedHandler) {
+	t.onDisconnectedHandler = handler
+}
+
+// Conn 返回GET通道
+func (t *httpTunnel) Conn() net.Conn {
+	return t.get
+}
+
+// Write 每次写入的数据单独做base64编码
+func (t *httpTunnel) Write(data []byte) (int, error) {
+	encoded := make([]byte, base64.StdEncoding.EncodedLen(len(data)))
+	base64.StdEncoding.Encode(encoded, data)
+	if _, err := t.post.Write(encoded); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+func (t *httpTunnel) Close() error {
+	if t.cancel != nil {
+		t.cancel()
+	}
+	t.post.Close()
+	return t.get.Close()
+}
+
+func (t *httpTunnel) doRead() {
+	var err error
+	var n int
+	var ctx context.Context
+	ctx, t.cancel = context.WithCancel(context.Background())
+
+	if len(t.remain) > 0 && t.onPacketHandler != nil {
+		t.onPacketHandler(t.get, t.remain)
+	}
+	t.remain = nil
+
+	data := make([]byte, 16000)
+	for ctx.Err() == nil {
+		n, err = t.get.Read(data)
+		if err != nil {
+			break
+		}
+
+		if t.onPacketHandler != nil {
+			t.onPacketHandler(t.get, data[:n])
+		}
+	}
+
+	if t.onDisconnectedHandler != nil {
+		t.onDisconnectedHandler(t.get, err)
+	}
+}
+
+func (t *httpTunnel) Read() {
+	go t.doRead()
+}
+
+func (t *httpTunnel) ListenPort() int {
+	return t.get.LocalAddr().(*net.TCPAddr).Port
+}
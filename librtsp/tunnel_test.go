@@ -0,0 +1,105 @@
+package librtsp
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPTunnel(t *testing.T) {
+	options := "OPTIONS rtsp://127.0.0.1/live RTSP/1.0\r\nCSeq: 1\r\n\r\n"
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	cookies := make(chan string, 2)
+	posted := make(chan string, 1)
+	go func() {
+		for i := 0; i < 2; i++ {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			reader := bufio.NewReader(conn)
+			request, err := http.ReadRequest(reader)
+			if err != nil {
+				return
+			}
+			cookies <- request.Header.Get("x-sessioncookie")
+
+			if request.Method == http.MethodGet {
+				//应答头和RTSP应答一起发送
+				conn.Write([]byte("HTTP/1.0 200 OK\r\nContent-Type: " + TunnelContentType + "\r\n\r\nRTSP/1.0 200 OK\r\nCSeq: 1\r\n\r\n"))
+				defer conn.Close()
+			} else {
+				encoded := make([]byte, base64.StdEncoding.EncodedLen(len(options)))
+				io.ReadFull(reader, encoded)
+				decoded, _ := base64.StdEncoding.DecodeString(string(encoded))
+				posted <- string(decoded)
+				conn.Close()
+			}
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	tunnel, err := NewHTTPTunnel("127.0.0.1", addr.Port, "/live")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tunnel.Close()
+
+	received := make(chan string, 1)
+	tunnel.SetOnPacketHandler(func(conn net.Conn, data []byte) {
+		received <- string(data)
+	})
+	tunnel.Read()
+
+	if _, err = tunnel.Write([]byte(options)); err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := time.After(5 * time.Second)
+	select {
+	case data := <-received:
+		if !strings.HasPrefix(data, "RTSP/1.0 200 OK") {
+			t.Fatalf("unexpected response %q", data)
+		}
+	case <-timeout:
+		t.Fatal("no response from the GET channel")
+	}
+
+	select {
+	case data := <-posted:
+		if data != options {
+			t.Fatalf("unexpected request %q", data)
+		}
+	case <-timeout:
+		t.Fatal("no request from the POST channel")
+	}
+
+	if cookie1, cookie2 := <-cookies, <-cookies; cookie1 == "" || cookie1 != cookie2 {
+		t.Fatalf("the session cookies are different %s %s", cookie1, cookie2)
+	}
+}
+
+func TestInterleavedFrame(t *testing.T) {
+	data := make([]byte, 16)
+	copy(data[4:], "rtcp")
+	n := writeInterleavedHeader(data, 3, 4)
+	channel, payload, consumed := readInterleavedFrame(data[:n])
+	if channel != 3 || string(payload) != "rtcp" || consumed != 8 {
+		t.Fatalf("read interleaved frame failed %d %q %d", channel, payload, consumed)
+	}
+
+	if _, _, consumed = readInterleavedFrame(data[:n-1]); consumed != 0 {
+		t.Fatalf("incomplete frame must not be consumed")
+	}
+}
@@ -82,17 +82,17 @@ func h(data string) string {
 	return hex.EncodeToString(hash.Sum(nil))
 }
 
-func calculateResponse(username, realm, nonce, uri, password string) string {
+func calculateResponse(method, username, realm, nonce, uri, password string) string {
 	//H(data) = MD5(data)
 	//KD(secret, data) = H(concat(secret, ":", data))
 	//request-digest  = <"> < KD ( H(A1), unq(nonce-value) ":" H(A2) ) > <">
 	A1 := fmt.Sprintf("%s:%s:%s", username, realm, password)
-	A2 := fmt.Sprintf("%s:%s", "DESCRIBE", uri)
+	A2 := fmt.Sprintf("%s:%s", method, uri)
 
 	return h(h(A1) + ":" + nonce + ":" + h(A2))
 }
 
-func generateCredentials(params map[string]string, password string) (string, error) {
+func generateCredentials(method string, params map[string]string, password string) (string, error) {
 
 	realm := params["realm"]
 	nonce := params["nonce"]
@@ -107,6 +107,6 @@ func generateCredentials(params map[string]string, password string) (string, err
 	//	return false
 	//}
 
-	response := calculateResponse(username, realm, nonce, uri, password)
+	response := calculateResponse(method, username, realm, nonce, uri, password)
 	return fmt.Sprintf("Digest username=\"%s\", realm=\"%s\", nonce=\"%s\", uri=\"%s\", response=\"%s\", algorithm=%s", username, realm, nonce, uri, response, DefaultAlgorithm), nil
 }
@@ -166,3 +166,141 @@ func ParseMpeg4AudioConfig(data []byte) (*MPEG4AudioConfig, error) {
 	//config.ps = -1
 	//if config.ObjectType == AotSbr || (config.ObjectType == AotPs && )
 }
+
+// readAudioObjectType 5bits, 31时再读取6bits
+func readAudioObjectType(reader *BitReader) (int, error) {
+	aot, err := reader.ReadBits(5)
+	if err != nil {
+		return 0, err
+	} else if AudioObjectType(aot) == AotEscape {
+		ext, err := reader.ReadBits(6)
+		if err != nil {
+			return 0, err
+		}
+		aot = 32 + ext
+	}
+	return int(aot), nil
+}
+
+// readSamplingFrequency 4bits, 0xF时再读取24bits的采样率
+func readSamplingFrequency(reader *BitReader) (int, int, error) {
+	index, err := reader.ReadBits(4)
+	if err != nil {
+		return 0, 0, err
+	} else if index == 0xF {
+		rate, err := reader.ReadBits(24)
+		return int(index), int(rate), err
+	}
+	return int(index), audioSamplingRates[int(index)], nil
+}
+
+// ReadMpeg4AudioConfig 按位读取AudioSpecificConfig, 用于LATM的StreamMuxConfig等不按字节对齐的场景
+func ReadMpeg4AudioConfig(reader *BitReader) (*MPEG4AudioConfig, error) {
+	config := &MPEG4AudioConfig{sbr: -1, ps: -1}
+	var err error
+	if config.ObjectType, err = readAudioObjectType(reader); err != nil {
+		return nil, err
+	} else if config.SamplingIndex, config.SampleRate, err = readSamplingFrequency(reader); err != nil {
+		return nil, err
+	}
+
+	chanConfig, err := reader.ReadBits(4)
+	if err != nil {
+		return nil, err
+	} else if int(chanConfig) >= len(mpeg4AudioChannels) {
+		return nil, fmt.Errorf("invalid channel configuration %d", chanConfig)
+	}
+	config.ChanConfig = int(chanConfig)
+	config.Channels = mpeg4AudioChannels[config.ChanConfig]
+
+	//显式SBR/PS
+	if AudioObjectType(config.ObjectType) == AotSbr || AudioObjectType(config.ObjectType) == AotPs {
+		config.extObjectType = int(AotSbr)
+		config.sbr = 1
+		if AudioObjectType(config.ObjectType) == AotPs {
+			config.ps = 1
+		}
+		if config.extSamplingIndex, config.extSampleRate, err = readSamplingFrequency(reader); err != nil {
+			return nil, err
+		} else if config.ObjectType, err = readAudioObjectType(reader); err != nil {
+			return nil, err
+		}
+	}
+
+	switch AudioObjectType(config.ObjectType) {
+	case AotAacMain, AotAacLc, AotAacSsr, AotAacLtp, AotAacScalable, AotTwinvq,
+		AotErAacLc, AotErAacLtp, AotErAacScalable, AotErTwinvq, AotErBsac, AotErAacLd:
+		//GASpecificConfig
+		frameLengthFlag, err := reader.ReadBits(1)
+		if err != nil {
+			return nil, err
+		}
+		config.frameLengthShort = int(frameLengthFlag)
+
+		if dependsOnCoreCoder, err := reader.ReadBits(1); err != nil {
+			return nil, err
+		} else if dependsOnCoreCoder == 1 {
+			if err = reader.SkipBits(14); err != nil {
+				return nil, err
+			}
+		}
+
+		extensionFlag, err := reader.ReadBits(1)
+		if err != nil {
+			return nil, err
+		} else if config.ChanConfig == 0 {
+			return nil, fmt.Errorf("program_config_element is not supported")
+		}
+		if AudioObjectType(config.ObjectType) == AotAacScalable || AudioObjectType(config.ObjectType) == AotErAacScalable {
+			if err = reader.SkipBits(3); err != nil {
+				return nil, err
+			}
+		}
+		if extensionFlag == 1 {
+			if AudioObjectType(config.ObjectType) == AotErBsac {
+				err = reader.SkipBits(16)
+			} else if config.ObjectType >= int(AotErAacLc) {
+				err = reader.SkipBits(3)
+			}
+			if err == nil {
+				err = reader.SkipBits(1)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		break
+	default:
+		return nil, fmt.Errorf("unsupported audio object type %d", config.ObjectType)
+	}
+
+	return config, nil
+}
+
+// FrameSize 每帧的采样数
+func (m *MPEG4AudioConfig) FrameSize() int {
+	if m.frameLengthShort == 1 {
+		return 960
+	}
+	return 1024
+}
+
+// WriteMpeg4AudioConfig 写入AudioSpecificConfig, 只写入GASpecificConfig的默认值
+func WriteMpeg4AudioConfig(writer *BitWriter, config *MPEG4AudioConfig) {
+	writer.WriteBits(uint32(config.ObjectType), 5)
+	writer.WriteBits(uint32(config.SamplingIndex), 4)
+	if config.SamplingIndex == 0xF {
+		writer.WriteBits(uint32(config.SampleRate), 24)
+	}
+	writer.WriteBits(uint32(config.ChanConfig), 4)
+	//frameLengthFlag, dependsOnCoreCoder, extensionFlag
+	writer.WriteBits(uint32(config.frameLengthShort), 1)
+	writer.WriteBits(0, 2)
+}
+
+// ToBytes AudioSpecificConfig, 用于fmtp的config和MP4的esds
+func (m *MPEG4AudioConfig) ToBytes() []byte {
+	writer := NewBitWriter()
+	WriteMpeg4AudioConfig(writer, m)
+	return writer.Bytes()
+}
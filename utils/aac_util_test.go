@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMpeg4AudioConfig(t *testing.T) {
+	//44100Hz 双声道 AAC-LC
+	config, err := ReadMpeg4AudioConfig(NewBitReader([]byte{0x12, 0x10}))
+	if err != nil {
+		t.Fatal(err)
+	} else if config.ObjectType != 2 || config.SampleRate != 44100 || config.Channels != 2 || config.FrameSize() != 1024 {
+		t.Fatalf("unexpected config %+v", config)
+	}
+
+	if !bytes.Equal(config.ToBytes(), []byte{0x12, 0x10}) {
+		t.Fatalf("write config failed % x", config.ToBytes())
+	}
+
+	//HE-AAC显式SBR: 24000Hz, 扩展采样率48000Hz
+	config, err = ReadMpeg4AudioConfig(NewBitReader([]byte{0x2B, 0x11, 0x88, 0x00}))
+	if err != nil {
+		t.Fatal(err)
+	} else if config.ObjectType != 2 || config.SampleRate != 24000 || config.extSampleRate != 48000 {
+		t.Fatalf("unexpected config %+v", config)
+	}
+}
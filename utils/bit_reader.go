@@ -0,0 +1,51 @@
+package utils
+
+import "fmt"
+
+// BitReader 大端序按位读取
+type BitReader struct {
+	data   []byte
+	offset int //bit offset
+}
+
+func NewBitReader(data []byte) *BitReader {
+	return &BitReader{data: data}
+}
+
+// ReadBits 最多读取32位
+func (r *BitReader) ReadBits(n int) (uint32, error) {
+	if n > 32 {
+		return 0, fmt.Errorf("can not read %d bits at once", n)
+	} else if r.offset+n > len(r.data)*8 {
+		return 0, fmt.Errorf("not enough bits. need:%d remaining:%d", n, r.RemainingBits())
+	}
+
+	var value uint32
+	for i := 0; i < n; i++ {
+		bit := r.data[r.offset>>3] >> (7 - uint(r.offset&7)) & 0x1
+		value = value<<1 | uint32(bit)
+		r.offset++
+	}
+	return value, nil
+}
+
+func (r *BitReader) SkipBits(n int) error {
+	if r.offset+n > len(r.data)*8 {
+		return fmt.Errorf("not enough bits. need:%d remaining:%d", n, r.RemainingBits())
+	}
+	r.offset += n
+	return nil
+}
+
+// ByteAlign 跳到下一个字节边界
+func (r *BitReader) ByteAlign() {
+	r.offset = (r.offset + 7) &^ 7
+}
+
+func (r *BitReader) Offset() int {
+	return r.offset
+}
+
+func (r *BitReader) RemainingBits() int {
+	return len(r.data)*8 - r.offset
+}
@@ -0,0 +1,33 @@
+package utils
+
+// BitWriter 大端序按位写入
+type BitWriter struct {
+	data   []byte
+	offset int //bit offset
+}
+
+func NewBitWriter() *BitWriter {
+	return &BitWriter{}
+}
+
+// WriteBits 写入value的低n位, n最多32
+func (w *BitWriter) WriteBits(value uint32, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if w.offset&7 == 0 {
+			w.data = append(w.data, 0)
+		}
+		if value>>uint(i)&0x1 == 1 {
+			w.data[w.offset>>3] |= 0x80 >> uint(w.offset&7)
+		}
+		w.offset++
+	}
+}
+
+func (w *BitWriter) Offset() int {
+	return w.offset
+}
+
+// Bytes 不足一个字节的部分补0
+func (w *BitWriter) Bytes() []byte {
+	return w.data
+}
@@ -0,0 +1,41 @@
+//go:build !windows
+// +build !windows
+
+package utils
+
+import (
+	"net"
+	"syscall"
+)
+
+func setMulticastOptions(conn *net.UDPConn, iface *net.Interface, ttl int) error {
+	var addr [4]byte
+	if iface != nil {
+		ip, err := interfaceIPv4(iface)
+		if err != nil {
+			return err
+		}
+		copy(addr[:], ip)
+	}
+
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var optErr error
+	err = raw.Control(func(fd uintptr) {
+		if ttl > 0 {
+			if optErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_MULTICAST_TTL, ttl); optErr != nil {
+				return
+			}
+		}
+		if iface != nil {
+			optErr = syscall.SetsockoptInet4Addr(int(fd), syscall.IPPROTO_IP, syscall.IP_MULTICAST_IF, addr)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return optErr
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"sync"
 )
 
 type OnPacketHandler func(conn net.Conn, data []byte)
@@ -44,7 +45,9 @@ func (t *transport) Write(data []byte) (int, error) {
 }
 
 func (t *transport) Close() error {
-	t.cancel()
+	if t.cancel != nil {
+		t.cancel()
+	}
 	return t.conn.Close()
 }
 
@@ -100,6 +103,74 @@ func NewTCPClient(localAddr *net.TCPAddr, serverIp string, serverPort int) (Tran
 	}
 }
 
+// TCPServer 被动模式的TCP, 只接受第一个连接. 例如GB28181 setup:passive时等待设备连接
+type TCPServer struct {
+	transport
+	listener net.Listener
+	lock     sync.Mutex
+	closed   bool
+}
+
+func NewTCPServer(port int) (Transport, error) {
+	listener, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4zero, Port: port})
+	if err != nil {
+		return nil, err
+	}
+	return &TCPServer{transport: transport{listPort: listener.Addr().(*net.TCPAddr).Port}, listener: listener}, nil
+}
+
+func (t *TCPServer) Conn() net.Conn {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.conn
+}
+
+// Write 连接建立之前返回错误
+func (t *TCPServer) Write(data []byte) (int, error) {
+	conn := t.Conn()
+	if conn == nil {
+		return 0, fmt.Errorf("the tcp connection is not established")
+	}
+	return conn.Write(data)
+}
+
+func (t *TCPServer) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.closed = true
+	err := t.listener.Close()
+	if t.conn != nil {
+		err = t.transport.Close()
+	}
+	return err
+}
+
+// Read 等待连接后开始读取
+func (t *TCPServer) Read() {
+	go func() {
+		conn, err := t.listener.Accept()
+		t.listener.Close()
+		if err == nil {
+			t.lock.Lock()
+			if t.closed {
+				conn.Close()
+				err = fmt.Errorf("the tcp server is closed")
+			} else {
+				t.conn = conn
+			}
+			t.lock.Unlock()
+		}
+
+		if err != nil {
+			if t.onDisConnectedHandler != nil {
+				t.onDisConnectedHandler(nil, err)
+			}
+			return
+		}
+		t.doRead()
+	}()
+}
+
 func NewUDPTransport(port int) (Transport, error) {
 	udp, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("0.0.0.0"), Port: port})
 	if err != nil {
@@ -107,3 +178,46 @@ func NewUDPTransport(port int) (Transport, error) {
 	}
 	return &UDPTransport{transport{conn: udp, listPort: port}}, nil
 }
+
+// NewMulticastTransport 在指定网卡上加入组播组. iface为nil时由系统选择网卡
+func NewMulticastTransport(iface *net.Interface, group string, port int) (Transport, error) {
+	ip := net.ParseIP(group)
+	if ip == nil || !ip.IsMulticast() {
+		return nil, fmt.Errorf("invalid multicast address:%s", group)
+	}
+
+	udp, err := net.ListenMulticastUDP("udp", iface, &net.UDPAddr{IP: ip, Port: port})
+	if err != nil {
+		return nil, err
+	}
+	return &UDPTransport{transport{conn: udp, listPort: port}}, nil
+}
+
+// NewMulticastSender 创建发送组播的UDP, 设置出口网卡和TTL
+func NewMulticastSender(iface *net.Interface, ttl int) (Transport, error) {
+	udp, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = setMulticastOptions(udp, iface, ttl); err != nil {
+		udp.Close()
+		return nil, err
+	}
+	return &UDPTransport{transport{conn: udp, listPort: udp.LocalAddr().(*net.UDPAddr).Port}}, nil
+}
+
+// interfaceIPv4 网卡的第一个IPv4地址
+func interfaceIPv4(iface *net.Interface) (net.IP, error) {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			return ipNet.IP.To4(), nil
+		}
+	}
+	return nil, fmt.Errorf("no ipv4 address on the interface %s", iface.Name)
+}